@@ -6,4 +6,5 @@ var (
     ErrInsufficientStock = errors.New("INSUFFICIENT_STOCK")
     ErrNotFound          = errors.New("ITEM_NOT_FOUND")
     ErrValidation        = errors.New("VALIDATION_ERROR")
+    ErrPrecondition      = errors.New("PRECONDITION_FAILED")
 )
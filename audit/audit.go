@@ -0,0 +1,213 @@
+// Package audit records who changed what. Recorder.Middleware wraps the
+// mutating routes: every successful POST, PUT, PATCH or DELETE is written to
+// the audit log with the actor, route, status and client IP. Handlers add
+// what they know about the change with Record (entity, before and after
+// values) or leave the request out with Skip.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/tenant"
+)
+
+// Actions used when a handler does not set one.
+const (
+    AksiCreate = "create"
+    AksiUpdate = "update"
+    AksiDelete = "delete"
+)
+
+// Store persists audit entries.
+type Store interface {
+    Insert(ctx context.Context, e *models.AuditLog) error
+}
+
+// Perubahan describes the change made by a request. Empty fields keep the
+// defaults derived from the route.
+type Perubahan struct {
+    Aksi      string
+    Entitas   string
+    EntitasID int64
+    UserID    int64 // actor, for requests made before authentication (login)
+    TenantID  int64 // tenant the entry belongs to, for requests made before authentication
+    Sebelum   any
+    Sesudah   any
+}
+
+type entry struct {
+    p      Perubahan
+    skip   bool
+    always bool
+}
+
+type ctxKey struct{}
+
+// Record attaches p to the audit entry of the current request. It does
+// nothing outside Middleware.
+func Record(ctx context.Context, p Perubahan) {
+    if e, ok := ctx.Value(ctxKey{}).(*entry); ok {
+        e.p = p
+    }
+}
+
+// RecordFailure is Record for requests that are audited even though they
+// fail, such as rejected logins.
+func RecordFailure(ctx context.Context, p Perubahan) {
+    if e, ok := ctx.Value(ctxKey{}).(*entry); ok {
+        e.p, e.always = p, true
+    }
+}
+
+// Skip leaves the current request out of the audit log, for POST endpoints
+// that do not change anything.
+func Skip(ctx context.Context) {
+    if e, ok := ctx.Value(ctxKey{}).(*entry); ok {
+        e.skip = true
+    }
+}
+
+// Recorder writes audit entries for the requests passing through Middleware.
+type Recorder struct {
+    Store      Store
+    Proxies    middleware.TrustedProxies // whose X-Forwarded-For is believed
+}
+
+func NewRecorder(store Store, proxies middleware.TrustedProxies) *Recorder {
+    return &Recorder{Store: store, Proxies: proxies}
+}
+
+type statusWriter struct {
+    http.ResponseWriter
+    status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+    if w.status == 0 { w.status = code }
+    w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+    if w.status == 0 { w.status = http.StatusOK }
+    return w.ResponseWriter.Write(b)
+}
+
+// Middleware records mutating requests that succeed (status below 400)
+// and failed ones marked with RecordFailure.
+// Mount it after authentication so the actor is known. A failure to write
+// the entry is logged; the response has already been sent.
+func (rc *Recorder) Middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.Method {
+        case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+        default:
+            next.ServeHTTP(w, r)
+            return
+        }
+        e := &entry{}
+        sw := &statusWriter{ResponseWriter: w}
+        next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), ctxKey{}, e)))
+        if e.skip || sw.status == 0 || (sw.status >= 400 && !e.always) { return }
+
+        row := rc.entri(r, e.p)
+        row.Status = sw.status
+        // The request context may already be cancelled by the client.
+        ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+        defer cancel()
+        if _, ok := tenant.FromContext(ctx); !ok && e.p.TenantID > 0 {
+            ctx = tenant.WithID(ctx, e.p.TenantID)
+        }
+        if err := rc.Store.Insert(ctx, row); err != nil {
+            log.Printf("audit: %s %s: %v", r.Method, r.URL.Path, err)
+        }
+    })
+}
+
+// entri builds the audit row for r, filling what p leaves empty from the
+// route: the entity is the first path segment after /api and the id the
+// {id} parameter.
+func (rc *Recorder) entri(r *http.Request, p Perubahan) *models.AuditLog {
+    e := &models.AuditLog{Aksi: p.Aksi, Entitas: p.Entitas, Method: r.Method, Path: r.URL.Path, IP: middleware.ClientIP(r, rc.Proxies)}
+    if uid, ok := middleware.UserIDFromContext(r.Context()); ok {
+        e.UserID = &uid
+    } else if p.UserID > 0 {
+        uid := p.UserID
+        e.UserID = &uid
+    }
+    if e.Aksi == "" {
+        switch r.Method {
+        case http.MethodPost:
+            e.Aksi = AksiCreate
+        case http.MethodDelete:
+            e.Aksi = AksiDelete
+        default:
+            e.Aksi = AksiUpdate
+        }
+    }
+    if e.Entitas == "" {
+        seg := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+        e.Entitas = seg[0]
+    }
+    id := p.EntitasID
+    if id == 0 {
+        id, _ = strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    }
+    if id > 0 { e.EntitasID = &id }
+    e.Sebelum, e.Sesudah = Diff(p.Sebelum, p.Sesudah)
+    return e
+}
+
+// rahasia are JSON fields never written to the audit log.
+var rahasia = map[string]bool{"password": true, "token": true, "secret": true, "refresh_token": true, "access_token": true, "key": true}
+
+// Diff returns the JSON of before and after. When both are objects only the
+// fields whose values differ are kept, so an update shows just what changed.
+// Secret fields are masked. Nil values give nil.
+func Diff(before, after any) (json.RawMessage, json.RawMessage) {
+    b, bObj := toJSON(before)
+    a, aObj := toJSON(after)
+    if bObj != nil && aObj != nil {
+        for k, v := range bObj {
+            if w, ok := aObj[k]; ok && reflect.DeepEqual(v, w) {
+                delete(bObj, k)
+                delete(aObj, k)
+            }
+        }
+        b, _ = json.Marshal(bObj)
+        a, _ = json.Marshal(aObj)
+    } else {
+        if bObj != nil { b, _ = json.Marshal(bObj) }
+        if aObj != nil { a, _ = json.Marshal(aObj) }
+    }
+    return b, a
+}
+
+// toJSON marshals v, also decoding it as an object (with secrets masked)
+// when it is one.
+func toJSON(v any) (json.RawMessage, map[string]any) {
+    if v == nil { return nil, nil }
+    if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+        return nil, nil
+    }
+    raw, err := json.Marshal(v)
+    if err != nil { return nil, nil }
+    var obj map[string]any
+    if json.Unmarshal(raw, &obj) != nil || obj == nil {
+        return raw, nil
+    }
+    for k := range obj {
+        if rahasia[k] { obj[k] = "***" }
+    }
+    return raw, obj
+}
@@ -0,0 +1,59 @@
+// Package barcode validates and normalizes the GTIN family of retail
+// barcodes (EAN-8, UPC-A, EAN-13 and GTIN-14) read by handheld scanners.
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+    ErrFormat     = errors.New("barcode must be 8, 12, 13 or 14 digits (EAN-8, UPC-A, EAN-13, GTIN-14)")
+    ErrCheckDigit = errors.New("barcode check digit is invalid")
+)
+
+// CheckDigit computes the GS1 check digit for payload (all digits except the
+// last). Weights alternate 3,1,3,... starting from the rightmost digit.
+func CheckDigit(payload string) int {
+    sum := 0
+    for i := len(payload) - 1; i >= 0; i-- {
+        d := int(payload[i] - '0')
+        if (len(payload)-1-i)%2 == 0 {
+            d *= 3
+        }
+        sum += d
+    }
+    return (10 - sum%10) % 10
+}
+
+// Validate checks length, digits and the check digit of code.
+func Validate(code string) error {
+    switch len(code) {
+    case 8, 12, 13, 14:
+    default:
+        return ErrFormat
+    }
+    for i := 0; i < len(code); i++ {
+        if code[i] < '0' || code[i] > '9' {
+            return ErrFormat
+        }
+    }
+    if CheckDigit(code[:len(code)-1]) != int(code[len(code)-1]-'0') {
+        return ErrCheckDigit
+    }
+    return nil
+}
+
+// Normalize trims code, validates it and returns its canonical stored form.
+// UPC-A codes are stored as EAN-13 (leading zero) since scanners may report
+// the same label either way.
+func Normalize(code string) (string, error) {
+    code = strings.TrimSpace(code)
+    if err := Validate(code); err != nil {
+        return "", err
+    }
+    if len(code) == 12 {
+        code = "0" + code
+    }
+    return code, nil
+}
@@ -0,0 +1,65 @@
+package barcode
+
+import "testing"
+
+func TestCheckDigit(t *testing.T) {
+    tests := []struct {
+        payload string
+        want    int
+    }{
+        {"9638507", 4},        // EAN-8 96385074
+        {"03600029145", 2},    // UPC-A 036000291452
+        {"400638133393", 1},   // EAN-13 4006381333931
+        {"590123412345", 7},   // EAN-13 5901234123457
+        {"1001234567890", 2},  // GTIN-14 10012345678902
+        {"000000000000", 0},
+    }
+    for _, tt := range tests {
+        if got := CheckDigit(tt.payload); got != tt.want {
+            t.Errorf("CheckDigit(%q) = %d, want %d", tt.payload, got, tt.want)
+        }
+    }
+}
+
+func TestValidate(t *testing.T) {
+    tests := []struct {
+        code string
+        want error
+    }{
+        {"96385074", nil},
+        {"036000291452", nil},
+        {"4006381333931", nil},
+        {"10012345678902", nil},
+        {"4006381333932", ErrCheckDigit},
+        {"96385075", ErrCheckDigit},
+        {"", ErrFormat},
+        {"1234567", ErrFormat},
+        {"123456789012345", ErrFormat},
+        {"40063813339A1", ErrFormat},
+        {" 4006381333931", ErrFormat},
+    }
+    for _, tt := range tests {
+        if got := Validate(tt.code); got != tt.want {
+            t.Errorf("Validate(%q) = %v, want %v", tt.code, got, tt.want)
+        }
+    }
+}
+
+func TestNormalize(t *testing.T) {
+    tests := []struct {
+        code    string
+        want    string
+        wantErr error
+    }{
+        {"036000291452", "0036000291452", nil},
+        {" 4006381333931\n", "4006381333931", nil},
+        {"96385074", "96385074", nil},
+        {"036000291453", "", ErrCheckDigit},
+    }
+    for _, tt := range tests {
+        got, err := Normalize(tt.code)
+        if got != tt.want || err != tt.wantErr {
+            t.Errorf("Normalize(%q) = %q, %v; want %q, %v", tt.code, got, err, tt.want, tt.wantErr)
+        }
+    }
+}
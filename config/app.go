@@ -0,0 +1,77 @@
+package config
+
+import (
+	"log"
+	"strconv"
+	"time"
+	_ "time/tzdata" // zone names resolve even where the OS has no zoneinfo
+)
+
+// WIB is Western Indonesia Time, the default business time zone.
+var WIB = time.FixedZone("WIB", 7*60*60)
+
+// Env returns an environment variable or def when it is not set.
+// Call after OpenDB so values from .env are loaded.
+func Env(key, def string) string {
+    return getenv(key, def)
+}
+
+// EnvDuration parses an environment variable as a time.Duration (e.g. "30s", "5m").
+// Invalid values are logged and replaced by def.
+func EnvDuration(key string, def time.Duration) time.Duration {
+    v := getenv(key, "")
+    if v == "" {
+        return def
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil || d <= 0 {
+        log.Printf("config: invalid %s=%q, using %s", key, v, def)
+        return def
+    }
+    return d
+}
+
+// EnvInt parses an environment variable as a positive integer.
+// Invalid values are logged and replaced by def.
+func EnvInt(key string, def int) int {
+    v := getenv(key, "")
+    if v == "" {
+        return def
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil || n <= 0 {
+        log.Printf("config: invalid %s=%q, using %d", key, v, def)
+        return def
+    }
+    return n
+}
+
+// EnvLocation loads the time zone named by an environment variable (e.g.
+// "Asia/Jakarta"). Unknown names are logged and replaced by def.
+func EnvLocation(key string, def *time.Location) *time.Location {
+    v := getenv(key, "")
+    if v == "" {
+        return def
+    }
+    loc, err := time.LoadLocation(v)
+    if err != nil {
+        log.Printf("config: invalid %s=%q, using %s", key, v, def)
+        return def
+    }
+    return loc
+}
+
+// EnvBool parses an environment variable as a boolean ("true", "1", ...).
+// Invalid values are logged and replaced by def.
+func EnvBool(key string, def bool) bool {
+    v := getenv(key, "")
+    if v == "" {
+        return def
+    }
+    b, err := strconv.ParseBool(v)
+    if err != nil {
+        log.Printf("config: invalid %s=%q, using %t", key, v, def)
+        return def
+    }
+    return b
+}
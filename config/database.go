@@ -7,10 +7,13 @@ import (
 
 	// Third-party packages
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+
+	"warehouse/tenant"
 )
 
 // OpenDB opens a connection pool to PostgreSQL using environment variables.
+// Statements run with the tenant of their context (see package tenant).
 func OpenDB() (*sql.DB, error) {
     _ = godotenv.Load()
 
@@ -28,11 +31,13 @@ func OpenDB() (*sql.DB, error) {
         host, port, user, pass, name, sslm,
     )
 
-    // Open a database handle using the pq driver name.
-    db, err := sql.Open("postgres", dsn)
+    // Open a database handle on the pq connector, wrapped so every
+    // statement carries the tenant of its context.
+    connector, err := pq.NewConnector(dsn)
     if err != nil {
         return nil, err
     }
+    db := sql.OpenDB(tenant.NewConnector(connector))
 
     // Optionally, ping the database to verify the connection parameters.
     if err := db.Ping(); err != nil {
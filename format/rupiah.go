@@ -0,0 +1,37 @@
+// Package format holds presentation helpers shared by labels, invoices and exports.
+package format
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Ribuan formats n with dots as thousands separators: 1234567 -> "1.234.567".
+func Ribuan(n int64) string {
+    neg := n < 0
+    if neg { n = -n }
+    s := strconv.FormatInt(n, 10)
+    out := make([]byte, 0, len(s)+len(s)/3+1)
+    if neg { out = append(out, '-') }
+    for i := 0; i < len(s); i++ {
+        if i > 0 && (len(s)-i)%3 == 0 {
+            out = append(out, '.')
+        }
+        out = append(out, s[i])
+    }
+    return string(out)
+}
+
+// Rupiah formats an amount in Rupiah: 15000 -> "Rp 15.000".
+func Rupiah(n int64) string {
+    if n < 0 {
+        return "-Rp " + Ribuan(-n)
+    }
+    return "Rp " + Ribuan(n)
+}
+
+// Persen formats a rate in basis points: 1100 -> "11%", 1150 -> "11,5%".
+func Persen(bp int64) string {
+    s := strconv.FormatFloat(float64(bp)/100, 'f', -1, 64)
+    return strings.Replace(s, ".", ",", 1) + "%"
+}
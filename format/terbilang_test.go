@@ -0,0 +1,38 @@
+package format
+
+import "testing"
+
+func TestTerbilang(t *testing.T) {
+    tests := []struct {
+        n    int64
+        want string
+    }{
+        {0, "nol"},
+        {1, "satu"},
+        {10, "sepuluh"},
+        {11, "sebelas"},
+        {12, "dua belas"},
+        {19, "sembilan belas"},
+        {20, "dua puluh"},
+        {21, "dua puluh satu"},
+        {100, "seratus"},
+        {101, "seratus satu"},
+        {111, "seratus sebelas"},
+        {999, "sembilan ratus sembilan puluh sembilan"},
+        {1000, "seribu"},
+        {1250, "seribu dua ratus lima puluh"},
+        {2000, "dua ribu"},
+        {11000, "sebelas ribu"},
+        {100000, "seratus ribu"},
+        {1_000_000, "satu juta"},
+        {1_001_000, "satu juta seribu"},
+        {2_500_000_000, "dua miliar lima ratus juta"},
+        {1_000_000_000_000, "satu triliun"},
+        {-5, "minus lima"},
+    }
+    for _, tt := range tests {
+        if got := Terbilang(tt.n); got != tt.want {
+            t.Errorf("Terbilang(%d) = %q, want %q", tt.n, got, tt.want)
+        }
+    }
+}
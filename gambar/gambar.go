@@ -0,0 +1,93 @@
+// Package gambar validates uploaded product images and makes their
+// thumbnails using only the standard library decoders (JPEG, PNG, GIF).
+package gambar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// MaxPixels caps the decoded size of an image so a small compressed file
+// cannot expand into a huge bitmap.
+const MaxPixels = 40_000_000
+
+// Types maps the accepted content types to the file extension used in
+// storage keys.
+var Types = map[string]string{
+    "image/jpeg": ".jpg",
+    "image/png":  ".png",
+    "image/gif":  ".gif",
+}
+
+// ErrFormat is returned for data that is not an accepted image.
+var ErrFormat = errors.New("image must be JPEG, PNG or GIF")
+
+// Periksa checks that data is a decodable image of an accepted type and
+// returns its content type, sniffed from the data rather than trusting the
+// client.
+func Periksa(data []byte) (string, error) {
+    ct := http.DetectContentType(data)
+    if _, ok := Types[ct]; !ok { return "", ErrFormat }
+    cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+    if err != nil { return "", ErrFormat }
+    if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+        return "", fmt.Errorf("image is %dx%d, at most %d pixels are allowed", cfg.Width, cfg.Height, MaxPixels)
+    }
+    return ct, nil
+}
+
+// Thumbnail scales the image down to fit in a size×size box, keeping the
+// aspect ratio (smaller images keep their size), and encodes it as JPEG.
+// Transparent areas become white.
+func Thumbnail(data []byte, size int) ([]byte, error) {
+    src, _, err := image.Decode(bytes.NewReader(data))
+    if err != nil { return nil, ErrFormat }
+    b := src.Bounds()
+    sw, sh := b.Dx(), b.Dy()
+    dw, dh := sw, sh
+    if sw > size || sh > size {
+        if sw >= sh {
+            dw, dh = size, sh*size/sw
+        } else {
+            dw, dh = sw*size/sh, size
+        }
+    }
+    if dw < 1 { dw = 1 }
+    if dh < 1 { dh = 1 }
+
+    // Box filter: every source pixel is added to the destination pixel it
+    // falls in, then each destination pixel is the average of its sums.
+    type acc struct{ r, g, b, n uint64 }
+    sums := make([]acc, dw*dh)
+    for y := 0; y < sh; y++ {
+        row := (y * dh / sh) * dw
+        for x := 0; x < sw; x++ {
+            r, g, bl, a := src.At(b.Min.X+x, b.Min.Y+y).RGBA()
+            // RGBA is alpha-premultiplied, so adding the missing alpha
+            // composites the pixel onto white.
+            s := &sums[row+x*dw/sw]
+            s.r += uint64(r + 0xffff - a)
+            s.g += uint64(g + 0xffff - a)
+            s.b += uint64(bl + 0xffff - a)
+            s.n++
+        }
+    }
+    dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+    for i, s := range sums {
+        if s.n == 0 { continue }
+        p := dst.Pix[i*4 : i*4+4]
+        p[0] = uint8(s.r / s.n >> 8)
+        p[1] = uint8(s.g / s.n >> 8)
+        p[2] = uint8(s.b / s.n >> 8)
+        p[3] = 0xff
+    }
+    var buf bytes.Buffer
+    if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil { return nil, err }
+    return buf.Bytes(), nil
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/apikey"
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/permission"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIKeyHandler manages API keys for machine-to-machine clients.
+type APIKeyHandler struct {
+    Repo *repositories.APIKeyRepo
+}
+
+func NewAPIKeyHandler(repo *repositories.APIKeyRepo) *APIKeyHandler {
+    return &APIKeyHandler{Repo: repo}
+}
+
+type apiKeyRequest struct {
+    Nama       string     `json:"nama"`
+    UserID     int64      `json:"user_id"`
+    Scopes     []string   `json:"scopes"`
+    AllowedIPs []string   `json:"allowed_ips"`
+    ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// GET /api/api-keys?user_id=&aktif=
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    userID, _ := strconv.ParseInt(q.Get("user_id"), 10, 64)
+    var aktif *bool
+    if v := q.Get("aktif"); v != "" {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "aktif must be true or false"})
+            return
+        }
+        aktif = &b
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.List(ctx, userID, aktif)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// POST /api/api-keys
+// Body: {"nama":"toko online","user_id":5,"scopes":["barang.view","penjualan.create"],
+//        "allowed_ips":["203.0.113.0/24"],"expires_at":"2027-01-01T00:00:00Z"}
+// user_id is a service user or the caller; the scopes must be permissions of
+// both that user's role and the caller. The key is in the response and is
+// not shown again.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+    uid, ok := middleware.UserIDFromContext(r.Context())
+    if !ok {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    var req apiKeyRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    req.Nama = strings.TrimSpace(req.Nama)
+    if req.Nama == "" || len(req.Nama) > 100 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama is required (max 100 characters)"})
+        return
+    }
+    if req.UserID <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "user_id is required"})
+        return
+    }
+    if len(req.Scopes) == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "scopes is required"})
+        return
+    }
+    for _, s := range req.Scopes {
+        if !permission.Valid(s) {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "unknown permission " + s})
+            return
+        }
+        // A key cannot do more than whoever creates it.
+        if !middleware.HasPermission(r.Context(), s) {
+            WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "you do not have permission " + s})
+            return
+        }
+    }
+    if req.AllowedIPs == nil { req.AllowedIPs = []string{} }
+    for i := range req.AllowedIPs { req.AllowedIPs[i] = strings.TrimSpace(req.AllowedIPs[i]) }
+    if err := apikey.CheckAllowlist(req.AllowedIPs); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "allowed_ips: " + err.Error()})
+        return
+    }
+    if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "expires_at must be in the future"})
+        return
+    }
+    key, prefix, hash, err := apikey.Generate()
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    k := models.APIKey{Nama: req.Nama, Prefix: prefix, UserID: req.UserID, Scopes: req.Scopes, AllowedIPs: req.AllowedIPs,
+        ExpiresAt: req.ExpiresAt, CreatedBy: &uid}
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Create(ctx, &k, hash); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    saved, err := h.Repo.GetByID(ctx, k.ID)
+    if err != nil || saved == nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: "api key saved but could not be read back"})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "api_key", EntitasID: saved.ID, Sesudah: saved})
+    // The audit entry is built after the handler returns, so the key only goes on a copy.
+    resp := *saved
+    resp.Key = key
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created; store the key now, it is not shown again", Data: resp})
+}
+
+// POST /api/api-keys/{id}/revoke
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Revoke(ctx, id); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    k, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "revoke", Entitas: "api_key"})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "revoked", Data: k})
+}
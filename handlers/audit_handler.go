@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"warehouse/models"
+	"warehouse/repositories"
+)
+
+// AuditHandler lists the audit log.
+type AuditHandler struct {
+    Repo *repositories.AuditRepo
+}
+
+func NewAuditHandler(repo *repositories.AuditRepo) *AuditHandler { return &AuditHandler{Repo: repo} }
+
+// GET /api/audit?entitas=barang&entitas_id=&user_id=&aksi=&from=YYYY-MM-DD&to=YYYY-MM-DD&page=&limit=
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    page, _ := strconv.Atoi(q.Get("page"))
+    limit, _ := strconv.Atoi(q.Get("limit"))
+    if page <= 0 { page = 1 }
+    if limit <= 0 { limit = 10 }
+
+    f := models.AuditFilter{Entitas: q.Get("entitas"), Aksi: q.Get("aksi")}
+    for _, p := range []struct {
+        name string
+        dst  *int64
+    }{{"entitas_id", &f.EntitasID}, {"user_id", &f.UserID}} {
+        s := q.Get(p.name)
+        if s == "" { continue }
+        id, err := strconv.ParseInt(s, 10, 64)
+        if err != nil || id <= 0 {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid " + p.name})
+            return
+        }
+        *p.dst = id
+    }
+    if fs := q.Get("from"); fs != "" {
+        t, err := time.Parse("2006-01-02", fs)
+        if err != nil {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "from must be YYYY-MM-DD"})
+            return
+        }
+        f.From = &t
+    }
+    if ts := q.Get("to"); ts != "" {
+        t, err := time.Parse("2006-01-02", ts)
+        if err != nil {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "to must be YYYY-MM-DD"})
+            return
+        }
+        t = t.Add(24*time.Hour - time.Nanosecond)
+        f.To = &t
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, total, err := h.Repo.List(ctx, f, page, limit)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list, Meta: &Meta{Page: page, Limit: limit, Total: total}})
+}
@@ -3,33 +3,60 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
+	"warehouse/audit"
+	"warehouse/loginlimit"
+	"warehouse/middleware"
+	"warehouse/models"
 	"warehouse/repositories"
+	"warehouse/tenant"
+	"warehouse/token"
 )
 
 type AuthHandler struct {
-    Users *repositories.UserRepo
+    Users      *repositories.UserRepo
+    Sesi       *repositories.SesiRepo
+    TOTP       *repositories.TOTPRepo
+    Tenants    *repositories.TenantRepo
+    Tokens     *token.Manager
+    Limiter    *loginlimit.Limiter
+    Proxies    middleware.TrustedProxies // whose X-Forwarded-For is believed
 }
 
-func NewAuthHandler(users *repositories.UserRepo) *AuthHandler { return &AuthHandler{Users: users} }
+func NewAuthHandler(users *repositories.UserRepo, sesi *repositories.SesiRepo, totp *repositories.TOTPRepo, tenants *repositories.TenantRepo, tokens *token.Manager, limiter *loginlimit.Limiter, proxies middleware.TrustedProxies) *AuthHandler {
+    return &AuthHandler{Users: users, Sesi: sesi, TOTP: totp, Tenants: tenants, Tokens: tokens, Limiter: limiter, Proxies: proxies}
+}
 
 type loginRequest struct {
     Username string `json:"username"`
     Password string `json:"password"`
+    Tenant   string `json:"tenant"` // kode; empty = the user's first tenant
+}
+
+type challengeRequest struct {
+    ChallengeToken string `json:"challenge_token"`
+    Code           string `json:"code"`
+    RecoveryCode   string `json:"recovery_code"`
 }
 
 type refreshRequest struct {
     RefreshToken string `json:"refresh_token"`
 }
 
-// jwtSecret shared with middleware; keep simple by duplicating constant if needed.
-var jwtSecret = []byte("super-secret-key")
-
 // POST /api/login
+// Every attempt is counted per username and per client IP before the
+// password is checked and taken back when it is right; once the limiter
+// asks for a pause the request is refused with 429 and Retry-After. Users
+// with two-factor authentication (or whose role requires it) get a
+// challenge token instead of tokens; see SecondFactor. The tokens are for
+// the tenant named in the request, or the user's first tenant.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
     var req loginRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -42,34 +69,300 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
     }
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
+    ip := middleware.ClientIP(r, h.Proxies)
+    attempt, err := h.Limiter.Begin(ctx, req.Username, ip)
+    if err != nil {
+        log.Printf("login limiter: %v", err)
+        WriteJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Message: "login temporarily unavailable"})
+        return
+    }
+    if attempt.Wait > 0 {
+        setRetryAfter(w, attempt.Wait)
+        WriteJSON(w, http.StatusTooManyRequests, APIResponse{Success: false, Message: "too many failed login attempts, try again later"})
+        return
+    }
     u, err := h.Users.GetByUsername(ctx, req.Username)
     if err != nil {
         WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
         return
     }
+    req.Tenant = strings.TrimSpace(req.Tenant)
     if u == nil {
-        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "invalid credentials"})
+        h.loginGagal(ctx, w, attempt, req.Username, 0, h.tenantGagal(ctx, nil, 0, req.Tenant), "invalid credentials")
+        return
+    }
+    t, err := h.Tenants.Resolve(ctx, u.ID, req.Tenant)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
         return
     }
     if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.Password)); err != nil {
-        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "invalid credentials"})
+        h.loginGagal(ctx, w, attempt, req.Username, u.ID, h.tenantGagal(ctx, t, u.ID, req.Tenant), "invalid credentials")
+        return
+    }
+    if err := h.Limiter.Undo(ctx, attempt); err != nil {
+        log.Printf("login limiter: %v", err)
+    }
+    if u.Aktif != nil && !*u.Aktif {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "user is deactivated"})
+        return
+    }
+    if u.Service {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "service users cannot log in; use an API key"})
+        return
+    }
+    if t == nil {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "no access to tenant"})
+        return
+    }
+    st, err := h.TOTP.Status(ctx, u.ID)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if st.Aktif || st.Wajib {
+        // The login is not finished, so earlier failures stay counted until
+        // the second factor is given; the code cannot be guessed by logging
+        // in again.
+        challenge, err := h.Tokens.IssueChallenge(u.ID, t.ID, t.Role, token.NewID())
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        langkah, msg := "code", "Two-factor code required"
+        if !st.Aktif { langkah, msg = "enroll", "Two-factor enrollment required" }
+        audit.Record(ctx, audit.Perubahan{Aksi: "login_challenge", Entitas: "user", EntitasID: u.ID, UserID: u.ID, TenantID: t.ID})
+        WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: msg, Data: map[string]any{
+            "challenge_token": challenge, "two_factor": langkah, "expires_in": int(token.ChallengeTTL.Seconds()),
+        }})
+        return
+    }
+    if err := h.Limiter.Success(ctx, req.Username); err != nil {
+        log.Printf("login limiter: %v", err)
+    }
+    // Every login starts a new session (refresh token family).
+    pair, err := h.issuePair(ctx, u.ID, t.ID, t.Role, token.NewID(), "")
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    pair["tenant"] = t.Kode
+    audit.Record(ctx, audit.Perubahan{Aksi: "login", Entitas: "user", EntitasID: u.ID, UserID: u.ID, TenantID: t.ID})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Login success", Data: pair})
+}
+
+// loginGagal records a failed login in the audit log of tenant tenantID and
+// answers 401 with msg; the limiter already counted it in Begin. userID is 0
+// when the username does not exist.
+func (h *AuthHandler) loginGagal(ctx context.Context, w http.ResponseWriter, attempt loginlimit.Attempt, username string, userID, tenantID int64, msg string) {
+    aksi := "login_failed"
+    if attempt.Locked { aksi = "login_locked" }
+    audit.RecordFailure(ctx, audit.Perubahan{Aksi: aksi, Entitas: "user", EntitasID: userID, TenantID: tenantID, Sesudah: map[string]any{"username": username}})
+    if attempt.Next > 0 { setRetryAfter(w, attempt.Next) }
+    WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: msg})
+}
+
+// tenantGagal returns the tenant whose audit log records a failed login, so
+// that its admins see it: t, the tenant the login was for, else the user's
+// first tenant, else the tenant with kode named in the request, else the
+// default tenant. t is nil and userID 0 when they are not known.
+func (h *AuthHandler) tenantGagal(ctx context.Context, t *models.Tenant, userID int64, kode string) int64 {
+    if t != nil { return t.ID }
+    if userID > 0 {
+        first, err := h.Tenants.Resolve(ctx, userID, "")
+        if err != nil { log.Printf("login audit: %v", err) }
+        if first != nil { return first.ID }
+    }
+    if kode != "" {
+        id, err := h.Tenants.IDByKode(ctx, kode)
+        if err != nil { log.Printf("login audit: %v", err) }
+        if id > 0 { return id }
+    }
+    return tenant.DefaultID
+}
+
+// challengeUser returns the user of a challenge token and the session id
+// and tenant it was issued for. It writes the error response and returns
+// nil on failure.
+func (h *AuthHandler) challengeUser(ctx context.Context, w http.ResponseWriter, challengeToken string) (*models.User, string, int64) {
+    claims, err := h.Tokens.ParseChallenge(challengeToken)
+    if err != nil {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "invalid or expired challenge token"})
+        return nil, "", 0
+    }
+    uid, _ := claims["user_id"].(float64)
+    sid, _ := claims["sid"].(string)
+    // Read in the tenant of the challenge, so u.Role is the role there.
+    u, err := h.Users.GetByID(tenant.WithID(ctx, token.TenantID(claims)), int64(uid))
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return nil, "", 0
+    }
+    if u == nil {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "invalid or expired challenge token"})
+        return nil, "", 0
+    }
+    if u.Aktif != nil && !*u.Aktif {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "user is deactivated"})
+        return nil, "", 0
+    }
+    return u, sid, token.TenantID(claims)
+}
+
+// POST /api/login/2fa exchanges a challenge token and a TOTP code (or a
+// recovery code) for access and refresh tokens. For users who still have
+// to enroll, the code finishes the enrollment started with
+// /api/login/2fa/enroll and the response also carries their recovery codes.
+func (h *AuthHandler) SecondFactor(w http.ResponseWriter, r *http.Request) {
+    var req challengeRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    req.Code, req.RecoveryCode = strings.TrimSpace(req.Code), strings.TrimSpace(req.RecoveryCode)
+    if req.Code == "" && req.RecoveryCode == "" {
+        WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "code or recovery_code required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    u, sid, tid := h.challengeUser(ctx, w, req.ChallengeToken)
+    if u == nil { return }
+    ip := middleware.ClientIP(r, h.Proxies)
+    attempt, err := h.Limiter.Begin(ctx, u.Username, ip)
+    if err != nil {
+        log.Printf("login limiter: %v", err)
+        WriteJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Message: "login temporarily unavailable"})
+        return
+    }
+    if attempt.Wait > 0 {
+        setRetryAfter(w, attempt.Wait)
+        WriteJSON(w, http.StatusTooManyRequests, APIResponse{Success: false, Message: "too many failed login attempts, try again later"})
         return
     }
-    // Generate access (15m) & refresh (7d) tokens.
-    accessToken, err := GenerateAccessToken(u.ID, u.Role)
+    st, err := h.TOTP.Status(ctx, u.ID)
     if err != nil {
-        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: "failed to generate access token"})
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    var recovery []string
+    cara := "totp"
+    if st.Aktif {
+        err = h.TOTP.Check(ctx, u.ID, req.Code, req.RecoveryCode)
+        if req.Code == "" { cara = "recovery_code" }
+    } else if req.Code == "" {
+        WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "code required to finish enrollment"})
+        return
+    } else {
+        recovery, err = h.TOTP.Activate(ctx, u.ID, req.Code)
+        cara = "enrolled"
+    }
+    if err == repositories.ErrTOTPInvalid {
+        h.loginGagal(ctx, w, attempt, u.Username, u.ID, tid, err.Error())
+        return
+    }
+    if uErr := h.Limiter.Undo(ctx, attempt); uErr != nil {
+        log.Printf("login limiter: %v", uErr)
+    }
+    if err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Limiter.Success(ctx, u.Username); err != nil {
+        log.Printf("login limiter: %v", err)
+    }
+    pair, err := h.issuePair(ctx, u.ID, tid, u.Role, sid, "")
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    data := map[string]any{"access_token": pair["access_token"], "refresh_token": pair["refresh_token"]}
+    if recovery != nil { data["recovery_codes"] = recovery }
+    audit.Record(ctx, audit.Perubahan{Aksi: "login", Entitas: "user", EntitasID: u.ID, UserID: u.ID, TenantID: tid, Sesudah: map[string]string{"two_factor": cara}})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Login success", Data: data})
+}
+
+// POST /api/login/2fa/enroll starts enrollment for a user whose role
+// requires two-factor authentication but who has not enrolled yet. The
+// returned otpauth_uri is shown as a QR code; the first code from the app
+// is then sent to /api/login/2fa.
+func (h *AuthHandler) EnrollSecondFactor(w http.ResponseWriter, r *http.Request) {
+    var req challengeRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "invalid json"})
         return
     }
-    refreshToken, err := GenerateRefreshToken(u.ID, u.Role)
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    u, _, _ := h.challengeUser(ctx, w, req.ChallengeToken)
+    if u == nil { return }
+    e, err := h.TOTP.Start(ctx, u.ID)
     if err != nil {
-        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: "failed to generate refresh token"})
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Scan the QR code, then send a code to /api/login/2fa", Data: e})
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounded up.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+    w.Header().Set("Retry-After", strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10))
+}
+
+type unlockRequest struct {
+    Username string `json:"username"`
+    IP       string `json:"ip"`
+}
+
+// POST /api/login/unlock clears the failed login record of a username
+// and/or an IP, lifting its backoff and lockout. The login of a user is
+// shared by all of their tenants, so unlocking one needs user.manage in each.
+func (h *AuthHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+    var req unlockRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    req.Username, req.IP = strings.TrimSpace(req.Username), strings.TrimSpace(req.IP)
+    if req.Username == "" && req.IP == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "username or ip required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if req.Username != "" {
+        u, err := h.Users.GetByUsername(ctx, req.Username)
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        if u != nil && !cekDikelola(ctx, w, h.Users, u.ID) { return }
+    }
+    if err := h.Limiter.Unlock(ctx, req.Username, req.IP); err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
         return
     }
-    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Login success", Data: map[string]string{
-        "access_token":  accessToken,
-        "refresh_token": refreshToken,
-    }})
+    audit.Record(ctx, audit.Perubahan{Aksi: "unlock", Entitas: "login", Sesudah: req})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Login unlocked", Data: req})
+}
+
+// issuePair issues an access and a refresh token in tenant tenantID for
+// session sid. The refresh token is recorded as the start of the session,
+// or, when prevJTI is set, as the successor of the refresh token being
+// exchanged.
+func (h *AuthHandler) issuePair(ctx context.Context, userID, tenantID int64, role, sid, prevJTI string) (map[string]string, error) {
+    jti := token.NewID()
+    refreshToken, exp, err := h.Tokens.IssueRefresh(userID, tenantID, role, sid, jti)
+    if err != nil { return nil, err }
+    if prevJTI == "" {
+        err = h.Sesi.Create(ctx, jti, sid, userID, exp)
+    } else {
+        err = h.Sesi.Rotate(ctx, prevJTI, jti, exp)
+    }
+    if err != nil { return nil, err }
+    accessToken, err := h.Tokens.IssueAccess(userID, tenantID, role, sid)
+    if err != nil { return nil, err }
+    return map[string]string{"access_token": accessToken, "refresh_token": refreshToken}, nil
 }
 
 // POST /api/refresh
@@ -83,34 +376,151 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
         WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "refresh_token required"})
         return
     }
-    claims, err := ParseAndValidateRefresh(req.RefreshToken)
+    claims, err := h.Tokens.ParseRefresh(req.RefreshToken)
     if err != nil {
         WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "invalid refresh token"})
         return
     }
-    expVal, ok := claims["exp"].(float64)
-    if !ok || time.Unix(int64(expVal), 0).Before(time.Now()) {
-        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "refresh token expired"})
-        return
-    }
     uidFloat, okUID := claims["user_id"].(float64)
-    role, okRole := claims["role"].(string)
-    if !okUID || !okRole {
+    if !okUID {
         WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "invalid token claims"})
         return
     }
-    accessToken, err := GenerateAccessToken(int64(uidFloat), role)
+    // New tokens carry the user's current role; deactivated users and
+    // users removed from the tenant get none.
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    sid, jti, tid := claims["sid"].(string), claims["jti"].(string), token.TenantID(claims)
+    st, err := h.Users.AuthStatus(ctx, int64(uidFloat), tid, sid)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if !st.Aktif {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "user is deactivated"})
+        return
+    }
+    if !st.Anggota {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "no access to this tenant"})
+        return
+    }
+    // A role that starts requiring two-factor applies to running sessions
+    // too: instead of new tokens the user gets the enrollment challenge, and
+    // the session ends.
+    fa, err := h.TOTP.Status(ctx, int64(uidFloat))
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if fa.Wajib && !fa.Aktif {
+        if err := h.Sesi.RevokeFamily(ctx, sid); err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        challenge, err := h.Tokens.IssueChallenge(int64(uidFloat), tid, st.Role, token.NewID())
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        audit.Record(ctx, audit.Perubahan{Aksi: "login_challenge", Entitas: "user", EntitasID: int64(uidFloat), UserID: int64(uidFloat), TenantID: tid})
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "Two-factor enrollment required", Data: map[string]any{
+            "challenge_token": challenge, "two_factor": "enroll", "expires_in": int(token.ChallengeTTL.Seconds()),
+        }})
+        return
+    }
+    pair, err := h.issuePair(ctx, int64(uidFloat), tid, st.Role, sid, jti)
     if err != nil {
-        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: "failed to generate access token"})
+        if err == repositories.ErrRefreshInvalid || err == repositories.ErrRefreshReuse {
+            WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Token refreshed", Data: pair})
+}
+
+// POST /api/logout ends the session of the refresh token in the body, or
+// of the Bearer access token when no body is sent. Its refresh tokens stop
+// working and its access tokens are rejected.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+    var req refreshRequest
+    _ = json.NewDecoder(r.Body).Decode(&req)
+    var claims map[string]interface{}
+    var err error
+    if req.RefreshToken != "" {
+        claims, err = h.Tokens.ParseRefresh(req.RefreshToken)
+    } else if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+        claims, err = h.Tokens.ParseAccess(strings.TrimPrefix(auth, "Bearer "))
+    } else {
+        WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "refresh_token or Authorization header required"})
         return
     }
-    newRefresh, err := GenerateRefreshToken(int64(uidFloat), role)
     if err != nil {
-        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: "failed to generate refresh token"})
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "invalid token"})
+        return
+    }
+    uid, _ := claims["user_id"].(float64)
+    sid, _ := claims["sid"].(string)
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Sesi.RevokeFamily(ctx, sid); err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "logout", Entitas: "user", EntitasID: int64(uid), UserID: int64(uid), TenantID: token.TenantID(claims)})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Logged out"})
+}
+
+type switchTenantRequest struct {
+    Tenant string `json:"tenant"` // kode
+}
+
+// POST /api/switch-tenant moves the caller to another of their tenants: the
+// current session ends and the tokens of a new session in that tenant are
+// returned.
+func (h *AuthHandler) SwitchTenant(w http.ResponseWriter, r *http.Request) {
+    uid, ok := middleware.UserIDFromContext(r.Context())
+    if !ok {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    if _, viaKey := middleware.APIKeyFromContext(r.Context()); viaKey {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "an API key belongs to one tenant"})
+        return
+    }
+    var req switchTenantRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    req.Tenant = strings.TrimSpace(req.Tenant)
+    if req.Tenant == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "tenant required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    t, err := h.Tenants.Resolve(ctx, uid, req.Tenant)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if t == nil {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "no access to tenant"})
+        return
+    }
+    pair, err := h.issuePair(ctx, uid, t.ID, t.Role, token.NewID(), "")
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    sid, _ := middleware.SessionFromContext(r.Context())
+    if err := h.Sesi.RevokeFamily(ctx, sid); err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
         return
     }
-    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Token refreshed", Data: map[string]string{
-        "access_token":  accessToken,
-        "refresh_token": newRefresh,
-    }})
+    pair["tenant"] = t.Kode
+    audit.Record(ctx, audit.Perubahan{Aksi: "switch_tenant", Entitas: "user", EntitasID: uid, Sesudah: map[string]string{"tenant": t.Kode}})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Switched tenant", Data: pair})
 }
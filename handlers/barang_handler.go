@@ -1,29 +1,51 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"warehouse/audit"
+	"warehouse/middleware"
 	"warehouse/models"
 	"warehouse/repositories"
+	"warehouse/spreadsheet"
+	"warehouse/storage"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // BarangHandler provides HTTP handlers for master_barang.
 type BarangHandler struct {
-    Repo *repositories.BarangRepo
+    Repo  *repositories.BarangRepo
+    Files storage.Storage // product images, removed with the barang
 }
 
-func NewBarangHandler(repo *repositories.BarangRepo) *BarangHandler {
-    return &BarangHandler{Repo: repo}
+func NewBarangHandler(repo *repositories.BarangRepo, files storage.Storage) *BarangHandler {
+    return &BarangHandler{Repo: repo, Files: files}
 }
 
-// GET /api/barang
+// arsipFilter reads ?archived=: empty or false lists active barang, true only
+// archived ones and all both. It writes a 422 and returns false otherwise.
+func arsipFilter(w http.ResponseWriter, r *http.Request) (repositories.ArsipFilter, bool) {
+    switch r.URL.Query().Get("archived") {
+    case "", "false":
+        return repositories.TanpaArsip, true
+    case "true":
+        return repositories.HanyaArsip, true
+    case "all":
+        return repositories.DenganArsip, true
+    }
+    WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "archived must be true, false or all"})
+    return 0, false
+}
+
+// GET /api/barang?search=&category=&archived=false|true|all
 func (h *BarangHandler) GetAll(w http.ResponseWriter, r *http.Request) {
     q := r.URL.Query()
     search := q.Get("search")             
@@ -31,13 +53,31 @@ func (h *BarangHandler) GetAll(w http.ResponseWriter, r *http.Request) {
     limit, _ := strconv.Atoi(q.Get("limit")) 
     if page <= 0 { page = 1 }
     if limit <= 0 { limit = 10 }
+    // category filters by kategori id, including its sub-categories
+    var kategoriID int64
+    if c := q.Get("category"); c != "" {
+        id, err := strconv.ParseInt(c, 10, 64)
+        if err != nil || id <= 0 {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid category"})
+            return
+        }
+        kategoriID = id
+    }
+    arsip, ok := arsipFilter(w, r)
+    if !ok { return }
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    if format != "" {
+        h.exportBarang(w, r, format, search, kategoriID, arsip)
+        return
+    }
 
     // Create a short-lived context for the DB call
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
 
     // Call the repository to get data and total rows for pagination
-    items, total, err := h.Repo.GetAll(ctx, search, page, limit)
+    items, total, err := h.Repo.GetAll(ctx, search, kategoriID, arsip, page, limit)
     if err != nil {
         WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
         return
@@ -80,9 +120,33 @@ func (h *BarangHandler) GetByID(w http.ResponseWriter, r *http.Request) {
         WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
         return
     }
+    w.Header().Set("ETag", barangETag(item.Version))
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: item})
 }
 
+// barangETag is the entity tag of a barang version.
+func barangETag(version int64) string {
+    return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion returns the barang version required by the If-Match header;
+// "*" accepts any version and yields 0. A missing header writes a 428 and a
+// tag that is not a barang version a 412; both return false.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int64, bool) {
+    tag := strings.TrimSpace(r.Header.Get("If-Match"))
+    if tag == "" {
+        WriteJSON(w, http.StatusPreconditionRequired, APIResponse{Success: false, Message: "If-Match header with the barang ETag is required"})
+        return 0, false
+    }
+    if tag == "*" { return 0, true }
+    v, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(tag, `"`), `"`), 10, 64)
+    if err != nil || v <= 0 || tag != barangETag(v) {
+        WriteJSON(w, http.StatusPreconditionFailed, APIResponse{Success: false, Message: "If-Match does not match the current barang version"})
+        return 0, false
+    }
+    return v, true
+}
+
 // POST /api/barang
 func (h *BarangHandler) Create(w http.ResponseWriter, r *http.Request) {
     var b models.Barang
@@ -107,13 +171,16 @@ func (h *BarangHandler) Create(w http.ResponseWriter, r *http.Request) {
     }
     b.KodeBarang = kode
     if err := h.Repo.Create(ctx, &b); err != nil {
-        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
         return
     }
+    audit.Record(ctx, audit.Perubahan{EntitasID: b.ID, Sesudah: b})
+    w.Header().Set("ETag", barangETag(b.Version))
     WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: b})
 }
 
 // PUT /api/barang/{id}
+// Requires If-Match with the ETag from GET /api/barang/{id}.
 func (h *BarangHandler) UpdateBarang(w http.ResponseWriter, r *http.Request) {
     idStr := chi.URLParam(r, "id")
     id, _ := strconv.ParseInt(idStr, 10, 64)
@@ -121,40 +188,106 @@ func (h *BarangHandler) UpdateBarang(w http.ResponseWriter, r *http.Request) {
         WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
         return
     }
+    version, ok := ifMatchVersion(w, r)
+    if !ok { return }
 
     var b models.Barang
     if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
         WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
         return
     }
-    // Enforce ID from path to avoid mismatch
+    // Enforce ID and version from the request, not the body
     b.ID = id
+    b.Version = version
 
-    if b.NamaBarang == "" || b.Satuan == "" {
-        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama_barang and satuan are required"})
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if before == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    h.saveBarang(w, r.WithContext(ctx), before, &b, false)
+}
+
+// PATCH /api/barang/{id}
+// Body: any subset of the PUT fields; omitted fields keep their value and
+// null clears deskripsi or kategori_id. Requires If-Match like PUT.
+func (h *BarangHandler) PatchBarang(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    version, ok := ifMatchVersion(w, r)
+    if !ok { return }
+    var patch map[string]json.RawMessage
+    if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
         return
     }
 
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
-    if err := h.Repo.Update(ctx, &b); err != nil {
+    b, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if b == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    current := *b
+    raw, _ := json.Marshal(patch)
+    if err := json.Unmarshal(raw, b); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json: " + err.Error()})
+        return
+    }
+    // Identity and state are not patchable. With "If-Match: *" the version
+    // just read still guards against a concurrent update.
+    b.ID, b.KodeBarang, b.ArchivedAt = current.ID, current.KodeBarang, current.ArchivedAt
+    b.Version = current.Version
+    if version != 0 { b.Version = version }
+    hapusKategori := string(bytes.TrimSpace(patch["kategori_id"])) == "null"
+    h.saveBarang(w, r.WithContext(ctx), &current, b, hapusKategori)
+}
+
+// saveBarang validates and updates b, then responds with the stored barang
+// and its new ETag. before is the barang as read, for the audit log; an
+// omitted kategori_id is kept unless hapusKategori is set.
+func (h *BarangHandler) saveBarang(w http.ResponseWriter, r *http.Request, before, b *models.Barang, hapusKategori bool) {
+    if b.NamaBarang == "" || b.Satuan == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama_barang and satuan are required"})
+        return
+    }
+    ctx := r.Context()
+    uid, _ := middleware.UserIDFromContext(ctx)
+    if err := h.Repo.Update(ctx, b, uid, hapusKategori); err != nil {
         if err == sql.ErrNoRows {
             WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
             return
         }
-        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
         return
     }
- 
-    updated, err := h.Repo.GetByID(ctx, id)
+
+    updated, err := h.Repo.GetByID(ctx, b.ID)
     if err != nil {
         WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
         return
     }
+    audit.Record(ctx, audit.Perubahan{Sebelum: before, Sesudah: updated})
+    w.Header().Set("ETag", barangETag(b.Version))
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: updated})
 }
 
 // DELETE /api/barang/{id}
+// Requires If-Match with the barang's ETag.
 func (h *BarangHandler) DeleteBarang(w http.ResponseWriter, r *http.Request) {
     idStr := chi.URLParam(r, "id")
     id, _ := strconv.ParseInt(idStr, 10, 64)
@@ -162,34 +295,134 @@ func (h *BarangHandler) DeleteBarang(w http.ResponseWriter, r *http.Request) {
         WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
         return
     }
+    version, ok := ifMatchVersion(w, r)
+    if !ok { return }
 
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
-    if err := h.Repo.Delete(ctx, id); err != nil {
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    files, err := h.Repo.GambarKeys(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.Delete(ctx, id, version); err != nil {
         if err == repositories.ErrBarangInUse {
-            WriteJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "Barang sudah dipakai di transaksi dan tidak dapat dihapus"})
+            WriteJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "Barang sudah dipakai di transaksi dan tidak dapat dihapus; arsipkan barang ini"})
             return
         }
         if err == sql.ErrNoRows {
             WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
             return
         }
-        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
         return
     }
+    hapusFile(ctx, h.Files, files)
+    audit.Record(ctx, audit.Perubahan{Sebelum: before})
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": id}})
 }
 
+// POST /api/barang/{id}/archive
+func (h *BarangHandler) Archive(w http.ResponseWriter, r *http.Request) {
+    h.setArsip(w, r, h.Repo.Archive, "archive", "archived")
+}
+
+// POST /api/barang/{id}/restore
+func (h *BarangHandler) Restore(w http.ResponseWriter, r *http.Request) {
+    h.setArsip(w, r, h.Repo.Restore, "restore", "restored")
+}
+
+func (h *BarangHandler) setArsip(w http.ResponseWriter, r *http.Request, fn func(context.Context, int64) error, aksi, msg string) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := fn(ctx, id); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    b, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if b == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: aksi, Sebelum: before, Sesudah: b})
+    w.Header().Set("ETag", barangETag(b.Version))
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: msg, Data: b})
+}
+
 // uses WriteJSON from response.go
 
-// GET /api/barang/stok
+// GET /api/barang/stok?archived=false|true|all
 func (h *BarangHandler) GetAllWithStok(w http.ResponseWriter, r *http.Request) {
+    arsip, ok := arsipFilter(w, r)
+    if !ok { return }
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    if format != "" {
+        h.exportBarangStok(w, r, format, arsip)
+        return
+    }
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
-    items, err := h.Repo.GetAllWithStok(ctx)
+    items, err := h.Repo.GetAllWithStok(ctx, arsip)
     if err != nil {
         WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
         return
     }
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: items})
 }
+
+var barangColumns = []spreadsheet.Column{
+    {Header: "Kode Barang"}, {Header: "Nama Barang"}, {Header: "Kategori"}, {Header: "Satuan"}, {Header: "Deskripsi"},
+    {Header: "Harga Beli", Kind: spreadsheet.Money}, {Header: "Harga Jual", Kind: spreadsheet.Money},
+    {Header: "Kena Pajak"}, {Header: "Harga Termasuk Pajak"}, {Header: "Diarsipkan", Kind: spreadsheet.DateTime},
+}
+
+// exportBarang streams every barang matching the GetAll filters, unpaged.
+func (h *BarangHandler) exportBarang(w http.ResponseWriter, r *http.Request, format, search string, kategoriID int64, arsip repositories.ArsipFilter) {
+    writeExport(w, r, format, "barang", barangColumns, func(ctx context.Context, row rowFunc) error {
+        return h.Repo.EachBarang(ctx, search, kategoriID, arsip, func(b models.Barang, kategori string) error {
+            var desc, diarsipkan any
+            if b.Deskripsi != nil { desc = *b.Deskripsi }
+            if b.ArchivedAt != nil { diarsipkan = *b.ArchivedAt }
+            return row(b.KodeBarang, b.NamaBarang, kategori, b.Satuan, desc, b.HargaBeli, b.HargaJual,
+                yaTidak(b.KenaPajak), yaTidak(b.HargaTermasukPajak), diarsipkan)
+        })
+    })
+}
+
+var barangStokColumns = []spreadsheet.Column{
+    {Header: "Kode Barang"}, {Header: "Nama Barang"}, {Header: "Satuan"},
+    {Header: "Harga Beli", Kind: spreadsheet.Money}, {Header: "Harga Jual", Kind: spreadsheet.Money},
+    {Header: "Stok", Kind: spreadsheet.Number},
+}
+
+func (h *BarangHandler) exportBarangStok(w http.ResponseWriter, r *http.Request, format string, arsip repositories.ArsipFilter) {
+    writeExport(w, r, format, "barang-stok", barangStokColumns, func(ctx context.Context, row rowFunc) error {
+        return h.Repo.EachWithStok(ctx, arsip, func(b models.BarangWithStok) error {
+            return row(b.KodeBarang, b.NamaBarang, b.Satuan, b.HargaBeli, b.HargaJual, b.StokAkhir)
+        })
+    })
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/jobs"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/repositories"
+	"warehouse/spreadsheet"
+)
+
+// maxImportSize caps an uploaded import file.
+const maxImportSize = 20 << 20
+
+// ImportHandler imports master barang and opening stock from CSV/XLSX
+// files. Files with more than AsyncRows rows are processed as a background job.
+type ImportHandler struct {
+    BarangRepo *repositories.BarangRepo
+    StokRepo   *repositories.StokRepo
+    Jobs       *jobs.Manager
+    AsyncRows  int
+}
+
+func NewImportHandler(barangRepo *repositories.BarangRepo, stokRepo *repositories.StokRepo, jm *jobs.Manager, asyncRows int) *ImportHandler {
+    if asyncRows <= 0 { asyncRows = 500 }
+    return &ImportHandler{BarangRepo: barangRepo, StokRepo: stokRepo, Jobs: jm, AsyncRows: asyncRows}
+}
+
+// readUpload reads the uploaded file (multipart field "file" or the raw
+// body) as a table. It writes the error response and returns false on failure.
+func readUpload(w http.ResponseWriter, r *http.Request) ([][]string, bool) {
+    var src io.Reader = r.Body
+    if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+        f, _, err := r.FormFile("file")
+        if err != nil {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "file is required"})
+            return nil, false
+        }
+        defer f.Close()
+        src = f
+    }
+    data, err := spreadsheet.ReadAll(src, maxImportSize)
+    if err != nil {
+        WriteJSON(w, http.StatusRequestEntityTooLarge, APIResponse{Success: false, Message: err.Error()})
+        return nil, false
+    }
+    table, err := spreadsheet.Read(data)
+    if err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: err.Error()})
+        return nil, false
+    }
+    if len(table) == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "file is empty"})
+        return nil, false
+    }
+    return table, true
+}
+
+// headerIndex maps known column names to their position in header. Names
+// are matched case-insensitively, with spaces read as underscores.
+func headerIndex(header, known, required []string) (map[string]int, error) {
+    col := make(map[string]int)
+    for i, name := range header {
+        name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+        name = strings.ReplaceAll(name, " ", "_")
+        for _, c := range known {
+            if name == c { col[c] = i }
+        }
+    }
+    for _, c := range required {
+        if _, ok := col[c]; !ok {
+            return nil, fmt.Errorf("missing column %s (columns: %s)", c, strings.Join(known, ", "))
+        }
+    }
+    return col, nil
+}
+
+// cellReader returns the trimmed value of a named column in cells.
+func cellReader(col map[string]int, cells []string) func(string) string {
+    return func(name string) string {
+        j, ok := col[name]
+        if !ok || j >= len(cells) { return "" }
+        return strings.TrimSpace(cells[j])
+    }
+}
+
+// importOptions reads ?best_effort= and ?async=.
+func importOptions(r *http.Request) (bestEffort, async bool) {
+    q := r.URL.Query()
+    bestEffort, _ = strconv.ParseBool(q.Get("best_effort"))
+    async, _ = strconv.ParseBool(q.Get("async"))
+    return bestEffort, async
+}
+
+// POST /api/barang/import?best_effort=false&async=false
+// Body: multipart/form-data with field "file", or the raw CSV/XLSX file.
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+    bestEffort, async := importOptions(r)
+    table, ok := readUpload(w, r)
+    if !ok { return }
+    rows, rowErrs, err := parseBarangRows(table)
+    if err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    total := len(rows) + len(rowErrs)
+    if total == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "file has no data rows"})
+        return
+    }
+
+    // Rows that fail validation never reach the database; all-or-nothing
+    // imports stop here.
+    if len(rowErrs) > 0 && !bestEffort {
+        rep := &models.ImportReport{Total: total, Gagal: len(rowErrs), Errors: rowErrs}
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "import rejected, nothing saved", Data: rep})
+        return
+    }
+
+    uid, _ := middleware.UserIDFromContext(r.Context())
+    run := func(ctx context.Context, progress func(int)) (*models.ImportReport, error) {
+        rep, err := h.BarangRepo.Import(ctx, rows, bestEffort, uid, func(n int) {
+            if progress != nil { progress(len(rowErrs) + n) }
+        })
+        if err != nil { return nil, err }
+        rep.Total = total
+        rep.Gagal += len(rowErrs)
+        rep.Errors = mergeImportErrors(rowErrs, rep.Errors)
+        return rep, nil
+    }
+
+    if async || total > h.AsyncRows {
+        job := h.Jobs.Start(r.Context(), "import_barang", uid, total, func(ctx context.Context, progress func(int)) (any, error) {
+            return run(ctx, progress)
+        })
+        w.Header().Set("Location", "/api/jobs/"+job.ID)
+        audit.Record(r.Context(), audit.Perubahan{Aksi: "import", Entitas: "barang", Sesudah: map[string]string{"job_id": job.ID}})
+        WriteJSON(w, http.StatusAccepted, APIResponse{Success: true, Message: "import started", Data: job})
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+    defer cancel()
+    rep, err := run(ctx, nil)
+    if err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if !rep.Disimpan {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "import rejected, nothing saved", Data: rep})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "import", Entitas: "barang", Sesudah: rep})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "imported", Data: rep})
+}
+
+// mergeImportErrors merges two lists that are each ordered by row.
+func mergeImportErrors(a, b []models.ImportError) []models.ImportError {
+    out := make([]models.ImportError, 0, len(a)+len(b))
+    for len(a) > 0 && len(b) > 0 {
+        if a[0].Baris <= b[0].Baris {
+            out, a = append(out, a[0]), a[1:]
+        } else {
+            out, b = append(out, b[0]), b[1:]
+        }
+    }
+    return append(append(out, a...), b...)
+}
+
+// importColumns are the recognised header names; nama_barang and satuan are required.
+var importColumns = []string{"kode_barang", "nama_barang", "deskripsi", "satuan", "harga_beli", "harga_jual", "kategori_id", "kena_pajak", "harga_termasuk_pajak"}
+
+// parseBarangRows maps a table with a header row to barang. Rows failing
+// validation are returned as errors; an unusable header is a fatal error.
+func parseBarangRows(table [][]string) ([]models.BarangImport, []models.ImportError, error) {
+    col, err := headerIndex(table[0], importColumns, []string{"nama_barang", "satuan"})
+    if err != nil { return nil, nil, err }
+
+    rows := make([]models.BarangImport, 0, len(table)-1)
+    errs := make([]models.ImportError, 0)
+    seen := make(map[string]int)
+    for i, cells := range table[1:] {
+        baris := i + 2
+        if isBlankRow(cells) { continue }
+        b, msgs := parseBarangRow(cellReader(col, cells))
+        if b.KodeBarang != "" {
+            key := strings.ToUpper(b.KodeBarang)
+            if prev, dup := seen[key]; dup {
+                msgs = append(msgs, fmt.Sprintf("kode_barang duplicates row %d", prev))
+            } else {
+                seen[key] = baris
+            }
+        }
+        if len(msgs) > 0 {
+            errs = append(errs, models.ImportError{Baris: baris, KodeBarang: b.KodeBarang, Pesan: strings.Join(msgs, "; ")})
+            continue
+        }
+        rows = append(rows, models.BarangImport{Baris: baris, Barang: b})
+    }
+    return rows, errs, nil
+}
+
+func isBlankRow(cells []string) bool {
+    for _, c := range cells {
+        if strings.TrimSpace(c) != "" { return false }
+    }
+    return true
+}
+
+func parseBarangRow(cell func(string) string) (models.Barang, []string) {
+    var msgs []string
+    b := models.Barang{KodeBarang: cell("kode_barang"), NamaBarang: cell("nama_barang"), Satuan: cell("satuan")}
+    if b.NamaBarang == "" { msgs = append(msgs, "nama_barang is required") }
+    if b.Satuan == "" { msgs = append(msgs, "satuan is required") }
+    if d := cell("deskripsi"); d != "" { b.Deskripsi = &d }
+    for _, p := range []struct {
+        name string
+        dst  *int64
+    }{{"harga_beli", &b.HargaBeli}, {"harga_jual", &b.HargaJual}} {
+        v, err := parseRupiah(cell(p.name))
+        if err != nil {
+            msgs = append(msgs, p.name+": "+err.Error())
+            continue
+        }
+        *p.dst = v
+    }
+    if s := cell("kategori_id"); s != "" {
+        id, err := strconv.ParseInt(s, 10, 64)
+        if err != nil || id <= 0 {
+            msgs = append(msgs, "kategori_id must be a positive number")
+        } else {
+            b.KategoriID = &id
+        }
+    }
+    for _, f := range []struct {
+        name string
+        dst  **bool
+    }{{"kena_pajak", &b.KenaPajak}, {"harga_termasuk_pajak", &b.HargaTermasukPajak}} {
+        s := cell(f.name)
+        if s == "" { continue }
+        v, ok := parseYaTidak(s)
+        if !ok {
+            msgs = append(msgs, f.name+" must be ya/tidak")
+            continue
+        }
+        *f.dst = &v
+    }
+    return b, msgs
+}
+
+var ribuanPattern = regexp.MustCompile(`^\d{1,3}(\.\d{3})+$`)
+
+// parseRupiah accepts "15000", "15.000", "Rp 15.000" and spreadsheet numbers
+// such as "15000.0" or "1.5E4". Empty is 0; negative and fractional amounts
+// are rejected.
+func parseRupiah(s string) (int64, error) {
+    s = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(s, "Rp"), "."))
+    s = strings.ReplaceAll(s, " ", "")
+    if s == "" { return 0, nil }
+    if ribuanPattern.MatchString(s) {
+        s = strings.ReplaceAll(s, ".", "")
+    }
+    if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+        if n < 0 { return 0, fmt.Errorf("must be >= 0") }
+        return n, nil
+    }
+    f, err := strconv.ParseFloat(s, 64)
+    if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+    if f < 0 { return 0, fmt.Errorf("must be >= 0") }
+    if f != math.Trunc(f) || f > math.MaxInt64/2 {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+    return int64(f), nil
+}
+
+func parseYaTidak(s string) (bool, bool) {
+    switch strings.ToLower(s) {
+    case "ya", "y", "true", "1", "yes":
+        return true, true
+    case "tidak", "t", "n", "false", "0", "no":
+        return false, true
+    }
+    return false, false
+}
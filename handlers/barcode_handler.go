@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BarcodeHandler provides HTTP handlers for barang barcodes and scanning.
+type BarcodeHandler struct {
+    Repo *repositories.BarcodeRepo
+}
+
+func NewBarcodeHandler(repo *repositories.BarcodeRepo) *BarcodeHandler {
+    return &BarcodeHandler{Repo: repo}
+}
+
+// GET /api/barang/scan/{barcode}
+func (h *BarcodeHandler) Scan(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    item, err := h.Repo.Scan(ctx, chi.URLParam(r, "barcode"))
+    if err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if item == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: item})
+}
+
+// GET /api/barang/{id}/barcode
+func (h *BarcodeHandler) List(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.ListByBarang(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// POST /api/barang/{id}/barcode
+// Body: {"barcode":"8991234567891","satuan":"dus","isi":12}
+func (h *BarcodeHandler) Create(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var b models.BarangBarcode
+    if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    b.BarangID = id
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Create(ctx, &b); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "barcode", EntitasID: b.ID, Sesudah: b})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: b})
+}
+
+// DELETE /api/barang/{id}/barcode/{barcode_id}
+func (h *BarcodeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    bcID, _ := strconv.ParseInt(chi.URLParam(r, "barcode_id"), 10, 64)
+    if id <= 0 || bcID <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Delete(ctx, id, bcID); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "barcode", EntitasID: bcID})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": bcID}})
+}
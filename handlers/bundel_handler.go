@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BundelHandler serves bundle definitions and assembly orders (rakit).
+type BundelHandler struct {
+    Repo *repositories.BundelRepo
+}
+
+func NewBundelHandler(repo *repositories.BundelRepo) *BundelHandler { return &BundelHandler{Repo: repo} }
+
+type bundelRequest struct {
+    Virtual  bool                    `json:"virtual"`
+    Komponen []models.KomponenBundel `json:"komponen"`
+}
+
+// GET /api/bundel lists every barang with components and how many can be
+// sold or assembled from component stock.
+func (h *BundelHandler) List(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.ListBundel(ctx)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// GET /api/barang/{id}/bundel
+func (h *BundelHandler) Get(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    b, err := h.Repo.GetBundel(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if b == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not a bundle"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: b})
+}
+
+// PUT /api/barang/{id}/bundel replaces the components of a barang, e.g.
+// {"virtual": true, "komponen": [{"barang_id": 3, "qty": 2}]}.
+func (h *BundelHandler) Set(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var req bundelRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetBundel(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.SetBundel(ctx, &models.Bundel{BarangID: id, Virtual: req.Virtual, Komponen: req.Komponen}); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    b, err := h.Repo.GetBundel(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "bundel", Sebelum: before, Sesudah: b})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: b})
+}
+
+// DELETE /api/barang/{id}/bundel turns a bundle back into a normal barang.
+func (h *BundelHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetBundel(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.DeleteBundel(ctx, id); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not a bundle"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "bundel", Sebelum: before})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": id}})
+}
+
+// POST /api/rakit assembles qty units of a kit from its components.
+func (h *BundelHandler) Rakit(w http.ResponseWriter, r *http.Request) {
+    var hdr models.RakitHeader
+    if err := json.NewDecoder(r.Body).Decode(&hdr); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if hdr.BarangID <= 0 || hdr.Qty <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "barang_id and qty are required"})
+        return
+    }
+    if uid, ok := middleware.UserIDFromContext(r.Context()); ok {
+        hdr.UserID = uid
+    } else {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    if err := h.Repo.Rakit(ctx, &hdr); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{EntitasID: hdr.ID, Sesudah: hdr})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: hdr})
+}
+
+// GET /api/rakit?from=YYYY-MM-DD&to=YYYY-MM-DD&page=&limit=
+func (h *BundelHandler) ListRakit(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    page, _ := strconv.Atoi(q.Get("page"))
+    limit, _ := strconv.Atoi(q.Get("limit"))
+    if page <= 0 { page = 1 }
+    if limit <= 0 { limit = 10 }
+    var fromPtr, toPtr *time.Time
+    if fs := q.Get("from"); fs != "" {
+        if t, err := time.Parse("2006-01-02", fs); err == nil { fromPtr = &t }
+    }
+    if ts := q.Get("to"); ts != "" {
+        if t, err := time.Parse("2006-01-02", ts); err == nil {
+            t2 := t.Add(24*time.Hour - time.Nanosecond)
+            toPtr = &t2
+        }
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, total, err := h.Repo.ListRakit(ctx, fromPtr, toPtr, page, limit)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list, Meta: &Meta{Page: page, Limit: limit, Total: total}})
+}
+
+// GET /api/rakit/{id}
+func (h *BundelHandler) GetRakit(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    hdr, err := h.Repo.GetRakit(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if hdr == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: hdr})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"warehouse/invoice"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DokumenHandler renders printable PDF documents for transactions.
+type DokumenHandler struct {
+    PenjualanRepo *repositories.PenjualanRepo
+    PembelianRepo *repositories.PembelianRepo
+    Renderer      *invoice.Renderer
+}
+
+func NewDokumenHandler(penjualan *repositories.PenjualanRepo, pembelian *repositories.PembelianRepo, renderer *invoice.Renderer) *DokumenHandler {
+    return &DokumenHandler{PenjualanRepo: penjualan, PembelianRepo: pembelian, Renderer: renderer}
+}
+
+// GET /api/penjualan/{id}/pdf?jenis=faktur|surat_jalan
+func (h *DokumenHandler) Penjualan(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    tmpl := invoice.Penjualan
+    switch r.URL.Query().Get("jenis") {
+    case "", "faktur":
+    case "surat_jalan":
+        tmpl = invoice.SuratJalan
+    default:
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "jenis must be faktur or surat_jalan"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    hdr, err := h.PenjualanRepo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if hdr == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    h.write(w, tmpl, hdr, hdr.NoFaktur, namaKasir(hdr.UserDetail))
+}
+
+// GET /api/pembelian/{id}/pdf
+func (h *DokumenHandler) Pembelian(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    hdr, err := h.PembelianRepo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if hdr == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    h.write(w, invoice.Pembelian, hdr, hdr.NoFaktur, namaKasir(hdr.UserDetail))
+}
+
+func (h *DokumenHandler) write(w http.ResponseWriter, tmpl string, faktur any, noFaktur, kasir string) {
+    var buf bytes.Buffer
+    if err := h.Renderer.Render(&buf, tmpl, faktur, kasir); err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    w.Header().Set("Content-Type", "application/pdf")
+    w.Header().Set("Content-Disposition", `inline; filename="`+tmpl+"-"+noFaktur+`.pdf"`)
+    w.WriteHeader(http.StatusOK)
+    _, _ = w.Write(buf.Bytes())
+}
+
+// namaKasir prefers the user's full name and falls back to the username.
+func namaKasir(u *models.User) string {
+    if u == nil { return "" }
+    if u.FullName != "" { return u.FullName }
+    return u.Username
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"warehouse/spreadsheet"
+)
+
+// exportTimeout bounds a file export; exports read whole tables, so they
+// get more time than the JSON list they mirror.
+const exportTimeout = 60 * time.Second
+
+// exportFormat returns the file format a list request asks for: ?format=
+// (json, csv or xlsx) wins over the Accept header, and "" means the usual
+// JSON response. An unknown ?format= writes a 422 and returns false.
+func exportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+    switch f := strings.ToLower(r.URL.Query().Get("format")); f {
+    case spreadsheet.CSV, spreadsheet.XLSX:
+        return f, true
+    case "json":
+        return "", true
+    case "":
+    default:
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "format must be json, csv or xlsx"})
+        return "", false
+    }
+    for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+        mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+        if err != nil { continue }
+        switch mt {
+        case "text/csv":
+            return spreadsheet.CSV, true
+        case spreadsheet.ContentType(spreadsheet.XLSX):
+            return spreadsheet.XLSX, true
+        case "application/json":
+            return "", true
+        }
+    }
+    return "", true
+}
+
+// rowFunc writes one row of an export.
+type rowFunc func(values ...any) error
+
+// writeExport streams the rows produced by each as a file download named
+// name-YYYYMMDD.<format>. The response starts with the first row, so a
+// failure before any row is still reported as JSON; a failure mid-stream
+// can only be logged, and truncates the file.
+func writeExport(w http.ResponseWriter, r *http.Request, format, name string, cols []spreadsheet.Column, each func(ctx context.Context, row rowFunc) error) {
+    ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+    defer cancel()
+
+    var (
+        sw      spreadsheet.Writer
+        started bool
+    )
+    start := func() error {
+        started = true
+        filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+        w.Header().Set("Content-Type", spreadsheet.ContentType(format))
+        w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+        w.WriteHeader(http.StatusOK)
+        var err error
+        sw, err = spreadsheet.NewWriter(w, format, cols)
+        return err
+    }
+    err := each(ctx, func(values ...any) error {
+        if !started {
+            if err := start(); err != nil { return err }
+        }
+        return sw.WriteRow(values...)
+    })
+    if err != nil && !started {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err == nil && !started {
+        err = start()
+    }
+    if sw != nil {
+        if cerr := sw.Close(); err == nil { err = cerr }
+    }
+    if err != nil {
+        log.Printf("export %s: %v", name, err)
+    }
+}
+
+// yaTidak presents a flag in exports.
+func yaTidak(b *bool) string {
+    if b != nil && *b { return "Ya" }
+    return "Tidak"
+}
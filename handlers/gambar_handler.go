@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/gambar"
+	"warehouse/repositories"
+	"warehouse/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GambarHandler uploads product images and serves the public image files.
+type GambarHandler struct {
+    Repo      *repositories.BarangRepo
+    Files     storage.Storage
+    MaxBytes  int64 // largest accepted upload
+    ThumbSize int   // thumbnail bounding box in pixels
+}
+
+func NewGambarHandler(repo *repositories.BarangRepo, files storage.Storage, maxBytes int64, thumbSize int) *GambarHandler {
+    if maxBytes <= 0 { maxBytes = 5 << 20 }
+    if thumbSize <= 0 { thumbSize = 256 }
+    return &GambarHandler{Repo: repo, Files: files, MaxBytes: maxBytes, ThumbSize: thumbSize}
+}
+
+// readFile reads the multipart field "file" of at most max bytes and
+// returns its content and client file name. It writes the error response
+// and returns false on failure.
+func readFile(w http.ResponseWriter, r *http.Request, max int64) ([]byte, string, bool) {
+    // Leave room for the multipart framing around the file.
+    r.Body = http.MaxBytesReader(w, r.Body, max+1<<20)
+    f, hdr, err := r.FormFile("file")
+    if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            WriteJSON(w, http.StatusRequestEntityTooLarge, APIResponse{Success: false, Message: fmt.Sprintf("file exceeds %d bytes", max)})
+            return nil, "", false
+        }
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "file is required"})
+        return nil, "", false
+    }
+    defer f.Close()
+    data, err := io.ReadAll(io.LimitReader(f, max+1))
+    if err != nil {
+        WriteJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: err.Error()})
+        return nil, "", false
+    }
+    if int64(len(data)) > max {
+        WriteJSON(w, http.StatusRequestEntityTooLarge, APIResponse{Success: false, Message: fmt.Sprintf("file exceeds %d bytes", max)})
+        return nil, "", false
+    }
+    if len(data) == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "file is empty"})
+        return nil, "", false
+    }
+    return data, hdr.Filename, true
+}
+
+// fileKey returns a new, unguessable storage key below dir.
+func fileKey(dir, suffix string) string {
+    b := make([]byte, 16)
+    _, _ = rand.Read(b)
+    return dir + "/" + hex.EncodeToString(b) + suffix
+}
+
+// hapusFile deletes files that are no longer referenced. Failures only
+// leave orphaned files behind, so they are logged and not reported.
+func hapusFile(ctx context.Context, files storage.Storage, keys []string) {
+    for _, k := range keys {
+        if err := files.Delete(ctx, k); err != nil {
+            log.Printf("storage: delete %s: %v", k, err)
+        }
+    }
+}
+
+// PUT /api/barang/{id}/gambar (multipart field "file", JPEG/PNG/GIF) sets
+// the product image and generates its thumbnail, replacing any previous image.
+func (h *GambarHandler) Upload(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    data, _, ok := readFile(w, r, h.MaxBytes)
+    if !ok { return }
+    ct, err := gambar.Periksa(data)
+    if err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    thumb, err := gambar.Thumbnail(data, h.ThumbSize)
+    if err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if before == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    dir := "barang/" + strconv.FormatInt(id, 10)
+    gambarKey, thumbKey := fileKey(dir, gambar.Types[ct]), fileKey(dir, "_thumb.jpg")
+    if err := h.Files.Put(ctx, gambarKey, data, ct); err != nil {
+        WriteJSON(w, http.StatusBadGateway, APIResponse{Success: false, Message: "storing image: " + err.Error()})
+        return
+    }
+    if err := h.Files.Put(ctx, thumbKey, thumb, "image/jpeg"); err != nil {
+        hapusFile(ctx, h.Files, []string{gambarKey})
+        WriteJSON(w, http.StatusBadGateway, APIResponse{Success: false, Message: "storing thumbnail: " + err.Error()})
+        return
+    }
+    old, err := h.Repo.SetGambar(ctx, id, &gambarKey, &thumbKey)
+    if err != nil {
+        hapusFile(ctx, h.Files, []string{gambarKey, thumbKey})
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    hapusFile(ctx, h.Files, old)
+    h.respond(ctx, w, id, "upload_image", before, "uploaded")
+}
+
+// DELETE /api/barang/{id}/gambar removes the product image.
+func (h *GambarHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if before == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    old, err := h.Repo.SetGambar(ctx, id, nil, nil)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    hapusFile(ctx, h.Files, old)
+    h.respond(ctx, w, id, "delete_image", before, "deleted")
+}
+
+// respond records the image change and writes the updated barang.
+func (h *GambarHandler) respond(ctx context.Context, w http.ResponseWriter, id int64, aksi string, before any, msg string) {
+    b, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if b == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: aksi, Entitas: "barang", Sebelum: before, Sesudah: b})
+    w.Header().Set("ETag", barangETag(b.Version))
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: msg, Data: b})
+}
+
+// GET /files/barang/* serves product images without authentication so they
+// can be used directly in <img> tags. Keys are random and never reused, so
+// the files may be cached forever.
+func (h *GambarHandler) File(w http.ResponseWriter, r *http.Request) {
+    key := "barang/" + chi.URLParam(r, "*")
+    ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+    defer cancel()
+    f, err := h.Files.Open(ctx, key)
+    if err != nil {
+        if err == storage.ErrNotFound || err == storage.ErrInvalidKey {
+            http.NotFound(w, r)
+            return
+        }
+        http.Error(w, "storage unavailable", http.StatusBadGateway)
+        return
+    }
+    defer f.Close()
+    if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+        w.Header().Set("Content-Type", ct)
+    }
+    w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+    _, _ = io.Copy(w, f)
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// HargaHandler provides HTTP handlers for price history, price lists and customer groups.
+type HargaHandler struct {
+    Repo *repositories.HargaRepo
+}
+
+func NewHargaHandler(repo *repositories.HargaRepo) *HargaHandler { return &HargaHandler{Repo: repo} }
+
+// GET /api/barang/{id}/harga
+func (h *HargaHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    page, _ := strconv.Atoi(q.Get("page"))
+    limit, _ := strconv.Atoi(q.Get("limit"))
+    if page <= 0 { page = 1 }
+    if limit <= 0 { limit = 10 }
+
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, total, err := h.Repo.GetHistory(ctx, id, page, limit)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list, Meta: &Meta{Page: page, Limit: limit, Total: total}})
+}
+
+// GET /api/daftar-harga
+func (h *HargaHandler) ListDaftarHarga(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.ListDaftarHarga(ctx)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// GET /api/daftar-harga/{id}
+func (h *HargaHandler) GetDaftarHarga(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    d, err := h.Repo.GetDaftarHarga(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if d == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: d})
+}
+
+// POST /api/daftar-harga
+func (h *HargaHandler) CreateDaftarHarga(w http.ResponseWriter, r *http.Request) {
+    var d models.DaftarHarga
+    if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if d.Nama == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama is required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.CreateDaftarHarga(ctx, &d); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{EntitasID: d.ID, Sesudah: d})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: d})
+}
+
+// DELETE /api/daftar-harga/{id}
+func (h *HargaHandler) DeleteDaftarHarga(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.DeleteDaftarHarga(ctx, id); err != nil {
+        if err == repositories.ErrDaftarHargaInUse {
+            WriteJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "Daftar harga masih dipakai grup pelanggan dan tidak dapat dihapus"})
+            return
+        }
+        if err == repositories.ErrDaftarHargaTerpakai {
+            WriteJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "Daftar harga sudah dipakai pada penjualan dan tidak dapat dihapus"})
+            return
+        }
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": id}})
+}
+
+// PUT /api/daftar-harga/{id}/item
+// Body: [{"barang_id": 1, "min_qty": 1, "harga": 1000}, {"barang_id": 1, "min_qty": 12, "harga": 900}]
+func (h *HargaHandler) UpsertItems(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var items []models.DaftarHargaItem
+    if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if len(items) == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "items required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    if err := h.Repo.UpsertItems(ctx, id, items); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: items})
+}
+
+// DELETE /api/daftar-harga/{id}/item/{item_id}
+func (h *HargaHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    itemID, _ := strconv.ParseInt(chi.URLParam(r, "item_id"), 10, 64)
+    if id <= 0 || itemID <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.DeleteItem(ctx, id, itemID); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": itemID}})
+}
+
+// GET /api/grup-pelanggan
+func (h *HargaHandler) ListGrup(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.ListGrup(ctx)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// POST /api/grup-pelanggan
+func (h *HargaHandler) CreateGrup(w http.ResponseWriter, r *http.Request) {
+    var g models.GrupPelanggan
+    if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if g.Nama == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama is required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.CreateGrup(ctx, &g); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{EntitasID: g.ID, Sesudah: g})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: g})
+}
+
+// PUT /api/grup-pelanggan/{id}
+func (h *HargaHandler) UpdateGrup(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var g models.GrupPelanggan
+    if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    g.ID = id
+    if g.Nama == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama is required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.UpdateGrup(ctx, &g); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: g})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// JadwalHargaHandler provides HTTP handlers for scheduled price changes.
+type JadwalHargaHandler struct {
+    Repo *repositories.JadwalHargaRepo
+}
+
+func NewJadwalHargaHandler(repo *repositories.JadwalHargaRepo) *JadwalHargaHandler {
+    return &JadwalHargaHandler{Repo: repo}
+}
+
+// GET /api/jadwal-harga?status=terjadwal,aktif&barang_id=
+func (h *JadwalHargaHandler) List(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    var statuses []string
+    if s := q.Get("status"); s != "" {
+        statuses = strings.Split(s, ",")
+    }
+    barangID, _ := strconv.ParseInt(q.Get("barang_id"), 10, 64)
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.List(ctx, statuses, barangID)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// POST /api/jadwal-harga
+// Body: {"barang_id":1,"harga_jual":250000,"mulai":"2026-11-01T00:00:00+07:00","selesai":"2026-11-08T00:00:00+07:00"}
+// With "kategori_id" instead of "barang_id" one schedule is created per barang in the kategori.
+func (h *JadwalHargaHandler) Create(w http.ResponseWriter, r *http.Request) {
+    var j models.JadwalHarga
+    if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    perKategori := j.KategoriID != nil && *j.KategoriID > 0
+    if (j.BarangID <= 0) == !perKategori || j.Mulai.IsZero() {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "mulai and either barang_id or kategori_id are required"})
+        return
+    }
+    if uid, ok := middleware.UserIDFromContext(r.Context()); ok {
+        j.UserID = uid
+    } else {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if perKategori {
+        list, err := h.Repo.CreateForKategori(ctx, j, *j.KategoriID)
+        if err != nil {
+            WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        audit.Record(ctx, audit.Perubahan{Sesudah: list})
+        WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: list})
+        return
+    }
+    j.KategoriID = nil
+    if err := h.Repo.Create(ctx, &j); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{EntitasID: j.ID, Sesudah: j})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: j})
+}
+
+// DELETE /api/jadwal-harga/{id}
+func (h *JadwalHargaHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Cancel(ctx, id); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "cancelled", Data: map[string]int64{"id": id}})
+}
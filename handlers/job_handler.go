@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"warehouse/jobs"
+	"warehouse/middleware"
+	"warehouse/tenant"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// JobHandler exposes the progress of background jobs.
+type JobHandler struct {
+    Jobs *jobs.Manager
+}
+
+func NewJobHandler(jm *jobs.Manager) *JobHandler {
+    return &JobHandler{Jobs: jm}
+}
+
+// GET /api/jobs/{id} returns status, progress (diproses of total) and, once
+// finished, the result. Only the user who started a job, or an admin, can see
+// it, and only in the tenant it was started in.
+func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
+    job, ok := h.Jobs.Get(chi.URLParam(r, "id"))
+    uid, _ := middleware.UserIDFromContext(r.Context())
+    role, _ := middleware.RoleFromContext(r.Context())
+    tid, _ := tenant.FromContext(r.Context())
+    if !ok || job.TenantID != tid || (job.UserID != uid && role != "admin") {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: job})
+}
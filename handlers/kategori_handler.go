@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// KategoriHandler provides HTTP handlers for the barang category tree.
+type KategoriHandler struct {
+    Repo *repositories.KategoriRepo
+}
+
+func NewKategoriHandler(repo *repositories.KategoriRepo) *KategoriHandler {
+    return &KategoriHandler{Repo: repo}
+}
+
+// GET /api/kategori?tree=true
+func (h *KategoriHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+    tree, _ := strconv.ParseBool(r.URL.Query().Get("tree"))
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    var (
+        list []models.Kategori
+        err  error
+    )
+    if tree {
+        list, err = h.Repo.Tree(ctx)
+    } else {
+        list, err = h.Repo.GetAll(ctx)
+    }
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// GET /api/kategori/{id}
+func (h *KategoriHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    k, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if k == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: k})
+}
+
+// POST /api/kategori
+// Body: {"nama":"Minuman","parent_id":1}
+func (h *KategoriHandler) Create(w http.ResponseWriter, r *http.Request) {
+    var k models.Kategori
+    if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    k.Nama = strings.TrimSpace(k.Nama)
+    if k.Nama == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama is required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Create(ctx, &k); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{EntitasID: k.ID, Sesudah: k})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: k})
+}
+
+// PUT /api/kategori/{id} renames and/or moves a kategori.
+func (h *KategoriHandler) Update(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var k models.Kategori
+    if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    k.ID = id
+    k.Nama = strings.TrimSpace(k.Nama)
+    if k.Nama == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama is required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.Update(ctx, &k); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Sebelum: before, Sesudah: k})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: k})
+}
+
+// DELETE /api/kategori/{id}
+func (h *KategoriHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.Delete(ctx, id); err != nil {
+        if err == repositories.ErrKategoriInUse {
+            WriteJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "Kategori masih memiliki barang atau sub-kategori dan tidak dapat dihapus"})
+            return
+        }
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Sebelum: before})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": id}})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/label"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxLabels caps one bulk label request.
+const maxLabels = 500
+
+// LabelHandler renders printable barcode/QR shelf labels.
+type LabelHandler struct {
+    Repo *repositories.BarcodeRepo
+}
+
+func NewLabelHandler(repo *repositories.BarcodeRepo) *LabelHandler {
+    return &LabelHandler{Repo: repo}
+}
+
+// GET /api/barang/{id}/label?format=png|pdf|zpl&jumlah=1
+func (h *LabelHandler) Single(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    h.render(w, r, []int64{id})
+}
+
+// GET /api/barang/label?ids=1,2,3&format=pdf&jumlah=2
+func (h *LabelHandler) Bulk(w http.ResponseWriter, r *http.Request) {
+    var ids []int64
+    for _, s := range strings.Split(r.URL.Query().Get("ids"), ",") {
+        if s = strings.TrimSpace(s); s == "" { continue }
+        id, err := strconv.ParseInt(s, 10, 64)
+        if err != nil || id <= 0 {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id " + s})
+            return
+        }
+        ids = append(ids, id)
+    }
+    if len(ids) == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "ids required"})
+        return
+    }
+    h.render(w, r, ids)
+}
+
+func (h *LabelHandler) render(w http.ResponseWriter, r *http.Request, ids []int64) {
+    q := r.URL.Query()
+    f := q.Get("format")
+    if f == "" { f = label.FormatPNG }
+    if f != label.FormatPNG && f != label.FormatPDF && f != label.FormatZPL {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: label.ErrFormat.Error()})
+        return
+    }
+    jumlah, _ := strconv.Atoi(q.Get("jumlah"))
+    if jumlah <= 0 { jumlah = 1 }
+    if len(ids)*jumlah > maxLabels {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "too many labels, max " + strconv.Itoa(maxLabels)})
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    data, err := h.Repo.LabelData(ctx, ids)
+    if err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    items := make([]label.Item, len(data))
+    for i, d := range data {
+        items[i] = label.Item{Kode: d.KodeBarang, Nama: d.NamaBarang, Harga: d.HargaJual, Barcode: d.Barcode}
+    }
+
+    var buf bytes.Buffer
+    if err := label.Render(&buf, f, items, jumlah); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    name := "label"
+    if len(data) == 1 { name += "-" + data[0].KodeBarang }
+    w.Header().Set("Content-Type", label.ContentType(f))
+    w.Header().Set("Content-Disposition", `inline; filename="`+name+"."+f+`"`)
+    w.WriteHeader(http.StatusOK)
+    _, _ = w.Write(buf.Bytes())
+}
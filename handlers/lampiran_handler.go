@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/repositories"
+	"warehouse/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// lampiranTypes maps the accepted attachment types to their file extension.
+var lampiranTypes = map[string]string{
+    "application/pdf": ".pdf",
+    "image/jpeg":      ".jpg",
+    "image/png":       ".png",
+}
+
+// LampiranHandler stores documents attached to pembelian and penjualan,
+// e.g. scanned supplier invoices.
+type LampiranHandler struct {
+    Repo     *repositories.LampiranRepo
+    Files    storage.Storage
+    MaxBytes int64
+}
+
+func NewLampiranHandler(repo *repositories.LampiranRepo, files storage.Storage, maxBytes int64) *LampiranHandler {
+    if maxBytes <= 0 { maxBytes = 10 << 20 }
+    return &LampiranHandler{Repo: repo, Files: files, MaxBytes: maxBytes}
+}
+
+func lampiranURL(l *models.Lampiran) {
+    l.URL = "/api/lampiran/" + strconv.FormatInt(l.ID, 10)
+}
+
+// Pembelian handles POST /api/pembelian/{id}/lampiran.
+func (h *LampiranHandler) Pembelian(w http.ResponseWriter, r *http.Request) { h.upload(w, r, "pembelian") }
+
+// Penjualan handles POST /api/penjualan/{id}/lampiran.
+func (h *LampiranHandler) Penjualan(w http.ResponseWriter, r *http.Request) { h.upload(w, r, "penjualan") }
+
+// ListPembelian handles GET /api/pembelian/{id}/lampiran.
+func (h *LampiranHandler) ListPembelian(w http.ResponseWriter, r *http.Request) { h.list(w, r, "pembelian") }
+
+// ListPenjualan handles GET /api/penjualan/{id}/lampiran.
+func (h *LampiranHandler) ListPenjualan(w http.ResponseWriter, r *http.Request) { h.list(w, r, "penjualan") }
+
+// upload stores the multipart field "file" (PDF, JPEG or PNG) and attaches
+// it to the transaction.
+func (h *LampiranHandler) upload(w http.ResponseWriter, r *http.Request, entitas string) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    uid, ok := middleware.UserIDFromContext(r.Context())
+    if !ok {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    data, nama, ok := readFile(w, r, h.MaxBytes)
+    if !ok { return }
+    ct := http.DetectContentType(data)
+    ext, ok := lampiranTypes[ct]
+    if !ok {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "file must be PDF, JPEG or PNG"})
+        return
+    }
+    l := models.Lampiran{
+        Entitas:     entitas,
+        EntitasID:   id,
+        NamaFile:    namaLampiran(nama, ext),
+        ContentType: ct,
+        Ukuran:      int64(len(data)),
+        StorageKey:  fileKey("lampiran/"+entitas+"/"+strconv.FormatInt(id, 10), ext),
+        UserID:      uid,
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+    defer cancel()
+    if err := h.Files.Put(ctx, l.StorageKey, data, ct); err != nil {
+        WriteJSON(w, http.StatusBadGateway, APIResponse{Success: false, Message: "storing file: " + err.Error()})
+        return
+    }
+    if err := h.Repo.Create(ctx, &l); err != nil {
+        hapusFile(ctx, h.Files, []string{l.StorageKey})
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: entitas + " not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    lampiranURL(&l)
+    audit.Record(ctx, audit.Perubahan{Aksi: "attach", Entitas: entitas, EntitasID: id, Sesudah: l})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: l})
+}
+
+// namaLampiran cleans the client file name for storage and downloads,
+// falling back to "lampiran" plus the detected extension.
+func namaLampiran(nama, ext string) string {
+    nama = strings.TrimSpace(filepath.Base(strings.ReplaceAll(nama, `\`, "/")))
+    nama = strings.Map(func(r rune) rune {
+        if r < 0x20 || r == '"' || r == 0x7f { return -1 }
+        return r
+    }, nama)
+    if nama == "" || nama == "." || nama == "/" { nama = "lampiran" + ext }
+    if len(nama) > 255 { nama = strings.ToValidUTF8(nama[:255], "") }
+    return nama
+}
+
+func (h *LampiranHandler) list(w http.ResponseWriter, r *http.Request, entitas string) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.List(ctx, entitas, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    for i := range list { lampiranURL(&list[i]) }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// GET /api/lampiran/{id} downloads the attachment. The caller needs the
+// view permission of the transaction it belongs to.
+func (h *LampiranHandler) Download(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+    defer cancel()
+    l, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if l == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    if !middleware.HasPermission(r.Context(), l.Entitas+".view") {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "forbidden: requires permission " + l.Entitas + ".view"})
+        return
+    }
+    f, err := h.Files.Open(ctx, l.StorageKey)
+    if err != nil {
+        if err == storage.ErrNotFound {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "file not found"})
+            return
+        }
+        WriteJSON(w, http.StatusBadGateway, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    defer f.Close()
+    w.Header().Set("Content-Type", l.ContentType)
+    w.Header().Set("Content-Length", strconv.FormatInt(l.Ukuran, 10))
+    w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": l.NamaFile}))
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+    _, _ = io.Copy(w, f)
+}
+
+// DELETE /api/lampiran/{id}
+func (h *LampiranHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    l, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if l == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    if err := h.Repo.Delete(ctx, id); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    hapusFile(ctx, h.Files, []string{l.StorageKey})
+    audit.Record(ctx, audit.Perubahan{Sebelum: l})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": id}})
+}
@@ -5,23 +5,63 @@ import (
 	"net/http"
 	"time"
 
+	"warehouse/format"
+	"warehouse/models"
 	"warehouse/repositories"
+	"warehouse/spreadsheet"
 )
 
 type LaporanHandler struct {
     StokRepo       *repositories.StokRepo
     PenjualanRepo  *repositories.PenjualanRepo
     PembelianRepo  *repositories.PembelianRepo
+    PajakRepo      *repositories.PajakRepo
+    // Lokasi is the time zone report dates and months are read in.
+    Lokasi         *time.Location
 }
 
-func NewLaporanHandler(s *repositories.StokRepo, pj *repositories.PenjualanRepo, pb *repositories.PembelianRepo) *LaporanHandler {
-    return &LaporanHandler{StokRepo: s, PenjualanRepo: pj, PembelianRepo: pb}
+func NewLaporanHandler(s *repositories.StokRepo, pj *repositories.PenjualanRepo, pb *repositories.PembelianRepo, pk *repositories.PajakRepo, loc *time.Location) *LaporanHandler {
+    return &LaporanHandler{StokRepo: s, PenjualanRepo: pj, PembelianRepo: pb, PajakRepo: pk, Lokasi: loc}
 }
 
-// GET /api/laporan/stok
+// GET /api/laporan/stok?group_by=kategori|induk&format=csv|xlsx
 func (h *LaporanHandler) LaporanStok(w http.ResponseWriter, r *http.Request) {
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    groupBy := r.URL.Query().Get("group_by")
+    perKategori := groupBy == "kategori"
+    if format != "" && !perKategori && groupBy != "induk" {
+        exportStok(w, r, format, h.StokRepo)
+        return
+    }
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
+    if groupBy == "induk" {
+        list, err := h.StokRepo.GetStokPerInduk(ctx)
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        if format != "" {
+            exportStokInduk(w, r, format, list)
+            return
+        }
+        WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Laporan stok per barang induk", Data: list})
+        return
+    }
+    if perKategori {
+        list, err := h.StokRepo.GetStokPerKategori(ctx)
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        if format != "" {
+            exportStokKategori(w, r, format, list)
+            return
+        }
+        WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Laporan stok per kategori", Data: list})
+        return
+    }
     list, err := h.StokRepo.GetStokAkhirAll(ctx)
     if err != nil {
         WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
@@ -30,21 +70,55 @@ func (h *LaporanHandler) LaporanStok(w http.ResponseWriter, r *http.Request) {
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Laporan stok", Data: list})
 }
 
-// GET /api/laporan/penjualan?from=YYYY-MM-DD&to=YYYY-MM-DD
+// GET /api/laporan/penjualan?from=YYYY-MM-DD&to=YYYY-MM-DD&group_by=kategori|induk&format=csv|xlsx
 func (h *LaporanHandler) LaporanPenjualan(w http.ResponseWriter, r *http.Request) {
     q := r.URL.Query()
     var fromPtr, toPtr *time.Time
     if fs := q.Get("from"); fs != "" {
-        if t, err := time.Parse("2006-01-02", fs); err == nil { fromPtr = &t }
+        if t, err := time.ParseInLocation("2006-01-02", fs, h.Lokasi); err == nil { fromPtr = &t }
     }
     if ts := q.Get("to"); ts != "" {
-        if t, err := time.Parse("2006-01-02", ts); err == nil {
-            t2 := t.Add(24*time.Hour - time.Nanosecond)
+        if t, err := time.ParseInLocation("2006-01-02", ts, h.Lokasi); err == nil {
+            t2 := t.AddDate(0, 0, 1).Add(-time.Nanosecond)
             toPtr = &t2
         }
     }
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    groupBy := q.Get("group_by")
+    perKategori := groupBy == "kategori"
+    if format != "" && !perKategori && groupBy != "induk" {
+        exportPenjualan(w, r, format, h.PenjualanRepo, fromPtr, toPtr)
+        return
+    }
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
+    if groupBy == "induk" {
+        list, err := h.PenjualanRepo.GetReportPerInduk(ctx, fromPtr, toPtr)
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        if format != "" {
+            exportPenjualanInduk(w, r, format, list)
+            return
+        }
+        WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Laporan penjualan per barang induk", Data: list})
+        return
+    }
+    if perKategori {
+        list, err := h.PenjualanRepo.GetReportPerKategori(ctx, fromPtr, toPtr)
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        if format != "" {
+            exportPenjualanKategori(w, r, format, list)
+            return
+        }
+        WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Laporan penjualan per kategori", Data: list})
+        return
+    }
     list, err := h.PenjualanRepo.GetReport(ctx, fromPtr, toPtr)
     if err != nil {
         WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
@@ -53,19 +127,25 @@ func (h *LaporanHandler) LaporanPenjualan(w http.ResponseWriter, r *http.Request
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Laporan penjualan", Data: list})
 }
 
-// GET /api/laporan/pembelian?from=YYYY-MM-DD&to=YYYY-MM-DD
+// GET /api/laporan/pembelian?from=YYYY-MM-DD&to=YYYY-MM-DD&format=csv|xlsx
 func (h *LaporanHandler) LaporanPembelian(w http.ResponseWriter, r *http.Request) {
     q := r.URL.Query()
     var fromPtr, toPtr *time.Time
     if fs := q.Get("from"); fs != "" {
-        if t, err := time.Parse("2006-01-02", fs); err == nil { fromPtr = &t }
+        if t, err := time.ParseInLocation("2006-01-02", fs, h.Lokasi); err == nil { fromPtr = &t }
     }
     if ts := q.Get("to"); ts != "" {
-        if t, err := time.Parse("2006-01-02", ts); err == nil {
-            t2 := t.Add(24*time.Hour - time.Nanosecond)
+        if t, err := time.ParseInLocation("2006-01-02", ts, h.Lokasi); err == nil {
+            t2 := t.AddDate(0, 0, 1).Add(-time.Nanosecond)
             toPtr = &t2
         }
     }
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    if format != "" {
+        exportPembelian(w, r, format, h.PembelianRepo, fromPtr, toPtr)
+        return
+    }
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
     list, err := h.PembelianRepo.GetReport(ctx, fromPtr, toPtr)
@@ -75,3 +155,113 @@ func (h *LaporanHandler) LaporanPembelian(w http.ResponseWriter, r *http.Request
     }
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Laporan pembelian", Data: list})
 }
+
+// GET /api/laporan/ppn?bulan=YYYY-MM&format=csv|xlsx
+func (h *LaporanHandler) LaporanPPN(w http.ResponseWriter, r *http.Request) {
+    bulan := r.URL.Query().Get("bulan")
+    // Month bounds are local midnights, so late-evening sales on the last day stay in the month.
+    from, err := time.ParseInLocation("2006-01", bulan, h.Lokasi)
+    if err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "bulan must be YYYY-MM"})
+        return
+    }
+    to := from.AddDate(0, 1, 0)
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    laporan, err := h.PajakRepo.LaporanPPN(ctx, from, to)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if format != "" {
+        exportPPN(w, r, format, laporan)
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Laporan PPN", Data: laporan})
+}
+
+// The per-kategori, per-induk and PPN reports are small aggregates, so they are built
+// in memory and only written out as a file.
+
+var stokKategoriColumns = []spreadsheet.Column{
+    {Header: "Kategori"}, {Header: "Jumlah Barang", Kind: spreadsheet.Number},
+    {Header: "Total Stok", Kind: spreadsheet.Number}, {Header: "Nilai Stok", Kind: spreadsheet.Money},
+}
+
+func exportStokKategori(w http.ResponseWriter, r *http.Request, f string, list []models.StokKategori) {
+    writeExport(w, r, f, "laporan-stok-kategori", stokKategoriColumns, func(_ context.Context, row rowFunc) error {
+        for _, k := range list {
+            if err := row(k.Nama, k.JumlahBarang, k.TotalStok, k.NilaiStok); err != nil { return err }
+        }
+        return nil
+    })
+}
+
+var penjualanKategoriColumns = []spreadsheet.Column{
+    {Header: "Kategori"}, {Header: "Jumlah Faktur", Kind: spreadsheet.Number}, {Header: "Qty", Kind: spreadsheet.Number},
+    {Header: "Subtotal", Kind: spreadsheet.Money}, {Header: "DPP", Kind: spreadsheet.Money}, {Header: "PPN", Kind: spreadsheet.Money},
+}
+
+func exportPenjualanKategori(w http.ResponseWriter, r *http.Request, f string, list []models.PenjualanKategori) {
+    writeExport(w, r, f, "laporan-penjualan-kategori", penjualanKategoriColumns, func(_ context.Context, row rowFunc) error {
+        for _, k := range list {
+            if err := row(k.Nama, k.JumlahFaktur, k.Qty, k.Subtotal, k.DPP, k.PPN); err != nil { return err }
+        }
+        return nil
+    })
+}
+
+var stokIndukColumns = []spreadsheet.Column{
+    {Header: "Kode"}, {Header: "Nama"}, {Header: "Jumlah Barang", Kind: spreadsheet.Number},
+    {Header: "Total Stok", Kind: spreadsheet.Number}, {Header: "Nilai Stok", Kind: spreadsheet.Money},
+}
+
+func exportStokInduk(w http.ResponseWriter, r *http.Request, f string, list []models.StokInduk) {
+    writeExport(w, r, f, "laporan-stok-induk", stokIndukColumns, func(_ context.Context, row rowFunc) error {
+        for _, k := range list {
+            if err := row(k.Kode, k.Nama, k.JumlahBarang, k.TotalStok, k.NilaiStok); err != nil { return err }
+        }
+        return nil
+    })
+}
+
+var penjualanIndukColumns = []spreadsheet.Column{
+    {Header: "Kode"}, {Header: "Nama"}, {Header: "Jumlah Faktur", Kind: spreadsheet.Number}, {Header: "Qty", Kind: spreadsheet.Number},
+    {Header: "Subtotal", Kind: spreadsheet.Money}, {Header: "DPP", Kind: spreadsheet.Money}, {Header: "PPN", Kind: spreadsheet.Money},
+}
+
+func exportPenjualanInduk(w http.ResponseWriter, r *http.Request, f string, list []models.PenjualanInduk) {
+    writeExport(w, r, f, "laporan-penjualan-induk", penjualanIndukColumns, func(_ context.Context, row rowFunc) error {
+        for _, k := range list {
+            if err := row(k.Kode, k.Nama, k.JumlahFaktur, k.Qty, k.Subtotal, k.DPP, k.PPN); err != nil { return err }
+        }
+        return nil
+    })
+}
+
+var ppnColumns = []spreadsheet.Column{
+    {Header: "Jenis"}, {Header: "Tanggal", Kind: spreadsheet.DateTime}, {Header: "No Faktur"}, {Header: "Customer/Supplier"},
+    {Header: "Tarif PPN"}, {Header: "DPP", Kind: spreadsheet.Money}, {Header: "PPN", Kind: spreadsheet.Money},
+    {Header: "Grand Total", Kind: spreadsheet.Money},
+}
+
+// exportPPN lists every faktur of the month, keluaran first, followed by the
+// totals and the PPN terutang.
+func exportPPN(w http.ResponseWriter, r *http.Request, f string, l *models.LaporanPPN) {
+    writeExport(w, r, f, "laporan-ppn-"+l.Periode, ppnColumns, func(_ context.Context, row rowFunc) error {
+        for _, s := range []struct {
+            jenis string
+            r     models.RingkasanPPN
+        }{{"Keluaran", l.Keluaran}, {"Masukan", l.Masukan}} {
+            for _, fk := range s.r.Faktur {
+                if err := row(s.jenis, fk.Tanggal, fk.NoFaktur, fk.Pihak, format.Persen(fk.TarifBP), fk.DPP, fk.PPN, fk.GrandTotal); err != nil {
+                    return err
+                }
+            }
+            if err := row("Total "+s.jenis, nil, nil, nil, nil, s.r.DPP, s.r.PPN, nil); err != nil { return err }
+        }
+        return row("PPN Terutang", nil, nil, nil, nil, nil, l.PPNTerutang, nil)
+    })
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/models"
+	"warehouse/repositories"
+)
+
+// PajakHandler exposes PPN rate configuration.
+type PajakHandler struct {
+    Repo *repositories.PajakRepo
+}
+
+func NewPajakHandler(repo *repositories.PajakRepo) *PajakHandler { return &PajakHandler{Repo: repo} }
+
+type tarifRequest struct {
+    TarifBP      int64  `json:"tarif_bp"`
+    BerlakuMulai string `json:"berlaku_mulai"` // YYYY-MM-DD
+}
+
+// GET /api/pajak/tarif
+func (h *PajakHandler) ListTarif(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.ListTarif(ctx)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// POST /api/pajak/tarif
+func (h *PajakHandler) CreateTarif(w http.ResponseWriter, r *http.Request) {
+    var req tarifRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    berlaku, err := time.Parse("2006-01-02", req.BerlakuMulai)
+    if err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "berlaku_mulai must be YYYY-MM-DD"})
+        return
+    }
+    t := models.TarifPajak{TarifBP: req.TarifBP, BerlakuMulai: berlaku}
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.CreateTarif(ctx, &t); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "pajak_tarif", EntitasID: t.ID, Sesudah: t})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: t})
+}
@@ -7,9 +7,12 @@ import (
 	"strconv"
 	"time"
 
+	"warehouse/audit"
+	"warehouse/format"
 	"warehouse/middleware"
 	"warehouse/models"
 	"warehouse/repositories"
+	"warehouse/spreadsheet"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -37,7 +40,7 @@ func (h *PembelianHandler) CreatePembelianHandler(w http.ResponseWriter, r *http
         return
     }
     for i, d := range hdr.Details {
-        if d.BarangID <= 0 || d.Qty <= 0 {
+        if (d.BarangID <= 0 && d.Barcode == "") || d.Qty <= 0 {
             WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid detail at index " + strconv.Itoa(i)})
             return
         }
@@ -58,6 +61,7 @@ func (h *PembelianHandler) CreatePembelianHandler(w http.ResponseWriter, r *http
         WriteJSON(w, code, APIResponse{Success: false, Message: err.Error()})
         return
     }
+    audit.Record(ctx, audit.Perubahan{EntitasID: hdr.ID, Sesudah: hdr})
     WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: hdr})
 }
 
@@ -82,6 +86,12 @@ func (h *PembelianHandler) GetAll(w http.ResponseWriter, r *http.Request) {
             toPtr = &t2
         }
     }
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    if format != "" {
+        exportPembelian(w, r, format, h.Repo, fromPtr, toPtr)
+        return
+    }
 
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
@@ -128,3 +138,20 @@ func (h *PembelianHandler) GetByID(w http.ResponseWriter, r *http.Request) {
     payload := pembelianDetailData{Header: headerOnly, Details: hdr.Details}
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: payload})
 }
+
+var pembelianColumns = []spreadsheet.Column{
+    {Header: "Tanggal", Kind: spreadsheet.DateTime}, {Header: "No Faktur"}, {Header: "Supplier"},
+    {Header: "Total", Kind: spreadsheet.Money}, {Header: "Tarif PPN"},
+    {Header: "DPP", Kind: spreadsheet.Money}, {Header: "PPN", Kind: spreadsheet.Money},
+    {Header: "Grand Total", Kind: spreadsheet.Money}, {Header: "Status"},
+}
+
+// exportPembelian streams the purchase headers in [from, to], unpaged. It
+// backs both /api/pembelian and /api/laporan/pembelian.
+func exportPembelian(w http.ResponseWriter, r *http.Request, f string, repo *repositories.PembelianRepo, from, to *time.Time) {
+    writeExport(w, r, f, "pembelian", pembelianColumns, func(ctx context.Context, row rowFunc) error {
+        return repo.EachReport(ctx, from, to, func(h models.BeliHeader) error {
+            return row(h.CreatedAt, h.NoFaktur, h.Supplier, h.Total, format.Persen(h.TarifPPN), h.DPP, h.PPN, h.GrandTotal, h.Status)
+        })
+    })
+}
@@ -7,9 +7,12 @@ import (
 	"strconv"
 	"time"
 
+	"warehouse/audit"
+	"warehouse/format"
 	"warehouse/middleware"
 	"warehouse/models"
 	"warehouse/repositories"
+	"warehouse/spreadsheet"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -36,7 +39,7 @@ func (h *PenjualanHandler) CreatePenjualanHandler(w http.ResponseWriter, r *http
         return
     }
     for i, d := range hdr.Details {
-        if d.BarangID <= 0 || d.Qty <= 0 {
+        if (d.BarangID <= 0 && d.Barcode == "") || d.Qty <= 0 {
             WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid detail at index " + strconv.Itoa(i)})
             return
         }
@@ -57,9 +60,39 @@ func (h *PenjualanHandler) CreatePenjualanHandler(w http.ResponseWriter, r *http
         WriteJSON(w, code, APIResponse{Success: false, Message: err.Error()})
         return
     }
+    audit.Record(ctx, audit.Perubahan{EntitasID: hdr.ID, Sesudah: hdr})
     WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: hdr})
 }
 
+// Preview handles POST /api/penjualan/preview. It returns the cart priced
+// with price lists, active promos and PPN without saving anything.
+func (h *PenjualanHandler) Preview(w http.ResponseWriter, r *http.Request) {
+    audit.Skip(r.Context())
+    var hdr models.JualHeader
+    if err := json.NewDecoder(r.Body).Decode(&hdr); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if len(hdr.Details) == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "details required"})
+        return
+    }
+    for i, d := range hdr.Details {
+        if (d.BarangID <= 0 && d.Barcode == "") || d.Qty <= 0 {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid detail at index " + strconv.Itoa(i)})
+            return
+        }
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    if err := h.Repo.PreviewPenjualan(ctx, &hdr); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: hdr})
+}
+
 // GetAll handles GET /api/penjualan
 func (h *PenjualanHandler) GetAll(w http.ResponseWriter, r *http.Request) {
     q := r.URL.Query()
@@ -80,6 +113,12 @@ func (h *PenjualanHandler) GetAll(w http.ResponseWriter, r *http.Request) {
             toPtr = &t2
         }
     }
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    if format != "" {
+        exportPenjualan(w, r, format, h.Repo, fromPtr, toPtr)
+        return
+    }
 
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
@@ -121,3 +160,20 @@ func (h *PenjualanHandler) GetByID(w http.ResponseWriter, r *http.Request) {
     payload := penjualanDetailData{Header: headerOnly, Details: hdr.Details}
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: payload})
 }
+
+var penjualanColumns = []spreadsheet.Column{
+    {Header: "Tanggal", Kind: spreadsheet.DateTime}, {Header: "No Faktur"}, {Header: "Customer"},
+    {Header: "Total", Kind: spreadsheet.Money}, {Header: "Diskon", Kind: spreadsheet.Money},
+    {Header: "Tarif PPN"}, {Header: "DPP", Kind: spreadsheet.Money}, {Header: "PPN", Kind: spreadsheet.Money},
+    {Header: "Grand Total", Kind: spreadsheet.Money}, {Header: "Status"},
+}
+
+// exportPenjualan streams the sales headers in [from, to], unpaged. It
+// backs both /api/penjualan and /api/laporan/penjualan.
+func exportPenjualan(w http.ResponseWriter, r *http.Request, f string, repo *repositories.PenjualanRepo, from, to *time.Time) {
+    writeExport(w, r, f, "penjualan", penjualanColumns, func(ctx context.Context, row rowFunc) error {
+        return repo.EachReport(ctx, from, to, func(h models.JualHeader) error {
+            return row(h.CreatedAt, h.NoFaktur, h.Customer, h.Total, h.Diskon, format.Persen(h.TarifPPN), h.DPP, h.PPN, h.GrandTotal, h.Status)
+        })
+    })
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PromoHandler provides HTTP handlers for promotion rules.
+type PromoHandler struct {
+    Repo *repositories.PromoRepo
+}
+
+func NewPromoHandler(repo *repositories.PromoRepo) *PromoHandler { return &PromoHandler{Repo: repo} }
+
+// GET /api/promo?aktif=true
+func (h *PromoHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+    aktif, _ := strconv.ParseBool(r.URL.Query().Get("aktif"))
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.GetAll(ctx, aktif)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// GET /api/promo/{id}
+func (h *PromoHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    p, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if p == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: p})
+}
+
+// POST /api/promo
+// Body: {"nama":"Beli 2 gratis 1","jenis":"beli_x_gratis_y","aktif":true,"barang_id":1,"beli_qty":2,"gratis_qty":1}
+func (h *PromoHandler) Create(w http.ResponseWriter, r *http.Request) {
+    var p models.Promo
+    if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    p.ID = 0
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Save(ctx, &p); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{EntitasID: p.ID, Sesudah: p})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: p})
+}
+
+// PUT /api/promo/{id} replaces the whole rule, including bundle items.
+func (h *PromoHandler) Update(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var p models.Promo
+    if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    p.ID = id
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.Save(ctx, &p); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Sebelum: before, Sesudah: p})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: p})
+}
+
+// DELETE /api/promo/{id}
+func (h *PromoHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.Delete(ctx, id); err != nil {
+        if err == repositories.ErrPromoInUse {
+            WriteJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "Promo sudah dipakai transaksi penjualan, nonaktifkan saja"})
+            return
+        }
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Sebelum: before})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": id}})
+}
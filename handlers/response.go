@@ -37,6 +37,8 @@ func StatusFromError(err error) int {
         return http.StatusNotFound
     case errors.Is(err, apperr.ErrValidation):
         return http.StatusUnprocessableEntity
+    case errors.Is(err, apperr.ErrPrecondition):
+        return http.StatusPreconditionFailed
     default:
         return http.StatusInternalServerError
     }
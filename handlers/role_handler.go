@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// namaRole is the accepted form of a role name.
+var namaRole = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,19}$`)
+
+// RoleHandler manages roles and the permissions they grant.
+type RoleHandler struct {
+    Repo *repositories.RoleRepo
+}
+
+func NewRoleHandler(repo *repositories.RoleRepo) *RoleHandler {
+    return &RoleHandler{Repo: repo}
+}
+
+// GET /api/permissions
+func (h *RoleHandler) Permissions(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.Permissions(ctx)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// GET /api/roles
+func (h *RoleHandler) List(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.List(ctx)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// GET /api/roles/{nama}
+func (h *RoleHandler) Get(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    ro, err := h.Repo.Get(ctx, chi.URLParam(r, "nama"))
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if ro == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: ro})
+}
+
+// decodeRole reads a role body and normalizes it. It writes the error
+// response and returns false on failure.
+func decodeRole(w http.ResponseWriter, r *http.Request, ro *models.Role) bool {
+    if err := json.NewDecoder(r.Body).Decode(ro); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return false
+    }
+    if ro.Deskripsi != nil {
+        v := strings.TrimSpace(*ro.Deskripsi)
+        ro.Deskripsi = &v
+        if v == "" { ro.Deskripsi = nil }
+    }
+    if ro.Permissions == nil { ro.Permissions = []string{} }
+    return true
+}
+
+// POST /api/roles
+// Body: {"nama":"kasir","deskripsi":"Kasir toko","permissions":["barang.view","penjualan.create"]}
+func (h *RoleHandler) Create(w http.ResponseWriter, r *http.Request) {
+    var ro models.Role
+    if !decodeRole(w, r, &ro) { return }
+    ro.Nama = strings.TrimSpace(ro.Nama)
+    if !namaRole.MatchString(ro.Nama) {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama must be 1-20 lowercase letters, digits, _ or -, starting with a letter"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Create(ctx, &ro); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    h.writeSaved(ctx, w, http.StatusCreated, "created", ro.Nama, nil)
+}
+
+// PUT /api/roles/{nama} replaces the description, permissions and wajib_2fa of a role.
+// Users with the role get the new permissions on their next request. A role
+// also given in tenants where the caller does not hold role.manage cannot be
+// changed.
+func (h *RoleHandler) Update(w http.ResponseWriter, r *http.Request) {
+    var ro models.Role
+    if !decodeRole(w, r, &ro) { return }
+    ro.Nama = chi.URLParam(r, "nama")
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.Get(ctx, ro.Nama)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if before == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    uid, _ := middleware.UserIDFromContext(r.Context())
+    _, viaKey := middleware.APIKeyFromContext(r.Context())
+    luar, err := h.Repo.DipakaiDiLuar(ctx, ro.Nama, uid, viaKey)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if luar {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "role " + ro.Nama + " is also given in a tenant you do not administer"})
+        return
+    }
+    if err := h.Repo.Update(ctx, &ro); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    h.writeSaved(ctx, w, http.StatusOK, "updated", ro.Nama, before)
+}
+
+// writeSaved reads role nama back, records the change and responds.
+func (h *RoleHandler) writeSaved(ctx context.Context, w http.ResponseWriter, status int, msg, nama string, before *models.Role) {
+    ro, err := h.Repo.Get(ctx, nama)
+    if err != nil || ro == nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: "role saved but could not be read back"})
+        return
+    }
+    p := audit.Perubahan{Entitas: "role", Sesudah: ro}
+    if before != nil { p.Sebelum = before }
+    audit.Record(ctx, p)
+    WriteJSON(w, status, APIResponse{Success: true, Message: msg, Data: ro})
+}
+
+// DELETE /api/roles/{nama}
+func (h *RoleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    nama := chi.URLParam(r, "nama")
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.Get(ctx, nama)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.Delete(ctx, nama); err != nil {
+        if err == repositories.ErrRoleInUse {
+            WriteJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "role is still given to users"})
+            return
+        }
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "role", Sebelum: before})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]string{"nama": nama}})
+}
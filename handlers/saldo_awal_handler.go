@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+)
+
+// saldoAwalColumns are the recognised header names of an opening-balance
+// file; harga_satuan (unit cost) defaults to the barang's harga_beli.
+var saldoAwalColumns = []string{"kode_barang", "qty", "harga_satuan"}
+
+// POST /api/stok/saldo-awal/import?best_effort=false&async=false
+// Body: CSV/XLSX with columns kode_barang, qty, harga_satuan.
+func (h *ImportHandler) SaldoAwal(w http.ResponseWriter, r *http.Request) {
+    bestEffort, async := importOptions(r)
+    table, ok := readUpload(w, r)
+    if !ok { return }
+    rows, rowErrs, err := parseSaldoAwalRows(table)
+    if err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    total := len(rows) + len(rowErrs)
+    if total == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "file has no data rows"})
+        return
+    }
+    if len(rowErrs) > 0 && !bestEffort {
+        rep := &models.SaldoAwalReport{Total: total, Gagal: len(rowErrs), Items: []models.SaldoAwal{}, Errors: rowErrs}
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "import rejected, nothing saved", Data: rep})
+        return
+    }
+
+    uid, _ := middleware.UserIDFromContext(r.Context())
+    run := func(ctx context.Context, progress func(int)) (*models.SaldoAwalReport, error) {
+        rep, err := h.StokRepo.ImportSaldoAwal(ctx, rows, bestEffort, uid, func(n int) {
+            if progress != nil { progress(len(rowErrs) + n) }
+        })
+        if err != nil { return nil, err }
+        rep.Total = total
+        rep.Gagal += len(rowErrs)
+        rep.Errors = mergeImportErrors(rowErrs, rep.Errors)
+        return rep, nil
+    }
+
+    if async || total > h.AsyncRows {
+        job := h.Jobs.Start(r.Context(), "import_saldo_awal", uid, total, func(ctx context.Context, progress func(int)) (any, error) {
+            return run(ctx, progress)
+        })
+        w.Header().Set("Location", "/api/jobs/"+job.ID)
+        audit.Record(r.Context(), audit.Perubahan{Aksi: "import", Entitas: "saldo_awal", Sesudah: map[string]string{"job_id": job.ID}})
+        WriteJSON(w, http.StatusAccepted, APIResponse{Success: true, Message: "import started", Data: job})
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+    defer cancel()
+    rep, err := run(ctx, nil)
+    if err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if !rep.Disimpan {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "import rejected, nothing saved", Data: rep})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "import", Entitas: "saldo_awal", Sesudah: rep})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "imported", Data: rep})
+}
+
+// parseSaldoAwalRows maps a table with a header row to opening-balance rows.
+func parseSaldoAwalRows(table [][]string) ([]models.SaldoAwalImport, []models.ImportError, error) {
+    col, err := headerIndex(table[0], saldoAwalColumns, []string{"kode_barang", "qty"})
+    if err != nil { return nil, nil, err }
+
+    rows := make([]models.SaldoAwalImport, 0, len(table)-1)
+    errs := make([]models.ImportError, 0)
+    seen := make(map[string]int)
+    for i, cells := range table[1:] {
+        baris := i + 2
+        if isBlankRow(cells) { continue }
+        cell := cellReader(col, cells)
+        row := models.SaldoAwalImport{Baris: baris, KodeBarang: cell("kode_barang")}
+        var msgs []string
+        if row.KodeBarang == "" {
+            msgs = append(msgs, "kode_barang is required")
+        } else if prev, dup := seen[strings.ToUpper(row.KodeBarang)]; dup {
+            msgs = append(msgs, fmt.Sprintf("kode_barang duplicates row %d", prev))
+        } else {
+            seen[strings.ToUpper(row.KodeBarang)] = baris
+        }
+        qty, err := parseRupiah(cell("qty")) // same number formats as prices
+        if err != nil || qty <= 0 {
+            msgs = append(msgs, "qty must be a number > 0")
+        }
+        row.Qty = qty
+        if s := cell("harga_satuan"); s != "" {
+            v, err := parseRupiah(s)
+            if err != nil {
+                msgs = append(msgs, "harga_satuan: "+err.Error())
+            } else {
+                row.HargaSatuan = &v
+            }
+        }
+        if len(msgs) > 0 {
+            errs = append(errs, models.ImportError{Baris: baris, KodeBarang: row.KodeBarang, Pesan: strings.Join(msgs, "; ")})
+            continue
+        }
+        rows = append(rows, row)
+    }
+    return rows, errs, nil
+}
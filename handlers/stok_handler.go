@@ -6,7 +6,9 @@ import (
 	"strconv"
 	"time"
 
+	"warehouse/models"
 	"warehouse/repositories"
+	"warehouse/spreadsheet"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -18,6 +20,12 @@ type StokHandler struct {
 func NewStokHandler(repo *repositories.StokRepo) *StokHandler { return &StokHandler{Repo: repo} }
 
 func (h *StokHandler) GetStokAkhirAll(w http.ResponseWriter, r *http.Request) {
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    if format != "" {
+        exportStok(w, r, format, h.Repo)
+        return
+    }
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
     list, err := h.Repo.GetStokAkhirAll(ctx)
@@ -29,6 +37,12 @@ func (h *StokHandler) GetStokAkhirAll(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *StokHandler) GetHistoryAll(w http.ResponseWriter, r *http.Request) {
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    if format != "" {
+        h.exportHistory(w, r, format, 0)
+        return
+    }
     q := r.URL.Query()
     page, _ := strconv.Atoi(q.Get("page"))
     limit, _ := strconv.Atoi(q.Get("limit"))
@@ -79,6 +93,12 @@ func (h *StokHandler) GetHistoryByBarangHandler(w http.ResponseWriter, r *http.R
         WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid barang_id"})
         return
     }
+    format, ok := exportFormat(w, r)
+    if !ok { return }
+    if format != "" {
+        h.exportHistory(w, r, format, barangID)
+        return
+    }
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
     list, total, err := h.Repo.GetHistoryByBarangID(ctx, barangID, page, limit)
@@ -88,3 +108,52 @@ func (h *StokHandler) GetHistoryByBarangHandler(w http.ResponseWriter, r *http.R
     }
     WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list, Meta: &Meta{Page: page, Limit: limit, Total: total}})
 }
+
+// GET /api/stok/saldo-awal lists opening balances (qty and value at cost).
+func (h *StokHandler) GetSaldoAwal(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.GetSaldoAwal(ctx)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+var stokColumns = []spreadsheet.Column{
+    {Header: "Kode Barang"}, {Header: "Nama Barang"}, {Header: "Satuan"},
+    {Header: "Stok Akhir", Kind: spreadsheet.Number}, {Header: "Harga Beli", Kind: spreadsheet.Money},
+    {Header: "Nilai Stok", Kind: spreadsheet.Money},
+}
+
+// exportStok streams the current stock of every barang, valued at harga_beli.
+// It backs both /api/stok and /api/laporan/stok.
+func exportStok(w http.ResponseWriter, r *http.Request, format string, repo *repositories.StokRepo) {
+    writeExport(w, r, format, "stok", stokColumns, func(ctx context.Context, row rowFunc) error {
+        return repo.EachStokAkhir(ctx, func(m models.Mstok) error {
+            b := m.Barang
+            return row(b.KodeBarang, b.NamaBarang, b.Satuan, m.StokAkhir, b.HargaBeli, m.StokAkhir*b.HargaBeli)
+        })
+    })
+}
+
+var historyColumns = []spreadsheet.Column{
+    {Header: "Tanggal", Kind: spreadsheet.DateTime}, {Header: "Kode Barang"}, {Header: "Nama Barang"},
+    {Header: "Jenis Transaksi"}, {Header: "Jumlah", Kind: spreadsheet.Number},
+    {Header: "Stok Sebelum", Kind: spreadsheet.Number}, {Header: "Stok Sesudah", Kind: spreadsheet.Number},
+    {Header: "Pengguna"}, {Header: "API Key"},
+}
+
+// exportHistory streams the stock history of one barang, or of all when
+// barangID is 0, unpaged.
+func (h *StokHandler) exportHistory(w http.ResponseWriter, r *http.Request, format string, barangID int64) {
+    writeExport(w, r, format, "history-stok", historyColumns, func(ctx context.Context, row rowFunc) error {
+        return h.Repo.EachHistory(ctx, barangID, func(hs models.HistoryStok) error {
+            b, u := hs.BarangDetail, hs.UserDetail
+            key := ""
+            if hs.APIKeyNama != nil { key = *hs.APIKeyNama }
+            return row(hs.CreatedAt, b.KodeBarang, b.NamaBarang, hs.JenisTransaksi, hs.Jumlah, hs.StokSebelum, hs.StokSesudah, u.Username, key)
+        })
+    })
+}
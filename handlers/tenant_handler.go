@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/permission"
+	"warehouse/repositories"
+	"warehouse/tenant"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// kodeTenant is the accepted form of a tenant code.
+var kodeTenant = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,29}$`)
+
+// TenantHandler manages tenants and their members. Tenants are only
+// visible to their members, and memberships can only be given and taken in
+// tenants where the caller holds tenant.manage.
+type TenantHandler struct {
+    Repo  *repositories.TenantRepo
+    Users *repositories.UserRepo
+    Roles *repositories.RoleRepo
+}
+
+func NewTenantHandler(repo *repositories.TenantRepo, users *repositories.UserRepo, roles *repositories.RoleRepo) *TenantHandler {
+    return &TenantHandler{Repo: repo, Users: users, Roles: roles}
+}
+
+// GET /api/me/tenants returns the tenants the caller can switch to and the
+// id of the current one.
+func (h *TenantHandler) Mine(w http.ResponseWriter, r *http.Request) {
+    uid, ok := middleware.UserIDFromContext(r.Context())
+    if !ok {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.ListForUser(ctx, uid, 0)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    tid, _ := tenant.FromContext(r.Context())
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: map[string]any{"tenant_id": tid, "tenants": list}})
+}
+
+// POST /api/tenants creates a tenant with the caller as its first member.
+func (h *TenantHandler) Create(w http.ResponseWriter, r *http.Request) {
+    var t models.Tenant
+    if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    t.Kode, t.Nama = strings.ToLower(strings.TrimSpace(t.Kode)), strings.TrimSpace(t.Nama)
+    if !kodeTenant.MatchString(t.Kode) {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "kode must be 1-30 lowercase letters, digits, _ or -"})
+        return
+    }
+    if t.Nama == "" || len(t.Nama) > 120 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama is required (max 120 characters)"})
+        return
+    }
+    uid, _ := middleware.UserIDFromContext(r.Context())
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Create(ctx, &t, uid); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "tenant", EntitasID: t.ID, Sesudah: t})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: t})
+}
+
+type userTenantsRequest struct {
+    TenantIDs []int64 `json:"tenant_ids"`
+    Role      string  `json:"role"` // in the tenants added; default the user's role in the current tenant
+}
+
+// GET /api/users/{id}/tenants returns the user's tenants the caller is
+// also a member of.
+func (h *TenantHandler) UserTenants(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    uid, _ := middleware.UserIDFromContext(r.Context())
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, err := h.Repo.ListForUser(ctx, id, uid)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+// PUT /api/users/{id}/tenants sets which of the caller's tenants a user
+// is a member of. Removing a user from a tenant ends their access to it at
+// their next request. See bolehUbahTenant for who may add and remove.
+func (h *TenantHandler) SetUserTenants(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    uid, _ := middleware.UserIDFromContext(r.Context())
+    var req userTenantsRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if len(req.TenantIDs) == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "tenant_ids requires at least one tenant"})
+        return
+    }
+    if uid == id {
+        tid, _ := tenant.FromContext(r.Context())
+        tetap := false
+        for _, t := range req.TenantIDs {
+            if t == tid { tetap = true }
+        }
+        if !tetap {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "you cannot remove yourself from the current tenant"})
+            return
+        }
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.ListForUser(ctx, id, uid)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    req.Role = strings.TrimSpace(req.Role)
+    if req.Role == "" {
+        tid, _ := tenant.FromContext(r.Context())
+        for _, t := range before {
+            if t.ID == tid { req.Role = t.Role }
+        }
+    }
+    tambah, hapus := ubahTenant(before, req.TenantIDs)
+    if !h.bolehUbahTenant(ctx, w, uid, tambah, hapus, req.Role) { return }
+    if err := h.Repo.SetUserTenants(ctx, uid, id, req.TenantIDs, req.Role); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    list, err := h.Repo.ListForUser(ctx, id, uid)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "set_tenants", Entitas: "user", Sebelum: kodeTenants(before), Sesudah: kodeTenants(list)})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: list})
+}
+
+// ubahTenant returns the tenants of want a user is added to and those of
+// before (the user's current tenants) the user is removed from.
+func ubahTenant(before []models.Tenant, want []int64) (tambah, hapus []int64) {
+    ada := make(map[int64]bool, len(before))
+    for _, t := range before { ada[t.ID] = true }
+    tetap := make(map[int64]bool, len(want))
+    for _, id := range want {
+        if !ada[id] && !tetap[id] { tambah = append(tambah, id) }
+        tetap[id] = true
+    }
+    for _, t := range before {
+        if !tetap[t.ID] { hapus = append(hapus, t.ID) }
+    }
+    return tambah, hapus
+}
+
+// bolehUbahTenant checks that the caller holds tenant.manage in every tenant
+// a user is added to or removed from, and may give role in those added to
+// (see bolehBeriRole). An API key, which belongs to one tenant, cannot
+// change memberships in other tenants. It writes the error response and
+// returns false otherwise.
+func (h *TenantHandler) bolehUbahTenant(ctx context.Context, w http.ResponseWriter, uid int64, tambah, hapus []int64, role string) bool {
+    if len(tambah) == 0 && len(hapus) == 0 { return true }
+    tid, _ := tenant.FromContext(ctx)
+    if _, viaKey := middleware.APIKeyFromContext(ctx); viaKey {
+        for _, t := range append(append([]int64{}, tambah...), hapus...) {
+            if t != tid {
+                WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "an API key belongs to one tenant"})
+                return false
+            }
+        }
+    }
+    perms, err := h.Repo.Permissions(ctx, uid)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return false
+    }
+    // The permissions of the request itself, which for an API key are
+    // limited to its scopes.
+    perms[tid], _ = middleware.PermissionsFromContext(ctx)
+    var rolePerms []string
+    if len(tambah) > 0 {
+        ro, err := h.Roles.Get(ctx, role)
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return false
+        }
+        if ro == nil {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "role not found"})
+            return false
+        }
+        rolePerms = ro.Permissions
+    }
+    for _, t := range append(append([]int64{}, tambah...), hapus...) {
+        if !slices.Contains(perms[t], permission.TenantManage) {
+            WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: fmt.Sprintf("changing members of tenant %d requires %s there", t, permission.TenantManage)})
+            return false
+        }
+    }
+    for _, t := range tambah {
+        if !bolehBeriRole(perms[t], rolePerms) {
+            WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: fmt.Sprintf("giving role %s in tenant %d requires %s or every permission of the role there", role, t, permission.RoleManage)})
+            return false
+        }
+    }
+    return true
+}
+
+// kodeTenants returns the codes of list, for the audit log.
+func kodeTenants(list []models.Tenant) []string {
+    kode := make([]string, 0, len(list))
+    for _, t := range list { kode = append(kode, t.Kode) }
+    return kode
+}
+
+// DalamTenant answers 404 for /users/{id} routes whose user is not a member
+// of the caller's tenant, so users of other tenants cannot be seen or
+// changed.
+func (h *TenantHandler) DalamTenant(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+        if id <= 0 {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+            return
+        }
+        ok, err := h.Users.Anggota(r.Context(), id)
+        if err != nil {
+            WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+            return
+        }
+        if !ok {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// Dikelola answers 403 for /users/{id} routes that change the user's
+// account (active state, sessions, two-factor), which all of the user's
+// tenants share, unless the caller may manage the user in all of them (see
+// cekDikelola). Mount it after DalamTenant.
+func (h *TenantHandler) Dikelola(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+        if !cekDikelola(r.Context(), w, h.Users, id) { return }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// cekDikelola checks that the caller holds user.manage in every tenant of
+// user id (see UserRepo.Dikelola). It writes the error response and returns
+// false otherwise.
+func cekDikelola(ctx context.Context, w http.ResponseWriter, users *repositories.UserRepo, id int64) bool {
+    uid, _ := middleware.UserIDFromContext(ctx)
+    _, viaKey := middleware.APIKeyFromContext(ctx)
+    ok, err := users.Dikelola(ctx, id, uid, permission.UserManage, viaKey)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return false
+    }
+    if !ok {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "the user also belongs to a tenant you do not administer"})
+        return false
+    }
+    return true
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"warehouse/models"
+)
+
+func TestUbahTenant(t *testing.T) {
+    tenants := func(ids ...int64) []models.Tenant {
+        out := make([]models.Tenant, 0, len(ids))
+        for _, id := range ids { out = append(out, models.Tenant{ID: id}) }
+        return out
+    }
+
+    tests := []struct {
+        name   string
+        before []models.Tenant
+        want   []int64
+        tambah []int64
+        hapus  []int64
+    }{
+        {"unchanged", tenants(1, 2), []int64{2, 1}, nil, nil},
+        {"added", tenants(1), []int64{1, 3}, []int64{3}, nil},
+        {"removed", tenants(1, 2), []int64{1}, nil, []int64{2}},
+        {"replaced", tenants(1), []int64{2}, []int64{2}, []int64{1}},
+        {"duplicates", tenants(), []int64{4, 4}, []int64{4}, nil},
+        {"emptied", tenants(1, 2), nil, nil, []int64{1, 2}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tambah, hapus := ubahTenant(tt.before, tt.want)
+            if !slices.Equal(tambah, tt.tambah) || !slices.Equal(hapus, tt.hapus) {
+                t.Errorf("ubahTenant = %v, %v; want %v, %v", tambah, hapus, tt.tambah, tt.hapus)
+            }
+        })
+    }
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TOTPHandler manages two-factor authentication of the caller's own account
+// (/api/me/2fa) and lets admins reset it for users who lost their device.
+type TOTPHandler struct {
+    Repo *repositories.TOTPRepo
+}
+
+func NewTOTPHandler(repo *repositories.TOTPRepo) *TOTPHandler {
+    return &TOTPHandler{Repo: repo}
+}
+
+type codeRequest struct {
+    Code string `json:"code"`
+}
+
+// akunSendiri returns the caller's user id. Two-factor settings belong to a
+// person, so requests made with an API key are refused. It writes the error
+// response and returns false on failure.
+func akunSendiri(w http.ResponseWriter, r *http.Request) (int64, bool) {
+    uid, ok := middleware.UserIDFromContext(r.Context())
+    if !ok {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return 0, false
+    }
+    if _, viaKey := middleware.APIKeyFromContext(r.Context()); viaKey {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "two-factor settings cannot be changed with an API key"})
+        return 0, false
+    }
+    return uid, true
+}
+
+// decodeCode reads a {"code": "..."} body. It writes the error response and
+// returns false when the code is missing.
+func decodeCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+    var req codeRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return "", false
+    }
+    req.Code = strings.TrimSpace(req.Code)
+    if req.Code == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "code is required"})
+        return "", false
+    }
+    return req.Code, true
+}
+
+// writeTOTPError maps ErrTOTPInvalid to 422.
+func writeTOTPError(w http.ResponseWriter, err error) {
+    status := StatusFromError(err)
+    if err == repositories.ErrTOTPInvalid { status = http.StatusUnprocessableEntity }
+    WriteJSON(w, status, APIResponse{Success: false, Message: err.Error()})
+}
+
+// GET /api/me/2fa
+func (h *TOTPHandler) Status(w http.ResponseWriter, r *http.Request) {
+    uid, ok := middleware.UserIDFromContext(r.Context())
+    if !ok {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    st, err := h.Repo.Status(ctx, uid)
+    if err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: st})
+}
+
+// POST /api/me/2fa starts enrollment and returns the secret and the
+// otpauth:// URI to show as a QR code. Nothing changes for logins until
+// the first code is verified.
+func (h *TOTPHandler) Start(w http.ResponseWriter, r *http.Request) {
+    uid, ok := akunSendiri(w, r)
+    if !ok { return }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    e, err := h.Repo.Start(ctx, uid)
+    if err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    // The secret stays out of the audit log.
+    audit.Record(ctx, audit.Perubahan{Aksi: "2fa_enroll", Entitas: "user", EntitasID: uid})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Scan the QR code, then verify a code", Data: e})
+}
+
+// POST /api/me/2fa/verify finishes enrollment with a code from the app and
+// returns the recovery codes, which are shown only this once.
+func (h *TOTPHandler) Verify(w http.ResponseWriter, r *http.Request) {
+    uid, ok := akunSendiri(w, r)
+    if !ok { return }
+    code, ok := decodeCode(w, r)
+    if !ok { return }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    codes, err := h.Repo.Activate(ctx, uid, code)
+    if err != nil {
+        writeTOTPError(w, err)
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "2fa_enable", Entitas: "user", EntitasID: uid})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Two-factor authentication enabled; store the recovery codes", Data: map[string][]string{"recovery_codes": codes}})
+}
+
+// POST /api/me/2fa/recovery-codes replaces the recovery codes. A current
+// code is required.
+func (h *TOTPHandler) RecoveryCodes(w http.ResponseWriter, r *http.Request) {
+    uid, ok := akunSendiri(w, r)
+    if !ok { return }
+    code, ok := decodeCode(w, r)
+    if !ok { return }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Check(ctx, uid, code, ""); err != nil {
+        writeTOTPError(w, err)
+        return
+    }
+    codes, err := h.Repo.NewRecoveryCodes(ctx, uid)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "2fa_recovery_codes", Entitas: "user", EntitasID: uid})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "New recovery codes; the old ones no longer work", Data: map[string][]string{"recovery_codes": codes}})
+}
+
+// POST /api/me/2fa/disable turns two-factor authentication off. A current
+// code is required, and users whose role requires it cannot turn it off.
+func (h *TOTPHandler) Disable(w http.ResponseWriter, r *http.Request) {
+    uid, ok := akunSendiri(w, r)
+    if !ok { return }
+    code, ok := decodeCode(w, r)
+    if !ok { return }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    st, err := h.Repo.Status(ctx, uid)
+    if err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if st.Wajib {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "your role requires two-factor authentication"})
+        return
+    }
+    if err := h.Repo.Check(ctx, uid, code, ""); err != nil {
+        writeTOTPError(w, err)
+        return
+    }
+    if err := h.Repo.Disable(ctx, uid); err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "2fa_disable", Entitas: "user", EntitasID: uid})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Two-factor authentication disabled"})
+}
+
+// POST /api/users/{id}/reset-2fa turns two-factor authentication off for a
+// user who lost their device. If their role requires it, they enroll again
+// at the next login.
+func (h *TOTPHandler) Reset(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.Disable(ctx, id); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "2fa_reset", Entitas: "user"})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Two-factor authentication reset", Data: map[string]int64{"id": id}})
+}
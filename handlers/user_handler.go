@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/permission"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// minPasswordLen is the shortest accepted password.
+const minPasswordLen = 8
+
+// UserHandler manages users (admin only) and the caller's own account (/api/me).
+type UserHandler struct {
+    Repo  *repositories.UserRepo
+    Sesi  *repositories.SesiRepo
+    Roles *repositories.RoleRepo
+}
+
+func NewUserHandler(repo *repositories.UserRepo, sesi *repositories.SesiRepo, roles *repositories.RoleRepo) *UserHandler {
+    return &UserHandler{Repo: repo, Sesi: sesi, Roles: roles}
+}
+
+type userRequest struct {
+    Username string `json:"username"`
+    Password string `json:"password"`
+    Email    string `json:"email"`
+    FullName string `json:"full_name"`
+    Role     string `json:"role"`
+    Service  bool   `json:"service"`  // create only
+}
+
+type passwordRequest struct {
+    OldPassword string `json:"old_password"`
+    NewPassword string `json:"new_password"`
+}
+
+// validUser checks the fields shared by create and update.
+func validUser(w http.ResponseWriter, req *userRequest) bool {
+    req.Email, req.FullName, req.Role = strings.TrimSpace(req.Email), strings.TrimSpace(req.FullName), strings.TrimSpace(req.Role)
+    if req.Email == "" || req.FullName == "" || req.Role == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "email, full_name and role are required"})
+        return false
+    }
+    if _, err := mail.ParseAddress(req.Email); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid email"})
+        return false
+    }
+    return true
+}
+
+// bolehBeriRole reports whether a caller holding callerPerms may give a
+// user the role granting rolePerms. With role.manage any role may be given
+// (the caller could edit the role anyway); otherwise only roles whose every
+// permission the caller holds, so user.manage alone cannot be used to hand
+// out more access, e.g. admin, than the caller has.
+func bolehBeriRole(callerPerms, rolePerms []string) bool {
+    punya := make(map[string]bool, len(callerPerms))
+    for _, p := range callerPerms { punya[p] = true }
+    if punya[permission.RoleManage] { return true }
+    for _, p := range rolePerms {
+        if !punya[p] { return false }
+    }
+    return true
+}
+
+// cekRole checks that role exists and that the caller may give it. It
+// writes the error response and returns false otherwise.
+func (h *UserHandler) cekRole(ctx context.Context, w http.ResponseWriter, role string) bool {
+    ro, err := h.Roles.Get(ctx, role)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return false
+    }
+    if ro == nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "role not found"})
+        return false
+    }
+    perms, _ := middleware.PermissionsFromContext(ctx)
+    if !bolehBeriRole(perms, ro.Permissions) {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "giving role " + role + " requires " + permission.RoleManage + " or every permission of the role"})
+        return false
+    }
+    return true
+}
+
+// hashPassword checks the length of a new password and hashes it with
+// bcrypt. It writes the error response and returns false on failure.
+func hashPassword(w http.ResponseWriter, password string) (string, bool) {
+    if len(password) < minPasswordLen {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "password must be at least " + strconv.Itoa(minPasswordLen) + " characters"})
+        return "", false
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        // bcrypt rejects passwords over 72 bytes.
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: err.Error()})
+        return "", false
+    }
+    return string(hash), true
+}
+
+// GET /api/users?search=&aktif=&page=&limit=
+func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    page, _ := strconv.Atoi(q.Get("page"))
+    limit, _ := strconv.Atoi(q.Get("limit"))
+    if page <= 0 { page = 1 }
+    if limit <= 0 { limit = 10 }
+    var aktif *bool
+    if v := q.Get("aktif"); v != "" {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "aktif must be true or false"})
+            return
+        }
+        aktif = &b
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, total, err := h.Repo.List(ctx, strings.TrimSpace(q.Get("search")), aktif, page, limit)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list, Meta: &Meta{Page: page, Limit: limit, Total: total}})
+}
+
+// GET /api/users/{id}
+func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    h.writeUser(w, r, id, nil)
+}
+
+// GET /api/me
+func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
+    uid, ok := middleware.UserIDFromContext(r.Context())
+    if !ok {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    perms, _ := middleware.PermissionsFromContext(r.Context())
+    h.writeUser(w, r, uid, perms)
+}
+
+// writeUser responds with user id; perms, when not nil, are shown as the
+// user's permissions.
+func (h *UserHandler) writeUser(w http.ResponseWriter, r *http.Request, id int64, perms []string) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    u, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if u == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    u.Permissions = perms
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: u})
+}
+
+// POST /api/users
+func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
+    var req userRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    req.Username = strings.TrimSpace(req.Username)
+    if req.Username == "" || len(req.Username) > 50 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "username is required (max 50 characters)"})
+        return
+    }
+    if !validUser(w, &req) { return }
+    hash, ok := hashPassword(w, req.Password)
+    if !ok { return }
+
+    u := models.User{Username: req.Username, Password: hash, Email: req.Email, FullName: req.FullName, Role: req.Role, Service: req.Service}
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if !h.cekRole(ctx, w, u.Role) { return }
+    if err := h.Repo.Create(ctx, &u); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "user", EntitasID: u.ID, Sesudah: u})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: u})
+}
+
+// PUT /api/users/{id} changes email, full_name and the role in the current
+// tenant. Username and password are not changed here. See bolehBeriRole for
+// who may change the role and cekDikelola for email and full_name.
+func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var req userRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if !validUser(w, &req) { return }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if before == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    if req.Role != before.Role && !h.cekRole(ctx, w, req.Role) { return }
+    // Email and name belong to the account, shared by all of the user's
+    // tenants; the role is the one in this tenant.
+    if (req.Email != before.Email || req.FullName != before.FullName) && !cekDikelola(ctx, w, h.Repo, id) { return }
+    u := *before
+    u.Email, u.FullName, u.Role = req.Email, req.FullName, req.Role
+    if err := h.Repo.Update(ctx, &u); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Entitas: "user", Sebelum: before, Sesudah: u})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: u})
+}
+
+// POST /api/users/{id}/deactivate
+func (h *UserHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+    h.setAktif(w, r, false, "deactivate", "deactivated")
+}
+
+// POST /api/users/{id}/activate
+func (h *UserHandler) Activate(w http.ResponseWriter, r *http.Request) {
+    h.setAktif(w, r, true, "activate", "activated")
+}
+
+func (h *UserHandler) setAktif(w http.ResponseWriter, r *http.Request, aktif bool, aksi, msg string) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    if uid, _ := middleware.UserIDFromContext(r.Context()); uid == id && !aktif {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "you cannot deactivate yourself"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.Repo.SetAktif(ctx, id, aktif); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    u, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: aksi, Entitas: "user", Sesudah: u})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: msg, Data: u})
+}
+
+// POST /api/users/{id}/revoke-sessions logs the user out everywhere: all
+// refresh tokens stop working and access tokens are rejected.
+func (h *UserHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    u, err := h.Repo.GetByID(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if u == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    n, err := h.Sesi.RevokeUser(ctx, id, "")
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "revoke_sessions", Entitas: "user", Sesudah: map[string]int64{"sesi": n}})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "sessions revoked", Data: map[string]int64{"revoked": n}})
+}
+
+// POST /api/me/password changes the caller's password after verifying the
+// old one and logs out the caller's other sessions.
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+    uid, ok := middleware.UserIDFromContext(r.Context())
+    if !ok {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    if _, viaKey := middleware.APIKeyFromContext(r.Context()); viaKey {
+        WriteJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "passwords cannot be changed with an API key"})
+        return
+    }
+    var req passwordRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if req.OldPassword == "" || req.NewPassword == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "old_password and new_password are required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    u, err := h.Repo.GetByID(ctx, uid)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if u == nil {
+        WriteJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Message: "unauthorized"})
+        return
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.OldPassword)); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "old password is incorrect"})
+        return
+    }
+    hash, ok := hashPassword(w, req.NewPassword)
+    if !ok { return }
+    if err := h.Repo.UpdatePassword(ctx, uid, hash); err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    // Other sessions may have been opened with the old password.
+    sid, _ := middleware.SessionFromContext(r.Context())
+    if _, err := h.Sesi.RevokeUser(ctx, uid, sid); err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "change_password", Entitas: "user", EntitasID: uid})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "password changed"})
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+
+	"warehouse/permission"
+)
+
+func TestBolehBeriRole(t *testing.T) {
+    semua := make([]string, 0, len(permission.All))
+    for _, d := range permission.All { semua = append(semua, d.Kode) }
+    kasir := []string{permission.BarangView, permission.PenjualanCreate}
+    userManager := []string{permission.UserManage, permission.BarangView, permission.PenjualanCreate}
+
+    tests := []struct {
+        name   string
+        caller []string
+        role   []string
+        want   bool
+    }{
+        {"user.manage cannot give admin", userManager, semua, false},
+        {"user.manage gives a role within its own permissions", userManager, kasir, true},
+        {"user.manage cannot give a role with one extra permission", userManager, append(kasir, permission.LaporanView), false},
+        {"user.manage cannot give a role with user.manage plus more", userManager, []string{permission.UserManage, permission.RoleManage}, false},
+        {"role.manage gives any role", []string{permission.UserManage, permission.RoleManage}, semua, true},
+        {"admin gives admin", semua, semua, true},
+        {"role without permissions", userManager, nil, true},
+        {"no permissions at all", nil, kasir, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := bolehBeriRole(tt.caller, tt.role); got != tt.want {
+                t.Errorf("bolehBeriRole = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
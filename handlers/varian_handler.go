@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/audit"
+	"warehouse/middleware"
+	"warehouse/models"
+	"warehouse/repositories"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// VarianHandler serves parent products (barang induk) and their variants.
+type VarianHandler struct {
+    Repo *repositories.VarianRepo
+}
+
+func NewVarianHandler(repo *repositories.VarianRepo) *VarianHandler { return &VarianHandler{Repo: repo} }
+
+type indukRequest struct {
+    models.BarangInduk
+    HargaVarian []models.HargaVarian `json:"harga_varian,omitempty"`
+}
+
+type tambahVarianRequest struct {
+    Atribut     []models.AtributVarian `json:"atribut"`
+    HargaVarian []models.HargaVarian   `json:"harga_varian,omitempty"`
+}
+
+// validInduk checks the parent fields shared by create and update.
+func validInduk(w http.ResponseWriter, in *models.BarangInduk) bool {
+    in.Nama, in.Satuan = strings.TrimSpace(in.Nama), strings.TrimSpace(in.Satuan)
+    if in.Nama == "" || in.Satuan == "" {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "nama and satuan are required"})
+        return false
+    }
+    if in.HargaBeli < 0 || in.HargaJual < 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "harga_beli and harga_jual must be >= 0"})
+        return false
+    }
+    return true
+}
+
+// GET /api/barang-induk?search=&page=&limit=
+func (h *VarianHandler) List(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    page, _ := strconv.Atoi(q.Get("page"))
+    limit, _ := strconv.Atoi(q.Get("limit"))
+    if page <= 0 { page = 1 }
+    if limit <= 0 { limit = 10 }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    list, total, err := h.Repo.ListInduk(ctx, q.Get("search"), page, limit)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: list, Meta: &Meta{Page: page, Limit: limit, Total: total}})
+}
+
+// GET /api/barang-induk/{id} returns the parent with every variant and its
+// stock, plus the totals over all variants.
+func (h *VarianHandler) Get(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    in, err := h.Repo.GetInduk(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if in == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "OK", Data: in})
+}
+
+// POST /api/barang-induk creates the parent and one variant per combination
+// of its attribute values. kode_induk is generated server-side.
+func (h *VarianHandler) Create(w http.ResponseWriter, r *http.Request) {
+    var req indukRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    in := req.BarangInduk
+    if !validInduk(w, &in) { return }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    kode, err := h.Repo.GenerateKodeInduk(ctx)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    in.KodeInduk = kode
+    if err := h.Repo.CreateInduk(ctx, &in, req.HargaVarian); err != nil {
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    created, err := h.Repo.GetInduk(ctx, in.ID)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{EntitasID: in.ID, Sesudah: created})
+    WriteJSON(w, http.StatusCreated, APIResponse{Success: true, Message: "created", Data: created})
+}
+
+// PUT /api/barang-induk/{id} updates the parent. Satuan and kategori are
+// applied to every variant, prices to variants without prices of their own.
+// Attributes, kode and variant names are not changed here.
+func (h *VarianHandler) Update(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var in models.BarangInduk
+    if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    in.ID = id
+    if !validInduk(w, &in) { return }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetInduk(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if before == nil {
+        WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+        return
+    }
+    uid, _ := middleware.UserIDFromContext(ctx)
+    if err := h.Repo.UpdateInduk(ctx, &in, uid); err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    updated, err := h.Repo.GetInduk(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Sebelum: before, Sesudah: updated})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "updated", Data: updated})
+}
+
+// POST /api/barang-induk/{id}/varian adds values to existing attributes,
+// e.g. {"atribut": [{"nama": "warna", "nilai": ["Hijau"]}]}, and creates the
+// missing variants.
+func (h *VarianHandler) TambahVarian(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    var req tambahVarianRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid json"})
+        return
+    }
+    if len(req.Atribut) == 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "atribut is required"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+    defer cancel()
+    n, err := h.Repo.TambahVarian(ctx, id, req.Atribut, req.HargaVarian)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, StatusFromError(err), APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    in, err := h.Repo.GetInduk(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Aksi: "add_variant", Sesudah: req})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: strconv.Itoa(n) + " variants created", Data: in})
+}
+
+// DELETE /api/barang-induk/{id}; only possible once it has no variants.
+func (h *VarianHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+    if id <= 0 {
+        WriteJSON(w, http.StatusUnprocessableEntity, APIResponse{Success: false, Message: "invalid id"})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    before, err := h.Repo.GetInduk(ctx, id)
+    if err != nil {
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    if err := h.Repo.DeleteInduk(ctx, id); err != nil {
+        if err == repositories.ErrIndukInUse {
+            WriteJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "Barang induk masih memiliki varian dan tidak dapat dihapus"})
+            return
+        }
+        if err == sql.ErrNoRows {
+            WriteJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "not found"})
+            return
+        }
+        WriteJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+        return
+    }
+    audit.Record(ctx, audit.Perubahan{Sebelum: before})
+    WriteJSON(w, http.StatusOK, APIResponse{Success: true, Message: "deleted", Data: map[string]int64{"id": id}})
+}
@@ -0,0 +1,235 @@
+// Package invoice renders printable documents (faktur penjualan, faktur
+// pembelian and surat jalan) as PDF.
+//
+// Documents are described by text/template files that produce a small
+// line-based layout script, so the look can be changed without recompiling.
+// The defaults are embedded; a file with the same name in the template
+// directory (INVOICE_TEMPLATE_DIR) overrides one and is re-read on every
+// render. One command per line, fields separated by "|":
+//
+//	font|regular|10            select font (regular or bold) and size in points
+//	text|40|l|Some text        draw text at x, aligned l(eft), r(ight) or c(enter)
+//	nl                         move down one line (1.4 x font size)
+//	nl|20                      move down 20 points
+//	line|40|555|0.5            horizontal rule from x1 to x2 with the given width
+//	gray|0.5                   set the colour (0 black .. 1 white)
+//	logo|40|48                 company logo at x, 48 points high, top at the cursor
+//	page                       start a new page
+//
+// Blank lines and lines starting with "#" are ignored. The cursor starts at
+// the top margin and moves down with nl; a new page is started when it
+// passes the bottom margin.
+package invoice
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"warehouse/format"
+	"warehouse/pdf"
+)
+
+//go:embed templates/*.tmpl
+var defaults embed.FS
+
+// Template names.
+const (
+    Penjualan  = "penjualan"
+    Pembelian  = "pembelian"
+    SuratJalan = "surat_jalan"
+)
+
+// Page margins in points.
+const (
+    marginTop    = 40.0
+    marginBottom = 50.0
+)
+
+// Perusahaan is the company printed on every document.
+type Perusahaan struct {
+    Nama    string
+    Alamat  string
+    Telepon string
+    NPWP    string
+    Logo    []byte // JPEG, optional
+}
+
+// Dokumen is the data passed to a template.
+type Dokumen struct {
+    Perusahaan Perusahaan
+    Faktur     any    // *models.JualHeader or *models.BeliHeader
+    Kasir      string // full name of the user who entered the transaction
+    Dicetak    time.Time
+}
+
+// Renderer renders documents from the embedded templates, overridden by
+// files in Dir when Dir is set.
+type Renderer struct {
+    Dir        string
+    Perusahaan Perusahaan
+}
+
+func NewRenderer(dir string, p Perusahaan) *Renderer {
+    return &Renderer{Dir: dir, Perusahaan: p}
+}
+
+var funcs = template.FuncMap{
+    "rupiah":    format.Rupiah,
+    "ribuan":    format.Ribuan,
+    "terbilang": format.Terbilang,
+    "tanggal":   tanggal,
+    "persen":    format.Persen,
+    "potong":    potong,
+    "inc":       func(i int) int { return i + 1 },
+}
+
+var bulan = [...]string{"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli",
+    "Agustus", "September", "Oktober", "November", "Desember"}
+
+// tanggal formats t as "2 Januari 2026".
+func tanggal(t time.Time) string {
+    return fmt.Sprintf("%d %s %d", t.Day(), bulan[t.Month()-1], t.Year())
+}
+
+// potong puts s on one line and cuts it to at most n runes. Use it for every
+// free-text field so a value cannot break the layout script.
+func potong(n int, s string) string {
+    s = strings.Join(strings.Fields(s), " ")
+    r := []rune(s)
+    if len(r) <= n { return s }
+    if n <= 3 { return string(r[:n]) }
+    return string(r[:n-3]) + "..."
+}
+
+// template loads name from Dir when present, else from the embedded defaults.
+func (r *Renderer) template(name string) (*template.Template, error) {
+    file := name + ".tmpl"
+    var (
+        src []byte
+        err error
+    )
+    if r.Dir != "" {
+        src, err = os.ReadFile(filepath.Join(r.Dir, file))
+        if err != nil && !errors.Is(err, os.ErrNotExist) {
+            return nil, fmt.Errorf("invoice: %w", err)
+        }
+    }
+    if src == nil {
+        if src, err = defaults.ReadFile("templates/" + file); err != nil {
+            return nil, fmt.Errorf("invoice: unknown template %s", name)
+        }
+    }
+    t, err := template.New(file).Funcs(funcs).Parse(string(src))
+    if err != nil { return nil, fmt.Errorf("invoice: %w", err) }
+    return t, nil
+}
+
+// Render executes template name with faktur and writes the PDF to w.
+func (r *Renderer) Render(w io.Writer, name string, faktur any, kasir string) error {
+    t, err := r.template(name)
+    if err != nil { return err }
+    var script bytes.Buffer
+    data := Dokumen{Perusahaan: r.Perusahaan, Faktur: faktur, Kasir: kasir, Dicetak: time.Now()}
+    if err := t.Execute(&script, data); err != nil {
+        return fmt.Errorf("invoice: %w", err)
+    }
+    doc := pdf.New(pdf.A4Width, pdf.A4Height)
+    if err := r.layout(doc, &script); err != nil { return err }
+    return doc.Write(w)
+}
+
+// layout interprets the script produced by a template.
+func (r *Renderer) layout(doc *pdf.Document, script io.Reader) error {
+    var logo string
+    var logoW, logoH int
+    if len(r.Perusahaan.Logo) > 0 {
+        name, err := doc.AddJPEG(r.Perusahaan.Logo)
+        if err != nil { return fmt.Errorf("invoice: logo: %w", err) }
+        cfg, _ := jpeg.DecodeConfig(bytes.NewReader(r.Perusahaan.Logo))
+        logo, logoW, logoH = name, cfg.Width, cfg.Height
+    }
+
+    page := doc.AddPage()
+    font, size, y := pdf.Helvetica, 10.0, marginTop
+    gray := 0.0
+    newPage := func() {
+        page = doc.AddPage()
+        y = marginTop
+        if gray != 0 { page.SetGray(gray) }
+    }
+
+    sc := bufio.NewScanner(script)
+    for n := 1; sc.Scan(); n++ {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") { continue }
+        f := strings.SplitN(line, "|", 4)
+        bad := func() error { return fmt.Errorf("invoice: line %d: invalid %q", n, line) }
+        switch f[0] {
+        case "font":
+            if len(f) != 3 { return bad() }
+            s, err := strconv.ParseFloat(f[2], 64)
+            if err != nil || s <= 0 { return bad() }
+            font, size = pdf.Helvetica, s
+            if f[1] == "bold" { font = pdf.HelveticaBold }
+        case "text":
+            if len(f) != 4 { return bad() }
+            x, err := strconv.ParseFloat(f[1], 64)
+            if err != nil { return bad() }
+            base := pdf.A4Height - y - size*0.8
+            switch f[2] {
+            case "r":
+                page.TextRight(font, size, x, base, f[3])
+            case "c":
+                page.Text(font, size, x-pdf.TextWidth(font, size, f[3])/2, base, f[3])
+            default:
+                page.Text(font, size, x, base, f[3])
+            }
+        case "nl":
+            dy := size * 1.4
+            if len(f) > 1 {
+                v, err := strconv.ParseFloat(f[1], 64)
+                if err != nil { return bad() }
+                dy = v
+            }
+            y += dy
+            if y > pdf.A4Height-marginBottom { newPage() }
+        case "line":
+            if len(f) != 4 { return bad() }
+            x1, e1 := strconv.ParseFloat(f[1], 64)
+            x2, e2 := strconv.ParseFloat(f[2], 64)
+            lw, e3 := strconv.ParseFloat(f[3], 64)
+            if e1 != nil || e2 != nil || e3 != nil { return bad() }
+            page.Line(x1, pdf.A4Height-y, x2, pdf.A4Height-y, lw)
+        case "gray":
+            if len(f) != 2 { return bad() }
+            g, err := strconv.ParseFloat(f[1], 64)
+            if err != nil { return bad() }
+            gray = g
+            page.SetGray(g)
+        case "logo":
+            if len(f) != 3 { return bad() }
+            x, e1 := strconv.ParseFloat(f[1], 64)
+            h, e2 := strconv.ParseFloat(f[2], 64)
+            if e1 != nil || e2 != nil { return bad() }
+            if logo == "" || logoH == 0 { continue }
+            w := h * float64(logoW) / float64(logoH)
+            page.Image(logo, x, pdf.A4Height-y-h, w, h)
+        case "page":
+            newPage()
+        default:
+            return bad()
+        }
+    }
+    return sc.Err()
+}
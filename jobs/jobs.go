@@ -0,0 +1,113 @@
+// Package jobs runs long operations (such as large imports) in the
+// background and keeps their progress in memory so clients can poll it.
+// Jobs do not survive a restart; finished jobs are forgotten after Retain.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"warehouse/tenant"
+)
+
+// Job statuses.
+const (
+    StatusBerjalan = "berjalan"
+    StatusSelesai  = "selesai"
+    StatusGagal    = "gagal"
+)
+
+// Job is a snapshot of a background job.
+type Job struct {
+    ID        string     `json:"id"`
+    Jenis     string     `json:"jenis"`
+    UserID    int64      `json:"user_id"`
+    TenantID  int64      `json:"-"`
+    Status    string     `json:"status"`
+    Total     int        `json:"total"`
+    Diproses  int        `json:"diproses"`
+    Hasil     any        `json:"hasil,omitempty"`
+    Error     string     `json:"error,omitempty"`
+    CreatedAt time.Time  `json:"created_at"`
+    SelesaiAt *time.Time `json:"selesai_at,omitempty"`
+}
+
+// Func does the work of a job. It reports progress as the number of items
+// processed so far and returns the job result.
+type Func func(ctx context.Context, progress func(done int)) (any, error)
+
+// Manager keeps track of running and recently finished jobs.
+type Manager struct {
+    Timeout time.Duration // per job
+    Retain  time.Duration // how long finished jobs can be polled
+
+    mu   sync.Mutex
+    jobs map[string]*Job
+}
+
+func NewManager(timeout, retain time.Duration) *Manager {
+    if timeout <= 0 { timeout = 30 * time.Minute }
+    if retain <= 0 { retain = time.Hour }
+    return &Manager{Timeout: timeout, Retain: retain, jobs: make(map[string]*Job)}
+}
+
+// Start runs fn in a new goroutine and returns the job as registered.
+// total is the number of items the job will process, for progress display.
+// fn gets the values of ctx (such as the tenant) but not its cancellation,
+// so the job outlives the request starting it.
+func (m *Manager) Start(ctx context.Context, jenis string, userID int64, total int, fn Func) Job {
+    var b [12]byte
+    _, _ = rand.Read(b[:])
+    tid, _ := tenant.FromContext(ctx)
+    j := &Job{ID: hex.EncodeToString(b[:]), Jenis: jenis, UserID: userID, TenantID: tid, Status: StatusBerjalan, Total: total, CreatedAt: time.Now()}
+
+    m.mu.Lock()
+    m.prune(j.CreatedAt)
+    m.jobs[j.ID] = j
+    snap := *j
+    m.mu.Unlock()
+
+    go func() {
+        ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.Timeout)
+        defer cancel()
+        progress := func(done int) {
+            m.mu.Lock()
+            j.Diproses = done
+            m.mu.Unlock()
+        }
+        hasil, err := fn(ctx, progress)
+        now := time.Now()
+        m.mu.Lock()
+        defer m.mu.Unlock()
+        j.Hasil, j.SelesaiAt = hasil, &now
+        if err != nil {
+            log.Printf("job %s (%s): %v", j.ID, j.Jenis, err)
+            j.Status, j.Error = StatusGagal, err.Error()
+            return
+        }
+        j.Status = StatusSelesai
+    }()
+    return snap
+}
+
+// Get returns a snapshot of job id, or false when it is unknown or expired.
+func (m *Manager) Get(id string) (Job, bool) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    j, ok := m.jobs[id]
+    if !ok { return Job{}, false }
+    return *j, true
+}
+
+// prune forgets jobs that finished more than Retain ago. Callers hold mu.
+func (m *Manager) prune(now time.Time) {
+    for id, j := range m.jobs {
+        if j.SelesaiAt != nil && now.Sub(*j.SelesaiAt) > m.Retain {
+            delete(m.jobs, id)
+        }
+    }
+}
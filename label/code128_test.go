@@ -0,0 +1,69 @@
+package label
+
+import (
+	"reflect"
+	"testing"
+)
+
+// code128Symbols splits modules back into Code 128 symbol values.
+func code128Symbols(t *testing.T, modules []bool) []int {
+    t.Helper()
+    var widths []byte
+    for i := 0; i < len(modules); {
+        j := i
+        for j < len(modules) && modules[j] == modules[i] { j++ }
+        widths = append(widths, byte('0'+j-i))
+        i = j
+    }
+    var out []int
+    for len(widths) > 0 {
+        n := 6
+        if len(widths) == 7 { n = 7 } // stop pattern
+        if len(widths) < n { t.Fatalf("trailing widths %q", widths) }
+        v := -1
+        for k, p := range code128Patterns {
+            if p == string(widths[:n]) { v = k }
+        }
+        if v < 0 { t.Fatalf("unknown pattern %q", widths[:n]) }
+        out = append(out, v)
+        widths = widths[n:]
+    }
+    return out
+}
+
+func TestCode128(t *testing.T) {
+    tests := []struct {
+        text string
+        want []int // start, data, checksum, stop
+    }{
+        {"12", []int{105, 12, 14, 106}},
+        {"123456", []int{105, 12, 34, 56, 44, 106}},
+        {"123", []int{104, 17, 18, 19, 8, 106}},
+        {"AB", []int{104, 33, 34, 102, 106}},
+        {"BRG-0001", []int{104, 34, 50, 39, 13, 16, 16, 16, 17, 7, 106}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.text, func(t *testing.T) {
+            modules, err := Code128(tt.text)
+            if err != nil { t.Fatal(err) }
+            if !modules[0] || !modules[len(modules)-1] {
+                t.Error("symbol must start and end with a bar")
+            }
+            if want := 11*(len(tt.want)-1) + 13; len(modules) != want {
+                t.Errorf("len = %d, want %d", len(modules), want)
+            }
+            got := code128Symbols(t, modules)
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("symbols = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestCode128Invalid(t *testing.T) {
+    for _, text := range []string{"", "café", "tab\there"} {
+        if _, err := Code128(text); err == nil {
+            t.Errorf("Code128(%q) succeeded, want error", text)
+        }
+    }
+}
@@ -0,0 +1,47 @@
+package label
+
+import (
+	"errors"
+
+	"warehouse/barcode"
+)
+
+var (
+    eanL = [10]string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+    eanG = [10]string{"0100111", "0110011", "0011011", "0100001", "0011101", "0111001", "0000101", "0010001", "0001001", "0010111"}
+    eanR = [10]string{"1110010", "1100110", "1101100", "1000010", "1011100", "1001110", "1010000", "1000100", "1001000", "1110100"}
+    // eanParity selects L or G codes for the left half from the first digit.
+    eanParity = [10]string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLG", "LGLGGL", "LGLGLG", "LGGLGL"}
+)
+
+// EAN13 encodes a 13-digit code (check digit included) into its 95 modules,
+// without quiet zones.
+func EAN13(code string) ([]bool, error) {
+    if len(code) != 13 {
+        return nil, errors.New("ean13: code must have 13 digits")
+    }
+    if err := barcode.Validate(code); err != nil {
+        return nil, err
+    }
+    bits := "101"
+    parity := eanParity[code[0]-'0']
+    for i := 1; i <= 6; i++ {
+        d := code[i] - '0'
+        if parity[i-1] == 'L' {
+            bits += eanL[d]
+        } else {
+            bits += eanG[d]
+        }
+    }
+    bits += "01010"
+    for i := 7; i <= 12; i++ {
+        bits += eanR[code[i]-'0']
+    }
+    bits += "101"
+
+    modules := make([]bool, len(bits))
+    for i := range bits {
+        modules[i] = bits[i] == '1'
+    }
+    return modules, nil
+}
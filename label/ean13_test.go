@@ -0,0 +1,45 @@
+package label
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEAN13(t *testing.T) {
+    tests := []struct {
+        code string
+        want string // modules as 0/1, spaces for readability
+    }{
+        {
+            // first digit 5: parity LGGLLG
+            code: "5901234123457",
+            want: "101 0001011 0100111 0110011 0010011 0111101 0011101 01010 1100110 1101100 1000010 1011100 1001110 1000100 101",
+        },
+        {
+            // first digit 0: all L, i.e. a UPC-A code
+            code: "0036000291452",
+            want: "101 0001101 0111101 0101111 0001101 0001101 0001101 01010 1101100 1110100 1100110 1011100 1001110 1101100 101",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.code, func(t *testing.T) {
+            modules, err := EAN13(tt.code)
+            if err != nil { t.Fatal(err) }
+            var b strings.Builder
+            for _, m := range modules {
+                if m { b.WriteByte('1') } else { b.WriteByte('0') }
+            }
+            if want := strings.ReplaceAll(tt.want, " ", ""); b.String() != want {
+                t.Errorf("modules = %s, want %s", b.String(), want)
+            }
+        })
+    }
+}
+
+func TestEAN13Invalid(t *testing.T) {
+    for _, code := range []string{"", "96385074", "036000291452", "5901234123458", "590123412345A"} {
+        if _, err := EAN13(code); err == nil {
+            t.Errorf("EAN13(%q) succeeded, want error", code)
+        }
+    }
+}
@@ -0,0 +1,299 @@
+// Package label renders shelf labels (nama, harga, a linear barcode and a QR
+// code) as PNG, as an A4 PDF sheet or as ZPL for thermal printers. Barcodes
+// are encoded here in pure Go.
+package label
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+
+	"warehouse/format"
+	"warehouse/pdf"
+)
+
+// Output formats.
+const (
+    FormatPNG = "png"
+    FormatPDF = "pdf"
+    FormatZPL = "zpl"
+)
+
+// ErrFormat is returned for an unknown output format.
+var ErrFormat = errors.New("format must be png, pdf or zpl")
+
+// Label size in points (about 63.5 x 31.75 mm); 3 x 9 fit on an A4 sheet.
+const (
+    labelWidth  = 180.0
+    labelHeight = 90.0
+    labelPad    = 6.0
+    pngScale    = 4.0 // pixels per point, about 288 dpi
+)
+
+// Item is the data printed on one label.
+type Item struct {
+    Kode    string // kode_barang
+    Nama    string
+    Harga   int64
+    Barcode string // primary barcode; empty when the barang has none
+}
+
+// Value is what both symbols encode: the barcode when present, else kode_barang.
+func (it Item) Value() string {
+    if it.Barcode != "" { return it.Barcode }
+    return it.Kode
+}
+
+// linear returns the EAN-13 modules for a 13-digit barcode, else Code 128.
+func (it Item) linear() ([]bool, error) {
+    if len(it.Barcode) == 13 {
+        return EAN13(it.Barcode)
+    }
+    return Code128(it.Value())
+}
+
+// ContentType returns the MIME type of an output format.
+func ContentType(f string) string {
+    switch f {
+    case FormatPDF:
+        return "application/pdf"
+    case FormatZPL:
+        return "text/plain; charset=utf-8"
+    default:
+        return "image/png"
+    }
+}
+
+// Render writes copies labels of every item in the given format.
+func Render(w io.Writer, f string, items []Item, copies int) error {
+    if copies < 1 { copies = 1 }
+    switch f {
+    case FormatPNG:
+        return renderPNG(w, items, copies)
+    case FormatPDF:
+        return renderPDF(w, items, copies)
+    case FormatZPL:
+        return renderZPL(w, items, copies)
+    }
+    return ErrFormat
+}
+
+// drawer abstracts the raster and PDF back ends. Coordinates are points with
+// the origin at the top-left; text y is the top of the text.
+type drawer interface {
+    rect(x, y, w, h float64)
+    text(x, y, size float64, bold bool, s string)
+    textWidth(size float64, bold bool, s string) float64
+    dot() float64 // smallest addressable size, used to keep modules uniform
+}
+
+func snap(v, dot float64) float64 {
+    if s := math.Floor(v/dot) * dot; s >= dot { return s }
+    return dot
+}
+
+// fit shortens s with "..." until it is at most width wide.
+func fit(d drawer, s string, size float64, bold bool, width float64) string {
+    if d.textWidth(size, bold, s) <= width { return s }
+    r := []rune(s)
+    for len(r) > 0 && d.textWidth(size, bold, string(r)+"...") > width {
+        r = r[:len(r)-1]
+    }
+    return string(r) + "..."
+}
+
+// drawLabel lays out one label with its top-left corner at (x0, y0).
+func drawLabel(d drawer, x0, y0 float64, it Item) error {
+    bars, err := it.linear()
+    if err != nil { return err }
+    qr, err := EncodeQR(it.Value())
+    if err != nil { return err }
+
+    inner := labelWidth - 2*labelPad
+    d.text(x0+labelPad, y0+labelPad, 9, true, fit(d, it.Nama, 9, true, inner))
+    d.text(x0+labelPad, y0+labelPad+12, 14, true, format.Rupiah(it.Harga))
+
+    // QR code in the bottom-right corner, two light modules of quiet zone.
+    box := 52.0
+    m := snap(box/float64(qr.Size+4), d.dot())
+    qx := x0 + labelWidth - labelPad - m*float64(qr.Size+2)
+    qy := y0 + labelHeight - labelPad - m*float64(qr.Size+2)
+    for y := 0; y < qr.Size; y++ {
+        for x := 0; x < qr.Size; {
+            if !qr.Dark(x, y) { x++; continue }
+            run := 1
+            for x+run < qr.Size && qr.Dark(x+run, y) { run++ }
+            d.rect(qx+float64(x)*m, qy+float64(y)*m, float64(run)*m, m)
+            x += run
+        }
+    }
+
+    // Linear barcode on the left with 10 modules of quiet zone each side.
+    const quiet = 10
+    avail := qx - x0 - labelPad
+    n := len(bars) + 2*quiet
+    bm := snap(avail/float64(n), d.dot())
+    bx := x0 + labelPad + bm*quiet
+    by, bh := y0+38, 32.0
+    for i := 0; i < len(bars); {
+        if !bars[i] { i++; continue }
+        run := 1
+        for i+run < len(bars) && bars[i+run] { run++ }
+        d.rect(bx+float64(i)*bm, by, float64(run)*bm, bh)
+        i += run
+    }
+    value := fit(d, it.Value(), 7, false, bm*float64(len(bars)))
+    tw := d.textWidth(7, false, value)
+    d.text(bx+(bm*float64(len(bars))-tw)/2, by+bh+2, 7, false, value)
+    return nil
+}
+
+// rasterDrawer draws black on a white grayscale image with the bitmap font.
+type rasterDrawer struct {
+    img *image.Gray
+}
+
+func (r *rasterDrawer) dot() float64 { return 1 / pngScale }
+
+func (r *rasterDrawer) rect(x, y, w, h float64) {
+    x0, y0 := int(math.Round(x*pngScale)), int(math.Round(y*pngScale))
+    x1, y1 := int(math.Round((x+w)*pngScale)), int(math.Round((y+h)*pngScale))
+    for py := y0; py < y1; py++ {
+        for px := x0; px < x1; px++ {
+            r.img.SetGray(px, py, color.Gray{Y: 0})
+        }
+    }
+}
+
+func fontScale(size float64) int {
+    return max(1, int(math.Round(size*pngScale/8)))
+}
+
+func (r *rasterDrawer) textWidth(size float64, bold bool, s string) float64 {
+    return float64(len([]rune(s))*6*fontScale(size)) / pngScale
+}
+
+func (r *rasterDrawer) text(x, y, size float64, bold bool, s string) {
+    sc := fontScale(size)
+    px, py := int(math.Round(x*pngScale)), int(math.Round(y*pngScale))
+    for _, ch := range s {
+        if ch < 32 || ch > 126 { ch = '?' }
+        glyph := font5x8[ch-32]
+        for col := 0; col < 5; col++ {
+            for row := 0; row < 8; row++ {
+                if glyph[col]&(1<<uint(row)) == 0 { continue }
+                for dy := 0; dy < sc; dy++ {
+                    for dx := 0; dx < sc; dx++ {
+                        r.img.SetGray(px+col*sc+dx, py+row*sc+dy, color.Gray{Y: 0})
+                        if bold {
+                            r.img.SetGray(px+col*sc+dx+1, py+row*sc+dy, color.Gray{Y: 0})
+                        }
+                    }
+                }
+            }
+        }
+        px += 6 * sc
+    }
+}
+
+// renderPNG draws a single label, or a sheet three labels wide.
+func renderPNG(w io.Writer, items []Item, copies int) error {
+    n := len(items) * copies
+    if n == 0 { return errors.New("no labels") }
+    cols := min(3, n)
+    rows := (n + cols - 1) / cols
+    img := image.NewGray(image.Rect(0, 0, int(float64(cols)*labelWidth*pngScale), int(float64(rows)*labelHeight*pngScale)))
+    for i := range img.Pix {
+        img.Pix[i] = 0xFF
+    }
+    d := &rasterDrawer{img: img}
+    for i := 0; i < n; i++ {
+        if err := drawLabel(d, float64(i%cols)*labelWidth, float64(i/cols)*labelHeight, items[i/copies]); err != nil {
+            return err
+        }
+    }
+    return png.Encode(w, img)
+}
+
+// pdfDrawer draws on a PDF page, flipping y to PDF's bottom-left origin.
+type pdfDrawer struct {
+    page *pdf.Page
+}
+
+func (p *pdfDrawer) dot() float64 { return 0.01 }
+
+func (p *pdfDrawer) rect(x, y, w, h float64) {
+    p.page.Rect(x, pdf.A4Height-y-h, w, h)
+}
+
+func pdfFont(bold bool) string {
+    if bold { return pdf.HelveticaBold }
+    return pdf.Helvetica
+}
+
+func (p *pdfDrawer) text(x, y, size float64, bold bool, s string) {
+    p.page.Text(pdfFont(bold), size, x, pdf.A4Height-y-size*0.8, s)
+}
+
+func (p *pdfDrawer) textWidth(size float64, bold bool, s string) float64 {
+    return pdf.TextWidth(pdfFont(bold), size, s)
+}
+
+// renderPDF lays labels out on A4 pages, 3 columns by 9 rows, with light cut lines.
+func renderPDF(w io.Writer, items []Item, copies int) error {
+    const cols, rows = 3, 9
+    mx := (pdf.A4Width - cols*labelWidth) / 2
+    my := (pdf.A4Height - rows*labelHeight) / 2
+    doc := pdf.New(pdf.A4Width, pdf.A4Height)
+    var d *pdfDrawer
+    n := len(items) * copies
+    for i := 0; i < n; i++ {
+        slot := i % (cols * rows)
+        if slot == 0 {
+            d = &pdfDrawer{page: doc.AddPage()}
+        }
+        x := mx + float64(slot%cols)*labelWidth
+        y := my + float64(slot/cols)*labelHeight
+        d.page.SetGray(0.8)
+        d.page.StrokeRect(x, pdf.A4Height-y-labelHeight, labelWidth, labelHeight, 0.25)
+        d.page.SetGray(0)
+        if err := drawLabel(d, x, y, items[i/copies]); err != nil {
+            return err
+        }
+    }
+    return doc.Write(w)
+}
+
+// renderZPL emits one ^XA..^XZ format per item for a 50 x 30 mm label at
+// 203 dpi; the printer draws the barcodes itself.
+func renderZPL(w io.Writer, items []Item, copies int) error {
+    clean := strings.NewReplacer("^", " ", "~", " ")
+    for _, it := range items {
+        bars, err := it.linear()
+        if err != nil { return err }
+        module := 2
+        if len(bars)*2 > 250 { module = 1 }
+        var symbol string
+        if len(it.Barcode) == 13 {
+            // ^BE computes the check digit from the first 12 digits.
+            symbol = fmt.Sprintf("^BY%d^BEN,80,Y,N^FD%s^FS", module, it.Barcode[:12])
+        } else {
+            symbol = fmt.Sprintf("^BY%d^BCN,80,Y,N,N^FD%s^FS", module, clean.Replace(it.Value()))
+        }
+        if _, err := fmt.Fprintf(w, "^XA^CI28^PW400^LL240\n"+
+            "^FO16,12^A0N,24,24^FB368,1,0,L^FD%s^FS\n"+
+            "^FO16,40^A0N,36,36^FD%s^FS\n"+
+            "^FO16,92%s\n"+
+            "^FO276,96^BQN,2,4^FDMA,%s^FS\n"+
+            "^PQ%d\n^XZ\n",
+            clean.Replace(it.Nama), format.Rupiah(it.Harga), symbol, clean.Replace(it.Value()), copies); err != nil {
+            return err
+        }
+    }
+    return nil
+}
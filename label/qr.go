@@ -0,0 +1,350 @@
+package label
+
+import "errors"
+
+// QR is an encoded QR Code symbol. Only byte mode with error correction
+// level M and versions 1-10 are supported, which covers the short codes
+// printed on labels (up to 213 bytes).
+type QR struct {
+    Size    int
+    modules [][]bool
+}
+
+// Dark reports whether the module at column x, row y is dark.
+func (q *QR) Dark(x, y int) bool { return q.modules[y][x] }
+
+// Level M block structure for versions 1..10.
+var (
+    qrTotalCodewords = [11]int{0, 26, 44, 70, 100, 134, 172, 196, 242, 292, 346}
+    qrEccPerBlock    = [11]int{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+    qrNumBlocks      = [11]int{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+    qrAlignment      = [11][]int{nil, {}, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34}, {6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50}}
+)
+
+const qrMaxVersion = 10
+
+type qrBuilder struct {
+    size       int
+    modules    [][]bool
+    isFunction [][]bool
+}
+
+// EncodeQR encodes text as a QR Code in byte mode, error correction level M,
+// choosing the smallest version and the mask with the lowest penalty.
+func EncodeQR(text string) (*QR, error) {
+    data := []byte(text)
+    version := 0
+    for v := 1; v <= qrMaxVersion; v++ {
+        ccBits := 8
+        if v >= 10 { ccBits = 16 }
+        capacity := (qrTotalCodewords[v] - qrEccPerBlock[v]*qrNumBlocks[v]) * 8
+        if 4+ccBits+8*len(data) <= capacity {
+            version = v
+            break
+        }
+    }
+    if version == 0 {
+        return nil, errors.New("qr: text too long")
+    }
+
+    // Data bit stream: mode, count, bytes, terminator, padding.
+    ccBits := 8
+    if version >= 10 { ccBits = 16 }
+    capacity := (qrTotalCodewords[version] - qrEccPerBlock[version]*qrNumBlocks[version]) * 8
+    var bits []bool
+    appendBits := func(val, n int) {
+        for i := n - 1; i >= 0; i-- {
+            bits = append(bits, (val>>i)&1 != 0)
+        }
+    }
+    appendBits(0x4, 4)
+    appendBits(len(data), ccBits)
+    for _, b := range data {
+        appendBits(int(b), 8)
+    }
+    appendBits(0, min(4, capacity-len(bits)))
+    appendBits(0, (8-len(bits)%8)%8)
+    for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+        appendBits(pad, 8)
+    }
+    codewords := make([]byte, len(bits)/8)
+    for i, b := range bits {
+        if b { codewords[i>>3] |= 1 << (7 - uint(i&7)) }
+    }
+
+    q := newQRBuilder(version)
+    q.drawCodewords(qrAddEcc(codewords, version))
+
+    best, bestPenalty := 0, -1
+    for mask := 0; mask < 8; mask++ {
+        q.applyMask(mask)
+        q.drawFormatBits(mask)
+        if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+            best, bestPenalty = mask, p
+        }
+        q.applyMask(mask) // XOR again to undo
+    }
+    q.applyMask(best)
+    q.drawFormatBits(best)
+    return &QR{Size: q.size, modules: q.modules}, nil
+}
+
+func newQRBuilder(version int) *qrBuilder {
+    size := version*4 + 17
+    q := &qrBuilder{size: size, modules: make([][]bool, size), isFunction: make([][]bool, size)}
+    for i := range q.modules {
+        q.modules[i] = make([]bool, size)
+        q.isFunction[i] = make([]bool, size)
+    }
+    for i := 0; i < size; i++ {
+        q.set(6, i, i%2 == 0)
+        q.set(i, 6, i%2 == 0)
+    }
+    q.finder(3, 3)
+    q.finder(size-4, 3)
+    q.finder(3, size-4)
+    pos := qrAlignment[version]
+    n := len(pos)
+    for i := 0; i < n; i++ {
+        for j := 0; j < n; j++ {
+            if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+                continue // overlaps a finder pattern
+            }
+            q.alignment(pos[i], pos[j])
+        }
+    }
+    q.drawFormatBits(0) // reserve the area; real bits are drawn after masking
+    if version >= 7 {
+        rem := version
+        for i := 0; i < 12; i++ {
+            rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+        }
+        v := version<<12 | rem
+        for i := 0; i < 18; i++ {
+            bit := (v>>i)&1 != 0
+            a, b := size-11+i%3, i/3
+            q.set(a, b, bit)
+            q.set(b, a, bit)
+        }
+    }
+    return q
+}
+
+func (q *qrBuilder) set(x, y int, dark bool) {
+    q.modules[y][x] = dark
+    q.isFunction[y][x] = true
+}
+
+func (q *qrBuilder) finder(cx, cy int) {
+    for dy := -4; dy <= 4; dy++ {
+        for dx := -4; dx <= 4; dx++ {
+            x, y := cx+dx, cy+dy
+            if x < 0 || y < 0 || x >= q.size || y >= q.size { continue }
+            d := max(abs(dx), abs(dy))
+            q.set(x, y, d != 2 && d != 4)
+        }
+    }
+}
+
+func (q *qrBuilder) alignment(cx, cy int) {
+    for dy := -2; dy <= 2; dy++ {
+        for dx := -2; dx <= 2; dx++ {
+            q.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+        }
+    }
+}
+
+// drawFormatBits writes both copies of the format information for level M.
+func (q *qrBuilder) drawFormatBits(mask int) {
+    data := 0<<3 | mask // level M = 00
+    rem := data
+    for i := 0; i < 10; i++ {
+        rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+    }
+    bits := (data<<10 | rem) ^ 0x5412
+    bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+    for i := 0; i <= 5; i++ {
+        q.set(8, i, bit(i))
+    }
+    q.set(8, 7, bit(6))
+    q.set(8, 8, bit(7))
+    q.set(7, 8, bit(8))
+    for i := 9; i < 15; i++ {
+        q.set(14-i, 8, bit(i))
+    }
+    for i := 0; i < 8; i++ {
+        q.set(q.size-1-i, 8, bit(i))
+    }
+    for i := 8; i < 15; i++ {
+        q.set(8, q.size-15+i, bit(i))
+    }
+    q.set(8, q.size-8, true) // always-dark module
+}
+
+// drawCodewords places the interleaved codewords in the zigzag order.
+func (q *qrBuilder) drawCodewords(data []byte) {
+    i := 0
+    for right := q.size - 1; right >= 1; right -= 2 {
+        if right == 6 { right = 5 }
+        for vert := 0; vert < q.size; vert++ {
+            for j := 0; j < 2; j++ {
+                x := right - j
+                y := vert
+                if (right+1)&2 == 0 { y = q.size - 1 - vert }
+                if !q.isFunction[y][x] && i < len(data)*8 {
+                    q.modules[y][x] = (data[i>>3]>>(7-uint(i&7)))&1 != 0
+                    i++
+                }
+            }
+        }
+    }
+}
+
+func (q *qrBuilder) applyMask(mask int) {
+    for y := 0; y < q.size; y++ {
+        for x := 0; x < q.size; x++ {
+            if q.isFunction[y][x] { continue }
+            var invert bool
+            switch mask {
+            case 0: invert = (x+y)%2 == 0
+            case 1: invert = y%2 == 0
+            case 2: invert = x%3 == 0
+            case 3: invert = (x+y)%3 == 0
+            case 4: invert = (x/3+y/2)%2 == 0
+            case 5: invert = x*y%2+x*y%3 == 0
+            case 6: invert = (x*y%2+x*y%3)%2 == 0
+            case 7: invert = ((x+y)%2+x*y%3)%2 == 0
+            }
+            if invert { q.modules[y][x] = !q.modules[y][x] }
+        }
+    }
+}
+
+// penalty scores the symbol with the four rules of ISO/IEC 18004 section 7.8.3.
+func (q *qrBuilder) penalty() int {
+    n := q.size
+    at := func(x, y int, vertical bool) bool {
+        if vertical { return q.modules[x][y] }
+        return q.modules[y][x]
+    }
+    score := 0
+    finderLike := [2][]bool{
+        {true, false, true, true, true, false, true, false, false, false, false},
+        {false, false, false, false, true, false, true, true, true, false, true},
+    }
+    for _, vertical := range []bool{false, true} {
+        for y := 0; y < n; y++ {
+            run := 1
+            for x := 1; x < n; x++ {
+                if at(x, y, vertical) == at(x-1, y, vertical) {
+                    run++
+                    continue
+                }
+                if run >= 5 { score += 3 + run - 5 }
+                run = 1
+            }
+            if run >= 5 { score += 3 + run - 5 }
+            for x := 0; x+11 <= n; x++ {
+                for _, pat := range finderLike {
+                    match := true
+                    for k, v := range pat {
+                        if at(x+k, y, vertical) != v {
+                            match = false
+                            break
+                        }
+                    }
+                    if match { score += 40 }
+                }
+            }
+        }
+    }
+    dark := 0
+    for y := 0; y < n; y++ {
+        for x := 0; x < n; x++ {
+            if q.modules[y][x] { dark++ }
+            if x+1 < n && y+1 < n {
+                c := q.modules[y][x]
+                if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+                    score += 3
+                }
+            }
+        }
+    }
+    total := n * n
+    score += abs(dark*100/total-50) / 5 * 10
+    return score
+}
+
+// qrAddEcc splits data into blocks, appends Reed-Solomon ECC to each and
+// interleaves the result.
+func qrAddEcc(data []byte, version int) []byte {
+    numBlocks := qrNumBlocks[version]
+    eccLen := qrEccPerBlock[version]
+    raw := qrTotalCodewords[version]
+    numShort := numBlocks - raw%numBlocks
+    shortLen := raw / numBlocks
+    divisor := rsDivisor(eccLen)
+
+    blocks := make([][]byte, 0, numBlocks)
+    k := 0
+    for i := 0; i < numBlocks; i++ {
+        n := shortLen - eccLen
+        if i >= numShort { n++ }
+        dat := append([]byte(nil), data[k:k+n]...)
+        k += n
+        ecc := rsRemainder(dat, divisor)
+        if i < numShort { dat = append(dat, 0) }
+        blocks = append(blocks, append(dat, ecc...))
+    }
+    out := make([]byte, 0, raw)
+    for i := range blocks[0] {
+        for j, b := range blocks {
+            if i != shortLen-eccLen || j >= numShort {
+                out = append(out, b[i])
+            }
+        }
+    }
+    return out
+}
+
+func rsMultiply(x, y byte) byte {
+    var z int
+    for i := 7; i >= 0; i-- {
+        z = (z << 1) ^ ((z >> 7) * 0x11D)
+        z ^= int((y>>uint(i))&1) * int(x)
+    }
+    return byte(z)
+}
+
+func rsDivisor(degree int) []byte {
+    result := make([]byte, degree)
+    result[degree-1] = 1
+    root := byte(1)
+    for i := 0; i < degree; i++ {
+        for j := 0; j < degree; j++ {
+            result[j] = rsMultiply(result[j], root)
+            if j+1 < degree { result[j] ^= result[j+1] }
+        }
+        root = rsMultiply(root, 0x02)
+    }
+    return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+    result := make([]byte, len(divisor))
+    for _, b := range data {
+        factor := b ^ result[0]
+        copy(result, result[1:])
+        result[len(result)-1] = 0
+        for i := range result {
+            result[i] ^= rsMultiply(divisor[i], factor)
+        }
+    }
+    return result
+}
+
+func abs(x int) int {
+    if x < 0 { return -x }
+    return x
+}
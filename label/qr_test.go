@@ -0,0 +1,94 @@
+package label
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+    // Version 1-M example from the QR Code tutorial at thonky.com.
+    data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+    want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+    if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+        t.Errorf("ecc = %v, want %v", got, want)
+    }
+    if got := qrAddEcc(data, 1); !bytes.Equal(got, append(append([]byte(nil), data...), want...)) {
+        t.Errorf("qrAddEcc = %v", got)
+    }
+}
+
+// qrFormatM holds the format information of level M for masks 0..7.
+var qrFormatM = []int{0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0}
+
+func TestEncodeQR(t *testing.T) {
+    tests := []struct {
+        text string
+        size int
+    }{
+        {"1", 21},
+        {strings.Repeat("x", 14), 21}, // fills version 1-M
+        {strings.Repeat("x", 15), 25},
+        {"BRG-0001", 21},
+        {"https://example.com/barang/BRG-0001", 29},
+        {strings.Repeat("x", 213), 57}, // fills version 10-M
+    }
+    for _, tt := range tests {
+        t.Run(tt.text, func(t *testing.T) {
+            q, err := EncodeQR(tt.text)
+            if err != nil { t.Fatal(err) }
+            if q.Size != tt.size {
+                t.Fatalf("size = %d, want %d", q.Size, tt.size)
+            }
+            n := q.Size
+            // Finder patterns: dark outer ring, light ring, dark 3x3 core.
+            for _, c := range [][2]int{{3, 3}, {n - 4, 3}, {3, n - 4}} {
+                for dy := -3; dy <= 3; dy++ {
+                    for dx := -3; dx <= 3; dx++ {
+                        d := max(abs(dx), abs(dy))
+                        if q.Dark(c[0]+dx, c[1]+dy) != (d != 2) {
+                            t.Fatalf("finder at %v broken at %d,%d", c, dx, dy)
+                        }
+                    }
+                }
+            }
+            for i := 8; i < n-8; i++ {
+                if q.Dark(i, 6) != (i%2 == 0) || q.Dark(6, i) != (i%2 == 0) {
+                    t.Fatalf("timing pattern broken at %d", i)
+                }
+            }
+            // Both copies of the format information must agree and be a
+            // valid level M code word.
+            var a, b int
+            for i := 0; i <= 5; i++ { a |= bit(q.Dark(8, i)) << i }
+            a |= bit(q.Dark(8, 7))<<6 | bit(q.Dark(8, 8))<<7 | bit(q.Dark(7, 8))<<8
+            for i := 9; i < 15; i++ { a |= bit(q.Dark(14-i, 8)) << i }
+            for i := 0; i < 8; i++ { b |= bit(q.Dark(n-1-i, 8)) << i }
+            for i := 8; i < 15; i++ { b |= bit(q.Dark(8, n-15+i)) << i }
+            if a != b {
+                t.Errorf("format copies differ: %015b vs %015b", a, b)
+            }
+            valid := false
+            for _, f := range qrFormatM {
+                if f == a { valid = true }
+            }
+            if !valid {
+                t.Errorf("format %015b is not level M", a)
+            }
+            if !q.Dark(8, n-8) {
+                t.Error("dark module missing")
+            }
+        })
+    }
+}
+
+func TestEncodeQRTooLong(t *testing.T) {
+    if _, err := EncodeQR(strings.Repeat("x", 214)); err == nil {
+        t.Error("EncodeQR accepted 214 bytes, want error")
+    }
+}
+
+func bit(b bool) int {
+    if b { return 1 }
+    return 0
+}
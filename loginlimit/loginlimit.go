@@ -0,0 +1,187 @@
+// Package loginlimit slows down password guessing. Failed logins are
+// counted per username and per client IP; after a few free attempts each
+// further attempt must wait exponentially longer, and too many failures
+// lock the username or IP for a while.
+package loginlimit
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// State is the failure record of one username or IP.
+type State struct {
+    Failures    int
+    Last        time.Time // last failure
+    LockedUntil time.Time
+}
+
+// Store keeps failure records. Memory is the default; a store shared by
+// several API instances (Redis, SQL, ...) implements the same interface.
+type Store interface {
+    Get(ctx context.Context, key string) (State, error)
+    // Update applies fn to the record of key atomically and keeps the
+    // result for at least ttl.
+    Update(ctx context.Context, key string, ttl time.Duration, fn func(*State)) (State, error)
+    Delete(ctx context.Context, key string) error
+}
+
+// Policy configures the limits for one kind of key.
+type Policy struct {
+    Free      int           // failures allowed before backoff starts
+    Base      time.Duration // first backoff, doubled by each further failure
+    Max       time.Duration // longest backoff
+    LockAfter int           // failures that lock the key; 0 never locks
+    LockFor   time.Duration
+    Window    time.Duration // failures older than this are forgotten
+}
+
+// wait returns how long the key must wait before its next attempt.
+func (p Policy) wait(s State, now time.Time) time.Duration {
+    if now.Before(s.LockedUntil) { return s.LockedUntil.Sub(now) }
+    if s.Failures < p.Free || now.Sub(s.Last) > p.Window { return 0 }
+    shift := s.Failures - p.Free
+    if shift > 30 { shift = 30 }
+    d := p.Base << shift
+    if d > p.Max || d <= 0 { d = p.Max }
+    if next := s.Last.Add(d); now.Before(next) { return next.Sub(now) }
+    return 0
+}
+
+// fail records one failure and reports whether it locked the key.
+func (p Policy) fail(s *State, now time.Time) bool {
+    if now.Sub(s.Last) > p.Window && !now.Before(s.LockedUntil) { s.Failures = 0 }
+    s.Failures++
+    s.Last = now
+    if p.LockAfter > 0 && s.Failures >= p.LockAfter {
+        s.LockedUntil = now.Add(p.LockFor)
+        return true
+    }
+    return false
+}
+
+// undo takes back the failure c counted. When nothing else was counted
+// since, s gets back exactly its state from before; otherwise one failure
+// is taken back, lifting a lock only the remaining count does not reach.
+func (p Policy) undo(s *State, c change) {
+    if !c.counted { return }
+    if s.equal(c.after) {
+        *s = c.before
+        return
+    }
+    if s.Failures > 0 { s.Failures-- }
+    if p.LockAfter > 0 && s.Failures < p.LockAfter { s.LockedUntil = time.Time{} }
+}
+
+func (s State) equal(o State) bool {
+    return s.Failures == o.Failures && s.Last.Equal(o.Last) && s.LockedUntil.Equal(o.LockedUntil)
+}
+
+// change is what Begin did to the record of one key.
+type change struct {
+    key           string
+    before, after State
+    counted       bool
+}
+
+func (p Policy) ttl() time.Duration {
+    if p.LockFor > p.Window { return p.LockFor }
+    return p.Window
+}
+
+// Limiter applies User and IP policies to login attempts.
+type Limiter struct {
+    Store Store
+    User  Policy
+    IP    Policy
+    now   func() time.Time
+}
+
+// DefaultUser and DefaultIP are the policies used when none is configured.
+var (
+    DefaultUser = Policy{Free: 3, Base: time.Second, Max: 5 * time.Minute, LockAfter: 10, LockFor: 15 * time.Minute, Window: time.Hour}
+    DefaultIP   = Policy{Free: 10, Base: time.Second, Max: 5 * time.Minute, LockAfter: 50, LockFor: 15 * time.Minute, Window: time.Hour}
+)
+
+func New(store Store, user, ip Policy) *Limiter {
+    if store == nil { store = NewMemory() }
+    return &Limiter{Store: store, User: user, IP: ip, now: time.Now}
+}
+
+func userKey(username string) string { return "user:" + strings.ToLower(strings.TrimSpace(username)) }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// Attempt is the outcome of Begin.
+type Attempt struct {
+    Wait   time.Duration // > 0 when the attempt is refused: retry after Wait
+    Next   time.Duration // wait before the next attempt, should this one fail
+    Locked bool          // this attempt locked the username or IP should it fail
+
+    user, ip change
+}
+
+// Begin counts a login attempt as a failure of username and ip before the
+// credentials are checked, so parallel guesses cannot all get past the
+// limit: each one is counted atomically by the Store before the next is
+// allowed. A refused attempt (Wait > 0) is not counted. Once the
+// credentials turn out right, Undo takes the attempt back.
+func (l *Limiter) Begin(ctx context.Context, username, ip string) (Attempt, error) {
+    now := l.now()
+    a := Attempt{user: change{key: userKey(username)}, ip: change{key: ipKey(ip)}}
+    var userLocked, ipLocked bool
+    count := func(p Policy, c *change, locked *bool) func(*State) {
+        return func(s *State) {
+            if w := p.wait(*s, now); w > 0 {
+                a.Wait = max(a.Wait, w)
+                return
+            }
+            c.before = *s
+            *locked = p.fail(s, now)
+            c.after, c.counted = *s, true
+        }
+    }
+    us, err := l.Store.Update(ctx, a.user.key, l.User.ttl(), count(l.User, &a.user, &userLocked))
+    if err != nil || a.Wait > 0 { return a, err }
+    is, err := l.Store.Update(ctx, a.ip.key, l.IP.ttl(), count(l.IP, &a.ip, &ipLocked))
+    if err != nil { return a, err }
+    if a.Wait > 0 {
+        // Refused by the IP: the username's count is taken back.
+        _, err := l.Store.Update(ctx, a.user.key, l.User.ttl(), func(s *State) { l.User.undo(s, a.user) })
+        return a, err
+    }
+    a.Next = max(l.User.wait(us, now), l.IP.wait(is, now))
+    a.Locked = userLocked || ipLocked
+    return a, nil
+}
+
+// Undo takes back attempt a, counted by Begin, after the credentials were
+// found valid. The username and IP get back the records they had before,
+// including a lock that had already expired.
+func (l *Limiter) Undo(ctx context.Context, a Attempt) error {
+    if a.user.counted {
+        if _, err := l.Store.Update(ctx, a.user.key, l.User.ttl(), func(s *State) { l.User.undo(s, a.user) }); err != nil {
+            return err
+        }
+    }
+    if !a.ip.counted { return nil }
+    _, err := l.Store.Update(ctx, a.ip.key, l.IP.ttl(), func(s *State) { l.IP.undo(s, a.ip) })
+    return err
+}
+
+// Success forgets the failures of username. The IP keeps its record, so
+// one valid account cannot be used to keep guessing others.
+func (l *Limiter) Success(ctx context.Context, username string) error {
+    return l.Store.Delete(ctx, userKey(username))
+}
+
+// Unlock clears the record of a username and/or IP (empty values are skipped).
+func (l *Limiter) Unlock(ctx context.Context, username, ip string) error {
+    if username != "" {
+        if err := l.Store.Delete(ctx, userKey(username)); err != nil { return err }
+    }
+    if ip != "" {
+        if err := l.Store.Delete(ctx, ipKey(ip)); err != nil { return err }
+    }
+    return nil
+}
@@ -0,0 +1,229 @@
+package loginlimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+
+func TestPolicyWait(t *testing.T) {
+    p := Policy{Free: 3, Base: time.Second, Max: time.Minute, LockAfter: 10, LockFor: 15 * time.Minute, Window: time.Hour}
+    tests := []struct {
+        name string
+        s    State
+        now  time.Time
+        want time.Duration
+    }{
+        {"no failures", State{}, t0, 0},
+        {"within free attempts", State{Failures: 2, Last: t0}, t0, 0},
+        {"first backoff", State{Failures: 3, Last: t0}, t0, time.Second},
+        {"backoff doubles", State{Failures: 5, Last: t0}, t0, 4 * time.Second},
+        {"backoff partly waited", State{Failures: 5, Last: t0}, t0.Add(3 * time.Second), time.Second},
+        {"backoff over", State{Failures: 5, Last: t0}, t0.Add(4 * time.Second), 0},
+        {"capped at max", State{Failures: 9, Last: t0}, t0, time.Minute},
+        {"huge count stays capped", State{Failures: 500, Last: t0}, t0, time.Minute},
+        {"outside window", State{Failures: 9, Last: t0}, t0.Add(time.Hour + time.Second), 0},
+        {"locked", State{Failures: 10, Last: t0, LockedUntil: t0.Add(15 * time.Minute)}, t0.Add(5 * time.Minute), 10 * time.Minute},
+        {"lock expired", State{Failures: 10, Last: t0, LockedUntil: t0.Add(15 * time.Minute)}, t0.Add(2 * time.Hour), 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := p.wait(tt.s, tt.now); got != tt.want {
+                t.Errorf("wait = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestPolicyFail(t *testing.T) {
+    p := Policy{Free: 3, Base: time.Second, Max: time.Minute, LockAfter: 4, LockFor: 15 * time.Minute, Window: time.Hour}
+    tests := []struct {
+        name       string
+        s          State
+        now        time.Time
+        failures   int
+        locked     bool
+        lockedTill time.Time
+    }{
+        {"first failure", State{}, t0, 1, false, time.Time{}},
+        {"counts up", State{Failures: 2, Last: t0}, t0.Add(time.Minute), 3, false, time.Time{}},
+        {"locks at LockAfter", State{Failures: 3, Last: t0}, t0.Add(time.Minute), 4, true, t0.Add(16 * time.Minute)},
+        {"old failures forgotten", State{Failures: 3, Last: t0}, t0.Add(2 * time.Hour), 1, false, time.Time{}},
+        {"still locked keeps count", State{Failures: 4, Last: t0, LockedUntil: t0.Add(3 * time.Hour)}, t0.Add(2 * time.Hour), 5, true, t0.Add(2*time.Hour + 15*time.Minute)},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := tt.s
+            locked := p.fail(&s, tt.now)
+            if s.Failures != tt.failures || locked != tt.locked || !s.Last.Equal(tt.now) {
+                t.Errorf("fail = %+v locked %v; want %d failures, locked %v", s, locked, tt.failures, tt.locked)
+            }
+            if tt.locked && !s.LockedUntil.Equal(tt.lockedTill) {
+                t.Errorf("locked until %v, want %v", s.LockedUntil, tt.lockedTill)
+            }
+        })
+    }
+
+    if (Policy{Free: 1, LockAfter: 0, Window: time.Hour}).fail(&State{Failures: 100, Last: t0}, t0) {
+        t.Error("LockAfter 0 must never lock")
+    }
+}
+
+func TestPolicyTTL(t *testing.T) {
+    if got := (Policy{Window: time.Hour, LockFor: 15 * time.Minute}).ttl(); got != time.Hour {
+        t.Errorf("ttl = %v, want window", got)
+    }
+    if got := (Policy{Window: time.Minute, LockFor: time.Hour}).ttl(); got != time.Hour {
+        t.Errorf("ttl = %v, want lock duration", got)
+    }
+}
+
+func TestBeginCountsParallelAttempts(t *testing.T) {
+    l := New(NewMemory(), Policy{Free: 3, Base: time.Minute, Max: time.Hour, Window: time.Hour}, DefaultIP)
+    l.now = func() time.Time { return t0 }
+    ctx := context.Background()
+
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    allowed := 0
+    for i := 0; i < 20; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            a, err := l.Begin(ctx, "kasir1", "198.51.100.7")
+            if err != nil { t.Error(err); return }
+            if a.Wait == 0 {
+                mu.Lock()
+                allowed++
+                mu.Unlock()
+            }
+        }()
+    }
+    wg.Wait()
+    // Only the free attempts get through; the rest wait for the backoff.
+    if allowed != 3 {
+        t.Errorf("%d parallel attempts allowed, want 3", allowed)
+    }
+}
+
+func TestBeginUndo(t *testing.T) {
+    user := Policy{Free: 1, Base: time.Minute, Max: time.Hour, LockAfter: 2, LockFor: time.Hour, Window: time.Hour}
+    l := New(NewMemory(), user, DefaultIP)
+    now := t0
+    l.now = func() time.Time { return now }
+    ctx := context.Background()
+
+    a, err := l.Begin(ctx, "Kasir1", "198.51.100.7")
+    if err != nil || a.Wait != 0 || a.Next != time.Minute || a.Locked {
+        t.Fatalf("first attempt = %+v, %v", a, err)
+    }
+    // A right password takes the attempt back, so there is no backoff.
+    if err := l.Undo(ctx, a); err != nil { t.Fatal(err) }
+    if a, _ := l.Begin(ctx, "kasir1", "198.51.100.7"); a.Wait != 0 || a.Locked {
+        t.Fatalf("after undo = %+v", a)
+    }
+    now = now.Add(time.Minute)
+    a, _ = l.Begin(ctx, "kasir1", "198.51.100.7")
+    if !a.Locked || a.Next != time.Hour {
+        t.Fatalf("second failure = %+v, want lock", a)
+    }
+    if a, _ := l.Begin(ctx, "kasir1", "198.51.100.8"); a.Wait != time.Hour {
+        t.Fatalf("locked user = %+v", a)
+    }
+    // The attempt that locked turned out right: the lock is lifted and
+    // only the earlier failure remains.
+    if err := l.Undo(ctx, a); err != nil { t.Fatal(err) }
+    if s, _ := l.Store.Get(ctx, userKey("kasir1")); !s.equal(State{Failures: 1, Last: t0}) {
+        t.Fatalf("after undoing the lock = %+v", s)
+    }
+}
+
+func TestUndoAfterExpiredLock(t *testing.T) {
+    p := Policy{Free: 1, Base: time.Second, Max: time.Minute, LockAfter: 2, LockFor: 15 * time.Minute, Window: time.Hour}
+    l := New(NewMemory(), p, p)
+    now := t0
+    l.now = func() time.Time { return now }
+    ctx := context.Background()
+
+    for i := 0; i < 2; i++ {
+        if a, _ := l.Begin(ctx, "kasir1", "198.51.100.7"); a.Wait != 0 { t.Fatalf("failure %d refused: %+v", i+1, a) }
+        now = now.Add(time.Minute)
+    }
+    before, _ := l.Store.Get(ctx, ipKey("198.51.100.7"))
+    // The lock has expired but the failures are still inside the window:
+    // each right password must leave the records as they were.
+    now = t0.Add(20 * time.Minute)
+    for i := 0; i < 3; i++ {
+        a, err := l.Begin(ctx, "kasir1", "198.51.100.7")
+        if err != nil || a.Wait != 0 || !a.Locked {
+            t.Fatalf("login %d = %+v, %v; want allowed, locking should it fail", i+1, a, err)
+        }
+        if err := l.Undo(ctx, a); err != nil { t.Fatal(err) }
+        // Success forgets the username but not the IP.
+        if err := l.Success(ctx, "kasir1"); err != nil { t.Fatal(err) }
+        if s, _ := l.Store.Get(ctx, ipKey("198.51.100.7")); !s.equal(before) {
+            t.Fatalf("IP after login %d = %+v, want %+v", i+1, s, before)
+        }
+        now = now.Add(time.Minute)
+    }
+}
+
+func TestUndoKeepsLaterFailures(t *testing.T) {
+    p := Policy{Free: 5, Base: time.Second, Max: time.Minute, LockAfter: 2, LockFor: time.Hour, Window: time.Hour}
+    l := New(NewMemory(), p, DefaultIP)
+    l.now = func() time.Time { return t0 }
+    ctx := context.Background()
+
+    a, _ := l.Begin(ctx, "kasir1", "198.51.100.7")
+    b, _ := l.Begin(ctx, "kasir1", "198.51.100.8")
+    if !b.Locked { t.Fatalf("second failure = %+v, want lock", b) }
+    // a was right, but b was counted since: only a's failure is taken back.
+    if err := l.Undo(ctx, a); err != nil { t.Fatal(err) }
+    if s, _ := l.Store.Get(ctx, userKey("kasir1")); s.Failures != 1 || !s.LockedUntil.IsZero() {
+        t.Errorf("after undo = %+v, want 1 failure and no lock", s)
+    }
+}
+
+func TestBeginRefusedByIPNotCountedForUser(t *testing.T) {
+    ip := Policy{Free: 1, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+    l := New(NewMemory(), DefaultUser, ip)
+    l.now = func() time.Time { return t0 }
+    ctx := context.Background()
+    if a, _ := l.Begin(ctx, "a", "198.51.100.7"); a.Wait != 0 { t.Fatal(a) }
+    if a, _ := l.Begin(ctx, "b", "198.51.100.7"); a.Wait != time.Minute { t.Fatal("second attempt from the IP allowed") }
+    s, _ := l.Store.Get(ctx, userKey("b"))
+    if s.Failures != 0 {
+        t.Errorf("refused attempt counted for the username: %+v", s)
+    }
+}
+
+func TestPasswordThenSecondFactorAfterLockout(t *testing.T) {
+    l := New(NewMemory(), DefaultUser, DefaultIP)
+    now := t0
+    l.now = func() time.Time { return now }
+    ctx := context.Background()
+
+    for i := 0; i < DefaultUser.LockAfter; i++ {
+        if a, _ := l.Begin(ctx, "kasir1", "198.51.100.7"); a.Wait != 0 { t.Fatalf("failure %d refused: %+v", i+1, a) }
+        now = now.Add(DefaultUser.Max)
+    }
+    if a, _ := l.Begin(ctx, "kasir1", "198.51.100.7"); a.Wait == 0 { t.Fatal("username not locked") }
+    now = now.Add(DefaultUser.LockFor)
+
+    // Login: the password is right, the user has TOTP.
+    a, err := l.Begin(ctx, "kasir1", "198.51.100.7")
+    if err != nil || a.Wait != 0 { t.Fatalf("password step = %+v, %v", a, err) }
+    if err := l.Undo(ctx, a); err != nil { t.Fatal(err) }
+
+    // SecondFactor counts the code like a password.
+    a, err = l.Begin(ctx, "kasir1", "198.51.100.7")
+    if err != nil || a.Wait != 0 { t.Fatalf("2FA step after a right password = %+v, %v", a, err) }
+    if err := l.Undo(ctx, a); err != nil { t.Fatal(err) }
+    if err := l.Success(ctx, "kasir1"); err != nil { t.Fatal(err) }
+    if a, _ := l.Begin(ctx, "kasir1", "198.51.100.8"); a.Wait != 0 {
+        t.Errorf("next login after success = %+v", a)
+    }
+}
@@ -0,0 +1,60 @@
+package loginlimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Store. Records are lost on restart and not
+// shared between API instances.
+type Memory struct {
+    mu        sync.Mutex
+    items     map[string]memItem
+    lastSweep time.Time
+}
+
+type memItem struct {
+    s       State
+    expires time.Time
+}
+
+func NewMemory() *Memory { return &Memory{items: map[string]memItem{}} }
+
+func (m *Memory) Get(_ context.Context, key string) (State, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    it, ok := m.items[key]
+    if !ok || time.Now().After(it.expires) { return State{}, nil }
+    return it.s, nil
+}
+
+func (m *Memory) Update(_ context.Context, key string, ttl time.Duration, fn func(*State)) (State, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := time.Now()
+    m.sweep(now)
+    it := m.items[key]
+    if now.After(it.expires) { it.s = State{} }
+    fn(&it.s)
+    it.expires = now.Add(ttl)
+    m.items[key] = it
+    return it.s, nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    delete(m.items, key)
+    return nil
+}
+
+// sweep drops expired records at most once a minute so attacks from many
+// IPs or usernames do not grow the map without bound.
+func (m *Memory) sweep(now time.Time) {
+    if now.Sub(m.lastSweep) < time.Minute { return }
+    m.lastSweep = now
+    for k, it := range m.items {
+        if now.After(it.expires) { delete(m.items, k) }
+    }
+}
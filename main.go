@@ -1,15 +1,29 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"warehouse/audit"
 	"warehouse/config"
 	"warehouse/handlers"
+	"warehouse/invoice"
+	"warehouse/jobs"
+	"warehouse/loginlimit"
+	"warehouse/permission"
 	wm "warehouse/middleware"
 	"warehouse/repositories"
+	"warehouse/scheduler"
+	"warehouse/storage"
+	"warehouse/token"
+	"warehouse/totp"
 )
 
 func main() {
@@ -20,62 +34,302 @@ func main() {
     }
     defer db.Close()
 
+    // File storage for product images and attachments
+    var files storage.Storage = storage.NewLocal(config.Env("STORAGE_DIR", "uploads"))
+    if driver := config.Env("STORAGE_DRIVER", "local"); driver == "s3" {
+        endpoint, bucket := config.Env("S3_ENDPOINT", ""), config.Env("S3_BUCKET", "")
+        if endpoint == "" || bucket == "" {
+            log.Fatal("STORAGE_DRIVER=s3 requires S3_ENDPOINT and S3_BUCKET")
+        }
+        files = storage.NewS3(endpoint, config.Env("S3_REGION", "us-east-1"), bucket, config.Env("S3_ACCESS_KEY", ""), config.Env("S3_SECRET_KEY", ""))
+    } else if driver != "local" {
+        log.Fatalf("unknown STORAGE_DRIVER %q", driver)
+    }
+    storage.BaseURL = config.Env("FILES_BASE_URL", "/files/")
+
+    // JWT signing and verification keys
+    tokens, err := token.Load(token.Config{
+        KeysFile:        config.Env("JWT_KEYS_FILE", ""),
+        PrivateKeyFile:  config.Env("JWT_PRIVATE_KEY_FILE", ""),
+        Secret:          config.Env("JWT_SECRET", ""),
+        PreviousSecrets: strings.Split(config.Env("JWT_PREVIOUS_SECRETS", ""), ","),
+        AccessTTL:       config.EnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+        RefreshTTL:      config.EnvDuration("REFRESH_TOKEN_TTL", 24*time.Hour),
+    })
+    if err != nil {
+        log.Fatalf("jwt keys: %v", err)
+    }
+
+    // Two-factor secrets are encrypted at rest when TOTP_ENCRYPTION_KEY (32 bytes, base64) is set
+    var totpBox *totp.Box
+    if key := config.Env("TOTP_ENCRYPTION_KEY", ""); key != "" {
+        raw, err := base64.StdEncoding.DecodeString(key)
+        if err == nil { totpBox, err = totp.NewBox(raw) }
+        if err != nil {
+            log.Fatalf("TOTP_ENCRYPTION_KEY: %v", err)
+        }
+    } else {
+        log.Println("TOTP_ENCRYPTION_KEY not set: two-factor secrets are stored unencrypted")
+    }
+
     // Init repositories and handlers
     barangRepo := repositories.NewBarangRepo(db)
-    barangHandler := handlers.NewBarangHandler(barangRepo)
+    barangHandler := handlers.NewBarangHandler(barangRepo, files)
+    gambarHandler := handlers.NewGambarHandler(barangRepo, files, int64(config.EnvInt("IMAGE_MAX_BYTES", 5<<20)), config.EnvInt("THUMBNAIL_SIZE", 256))
+    lampiranHandler := handlers.NewLampiranHandler(repositories.NewLampiranRepo(db), files, int64(config.EnvInt("ATTACHMENT_MAX_BYTES", 10<<20)))
+    jobManager := jobs.NewManager(config.EnvDuration("JOB_TIMEOUT", 30*time.Minute), config.EnvDuration("JOB_RETAIN", time.Hour))
+    jobHandler := handlers.NewJobHandler(jobManager)
     stokRepo := repositories.NewStokRepo(db)
     stokHandler := handlers.NewStokHandler(stokRepo)
+    importHandler := handlers.NewImportHandler(barangRepo, stokRepo, jobManager, config.EnvInt("IMPORT_ASYNC_ROWS", 500))
     pembelianRepo := repositories.NewPembelianRepo(db)
     pembelianHandler := handlers.NewPembelianHandler(pembelianRepo)
     penjualanRepo := repositories.NewPenjualanRepo(db)
     penjualanHandler := handlers.NewPenjualanHandler(penjualanRepo)
     userRepo := repositories.NewUserRepo(db)
-    authHandler := handlers.NewAuthHandler(userRepo)
-    laporanHandler := handlers.NewLaporanHandler(stokRepo, penjualanRepo, pembelianRepo)
+    sesiRepo := repositories.NewSesiRepo(db)
+    tenantRepo := repositories.NewTenantRepo(db)
+    proxies := trustedProxies()
+    loginLimiter := loginlimit.New(loginlimit.NewMemory(), loginPolicy("LOGIN", loginlimit.DefaultUser), loginPolicy("LOGIN_IP", loginlimit.DefaultIP))
+    totpRepo := repositories.NewTOTPRepo(db, totpBox, config.Env("TOTP_ISSUER", config.Env("COMPANY_NAME", "Warehouse")))
+    totpHandler := handlers.NewTOTPHandler(totpRepo)
+    authHandler := handlers.NewAuthHandler(userRepo, sesiRepo, totpRepo, tenantRepo, tokens, loginLimiter, proxies)
+    roleRepo := repositories.NewRoleRepo(db)
+    tenantHandler := handlers.NewTenantHandler(tenantRepo, userRepo, roleRepo)
+    userHandler := handlers.NewUserHandler(userRepo, sesiRepo, roleRepo)
+    roleHandler := handlers.NewRoleHandler(roleRepo)
+    apiKeyRepo := repositories.NewAPIKeyRepo(db)
+    apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo)
+    syncCtx, syncCancel := context.WithTimeout(context.Background(), 10*time.Second)
+    if err := roleRepo.Sync(syncCtx, permission.All); err != nil {
+        log.Fatalf("sync permissions: %v", err)
+    }
+    // Row-level security only keeps tenants apart for roles that do not bypass it.
+    if bypass, err := tenantRepo.BypassesRLS(syncCtx); err != nil {
+        log.Fatalf("check database role: %v", err)
+    } else if bypass {
+        ids, err := tenantRepo.IDs(syncCtx)
+        if err != nil {
+            log.Fatalf("list tenants: %v", err)
+        }
+        if len(ids) > 1 {
+            log.Fatal("DB_USER is a superuser or has BYPASSRLS, so tenants would see each other's data; connect as an ordinary role")
+        }
+        log.Println("warning: DB_USER is a superuser or has BYPASSRLS; connect as an ordinary role before adding tenants")
+    }
+    syncCancel()
+    hargaRepo := repositories.NewHargaRepo(db)
+    hargaHandler := handlers.NewHargaHandler(hargaRepo)
+    jadwalHargaRepo := repositories.NewJadwalHargaRepo(db)
+    jadwalHargaHandler := handlers.NewJadwalHargaHandler(jadwalHargaRepo)
+    promoRepo := repositories.NewPromoRepo(db)
+    kategoriRepo := repositories.NewKategoriRepo(db)
+    varianRepo := repositories.NewVarianRepo(db)
+    varianHandler := handlers.NewVarianHandler(varianRepo)
+    bundelHandler := handlers.NewBundelHandler(repositories.NewBundelRepo(db))
+    barcodeRepo := repositories.NewBarcodeRepo(db)
+    barcodeHandler := handlers.NewBarcodeHandler(barcodeRepo)
+    labelHandler := handlers.NewLabelHandler(barcodeRepo)
+    kategoriHandler := handlers.NewKategoriHandler(kategoriRepo)
+    promoHandler := handlers.NewPromoHandler(promoRepo)
+    lokasi := config.EnvLocation("APP_TIMEZONE", config.WIB)
+    pajakRepo := repositories.NewPajakRepo(db, lokasi)
+    pajakHandler := handlers.NewPajakHandler(pajakRepo)
+    laporanHandler := handlers.NewLaporanHandler(stokRepo, penjualanRepo, pembelianRepo, pajakRepo, lokasi)
+    perusahaan := invoice.Perusahaan{
+        Nama:    config.Env("COMPANY_NAME", "Warehouse"),
+        Alamat:  config.Env("COMPANY_ADDRESS", ""),
+        Telepon: config.Env("COMPANY_PHONE", ""),
+        NPWP:    config.Env("COMPANY_NPWP", ""),
+    }
+    if path := config.Env("COMPANY_LOGO", ""); path != "" {
+        if perusahaan.Logo, err = os.ReadFile(path); err != nil {
+            log.Printf("company logo not loaded: %v", err)
+        }
+    }
+    auditRepo := repositories.NewAuditRepo(db)
+    auditHandler := handlers.NewAuditHandler(auditRepo)
+    auditRecorder := audit.NewRecorder(auditRepo, proxies)
+    dokumenHandler := handlers.NewDokumenHandler(penjualanRepo, pembelianRepo, invoice.NewRenderer(config.Env("INVOICE_TEMPLATE_DIR", ""), perusahaan))
+
+    // Background jobs
+    hargaScheduler := scheduler.NewHargaScheduler(jadwalHargaRepo, tenantRepo, config.EnvDuration("PRICE_SCHEDULER_INTERVAL", time.Minute))
+    go hargaScheduler.Run(context.Background())
 
     // Router setup
     r := chi.NewRouter()
     r.Get("/health", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK); _, _ = w.Write([]byte("ok")) })
+    r.Get("/files/barang/*", gambarHandler.File)
+    r.Get("/.well-known/jwks.json", tokens.ServeJWKS)
 
     // API routes
     r.Route("/api", func(api chi.Router) {
         // Public
-        api.Post("/login", authHandler.Login)
+        api.With(auditRecorder.Middleware).Post("/login", authHandler.Login)
+        api.With(auditRecorder.Middleware).Post("/login/2fa", authHandler.SecondFactor)
+        api.Post("/login/2fa/enroll", authHandler.EnrollSecondFactor)
         api.Post("/refresh", authHandler.Refresh)
+        api.With(auditRecorder.Middleware).Post("/logout", authHandler.Logout)
 
         // Protected group
         api.Group(func(priv chi.Router) {
-            priv.Use(wm.AuthMiddleware)
+            priv.Use(wm.NewAuthenticator(tokens, userRepo, apiKeyRepo, proxies).Middleware)
+            priv.Use(auditRecorder.Middleware)
+            perm := wm.RequirePermission
+
+            // Own account
+            priv.Get("/me", userHandler.Me)
+            priv.Post("/me/password", userHandler.ChangePassword)
+            priv.Get("/me/2fa", totpHandler.Status)
+            priv.Post("/me/2fa", totpHandler.Start)
+            priv.Post("/me/2fa/verify", totpHandler.Verify)
+            priv.Post("/me/2fa/recovery-codes", totpHandler.RecoveryCodes)
+            priv.Post("/me/2fa/disable", totpHandler.Disable)
+            priv.Get("/me/tenants", tenantHandler.Mine)
+            priv.Post("/switch-tenant", authHandler.SwitchTenant)
+
+            // User management (users of the current tenant only; changes to
+            // the account need user.manage in all of the user's tenants)
+            inTenant := tenantHandler.DalamTenant
+            kelola := tenantHandler.Dikelola
+            priv.With(perm(permission.UserManage)).Get("/users", userHandler.List)
+            priv.With(perm(permission.UserManage), inTenant).Get("/users/{id}", userHandler.Get)
+            priv.With(perm(permission.UserManage)).Post("/users", userHandler.Create)
+            priv.With(perm(permission.UserManage), inTenant).Put("/users/{id}", userHandler.Update)
+            priv.With(perm(permission.UserManage), inTenant, kelola).Post("/users/{id}/deactivate", userHandler.Deactivate)
+            priv.With(perm(permission.UserManage), inTenant, kelola).Post("/users/{id}/activate", userHandler.Activate)
+            priv.With(perm(permission.UserManage), inTenant, kelola).Post("/users/{id}/revoke-sessions", userHandler.RevokeSessions)
+            priv.With(perm(permission.UserManage), inTenant, kelola).Post("/users/{id}/reset-2fa", totpHandler.Reset)
+            priv.With(perm(permission.UserManage)).Post("/login/unlock", authHandler.Unlock)
+
+            // Roles and permissions
+            priv.With(perm(permission.RoleManage)).Get("/permissions", roleHandler.Permissions)
+            priv.With(perm(permission.RoleManage)).Get("/roles", roleHandler.List)
+            priv.With(perm(permission.RoleManage)).Get("/roles/{nama}", roleHandler.Get)
+            priv.With(perm(permission.RoleManage)).Post("/roles", roleHandler.Create)
+            priv.With(perm(permission.RoleManage)).Put("/roles/{nama}", roleHandler.Update)
+            priv.With(perm(permission.RoleManage)).Delete("/roles/{nama}", roleHandler.Delete)
+
+            // Tenants
+            priv.With(perm(permission.TenantManage)).Post("/tenants", tenantHandler.Create)
+            priv.With(perm(permission.TenantManage), inTenant).Get("/users/{id}/tenants", tenantHandler.UserTenants)
+            priv.With(perm(permission.TenantManage), inTenant).Put("/users/{id}/tenants", tenantHandler.SetUserTenants)
+
+            // API keys
+            priv.With(perm(permission.APIKeyManage)).Get("/api-keys", apiKeyHandler.List)
+            priv.With(perm(permission.APIKeyManage)).Post("/api-keys", apiKeyHandler.Create)
+            priv.With(perm(permission.APIKeyManage)).Post("/api-keys/{id}/revoke", apiKeyHandler.Revoke)
 
             // Master Barang CRUD
-            priv.Get("/barang", barangHandler.GetAll)
-            priv.Get("/barang/stok", barangHandler.GetAllWithStok)
-            priv.Get("/barang/{id}", barangHandler.GetByID)
-            priv.Post("/barang", barangHandler.Create)
-            priv.Put("/barang/{id}", barangHandler.UpdateBarang)
-            // Only admin can delete
-            priv.With(wm.RequireRoles("admin")).Delete("/barang/{id}", barangHandler.DeleteBarang)
+            priv.With(perm(permission.BarangView)).Get("/barang", barangHandler.GetAll)
+            priv.With(perm(permission.BarangView)).Get("/barang/stok", barangHandler.GetAllWithStok)
+            priv.With(perm(permission.BarangView)).Get("/barang/scan/{barcode}", barcodeHandler.Scan)
+            priv.With(perm(permission.BarangView)).Get("/barang/label", labelHandler.Bulk)
+            priv.With(perm(permission.BarangImport)).Post("/barang/import", importHandler.Import)
+            priv.With(perm(permission.BarangView)).Get("/barang/{id}", barangHandler.GetByID)
+            priv.With(perm(permission.BarangView)).Get("/barang/{id}/harga", hargaHandler.GetHistory)
+            priv.With(perm(permission.BarangView)).Get("/barang/{id}/barcode", barcodeHandler.List)
+            priv.With(perm(permission.BarangView)).Get("/barang/{id}/label", labelHandler.Single)
+            priv.With(perm(permission.BarangWrite)).Post("/barang/{id}/barcode", barcodeHandler.Create)
+            priv.With(perm(permission.BarangDelete)).Delete("/barang/{id}/barcode/{barcode_id}", barcodeHandler.Delete)
+            priv.With(perm(permission.BarangWrite)).Post("/barang", barangHandler.Create)
+            priv.With(perm(permission.BarangWrite)).Put("/barang/{id}", barangHandler.UpdateBarang)
+            priv.With(perm(permission.BarangWrite)).Patch("/barang/{id}", barangHandler.PatchBarang)
+            priv.With(perm(permission.BarangDelete)).Delete("/barang/{id}", barangHandler.DeleteBarang)
+            priv.With(perm(permission.BarangDelete)).Post("/barang/{id}/archive", barangHandler.Archive)
+            priv.With(perm(permission.BarangDelete)).Post("/barang/{id}/restore", barangHandler.Restore)
+            priv.With(perm(permission.BarangWrite)).Put("/barang/{id}/gambar", gambarHandler.Upload)
+            priv.With(perm(permission.BarangWrite)).Delete("/barang/{id}/gambar", gambarHandler.Delete)
+
+            // Bundles and assembly
+            priv.With(perm(permission.BarangView)).Get("/bundel", bundelHandler.List)
+            priv.With(perm(permission.BarangView)).Get("/barang/{id}/bundel", bundelHandler.Get)
+            priv.With(perm(permission.BundelWrite)).Put("/barang/{id}/bundel", bundelHandler.Set)
+            priv.With(perm(permission.BundelWrite)).Delete("/barang/{id}/bundel", bundelHandler.Delete)
+            priv.With(perm(permission.RakitCreate)).Post("/rakit", bundelHandler.Rakit)
+            priv.With(perm(permission.StokView)).Get("/rakit", bundelHandler.ListRakit)
+            priv.With(perm(permission.StokView)).Get("/rakit/{id}", bundelHandler.GetRakit)
+
+            // Background jobs (imports)
+            priv.With(perm(permission.BarangImport, permission.StokImport)).Get("/jobs/{id}", jobHandler.Get)
+
+            // Barang induk (variants)
+            priv.With(perm(permission.BarangView)).Get("/barang-induk", varianHandler.List)
+            priv.With(perm(permission.BarangView)).Get("/barang-induk/{id}", varianHandler.Get)
+            priv.With(perm(permission.BarangWrite)).Post("/barang-induk", varianHandler.Create)
+            priv.With(perm(permission.BarangWrite)).Put("/barang-induk/{id}", varianHandler.Update)
+            priv.With(perm(permission.BarangWrite)).Post("/barang-induk/{id}/varian", varianHandler.TambahVarian)
+            priv.With(perm(permission.BarangDelete)).Delete("/barang-induk/{id}", varianHandler.Delete)
+
+            // Kategori
+            priv.With(perm(permission.BarangView)).Get("/kategori", kategoriHandler.GetAll)
+            priv.With(perm(permission.BarangView)).Get("/kategori/{id}", kategoriHandler.GetByID)
+            priv.With(perm(permission.KategoriWrite)).Post("/kategori", kategoriHandler.Create)
+            priv.With(perm(permission.KategoriWrite)).Put("/kategori/{id}", kategoriHandler.Update)
+            priv.With(perm(permission.KategoriWrite)).Delete("/kategori/{id}", kategoriHandler.Delete)
+
+            // Daftar Harga, Jadwal Harga and Grup Pelanggan
+            priv.With(perm(permission.BarangView)).Get("/daftar-harga", hargaHandler.ListDaftarHarga)
+            priv.With(perm(permission.BarangView)).Get("/daftar-harga/{id}", hargaHandler.GetDaftarHarga)
+            priv.With(perm(permission.HargaWrite)).Post("/daftar-harga", hargaHandler.CreateDaftarHarga)
+            priv.With(perm(permission.HargaWrite)).Delete("/daftar-harga/{id}", hargaHandler.DeleteDaftarHarga)
+            priv.With(perm(permission.HargaWrite)).Put("/daftar-harga/{id}/item", hargaHandler.UpsertItems)
+            priv.With(perm(permission.HargaWrite)).Delete("/daftar-harga/{id}/item/{item_id}", hargaHandler.DeleteItem)
+            priv.With(perm(permission.BarangView)).Get("/jadwal-harga", jadwalHargaHandler.List)
+            priv.With(perm(permission.HargaWrite)).Post("/jadwal-harga", jadwalHargaHandler.Create)
+            priv.With(perm(permission.HargaWrite)).Delete("/jadwal-harga/{id}", jadwalHargaHandler.Cancel)
+            priv.With(perm(permission.BarangView)).Get("/grup-pelanggan", hargaHandler.ListGrup)
+            priv.With(perm(permission.HargaWrite)).Post("/grup-pelanggan", hargaHandler.CreateGrup)
+            priv.With(perm(permission.HargaWrite)).Put("/grup-pelanggan/{id}", hargaHandler.UpdateGrup)
+
+            // Promo
+            priv.With(perm(permission.BarangView)).Get("/promo", promoHandler.GetAll)
+            priv.With(perm(permission.BarangView)).Get("/promo/{id}", promoHandler.GetByID)
+            priv.With(perm(permission.PromoWrite)).Post("/promo", promoHandler.Create)
+            priv.With(perm(permission.PromoWrite)).Put("/promo/{id}", promoHandler.Update)
+            priv.With(perm(permission.PromoWrite)).Delete("/promo/{id}", promoHandler.Delete)
 
             // Stok and History
-            priv.Get("/stok", stokHandler.GetStokAkhirAll)
-            priv.Get("/history-stok", stokHandler.GetHistoryAll)
-            priv.Get("/stok/{barang_id}", stokHandler.GetStokByBarangHandler)
-            priv.Get("/history-stok/{barang_id}", stokHandler.GetHistoryByBarangHandler)
+            priv.With(perm(permission.StokView)).Get("/stok", stokHandler.GetStokAkhirAll)
+            priv.With(perm(permission.StokView)).Get("/history-stok", stokHandler.GetHistoryAll)
+            priv.With(perm(permission.StokView)).Get("/stok/saldo-awal", stokHandler.GetSaldoAwal)
+            priv.With(perm(permission.StokImport)).Post("/stok/saldo-awal/import", importHandler.SaldoAwal)
+            priv.With(perm(permission.StokView)).Get("/stok/{barang_id}", stokHandler.GetStokByBarangHandler)
+            priv.With(perm(permission.StokView)).Get("/history-stok/{barang_id}", stokHandler.GetHistoryByBarangHandler)
 
             // Transaksi Pembelian
-            priv.Post("/pembelian", pembelianHandler.CreatePembelianHandler)
-            priv.Get("/pembelian", pembelianHandler.GetAll)
-            priv.Get("/pembelian/{id}", pembelianHandler.GetByID)
+            priv.With(perm(permission.PembelianCreate)).Post("/pembelian", pembelianHandler.CreatePembelianHandler)
+            priv.With(perm(permission.PembelianView)).Get("/pembelian", pembelianHandler.GetAll)
+            priv.With(perm(permission.PembelianView)).Get("/pembelian/{id}", pembelianHandler.GetByID)
+            priv.With(perm(permission.PembelianView)).Get("/pembelian/{id}/pdf", dokumenHandler.Pembelian)
+            priv.With(perm(permission.PembelianView)).Get("/pembelian/{id}/lampiran", lampiranHandler.ListPembelian)
+            priv.With(perm(permission.PembelianCreate)).Post("/pembelian/{id}/lampiran", lampiranHandler.Pembelian)
 
             // Transaksi Penjualan
-            priv.Post("/penjualan", penjualanHandler.CreatePenjualanHandler)
-            priv.Get("/penjualan", penjualanHandler.GetAll)
-            priv.Get("/penjualan/{id}", penjualanHandler.GetByID)
+            priv.With(perm(permission.PenjualanCreate)).Post("/penjualan", penjualanHandler.CreatePenjualanHandler)
+            priv.With(perm(permission.PenjualanCreate)).Post("/penjualan/preview", penjualanHandler.Preview)
+            priv.With(perm(permission.PenjualanView)).Get("/penjualan", penjualanHandler.GetAll)
+            priv.With(perm(permission.PenjualanView)).Get("/penjualan/{id}", penjualanHandler.GetByID)
+            priv.With(perm(permission.PenjualanView)).Get("/penjualan/{id}/pdf", dokumenHandler.Penjualan)
+            priv.With(perm(permission.PenjualanView)).Get("/penjualan/{id}/lampiran", lampiranHandler.ListPenjualan)
+            priv.With(perm(permission.PenjualanCreate)).Post("/penjualan/{id}/lampiran", lampiranHandler.Penjualan)
+
+            // Attachments
+            priv.With(perm(permission.PembelianView, permission.PenjualanView)).Get("/lampiran/{id}", lampiranHandler.Download)
+            priv.With(perm(permission.LampiranDelete)).Delete("/lampiran/{id}", lampiranHandler.Delete)
 
             // Laporan
-            priv.Get("/laporan/stok", laporanHandler.LaporanStok)
-            priv.Get("/laporan/penjualan", laporanHandler.LaporanPenjualan)
-            priv.Get("/laporan/pembelian", laporanHandler.LaporanPembelian)
+            priv.With(perm(permission.LaporanView)).Get("/laporan/stok", laporanHandler.LaporanStok)
+            priv.With(perm(permission.LaporanView)).Get("/laporan/penjualan", laporanHandler.LaporanPenjualan)
+            priv.With(perm(permission.LaporanView)).Get("/laporan/pembelian", laporanHandler.LaporanPembelian)
+            priv.With(perm(permission.LaporanView)).Get("/laporan/ppn", laporanHandler.LaporanPPN)
+
+            // Pajak (PPN)
+            priv.With(perm(permission.BarangView)).Get("/pajak/tarif", pajakHandler.ListTarif)
+            priv.With(perm(permission.PajakWrite)).Post("/pajak/tarif", pajakHandler.CreateTarif)
+
+            // Audit log
+            priv.With(perm(permission.AuditView)).Get("/audit", auditHandler.List)
         })
     })
 
@@ -84,3 +338,30 @@ func main() {
         log.Fatal(err)
     }
 }
+
+// trustedProxies reads TRUSTED_PROXIES, the IPs/CIDRs of the reverse proxies
+// whose X-Forwarded-For is believed. The older TRUST_PROXY=true trusts the
+// loopback and private networks.
+func trustedProxies() wm.TrustedProxies {
+    list := config.Env("TRUSTED_PROXIES", "")
+    if list == "" && config.EnvBool("TRUST_PROXY", false) {
+        log.Printf("TRUST_PROXY is deprecated; trusting %s, set TRUSTED_PROXIES instead", wm.PrivateNetworks)
+        list = wm.PrivateNetworks
+    }
+    proxies, err := wm.ParseTrustedProxies(list)
+    if err != nil { log.Fatalf("TRUSTED_PROXIES: %v", err) }
+    return proxies
+}
+
+// loginPolicy reads a login limiter policy from <prefix>_FREE_ATTEMPTS,
+// _BACKOFF_BASE, _BACKOFF_MAX, _LOCKOUT_AFTER, _LOCKOUT_DURATION and _WINDOW.
+func loginPolicy(prefix string, def loginlimit.Policy) loginlimit.Policy {
+    return loginlimit.Policy{
+        Free:      config.EnvInt(prefix+"_FREE_ATTEMPTS", def.Free),
+        Base:      config.EnvDuration(prefix+"_BACKOFF_BASE", def.Base),
+        Max:       config.EnvDuration(prefix+"_BACKOFF_MAX", def.Max),
+        LockAfter: config.EnvInt(prefix+"_LOCKOUT_AFTER", def.LockAfter),
+        LockFor:   config.EnvDuration(prefix+"_LOCKOUT_DURATION", def.LockFor),
+        Window:    config.EnvDuration(prefix+"_WINDOW", def.Window),
+    }
+}
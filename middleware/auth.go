@@ -2,53 +2,83 @@ package middleware
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"warehouse/apikey"
+	"warehouse/models"
+	"warehouse/tenant"
+	"warehouse/token"
 )
 
-var jwtSecret = []byte("super-secret-key")
-
 type ctxKey string
 
 const (
     ctxUserID ctxKey = "user_id"
     ctxRole   ctxKey = "role"
+    ctxSesi   ctxKey = "sid"
+    ctxPerms  ctxKey = "permissions"
 )
 
-// AuthMiddleware verifies Bearer JWT and sets user_id and role into context.
-func AuthMiddleware(next http.Handler) http.Handler {
+// UserStatus looks up the current state of a user and session.
+type UserStatus interface {
+    AuthStatus(ctx context.Context, userID, tenantID int64, sid string) (models.StatusAuth, error)
+}
+
+// APIKeyStore looks up API keys.
+type APIKeyStore interface {
+    // AuthAPIKey returns the key with prefix and its user's state, or nil.
+    AuthAPIKey(ctx context.Context, prefix string) (*models.AuthAPIKey, error)
+    // TouchAPIKey records that key id was used from ip.
+    TouchAPIKey(ctx context.Context, id int64, ip string) error
+}
+
+// Authenticator verifies Bearer access tokens issued by Tokens. When Users
+// is set, every request is also checked against the user's current state:
+// deactivated users and revoked sessions are rejected and the role and its
+// permissions come from the database rather than the token, so all of them
+// take effect before the token expires. Without Users no permissions are
+// known and RequirePermission refuses every request.
+//
+// When Keys is set, requests may instead carry an API key in the X-API-Key
+// header. They act as the key's user with the permissions that are both in
+// the key's scopes and in the user's role.
+//
+// The request context carries the tenant of the token (tid claim) or key,
+// so the database statements of the request only see that tenant's rows.
+// Users who are no longer members of the tenant are rejected.
+type Authenticator struct {
+    Tokens     *token.Manager
+    Users      UserStatus
+    Keys       APIKeyStore
+    Proxies    TrustedProxies // whose X-Forwarded-For is believed for API key allowlists
+}
+
+func NewAuthenticator(tokens *token.Manager, users UserStatus, keys APIKeyStore, proxies TrustedProxies) *Authenticator {
+    return &Authenticator{Tokens: tokens, Users: users, Keys: keys, Proxies: proxies}
+}
+
+// Middleware verifies the Bearer JWT or API key and sets user_id, role and
+// permissions into context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if key := r.Header.Get(apikey.Header); key != "" && a.Keys != nil {
+            a.apiKey(w, r, next, key)
+            return
+        }
         auth := r.Header.Get("Authorization")
         if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
             http.Error(w, "missing or invalid Authorization header", http.StatusUnauthorized)
             return
         }
         tokenStr := strings.TrimPrefix(auth, "Bearer ")
-        token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-            if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-                return nil, jwt.ErrTokenSignatureInvalid
-            }
-            return jwtSecret, nil
-        })
-        if err != nil || !token.Valid {
+        claims, err := a.Tokens.ParseAccess(tokenStr)
+        if err != nil {
             http.Error(w, "invalid token", http.StatusUnauthorized)
             return
         }
-        claims, ok := token.Claims.(jwt.MapClaims)
-        if !ok {
-            http.Error(w, "invalid token claims", http.StatusUnauthorized)
-            return
-        }
-        // Check exp if present (jwt lib can also validate, but keep it simple)
-        if exp, ok := claims["exp"].(float64); ok {
-            if time.Now().Unix() > int64(exp) {
-                http.Error(w, "token expired", http.StatusUnauthorized)
-                return
-            }
-        }
         var userID int64
         if v, ok := claims["user_id"].(float64); ok {
             userID = int64(v)
@@ -57,12 +87,98 @@ func AuthMiddleware(next http.Handler) http.Handler {
             return
         }
         role, _ := claims["role"].(string)
-        ctx := context.WithValue(r.Context(), ctxUserID, userID)
+        sid, _ := claims["sid"].(string)
+        tid := token.TenantID(claims)
+        var perms []string
+        if a.Users != nil {
+            st, err := a.Users.AuthStatus(r.Context(), userID, tid, sid)
+            if err != nil {
+                http.Error(w, "authentication unavailable", http.StatusServiceUnavailable)
+                return
+            }
+            if !st.Aktif {
+                http.Error(w, "user is deactivated", http.StatusUnauthorized)
+                return
+            }
+            if st.SesiDicabut {
+                http.Error(w, "session has been revoked", http.StatusUnauthorized)
+                return
+            }
+            if !st.Anggota {
+                http.Error(w, "no access to this tenant", http.StatusUnauthorized)
+                return
+            }
+            role, perms = st.Role, st.Permissions
+        }
+        ctx := tenant.WithID(r.Context(), tid)
+        ctx = context.WithValue(ctx, ctxUserID, userID)
         ctx = context.WithValue(ctx, ctxRole, role)
+        ctx = context.WithValue(ctx, ctxSesi, sid)
+        ctx = context.WithValue(ctx, ctxPerms, perms)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
 }
 
+// apiKey authenticates a request carrying an API key.
+func (a *Authenticator) apiKey(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+    prefix, ok := apikey.Prefix(key)
+    if !ok {
+        http.Error(w, "invalid api key", http.StatusUnauthorized)
+        return
+    }
+    k, err := a.Keys.AuthAPIKey(r.Context(), prefix)
+    if err != nil {
+        http.Error(w, "authentication unavailable", http.StatusServiceUnavailable)
+        return
+    }
+    if k == nil || !apikey.Verify(key, k.Hash) {
+        http.Error(w, "invalid api key", http.StatusUnauthorized)
+        return
+    }
+    if k.RevokedAt != nil {
+        http.Error(w, "api key has been revoked", http.StatusUnauthorized)
+        return
+    }
+    if k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt) {
+        http.Error(w, "api key has expired", http.StatusUnauthorized)
+        return
+    }
+    ip := ClientIP(r, a.Proxies)
+    if !apikey.AllowedIP(k.AllowedIPs, ip) {
+        http.Error(w, "api key is not allowed from this address", http.StatusForbidden)
+        return
+    }
+    if !k.User.Aktif {
+        http.Error(w, "user is deactivated", http.StatusUnauthorized)
+        return
+    }
+    if !k.User.Anggota {
+        http.Error(w, "no access to this tenant", http.StatusUnauthorized)
+        return
+    }
+    scope := make(map[string]bool, len(k.Scopes))
+    for _, s := range k.Scopes { scope[s] = true }
+    perms := make([]string, 0, len(k.Scopes))
+    for _, p := range k.User.Permissions {
+        if scope[p] { perms = append(perms, p) }
+    }
+    if err := a.Keys.TouchAPIKey(r.Context(), k.ID, ip); err != nil {
+        log.Printf("api key %d: last used: %v", k.ID, err)
+    }
+    ctx := tenant.WithID(r.Context(), k.TenantID)
+    ctx = context.WithValue(ctx, ctxUserID, k.UserID)
+    ctx = context.WithValue(ctx, ctxRole, k.User.Role)
+    ctx = context.WithValue(ctx, ctxPerms, perms)
+    ctx = apikey.WithID(ctx, k.ID)
+    next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// APIKeyFromContext retrieves the id of the API key the request was made
+// with; false for requests with a JWT.
+func APIKeyFromContext(ctx context.Context) (int64, bool) {
+    return apikey.FromContext(ctx)
+}
+
 // UserIDFromContext retrieves user_id from request context.
 func UserIDFromContext(ctx context.Context) (int64, bool) {
     v := ctx.Value(ctxUserID)
@@ -70,6 +186,12 @@ func UserIDFromContext(ctx context.Context) (int64, bool) {
     return id, ok
 }
 
+// SessionFromContext retrieves the session id (sid) of the access token.
+func SessionFromContext(ctx context.Context) (string, bool) {
+    s, ok := ctx.Value(ctxSesi).(string)
+    return s, ok
+}
+
 // RoleFromContext retrieves role from request context.
 func RoleFromContext(ctx context.Context) (string, bool) {
     v := ctx.Value(ctxRole)
@@ -77,6 +199,36 @@ func RoleFromContext(ctx context.Context) (string, bool) {
     return s, ok
 }
 
+// PermissionsFromContext retrieves the permissions of the user's role.
+func PermissionsFromContext(ctx context.Context) ([]string, bool) {
+    p, ok := ctx.Value(ctxPerms).([]string)
+    return p, ok
+}
+
+// HasPermission reports whether the authenticated user holds perm.
+func HasPermission(ctx context.Context, perm string) bool {
+    perms, _ := PermissionsFromContext(ctx)
+    for _, p := range perms {
+        if p == perm { return true }
+    }
+    return false
+}
+
+// RequirePermission allows a route to users holding any of perms.
+func RequirePermission(perms ...string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            for _, p := range perms {
+                if HasPermission(r.Context(), p) {
+                    next.ServeHTTP(w, r)
+                    return
+                }
+            }
+            http.Error(w, "forbidden: requires permission "+strings.Join(perms, " or "), http.StatusForbidden)
+        })
+    }
+}
+
 // RequireRoles allows only specified roles to access a route.
 func RequireRoles(allowed ...string) func(http.Handler) http.Handler {
     allowedSet := make(map[string]struct{}, len(allowed))
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies lists the networks of the reverse proxies whose
+// X-Forwarded-For entries are believed. Empty trusts none.
+type TrustedProxies []*net.IPNet
+
+// PrivateNetworks are the loopback and private ranges, the usual home of a
+// reverse proxy in front of the API.
+const PrivateNetworks = "127.0.0.0/8,::1/128,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,fc00::/7"
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and
+// CIDR networks.
+func ParseTrustedProxies(s string) (TrustedProxies, error) {
+    var out TrustedProxies
+    for _, part := range strings.Split(s, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" { continue }
+        if !strings.Contains(part, "/") {
+            ip := net.ParseIP(part)
+            if ip == nil { return nil, fmt.Errorf("invalid trusted proxy %q", part) }
+            bits := 128
+            if ip.To4() != nil { ip, bits = ip.To4(), 32 }
+            out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+            continue
+        }
+        _, n, err := net.ParseCIDR(part)
+        if err != nil { return nil, fmt.Errorf("invalid trusted proxy %q", part) }
+        out = append(out, n)
+    }
+    return out, nil
+}
+
+func (t TrustedProxies) contains(addr string) bool {
+    ip := net.ParseIP(addr)
+    if ip == nil { return false }
+    for _, n := range t {
+        if n.Contains(ip) { return true }
+    }
+    return false
+}
+
+// ClientIP returns the address of the client that sent r. When the
+// connection comes from a trusted proxy, X-Forwarded-For is read from the
+// right, skipping the entries added by trusted proxies; the first other
+// entry is the client. Entries left of it were written by the client and
+// are ignored, so they cannot be used to pose as another address.
+func ClientIP(r *http.Request, proxies TrustedProxies) string {
+    addr, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil { addr = r.RemoteAddr }
+    var hops []string
+    for _, v := range r.Header.Values("X-Forwarded-For") {
+        hops = append(hops, strings.Split(v, ",")...)
+    }
+    for i := len(hops) - 1; i >= 0 && proxies.contains(addr); i-- {
+        ip := net.ParseIP(strings.TrimSpace(hops[i]))
+        if ip == nil { break }
+        addr = ip.String()
+    }
+    return addr
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+    proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+    if err != nil { t.Fatal(err) }
+    tests := []struct {
+        name    string
+        remote  string
+        fwd     []string
+        proxies TrustedProxies
+        want    string
+    }{
+        {"no proxy configured", "203.0.113.9:5000", []string{"1.2.3.4"}, nil, "203.0.113.9"},
+        {"direct client ignores header", "203.0.113.9:5000", []string{"1.2.3.4"}, proxies, "203.0.113.9"},
+        {"one proxy", "10.0.0.2:5000", []string{"198.51.100.7"}, proxies, "198.51.100.7"},
+        {"spoofed entry left of the client", "10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.7"}, proxies, "198.51.100.7"},
+        {"proxy chain", "10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.7, 192.0.2.1"}, proxies, "198.51.100.7"},
+        {"several headers", "10.0.0.2:5000", []string{"1.2.3.4", "198.51.100.7, 10.1.1.1"}, proxies, "198.51.100.7"},
+        {"only proxies", "10.0.0.2:5000", []string{"10.0.0.5"}, proxies, "10.0.0.5"},
+        {"garbage entry", "10.0.0.2:5000", []string{"1.2.3.4, unknown"}, proxies, "10.0.0.2"},
+        {"no header", "10.0.0.2:5000", nil, proxies, "10.0.0.2"},
+        {"ipv6", "[2001:db8::1]:443", nil, proxies, "2001:db8::1"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := httptest.NewRequest("GET", "/", nil)
+            r.RemoteAddr = tt.remote
+            for _, v := range tt.fwd { r.Header.Add("X-Forwarded-For", v) }
+            if got := ClientIP(r, tt.proxies); got != tt.want {
+                t.Errorf("ClientIP = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+    if p, err := ParseTrustedProxies(""); err != nil || len(p) != 0 {
+        t.Errorf("empty list = %v, %v", p, err)
+    }
+    if p, err := ParseTrustedProxies(PrivateNetworks); err != nil || len(p) != 6 {
+        t.Errorf("PrivateNetworks = %v, %v", p, err)
+    }
+    for _, bad := range []string{"10.0.0.0/33", "proxy.local", "10.0.0"} {
+        if _, err := ParseTrustedProxies(bad); err == nil {
+            t.Errorf("ParseTrustedProxies(%q) succeeded, want error", bad)
+        }
+    }
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// APIKey is a key for machine-to-machine clients. Requests made with it act
+// as user UserID, limited to Scopes. The key itself is never stored.
+type APIKey struct {
+	ID         int64      `json:"id" db:"id"`
+	Nama       string     `json:"nama" db:"nama"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	UserID     int64      `json:"user_id" db:"user_id"`
+	Username   string     `json:"username,omitempty"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips" db:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip,omitempty" db:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedBy  *int64     `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	// Key is the full key, only returned once when it is created.
+	Key string `json:"key,omitempty"`
+}
+
+// AuthAPIKey is what the auth middleware needs to check an API key.
+type AuthAPIKey struct {
+	ID         int64
+	TenantID   int64
+	Hash       string
+	UserID     int64
+	Scopes     []string
+	AllowedIPs []string
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	User       StatusAuth
+}
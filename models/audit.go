@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog represents a row in audit_log: one mutating request, who made it
+// and what it changed. For updates Sebelum and Sesudah hold only the fields
+// that changed; creates have no Sebelum and deletes no Sesudah.
+type AuditLog struct {
+    ID        int64           `json:"id" db:"id"`
+    UserID    *int64          `json:"user_id,omitempty" db:"user_id"`
+    Username  string          `json:"username,omitempty" db:"-"`
+    Aksi      string          `json:"aksi" db:"aksi"`
+    Entitas   string          `json:"entitas" db:"entitas"`
+    EntitasID *int64          `json:"entitas_id,omitempty" db:"entitas_id"`
+    Sebelum   json.RawMessage `json:"sebelum,omitempty" db:"sebelum"`
+    Sesudah   json.RawMessage `json:"sesudah,omitempty" db:"sesudah"`
+    Method    string          `json:"method" db:"method"`
+    Path      string          `json:"path" db:"path"`
+    Status    int             `json:"status" db:"status"`
+    IP        string          `json:"ip" db:"ip"`
+    CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter narrows GET /api/audit. Zero values do not filter.
+type AuditFilter struct {
+    Entitas   string
+    EntitasID int64
+    UserID    int64
+    Aksi      string
+    From, To  *time.Time
+}
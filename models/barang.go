@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Barang represents the master_barang table.
 type Barang struct {
     ID         int64   `json:"id" db:"id"`
@@ -9,4 +11,43 @@ type Barang struct {
     Satuan     string  `json:"satuan" db:"satuan"`
     HargaBeli  int64   `json:"harga_beli" db:"harga_beli"`
     HargaJual  int64   `json:"harga_jual" db:"harga_jual"`
+    KategoriID *int64  `json:"kategori_id,omitempty" db:"kategori_id"`
+    // Tax settings; nil means "not loaded" on nested reads and "use default" on writes.
+    KenaPajak          *bool `json:"kena_pajak,omitempty" db:"kena_pajak"`
+    HargaTermasukPajak *bool `json:"harga_termasuk_pajak,omitempty" db:"harga_termasuk_pajak"`
+    // ArchivedAt is set while the barang is archived (discontinued); it is
+    // read-only and changed through archive/restore.
+    ArchivedAt *time.Time `json:"archived_at,omitempty" db:"archived_at"`
+    // Version is incremented by every update; it is the barang's ETag and
+    // must be sent back in If-Match to update or delete it.
+    Version int64 `json:"version" db:"version"`
+    // IndukID and Varian are set on variants of a BarangInduk; both are
+    // read-only here and managed through /api/barang-induk.
+    IndukID *int64            `json:"induk_id,omitempty" db:"induk_id"`
+    Varian  map[string]string `json:"varian,omitempty" db:"varian"`
+    // GambarURL and ThumbnailURL point at the product image, set through
+    // /api/barang/{id}/gambar; nil when the barang has no image.
+    GambarURL    *string `json:"gambar_url,omitempty" db:"-"`
+    ThumbnailURL *string `json:"thumbnail_url,omitempty" db:"-"`
+}
+
+// BarangBarcode represents a row in barang_barcode. Satuan optionally names
+// the packaging unit the barcode is printed on (e.g. "dus") and Isi is how
+// many base units of the barang that unit holds.
+type BarangBarcode struct {
+    ID       int64   `json:"id" db:"id"`
+    BarangID int64   `json:"barang_id" db:"barang_id"`
+    Barcode  string  `json:"barcode" db:"barcode"`
+    Satuan   *string `json:"satuan,omitempty" db:"satuan"`
+    Isi      int64   `json:"isi" db:"isi"`
+}
+
+// LabelBarang is the data printed on a shelf label. Barcode is the primary
+// barcode (the first one registered for the base unit), empty when none.
+type LabelBarang struct {
+    BarangID   int64  `json:"barang_id"`
+    KodeBarang string `json:"kode_barang"`
+    NamaBarang string `json:"nama_barang"`
+    HargaJual  int64  `json:"harga_jual"`
+    Barcode    string `json:"barcode,omitempty"`
 }
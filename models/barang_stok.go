@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type BarangWithStok struct {
 	ID         int64   `json:"id"`
 	KodeBarang string  `json:"kode_barang"`
@@ -9,4 +11,19 @@ type BarangWithStok struct {
 	HargaBeli  int64   `json:"harga_beli"`
 	HargaJual  int64   `json:"harga_jual"`
 	StokAkhir  int64   `json:"stok_akhir"`
+	KategoriID *int64  `json:"kategori_id,omitempty"`
+	KenaPajak          *bool `json:"kena_pajak,omitempty"`
+	HargaTermasukPajak *bool `json:"harga_termasuk_pajak,omitempty"`
+	ArchivedAt         *time.Time `json:"archived_at,omitempty"`
+	Version            int64      `json:"version"`
+	IndukID            *int64            `json:"induk_id,omitempty"`
+	Varian             map[string]string `json:"varian,omitempty"`
+	GambarURL          *string           `json:"gambar_url,omitempty"`
+	ThumbnailURL       *string           `json:"thumbnail_url,omitempty"`
+}
+
+// HasilScan is the barang found for a scanned barcode, with current stock.
+type HasilScan struct {
+	BarangWithStok
+	Barcode BarangBarcode `json:"barcode"`
 }
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// KomponenBundel is one line of a bundle's bill of materials: Qty units of
+// barang BarangID per bundle. StokAkhir is the component's current stock.
+type KomponenBundel struct {
+    BarangID   int64  `json:"barang_id"`
+    KodeBarang string `json:"kode_barang,omitempty"`
+    NamaBarang string `json:"nama_barang,omitempty"`
+    Qty        int64  `json:"qty"`
+    StokAkhir  int64  `json:"stok_akhir"`
+}
+
+// Bundel is a barang made of other barang. A virtual bundle has no stock of
+// its own: selling it takes the components out of stock. Otherwise it is a
+// kit with its own stock, built from the components by assembly orders.
+// Tersedia is how many bundles the component stock allows.
+type Bundel struct {
+    BarangID   int64            `json:"barang_id"`
+    KodeBarang string           `json:"kode_barang"`
+    NamaBarang string           `json:"nama_barang"`
+    Virtual    bool             `json:"virtual"`
+    Komponen   []KomponenBundel `json:"komponen"`
+    StokAkhir  int64            `json:"stok_akhir"`
+    Tersedia   int64            `json:"tersedia"`
+}
+
+// RakitHeader is an assembly order building Qty units of kit BarangID.
+// Details lists the components consumed.
+type RakitHeader struct {
+    ID           int64         `json:"id" db:"id"`
+    NoRakit      string        `json:"no_rakit" db:"no_rakit"`
+    BarangID     int64         `json:"barang_id" db:"barang_id"`
+    Qty          int64         `json:"qty" db:"qty"`
+    Catatan      *string       `json:"catatan,omitempty" db:"catatan"`
+    UserID       int64         `json:"user_id" db:"user_id"`
+    CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+    Details      []RakitDetail `json:"details,omitempty" db:"-"`
+    BarangDetail *Barang       `json:"barang_detail,omitempty" db:"-"`
+}
+
+// RakitDetail is a component consumed by an assembly order, Qty in total.
+type RakitDetail struct {
+    ID            int64   `json:"id" db:"id"`
+    RakitHeaderID int64   `json:"rakit_header_id" db:"rakit_header_id"`
+    BarangID      int64   `json:"barang_id" db:"barang_id"`
+    Qty           int64   `json:"qty" db:"qty"`
+    BarangDetail  *Barang `json:"barang_detail,omitempty" db:"-"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// HargaHistory represents a row in harga_history, written whenever the
+// harga_beli or harga_jual of a barang changes. UserID is nil for changes
+// made by the system (e.g. scheduled price changes).
+type HargaHistory struct {
+    ID            int64     `json:"id" db:"id"`
+    BarangID      int64     `json:"barang_id" db:"barang_id"`
+    HargaBeliLama int64     `json:"harga_beli_lama" db:"harga_beli_lama"`
+    HargaBeliBaru int64     `json:"harga_beli_baru" db:"harga_beli_baru"`
+    HargaJualLama int64     `json:"harga_jual_lama" db:"harga_jual_lama"`
+    HargaJualBaru int64     `json:"harga_jual_baru" db:"harga_jual_baru"`
+    UserID        *int64    `json:"user_id,omitempty" db:"user_id"`
+    Keterangan    string    `json:"keterangan" db:"keterangan"`
+    CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// DaftarHarga represents a named price list (retail, grosir, reseller, ...).
+// Items holds its quantity-break tiers when loaded by ID.
+type DaftarHarga struct {
+    ID         int64             `json:"id" db:"id"`
+    Nama       string            `json:"nama" db:"nama"`
+    Keterangan *string           `json:"keterangan,omitempty" db:"keterangan"`
+    Items      []DaftarHargaItem `json:"items,omitempty" db:"-"`
+}
+
+// DaftarHargaItem is one quantity-break tier: buying at least MinQty of the
+// barang under this price list costs Harga per unit.
+type DaftarHargaItem struct {
+    ID            int64   `json:"id" db:"id"`
+    DaftarHargaID int64   `json:"daftar_harga_id" db:"daftar_harga_id"`
+    BarangID      int64   `json:"barang_id" db:"barang_id"`
+    MinQty        int64   `json:"min_qty" db:"min_qty"`
+    Harga         int64   `json:"harga" db:"harga"`
+    BarangDetail  *Barang `json:"barang_detail,omitempty" db:"-"`
+}
+
+// GrupPelanggan is a customer group assigned to a price list.
+type GrupPelanggan struct {
+    ID            int64  `json:"id" db:"id"`
+    Nama          string `json:"nama" db:"nama"`
+    DaftarHargaID *int64 `json:"daftar_harga_id,omitempty" db:"daftar_harga_id"`
+}
+
+// JadwalHarga represents a row in jadwal_harga: a planned harga_beli and/or
+// harga_jual change for a barang. A nil Selesai makes the change permanent;
+// otherwise the original prices (HargaBeliAsal/HargaJualAsal, captured when
+// the change starts) are restored at Selesai.
+//
+// Status moves terjadwal -> aktif -> selesai, or to dibatalkan when cancelled.
+type JadwalHarga struct {
+    ID            int64      `json:"id" db:"id"`
+    BarangID      int64      `json:"barang_id" db:"barang_id"`
+    KategoriID    *int64     `json:"kategori_id,omitempty" db:"kategori_id"` // set when created for a whole kategori
+    HargaBeli     *int64     `json:"harga_beli,omitempty" db:"harga_beli"`
+    HargaJual     *int64     `json:"harga_jual,omitempty" db:"harga_jual"`
+    Mulai         time.Time  `json:"mulai" db:"mulai"`
+    Selesai       *time.Time `json:"selesai,omitempty" db:"selesai"`
+    Status        string     `json:"status" db:"status"`
+    HargaBeliAsal *int64     `json:"harga_beli_asal,omitempty" db:"harga_beli_asal"`
+    HargaJualAsal *int64     `json:"harga_jual_asal,omitempty" db:"harga_jual_asal"`
+    Keterangan    string     `json:"keterangan" db:"keterangan"`
+    UserID        int64      `json:"user_id" db:"user_id"`
+    CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+    BarangDetail  *Barang    `json:"barang_detail,omitempty" db:"-"`
+}
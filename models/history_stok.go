@@ -13,6 +13,8 @@ type HistoryStok struct {
 	StokSebelum    int64     `json:"stok_sebelum" db:"stok_sebelum"`
 	StokSesudah    int64     `json:"stok_sesudah" db:"stok_sesudah"`
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	APIKeyID       *int64    `json:"api_key_id" db:"api_key_id"`  // set when the movement was made with an API key
+	APIKeyNama     *string   `json:"api_key_nama,omitempty" db:"-"`
 	BarangDetail   *Barang   `json:"barang_detail,omitempty" db:"-"`
 	UserDetail     *User     `json:"user_detail,omitempty" db:"-"`
 }
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// BarangImport is one parsed row of a barang import file. Baris is the row
+// number in the file (the header is row 1). An empty KodeBarang means a new
+// barang whose kode is generated.
+type BarangImport struct {
+    Baris  int
+    Barang Barang
+}
+
+// ImportError describes why a row of an import file was rejected.
+type ImportError struct {
+    Baris      int    `json:"baris"`
+    KodeBarang string `json:"kode_barang,omitempty"`
+    Pesan      string `json:"pesan"`
+}
+
+// ImportReport summarises an import. Disimpan is false when nothing was
+// written because the import was all-or-nothing and some row failed.
+type ImportReport struct {
+    Total      int           `json:"total"`
+    Dibuat     int           `json:"dibuat"`
+    Diperbarui int           `json:"diperbarui"`
+    Gagal      int           `json:"gagal"`
+    Disimpan   bool          `json:"disimpan"`
+    Errors     []ImportError `json:"errors"`
+}
+
+// SaldoAwalImport is one parsed row of an opening-balance file. A nil
+// HargaSatuan means the barang's current harga_beli is used as unit cost.
+type SaldoAwalImport struct {
+    Baris       int
+    KodeBarang  string
+    Qty         int64
+    HargaSatuan *int64
+}
+
+// SaldoAwal represents a row in saldo_awal: the opening stock of a barang.
+type SaldoAwal struct {
+    ID          int64     `json:"id" db:"id"`
+    BarangID    int64     `json:"barang_id" db:"barang_id"`
+    KodeBarang  string    `json:"kode_barang" db:"-"`
+    NamaBarang  string    `json:"nama_barang" db:"-"`
+    Qty         int64     `json:"qty" db:"qty"`
+    HargaSatuan int64     `json:"harga_satuan" db:"harga_satuan"`
+    Nilai       int64     `json:"nilai" db:"nilai"`
+    UserID      int64     `json:"user_id" db:"user_id"`
+    CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// SaldoAwalReport is the reconciliation summary of an opening-balance import:
+// what was applied, the total quantity and value at cost, and rejected rows.
+type SaldoAwalReport struct {
+    Total      int           `json:"total"`
+    Diterapkan int           `json:"diterapkan"`
+    Gagal      int           `json:"gagal"`
+    Disimpan   bool          `json:"disimpan"`
+    TotalQty   int64         `json:"total_qty"`
+    TotalNilai int64         `json:"total_nilai"`
+    Items      []SaldoAwal   `json:"items"`
+    Errors     []ImportError `json:"errors"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// Kategori represents a node of the category tree. ParentID nil = root.
+type Kategori struct {
+    ID       int64      `json:"id" db:"id"`
+    Nama     string     `json:"nama" db:"nama"`
+    ParentID *int64     `json:"parent_id,omitempty" db:"parent_id"`
+    Children []Kategori `json:"children,omitempty" db:"-"`
+}
+
+// StokKategori is one row of the stock report grouped by category. Totals
+// include every descendant category; KategoriID nil holds uncategorized barang.
+type StokKategori struct {
+    KategoriID   *int64 `json:"kategori_id"`
+    Nama         string `json:"nama"`
+    ParentID     *int64 `json:"parent_id,omitempty"`
+    JumlahBarang int64  `json:"jumlah_barang"`
+    TotalStok    int64  `json:"total_stok"`
+    NilaiStok    int64  `json:"nilai_stok"` // stok_akhir * harga_beli
+}
+
+// PenjualanKategori is one row of the sales report grouped by category,
+// rolled up like StokKategori.
+type PenjualanKategori struct {
+    KategoriID   *int64 `json:"kategori_id"`
+    Nama         string `json:"nama"`
+    ParentID     *int64 `json:"parent_id,omitempty"`
+    JumlahFaktur int64  `json:"jumlah_faktur"`
+    Qty          int64  `json:"qty"`
+    Subtotal     int64  `json:"subtotal"`
+    DPP          int64  `json:"dpp"`
+    PPN          int64  `json:"ppn"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Lampiran is a document attached to a pembelian or penjualan, e.g. a
+// scanned supplier invoice. URL downloads it (authenticated).
+type Lampiran struct {
+    ID          int64     `json:"id" db:"id"`
+    Entitas     string    `json:"entitas" db:"entitas"`
+    EntitasID   int64     `json:"entitas_id" db:"entitas_id"`
+    NamaFile    string    `json:"nama_file" db:"nama_file"`
+    ContentType string    `json:"content_type" db:"content_type"`
+    Ukuran      int64     `json:"ukuran" db:"ukuran"`
+    StorageKey  string    `json:"-" db:"storage_key"`
+    UserID      int64     `json:"user_id" db:"user_id"`
+    CreatedAt   time.Time `json:"created_at" db:"created_at"`
+    URL         string    `json:"url" db:"-"`
+}
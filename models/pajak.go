@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// TarifPajak represents a row in tarif_pajak. Rates are effective-dated:
+// the rate applied to an invoice is the latest row whose BerlakuMulai is on
+// or before the invoice date, and it is copied onto the invoice header.
+type TarifPajak struct {
+    ID           int64     `json:"id" db:"id"`
+    Kode         string    `json:"kode" db:"kode"`
+    TarifBP      int64     `json:"tarif_bp" db:"tarif_bp"` // basis points, 1100 = 11%
+    BerlakuMulai time.Time `json:"berlaku_mulai" db:"berlaku_mulai"`
+    CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// FakturPPN is a single invoice line in the monthly PPN report.
+type FakturPPN struct {
+    ID         int64     `json:"id"`
+    NoFaktur   string    `json:"no_faktur"`
+    Pihak      string    `json:"pihak"`
+    Tanggal    time.Time `json:"tanggal"`
+    TarifBP    int64     `json:"tarif_ppn_bp"`
+    DPP        int64     `json:"dpp"`
+    PPN        int64     `json:"ppn"`
+    GrandTotal int64     `json:"grand_total"`
+}
+
+// RingkasanPPN aggregates either PPN keluaran (sales) or PPN masukan (purchases).
+type RingkasanPPN struct {
+    JumlahFaktur  int         `json:"jumlah_faktur"`
+    DPP           int64       `json:"dpp"`
+    DPPBebasPajak int64       `json:"dpp_bebas_pajak"`
+    PPN           int64       `json:"ppn"`
+    Faktur        []FakturPPN `json:"faktur"`
+}
+
+// LaporanPPN is the monthly PPN report. PPNTerutang is keluaran minus masukan;
+// a negative value means lebih bayar.
+type LaporanPPN struct {
+    Periode     string       `json:"periode"`
+    Keluaran    RingkasanPPN `json:"keluaran"`
+    Masukan     RingkasanPPN `json:"masukan"`
+    PPNTerutang int64        `json:"ppn_terutang"`
+}
@@ -10,6 +10,10 @@ type BeliHeader struct {
     NoFaktur  string       `json:"no_faktur" db:"no_faktur"`
     Supplier  string       `json:"supplier" db:"supplier"`
     Total     int64        `json:"total" db:"total"`
+    TarifPPN   int64       `json:"tarif_ppn_bp" db:"tarif_ppn_bp"`
+    DPP        int64       `json:"dpp" db:"dpp"`
+    PPN        int64       `json:"ppn" db:"ppn"`
+    GrandTotal int64       `json:"grand_total" db:"grand_total"`
     UserID    int64        `json:"user_id" db:"user_id"`
     Status    string       `json:"status" db:"status"`
     CreatedAt time.Time    `json:"created_at" db:"created_at"`
@@ -22,8 +26,12 @@ type BeliDetail struct {
     ID           int64 `json:"id" db:"id"`
     BeliHeaderID int64 `json:"beli_header_id" db:"beli_header_id"`
     BarangID     int64 `json:"barang_id" db:"barang_id"`
+    Barcode      string `json:"barcode,omitempty" db:"-"` // alternative to barang_id on input
     Qty          int64 `json:"qty" db:"qty"`
     Harga        int64 `json:"harga" db:"harga"`
     Subtotal     int64 `json:"subtotal" db:"subtotal"`
+    KenaPajak    bool  `json:"kena_pajak" db:"kena_pajak"`
+    DPP          int64 `json:"dpp" db:"dpp"`
+    PPN          int64 `json:"ppn" db:"ppn"`
     BarangDetail *Barang `json:"barang_detail,omitempty" db:"-"`
 }
@@ -5,11 +5,20 @@ import "time"
 // JualHeader represents a row in jual_header (sales transaction header).
 // Details holds associated line items submitted in a POST request and can be
 // populated after querying jual_detail for read operations.
+// Total is the sum of line subtotals; Diskon is the header-level promo
+// discount, spread over the lines before PPN is computed.
 type JualHeader struct {
     ID        int64        `json:"id" db:"id"`
     NoFaktur  string       `json:"no_faktur" db:"no_faktur"`
     Customer  string       `json:"customer" db:"customer"`
+    GrupPelangganID *int64 `json:"grup_pelanggan_id,omitempty" db:"grup_pelanggan_id"`
     Total     int64        `json:"total" db:"total"`
+    Diskon    int64        `json:"diskon" db:"diskon"`
+    PromoID   *int64       `json:"promo_id,omitempty" db:"promo_id"`
+    TarifPPN   int64       `json:"tarif_ppn_bp" db:"tarif_ppn_bp"`
+    DPP        int64       `json:"dpp" db:"dpp"`
+    PPN        int64       `json:"ppn" db:"ppn"`
+    GrandTotal int64       `json:"grand_total" db:"grand_total"`
     UserID    int64        `json:"user_id" db:"user_id"`
     Status    string       `json:"status" db:"status"`
     CreatedAt time.Time    `json:"created_at" db:"created_at"`
@@ -18,12 +27,21 @@ type JualHeader struct {
 }
 
 // JualDetail represents a row in jual_detail (sales line item).
+// Subtotal is Qty*Harga minus the line Diskon given by PromoID; free lines
+// added by a promo have Harga 0.
 type JualDetail struct {
     ID            int64 `json:"id" db:"id"`
     JualHeaderID  int64 `json:"jual_header_id" db:"jual_header_id"`
     BarangID      int64 `json:"barang_id" db:"barang_id"`
+    Barcode       string `json:"barcode,omitempty" db:"-"` // alternative to barang_id on input
     Qty           int64 `json:"qty" db:"qty"`
     Harga         int64 `json:"harga" db:"harga"`
+    Diskon        int64 `json:"diskon" db:"diskon"`
     Subtotal      int64 `json:"subtotal" db:"subtotal"`
+    PromoID       *int64 `json:"promo_id,omitempty" db:"promo_id"`
+    DaftarHargaID *int64 `json:"daftar_harga_id,omitempty" db:"daftar_harga_id"`
+    KenaPajak     bool  `json:"kena_pajak" db:"kena_pajak"`
+    DPP           int64 `json:"dpp" db:"dpp"`
+    PPN           int64 `json:"ppn" db:"ppn"`
     BarangDetail  *Barang `json:"barang_detail,omitempty" db:"-"`
 }
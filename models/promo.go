@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Promo jenis values.
+const (
+    PromoBeliGratis     = "beli_x_gratis_y"    // buy BeliQty of BarangID, GratisQty scanned units of GratisBarangID are free
+    PromoHargaBundel    = "harga_bundel"       // every complete set of Items costs HargaBundel
+    PromoDiskonMinimal  = "diskon_min_belanja" // header discount when the cart total reaches MinTotal
+)
+
+// Promo represents a row in promo. Only the fields relevant to Jenis are used.
+// Lower Prioritas values are evaluated first.
+type Promo struct {
+    ID             int64       `json:"id" db:"id"`
+    Nama           string      `json:"nama" db:"nama"`
+    Jenis          string      `json:"jenis" db:"jenis"`
+    Aktif          bool        `json:"aktif" db:"aktif"`
+    Mulai          time.Time   `json:"mulai" db:"mulai"`
+    Selesai        *time.Time  `json:"selesai,omitempty" db:"selesai"`
+    Prioritas      int         `json:"prioritas" db:"prioritas"`
+    BarangID       *int64      `json:"barang_id,omitempty" db:"barang_id"`
+    BeliQty        int64       `json:"beli_qty,omitempty" db:"beli_qty"`
+    GratisQty      int64       `json:"gratis_qty,omitempty" db:"gratis_qty"`
+    GratisBarangID *int64      `json:"gratis_barang_id,omitempty" db:"gratis_barang_id"`
+    HargaBundel    int64       `json:"harga_bundel,omitempty" db:"harga_bundel"`
+    Items          []PromoItem `json:"items,omitempty" db:"-"`
+    MinTotal       int64       `json:"min_total,omitempty" db:"min_total"`
+    DiskonBP       int64       `json:"diskon_bp,omitempty" db:"diskon_bp"` // basis points, 1000 = 10%
+    DiskonNominal  int64       `json:"diskon_nominal,omitempty" db:"diskon_nominal"`
+    MaksDiskon     int64       `json:"maks_diskon,omitempty" db:"maks_diskon"`
+    CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+}
+
+// PromoItem is one component of a harga_bundel promo.
+type PromoItem struct {
+    BarangID int64 `json:"barang_id" db:"barang_id"`
+    Qty      int64 `json:"qty" db:"qty"`
+}
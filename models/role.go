@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions given to users.
+type Role struct {
+	Nama        string     `json:"nama" db:"nama"`
+	Deskripsi   *string    `json:"deskripsi,omitempty" db:"deskripsi"`
+	Permissions []string   `json:"permissions"`
+	Wajib2FA    bool       `json:"wajib_2fa" db:"wajib_2fa"` // users must enroll in two-factor authentication
+	JumlahUser  int64      `json:"jumlah_user"`
+	CreatedAt   *time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// Permission is a row of the permission table.
+type Permission struct {
+	Kode      string `json:"kode" db:"kode"`
+	Deskripsi string `json:"deskripsi" db:"deskripsi"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Tenant is a company whose data is kept apart from the other tenants.
+type Tenant struct {
+	ID        int64     `json:"id" db:"id"`
+	Kode      string    `json:"kode" db:"kode"`
+	Nama      string    `json:"nama" db:"nama"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// JumlahUser is the number of member users, only filled in listings.
+	JumlahUser int `json:"jumlah_user"`
+	// Role of the user in the tenant, in listings of a user's tenants.
+	Role string `json:"role,omitempty"`
+}
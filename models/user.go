@@ -1,13 +1,47 @@
 package models
 
+import "time"
+
 // User represents a row in the users table.
-// Password field intentionally has no json tag so it is omitted when marshaling to JSON.
+// Password holds the bcrypt hash and is never marshaled to JSON.
 type User struct {
 	ID       int64  `json:"id" db:"id"`
 	Username string `json:"username" db:"username"`
-	Password string `db:"password"`
+	Password string `json:"-" db:"password"`
 	Email    string `json:"email" db:"email"`
 	FullName string `json:"full_name" db:"full_name"`
 	Role     string `json:"role" db:"role"`
+	// Service users are accounts for integrations: they cannot log in and
+	// only act through API keys.
+	Service bool `json:"service,omitempty" db:"service"`
+	// Aktif and CreatedAt are only loaded by the user management endpoints;
+	// nil on users nested in transactions.
+	Aktif     *bool      `json:"aktif,omitempty" db:"aktif"`
+	CreatedAt *time.Time `json:"created_at,omitempty" db:"created_at"`
+	// Permissions of the role, only filled for /api/me.
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// StatusAuth is the state of a user and session checked on every
+// authenticated request. An unknown user is not Aktif.
+type StatusAuth struct {
+	Role        string
+	Permissions []string // of Role
+	Aktif       bool
+	SesiDicabut bool // the session (refresh token family) was revoked
+	Anggota     bool // member of the tenant the token or key is for
 }
 
+// Status2FA is the two-factor state of a user.
+type Status2FA struct {
+	Aktif            bool `json:"aktif"`              // enrolled and verified
+	Wajib            bool `json:"wajib"`              // required by the user's role
+	SisaRecoveryCode int  `json:"sisa_recovery_code"` // unused recovery codes
+}
+
+// Enrollment2FA is returned when TOTP enrollment starts. OtpauthURI is
+// shown as a QR code; Secret is for typing in by hand.
+type Enrollment2FA struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
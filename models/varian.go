@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// AtributVarian is one dimension of a BarangInduk's variants (e.g. "ukuran")
+// with its values in display order.
+type AtributVarian struct {
+    Nama  string   `json:"nama"`
+    Nilai []string `json:"nilai"`
+}
+
+// BarangInduk is a parent product whose variants are master_barang rows, one
+// per combination of Atribut values. HargaBeli and HargaJual are the default
+// prices of the variants. The stock fields and Varian are filled on reads.
+type BarangInduk struct {
+    ID         int64           `json:"id" db:"id"`
+    KodeInduk  string          `json:"kode_induk" db:"kode_induk"`
+    Nama       string          `json:"nama" db:"nama"`
+    Deskripsi  *string         `json:"deskripsi,omitempty" db:"deskripsi"`
+    Satuan     string          `json:"satuan" db:"satuan"`
+    HargaBeli  int64           `json:"harga_beli" db:"harga_beli"`
+    HargaJual  int64           `json:"harga_jual" db:"harga_jual"`
+    KategoriID *int64          `json:"kategori_id,omitempty" db:"kategori_id"`
+    Atribut    []AtributVarian `json:"atribut" db:"atribut"`
+    CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+
+    JumlahVarian int64        `json:"jumlah_varian"`
+    TotalStok    int64        `json:"total_stok"`
+    NilaiStok    int64        `json:"nilai_stok"` // stok_akhir * harga_beli over all variants
+    Varian       []VarianStok `json:"varian,omitempty"`
+}
+
+// VarianStok is a variant of a BarangInduk with its current stock.
+// HargaSendiri is true when the variant overrides the parent prices.
+type VarianStok struct {
+    BarangID     int64             `json:"barang_id"`
+    KodeBarang   string            `json:"kode_barang"`
+    NamaBarang   string            `json:"nama_barang"`
+    Varian       map[string]string `json:"varian"`
+    HargaBeli    int64             `json:"harga_beli"`
+    HargaJual    int64             `json:"harga_jual"`
+    HargaSendiri bool              `json:"harga_sendiri"`
+    StokAkhir    int64             `json:"stok_akhir"`
+    ArchivedAt   *time.Time        `json:"archived_at,omitempty"`
+}
+
+// HargaVarian overrides the parent prices for every generated variant whose
+// attributes include all pairs of Atribut, e.g. {"ukuran": "XL"}. Nil prices
+// keep the parent's.
+type HargaVarian struct {
+    Atribut   map[string]string `json:"atribut"`
+    HargaBeli *int64            `json:"harga_beli,omitempty"`
+    HargaJual *int64            `json:"harga_jual,omitempty"`
+}
+
+// StokInduk is one row of the stock report rolled up to parent products.
+// Variants are summed under their parent (IndukID); barang without a parent
+// are rows of their own (BarangID).
+type StokInduk struct {
+    IndukID      *int64 `json:"induk_id,omitempty"`
+    BarangID     *int64 `json:"barang_id,omitempty"`
+    Kode         string `json:"kode"`
+    Nama         string `json:"nama"`
+    JumlahBarang int64  `json:"jumlah_barang"`
+    TotalStok    int64  `json:"total_stok"`
+    NilaiStok    int64  `json:"nilai_stok"`
+}
+
+// PenjualanInduk is one row of the sales report rolled up like StokInduk.
+type PenjualanInduk struct {
+    IndukID      *int64 `json:"induk_id,omitempty"`
+    BarangID     *int64 `json:"barang_id,omitempty"`
+    Kode         string `json:"kode"`
+    Nama         string `json:"nama"`
+    JumlahFaktur int64  `json:"jumlah_faktur"`
+    Qty          int64  `json:"qty"`
+    Subtotal     int64  `json:"subtotal"`
+    DPP          int64  `json:"dpp"`
+    PPN          int64  `json:"ppn"`
+}
@@ -0,0 +1,210 @@
+// Package pdf is a minimal PDF 1.4 writer: pages of filled rectangles, lines
+// and text in the standard Helvetica fonts (no embedding). It is enough for
+// labels and invoices without pulling in a third-party library.
+//
+// Coordinates are in points (1/72 inch) with the origin at the bottom-left.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Common page sizes in points.
+const (
+    A4Width  = 595.28
+    A4Height = 841.89
+)
+
+// Fonts available on every page.
+const (
+    Helvetica     = "F1"
+    HelveticaBold = "F2"
+)
+
+// Document collects pages and writes them with Write.
+type Document struct {
+    width, height float64
+    pages         []*Page
+    images        []jpegImage
+}
+
+type jpegImage struct {
+    data          []byte
+    width, height int
+    colorSpace    string
+}
+
+// New returns an empty document whose pages are width x height points.
+func New(width, height float64) *Document {
+    return &Document{width: width, height: height}
+}
+
+// Page is one page's content stream.
+type Page struct {
+    buf bytes.Buffer
+}
+
+// AddPage appends a blank page and returns it.
+func (d *Document) AddPage() *Page {
+    p := &Page{}
+    d.pages = append(d.pages, p)
+    return p
+}
+
+// AddJPEG registers a JPEG image and returns the name to pass to Page.Image.
+// The JPEG data is embedded as is (DCTDecode).
+func (d *Document) AddJPEG(data []byte) (string, error) {
+    cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+    if err != nil { return "", fmt.Errorf("pdf: %w", err) }
+    img := jpegImage{data: data, width: cfg.Width, height: cfg.Height, colorSpace: "DeviceRGB"}
+    switch cfg.ColorModel {
+    case color.GrayModel:
+        img.colorSpace = "DeviceGray"
+    case color.CMYKModel:
+        img.colorSpace = "DeviceCMYK"
+    }
+    d.images = append(d.images, img)
+    return fmt.Sprintf("Im%d", len(d.images)), nil
+}
+
+// Image draws a registered image scaled to w x h with its bottom-left corner at (x, y).
+func (p *Page) Image(name string, x, y, w, h float64) {
+    fmt.Fprintf(&p.buf, "q %s 0 0 %s %s %s cm /%s Do Q\n", num(w), num(h), num(x), num(y), name)
+}
+
+func num(f float64) string { return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64) }
+
+// SetGray sets the fill and stroke gray level (0 = black, 1 = white).
+func (p *Page) SetGray(g float64) {
+    fmt.Fprintf(&p.buf, "%s g %s G\n", num(g), num(g))
+}
+
+// Rect fills a rectangle.
+func (p *Page) Rect(x, y, w, h float64) {
+    fmt.Fprintf(&p.buf, "%s %s %s %s re f\n", num(x), num(y), num(w), num(h))
+}
+
+// StrokeRect outlines a rectangle with the given line width.
+func (p *Page) StrokeRect(x, y, w, h, width float64) {
+    fmt.Fprintf(&p.buf, "%s w %s %s %s %s re S\n", num(width), num(x), num(y), num(w), num(h))
+}
+
+// Line draws a straight line with the given width.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+    fmt.Fprintf(&p.buf, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+// Text draws s with its baseline starting at (x, y).
+func (p *Page) Text(font string, size, x, y float64, s string) {
+    fmt.Fprintf(&p.buf, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(y), escape(s))
+}
+
+// TextRight draws s so that it ends at x.
+func (p *Page) TextRight(font string, size, x, y float64, s string) {
+    p.Text(font, size, x-TextWidth(font, size, s), y, s)
+}
+
+// escape encodes s for a PDF literal string in WinAnsiEncoding. Runes
+// outside Latin-1 are replaced by '?'.
+func escape(s string) string {
+    var b bytes.Buffer
+    for _, r := range s {
+        switch {
+        case r == '(' || r == ')' || r == '\\':
+            b.WriteByte('\\')
+            b.WriteRune(r)
+        case r >= 32 && r < 127:
+            b.WriteRune(r)
+        case r >= 0xA0 && r <= 0xFF:
+            fmt.Fprintf(&b, "\\%03o", r)
+        default:
+            b.WriteByte('?')
+        }
+    }
+    return b.String()
+}
+
+// helveticaWidths are the AFM advance widths (1/1000 em) of Helvetica for
+// ASCII 32..126.
+var helveticaWidths = [95]int{
+    278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+    556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+    1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+    667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+    333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+    556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// TextWidth returns the width of s in points. Bold text is estimated from the
+// regular metrics; digits have the same width in both.
+func TextWidth(font string, size float64, s string) float64 {
+    total := 0
+    for _, r := range s {
+        w := 556
+        if r >= 32 && r < 127 {
+            w = helveticaWidths[r-32]
+        }
+        if font == HelveticaBold && (r < '0' || r > '9') {
+            w = w * 108 / 100
+        }
+        total += w
+    }
+    return float64(total) * size / 1000
+}
+
+// Write serializes the document.
+func (d *Document) Write(w io.Writer) error {
+    if len(d.pages) == 0 {
+        d.AddPage()
+    }
+    var out bytes.Buffer
+    offsets := make([]int, 0)
+    obj := func(body string) {
+        offsets = append(offsets, out.Len())
+        fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+    }
+
+    out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+    // Objects 1-4 are fixed, followed by one object per image; each page
+    // then takes two objects (page, contents).
+    first := 5 + len(d.images)
+    kids := ""
+    for i := range d.pages {
+        kids += fmt.Sprintf("%d 0 R ", first+2*i)
+    }
+    obj("<< /Type /Catalog /Pages 2 0 R >>")
+    obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(d.pages)))
+    obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+    obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+    xobjects := ""
+    for i, img := range d.images {
+        xobjects += fmt.Sprintf("/Im%d %d 0 R ", i+1, 5+i)
+        obj(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream",
+            img.width, img.height, img.colorSpace, len(img.data), img.data))
+    }
+    for i, p := range d.pages {
+        obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> /XObject << %s>> >> /Contents %d 0 R >>",
+            num(d.width), num(d.height), xobjects, first+1+2*i))
+        var z bytes.Buffer
+        zw := zlib.NewWriter(&z)
+        if _, err := zw.Write(p.buf.Bytes()); err != nil { return err }
+        if err := zw.Close(); err != nil { return err }
+        obj(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.String()))
+    }
+
+    xref := out.Len()
+    fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+    for _, off := range offsets {
+        fmt.Fprintf(&out, "%010d 00000 n \n", off)
+    }
+    fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+    _, err := w.Write(out.Bytes())
+    return err
+}
@@ -0,0 +1,78 @@
+// Package permission lists the permissions checked by the API. Roles map
+// to permissions in the role_permission table; the catalogue here is the
+// source of truth for which permissions exist and is synced into the
+// permission table at startup.
+package permission
+
+const (
+    UserManage      = "user.manage"
+    RoleManage      = "role.manage"
+    APIKeyManage    = "apikey.manage"
+    TenantManage    = "tenant.manage"
+    AuditView       = "audit.view"
+    BarangView      = "barang.view"
+    BarangWrite     = "barang.write"
+    BarangDelete    = "barang.delete"
+    BarangImport    = "barang.import"
+    BundelWrite     = "bundel.write"
+    KategoriWrite   = "kategori.write"
+    HargaWrite      = "harga.write"
+    PromoWrite      = "promo.write"
+    PajakWrite      = "pajak.write"
+    StokView        = "stok.view"
+    StokImport      = "stok.import"
+    RakitCreate     = "rakit.create"
+    PembelianView   = "pembelian.view"
+    PembelianCreate = "pembelian.create"
+    PenjualanView   = "penjualan.view"
+    PenjualanCreate = "penjualan.create"
+    LampiranDelete  = "lampiran.delete"
+    LaporanView     = "laporan.view"
+)
+
+// Admin is the built-in role that always holds every permission. It cannot
+// be edited or deleted, so it is always possible to manage roles.
+const Admin = "admin"
+
+// Def describes a permission. Staff marks permissions granted to the "user"
+// role when the permission is first created.
+type Def struct {
+    Kode      string
+    Deskripsi string
+    Staff     bool
+}
+
+// All is the permission catalogue.
+var All = []Def{
+    {UserManage, "Manage users, their sessions and login locks", false},
+    {RoleManage, "Manage roles and their permissions", false},
+    {APIKeyManage, "Create, list and revoke API keys", false},
+    {TenantManage, "Create tenants and set which of one's tenants users belong to", false},
+    {AuditView, "Read the audit log", false},
+    {BarangView, "Read barang, kategori, barcodes, prices, promos and tax rates", true},
+    {BarangWrite, "Create and update barang, variants, barcodes and images", true},
+    {BarangDelete, "Delete, archive and restore barang, variants and barcodes", false},
+    {BarangImport, "Import barang from CSV/XLSX", false},
+    {BundelWrite, "Define bundle components", false},
+    {KategoriWrite, "Create, update and delete kategori", false},
+    {HargaWrite, "Manage price lists, scheduled prices and customer groups", false},
+    {PromoWrite, "Create, update and delete promos", false},
+    {PajakWrite, "Add tax rates", false},
+    {StokView, "Read stock, stock history, opening balances and assembly orders", true},
+    {StokImport, "Import opening balances", false},
+    {RakitCreate, "Create assembly orders", true},
+    {PembelianView, "Read purchases and their attachments", true},
+    {PembelianCreate, "Record purchases and attach documents", true},
+    {PenjualanView, "Read sales and their attachments", true},
+    {PenjualanCreate, "Record sales and attach documents", true},
+    {LampiranDelete, "Delete attachments", false},
+    {LaporanView, "Read stock, sales, purchase and tax reports", true},
+}
+
+// Valid reports whether kode is in the catalogue.
+func Valid(kode string) bool {
+    for _, d := range All {
+        if d.Kode == kode { return true }
+    }
+    return false
+}
@@ -0,0 +1,54 @@
+package permission
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogue(t *testing.T) {
+    seen := map[string]bool{}
+    for _, d := range All {
+        if seen[d.Kode] {
+            t.Errorf("%s listed twice", d.Kode)
+        }
+        seen[d.Kode] = true
+        if d.Deskripsi == "" {
+            t.Errorf("%s has no description", d.Kode)
+        }
+        if parts := strings.Split(d.Kode, "."); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+            t.Errorf("%s is not of the form area.action", d.Kode)
+        }
+    }
+    if seen[Admin] {
+        t.Errorf("%q is a role, not a permission", Admin)
+    }
+    // Permissions that could lock everyone out or hand out access must
+    // never be granted to staff automatically.
+    for _, d := range All {
+        switch d.Kode {
+        case UserManage, RoleManage, APIKeyManage, TenantManage, AuditView:
+            if d.Staff { t.Errorf("%s must not be a staff permission", d.Kode) }
+        }
+    }
+}
+
+func TestValid(t *testing.T) {
+    tests := []struct {
+        kode string
+        want bool
+    }{
+        {BarangView, true},
+        {RoleManage, true},
+        {TenantManage, true},
+        {LaporanView, true},
+        {Admin, false},
+        {"", false},
+        {"barang.VIEW", false},
+        {"barang.fly", false},
+    }
+    for _, tt := range tests {
+        if got := Valid(tt.kode); got != tt.want {
+            t.Errorf("Valid(%q) = %v, want %v", tt.kode, got, tt.want)
+        }
+    }
+}
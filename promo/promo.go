@@ -0,0 +1,232 @@
+// Package promo evaluates promotion rules against a priced sales cart.
+//
+// The engine is pure: it receives lines whose Harga has already been resolved
+// and the promo rules active at the time of sale, and returns the lines with
+// line discounts and free lines applied plus the header discount. Persisting
+// the result is up to the caller (see repositories.PenjualanRepo).
+package promo
+
+import (
+	"sort"
+
+	"warehouse/models"
+)
+
+// Hasil is the outcome of applying promos to a cart.
+type Hasil struct {
+    Details []models.JualDetail
+    Diskon  int64  // header-level discount
+    PromoID *int64 // promo that gave the header discount
+}
+
+// Terapkan applies rules in Prioritas order. Each unit bought takes part in at
+// most one item-level promo (beli_x_gratis_y or harga_bundel); free units are
+// taken from the scanned lines into appended free lines and never trigger
+// further promos. Of the qualifying diskon_min_belanja rules, the one giving
+// the largest discount wins.
+func Terapkan(lines []models.JualDetail, rules []models.Promo) Hasil {
+    details := make([]models.JualDetail, len(lines))
+    copy(details, lines)
+    sisa := make([]int64, len(details))
+    for i := range details {
+        sisa[i] = details[i].Qty
+    }
+
+    sorted := make([]models.Promo, len(rules))
+    copy(sorted, rules)
+    sort.SliceStable(sorted, func(a, b int) bool {
+        if sorted[a].Prioritas != sorted[b].Prioritas {
+            return sorted[a].Prioritas < sorted[b].Prioritas
+        }
+        return sorted[a].ID < sorted[b].ID
+    })
+
+    var gratis []models.JualDetail
+    for i := range sorted {
+        p := &sorted[i]
+        switch p.Jenis {
+        case models.PromoBeliGratis:
+            if line, ok := beliGratis(details, sisa, p); ok {
+                gratis = append(gratis, line)
+            }
+        case models.PromoHargaBundel:
+            hargaBundel(details, sisa, p)
+        }
+    }
+
+    // Lines whose every unit became free are replaced by their free line.
+    dibayar := details[:0]
+    for _, d := range details {
+        if d.Qty == 0 { continue }
+        d.Subtotal = d.Qty*d.Harga - d.Diskon
+        dibayar = append(dibayar, d)
+    }
+    details = append(dibayar, gratis...)
+
+    var total int64
+    for _, d := range details {
+        total += d.Subtotal
+    }
+    res := Hasil{Details: details}
+    for i := range sorted {
+        p := &sorted[i]
+        if p.Jenis != models.PromoDiskonMinimal || total < p.MinTotal {
+            continue
+        }
+        d := p.DiskonNominal + (total*p.DiskonBP+5000)/10000
+        if p.MaksDiskon > 0 && d > p.MaksDiskon { d = p.MaksDiskon }
+        if d > total { d = total }
+        if d > res.Diskon {
+            id := p.ID
+            res.Diskon, res.PromoID = d, &id
+        }
+    }
+    return res
+}
+
+// beliGratis makes GratisQty of the scanned GratisBarangID units free for
+// every BeliQty units of the trigger barang. When both are the same barang a
+// set is BeliQty+GratisQty scanned units. The free units are moved out of
+// their lines into one free line with harga 0, so the quantity sold (and the
+// stock taken) stays what was scanned; unscanned free units are never added.
+func beliGratis(details []models.JualDetail, sisa []int64, p *models.Promo) (models.JualDetail, bool) {
+    if p.BarangID == nil || p.BeliQty <= 0 || p.GratisQty <= 0 {
+        return models.JualDetail{}, false
+    }
+    barangGratis := *p.BarangID
+    if p.GratisBarangID != nil { barangGratis = *p.GratisBarangID }
+
+    tersedia := func(barangID int64) int64 {
+        var n int64
+        for i, d := range details {
+            if d.BarangID == barangID { n += sisa[i] }
+        }
+        return n
+    }
+    var kali int64
+    if barangGratis == *p.BarangID {
+        kali = tersedia(barangGratis) / (p.BeliQty + p.GratisQty)
+    } else {
+        kali = min(tersedia(*p.BarangID)/p.BeliQty, tersedia(barangGratis)/p.GratisQty)
+    }
+    if kali == 0 {
+        return models.JualDetail{}, false
+    }
+
+    pakai := kali * p.BeliQty
+    for i := range details {
+        if pakai == 0 { break }
+        if details[i].BarangID != *p.BarangID || sisa[i] == 0 { continue }
+        n := min(sisa[i], pakai)
+        sisa[i] -= n
+        pakai -= n
+        if details[i].PromoID == nil { details[i].PromoID = promoID(p) }
+    }
+    gratis := kali * p.GratisQty
+    for i := range details {
+        if gratis == 0 { break }
+        if details[i].BarangID != barangGratis || sisa[i] == 0 { continue }
+        n := min(sisa[i], gratis)
+        sisa[i] -= n
+        details[i].Qty -= n
+        gratis -= n
+    }
+    return models.JualDetail{
+        BarangID: barangGratis,
+        Qty:      kali * p.GratisQty,
+        PromoID:  promoID(p),
+    }, true
+}
+
+// hargaBundel prices every complete set of the promo items at HargaBundel,
+// spreading the saving over the lines that make up the sets.
+func hargaBundel(details []models.JualDetail, sisa []int64, p *models.Promo) {
+    if len(p.Items) == 0 || p.HargaBundel < 0 {
+        return
+    }
+    set := int64(-1)
+    for _, it := range p.Items {
+        if it.Qty <= 0 { return }
+        var eligible int64
+        for i, d := range details {
+            if d.BarangID == it.BarangID { eligible += sisa[i] }
+        }
+        if n := eligible / it.Qty; set < 0 || n < set { set = n }
+    }
+    if set <= 0 {
+        return
+    }
+
+    pakai := make([]int64, len(details)) // units of each line consumed by the sets
+    nilai := make([]int64, len(details)) // normal value of those units
+    var normal int64
+    for _, it := range p.Items {
+        butuh := set * it.Qty
+        for i := range details {
+            if butuh == 0 { break }
+            if details[i].BarangID != it.BarangID { continue }
+            n := min(sisa[i]-pakai[i], butuh)
+            if n <= 0 { continue }
+            pakai[i] += n
+            nilai[i] += n * details[i].Harga
+            normal += n * details[i].Harga
+            butuh -= n
+        }
+    }
+    hemat := normal - set*p.HargaBundel
+    if hemat <= 0 {
+        return
+    }
+    alokasi := BagiDiskon(nilai, hemat)
+    for i := range details {
+        if pakai[i] == 0 { continue }
+        sisa[i] -= pakai[i]
+        details[i].Diskon += alokasi[i]
+        details[i].PromoID = promoID(p)
+    }
+}
+
+// BagiDiskon spreads diskon over amounts proportionally to their size. The
+// last non-zero amount absorbs the rounding remainder so the parts always sum
+// to diskon (capped at the sum of amounts).
+func BagiDiskon(amounts []int64, diskon int64) []int64 {
+    out := make([]int64, len(amounts))
+    var total int64
+    last := -1
+    for i, a := range amounts {
+        if a > 0 {
+            total += a
+            last = i
+        }
+    }
+    if total == 0 || diskon <= 0 {
+        return out
+    }
+    if diskon > total { diskon = total }
+    var dibagi int64
+    for i, a := range amounts {
+        if a <= 0 || i == last { continue }
+        out[i] = diskon * a / total
+        dibagi += out[i]
+    }
+    out[last] = diskon - dibagi
+    // A tiny last amount may not hold the whole remainder; move the excess
+    // to amounts that still have room.
+    if lebih := out[last] - amounts[last]; lebih > 0 {
+        out[last] = amounts[last]
+        for i, a := range amounts {
+            if lebih == 0 { break }
+            if ruang := a - out[i]; ruang > 0 {
+                n := min(ruang, lebih)
+                out[i] += n
+                lebih -= n
+            }
+        }
+    }
+    return out
+}
+
+func promoID(p *models.Promo) *int64 {
+    id := p.ID
+    return &id
+}
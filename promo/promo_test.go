@@ -0,0 +1,194 @@
+package promo
+
+import (
+	"reflect"
+	"testing"
+
+	"warehouse/models"
+)
+
+func i64(v int64) *int64 { return &v }
+
+func line(barangID, qty, harga int64) models.JualDetail {
+    return models.JualDetail{BarangID: barangID, Qty: qty, Harga: harga}
+}
+
+// ringkas drops the fields the engine does not set so expectations stay short.
+type ringkas struct {
+    BarangID, Qty, Harga, Diskon, Subtotal int64
+    PromoID                                int64
+}
+
+func ringkasan(details []models.JualDetail) []ringkas {
+    out := make([]ringkas, len(details))
+    for i, d := range details {
+        out[i] = ringkas{BarangID: d.BarangID, Qty: d.Qty, Harga: d.Harga, Diskon: d.Diskon, Subtotal: d.Subtotal}
+        if d.PromoID != nil { out[i].PromoID = *d.PromoID }
+    }
+    return out
+}
+
+func TestTerapkan(t *testing.T) {
+    beli2gratis1 := models.Promo{ID: 1, Jenis: models.PromoBeliGratis, BarangID: i64(10), BeliQty: 2, GratisQty: 1}
+    tests := []struct {
+        name      string
+        lines     []models.JualDetail
+        rules     []models.Promo
+        want      []ringkas
+        diskon    int64
+        promoID   *int64
+    }{
+        {
+            name:  "no promo",
+            lines: []models.JualDetail{line(10, 2, 1000)},
+            want:  []ringkas{{BarangID: 10, Qty: 2, Harga: 1000, Subtotal: 2000}},
+        },
+        {
+            name:  "beli 2 gratis 1 frees one of three scanned units",
+            lines: []models.JualDetail{line(10, 3, 1000)},
+            rules: []models.Promo{beli2gratis1},
+            want: []ringkas{
+                {BarangID: 10, Qty: 2, Harga: 1000, Subtotal: 2000, PromoID: 1},
+                {BarangID: 10, Qty: 1, PromoID: 1},
+            },
+        },
+        {
+            name:  "beli 2 gratis 1 needs the free unit scanned",
+            lines: []models.JualDetail{line(10, 2, 1000)},
+            rules: []models.Promo{beli2gratis1},
+            want:  []ringkas{{BarangID: 10, Qty: 2, Harga: 1000, Subtotal: 2000}},
+        },
+        {
+            name:  "beli 2 gratis 1 over several lines",
+            lines: []models.JualDetail{line(10, 4, 1000), line(10, 3, 1000)},
+            rules: []models.Promo{beli2gratis1},
+            want: []ringkas{
+                {BarangID: 10, Qty: 4, Harga: 1000, Subtotal: 4000, PromoID: 1},
+                {BarangID: 10, Qty: 1, Harga: 1000, Subtotal: 1000},
+                {BarangID: 10, Qty: 2, PromoID: 1},
+            },
+        },
+        {
+            name:  "free line replaces a fully free line",
+            lines: []models.JualDetail{line(20, 2, 500), line(10, 1, 3000)},
+            rules: []models.Promo{{ID: 2, Jenis: models.PromoBeliGratis, BarangID: i64(20), BeliQty: 2, GratisQty: 1, GratisBarangID: i64(10)}},
+            want: []ringkas{
+                {BarangID: 20, Qty: 2, Harga: 500, Subtotal: 1000, PromoID: 2},
+                {BarangID: 10, Qty: 1, PromoID: 2},
+            },
+        },
+        {
+            name:  "other free barang limited by what was scanned",
+            lines: []models.JualDetail{line(20, 6, 500), line(10, 2, 3000)},
+            rules: []models.Promo{{ID: 2, Jenis: models.PromoBeliGratis, BarangID: i64(20), BeliQty: 2, GratisQty: 1, GratisBarangID: i64(10)}},
+            want: []ringkas{
+                {BarangID: 20, Qty: 6, Harga: 500, Subtotal: 3000, PromoID: 2},
+                {BarangID: 10, Qty: 2, PromoID: 2},
+            },
+        },
+        {
+            name:  "bundle spreads the saving",
+            lines: []models.JualDetail{line(1, 1, 6000), line(2, 1, 4000)},
+            rules: []models.Promo{{ID: 3, Jenis: models.PromoHargaBundel, HargaBundel: 8000,
+                Items: []models.PromoItem{{BarangID: 1, Qty: 1}, {BarangID: 2, Qty: 1}}}},
+            want: []ringkas{
+                {BarangID: 1, Qty: 1, Harga: 6000, Diskon: 1200, Subtotal: 4800, PromoID: 3},
+                {BarangID: 2, Qty: 1, Harga: 4000, Diskon: 800, Subtotal: 3200, PromoID: 3},
+            },
+        },
+        {
+            name:  "bundle dearer than the items is ignored",
+            lines: []models.JualDetail{line(1, 1, 6000), line(2, 1, 4000)},
+            rules: []models.Promo{{ID: 3, Jenis: models.PromoHargaBundel, HargaBundel: 12000,
+                Items: []models.PromoItem{{BarangID: 1, Qty: 1}, {BarangID: 2, Qty: 1}}}},
+            want: []ringkas{
+                {BarangID: 1, Qty: 1, Harga: 6000, Subtotal: 6000},
+                {BarangID: 2, Qty: 1, Harga: 4000, Subtotal: 4000},
+            },
+        },
+        {
+            name:  "units join one item promo in prioritas order",
+            lines: []models.JualDetail{line(10, 3, 1000), line(2, 1, 4000)},
+            rules: []models.Promo{
+                {ID: 1, Prioritas: 2, Jenis: models.PromoBeliGratis, BarangID: i64(10), BeliQty: 2, GratisQty: 1},
+                {ID: 3, Prioritas: 1, Jenis: models.PromoHargaBundel, HargaBundel: 4000,
+                    Items: []models.PromoItem{{BarangID: 10, Qty: 1}, {BarangID: 2, Qty: 1}}},
+            },
+            want: []ringkas{
+                {BarangID: 10, Qty: 3, Harga: 1000, Diskon: 200, Subtotal: 2800, PromoID: 3},
+                {BarangID: 2, Qty: 1, Harga: 4000, Diskon: 800, Subtotal: 3200, PromoID: 3},
+            },
+        },
+        {
+            name:  "min belanja below threshold",
+            lines: []models.JualDetail{line(1, 1, 9000)},
+            rules: []models.Promo{{ID: 4, Jenis: models.PromoDiskonMinimal, MinTotal: 10000, DiskonNominal: 1000}},
+            want:  []ringkas{{BarangID: 1, Qty: 1, Harga: 9000, Subtotal: 9000}},
+        },
+        {
+            name:  "best min belanja wins and is capped",
+            lines: []models.JualDetail{line(1, 2, 50000)},
+            rules: []models.Promo{
+                {ID: 4, Jenis: models.PromoDiskonMinimal, MinTotal: 10000, DiskonNominal: 5000},
+                {ID: 5, Jenis: models.PromoDiskonMinimal, MinTotal: 50000, DiskonBP: 1000, MaksDiskon: 8000},
+            },
+            want:    []ringkas{{BarangID: 1, Qty: 2, Harga: 50000, Subtotal: 100000}},
+            diskon:  8000,
+            promoID: i64(5),
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := Terapkan(tt.lines, tt.rules)
+            if g := ringkasan(got.Details); !reflect.DeepEqual(g, tt.want) {
+                t.Errorf("details = %+v, want %+v", g, tt.want)
+            }
+            if got.Diskon != tt.diskon {
+                t.Errorf("diskon = %d, want %d", got.Diskon, tt.diskon)
+            }
+            if !reflect.DeepEqual(got.PromoID, tt.promoID) {
+                t.Errorf("promo id = %v, want %v", got.PromoID, tt.promoID)
+            }
+        })
+    }
+}
+
+func TestTerapkanKeepsScannedQty(t *testing.T) {
+    lines := []models.JualDetail{line(10, 7, 1000), line(20, 1, 500)}
+    rules := []models.Promo{{ID: 1, Jenis: models.PromoBeliGratis, BarangID: i64(10), BeliQty: 2, GratisQty: 1}}
+    qty := make(map[int64]int64)
+    for _, d := range Terapkan(lines, rules).Details {
+        qty[d.BarangID] += d.Qty
+    }
+    if qty[10] != 7 || qty[20] != 1 {
+        t.Errorf("qty per barang = %v, want 10:7 20:1", qty)
+    }
+    if lines[0].Qty != 7 {
+        t.Errorf("input line modified: qty %d", lines[0].Qty)
+    }
+}
+
+func TestBagiDiskon(t *testing.T) {
+    tests := []struct {
+        name    string
+        amounts []int64
+        diskon  int64
+        want    []int64
+    }{
+        {"proportional", []int64{6000, 4000}, 1000, []int64{600, 400}},
+        {"remainder on last", []int64{100, 100, 100}, 100, []int64{33, 33, 34}},
+        {"zero amounts skipped", []int64{0, 300, 0}, 100, []int64{0, 100, 0}},
+        {"capped at total", []int64{100, 200}, 500, []int64{100, 200}},
+        {"tiny last amount", []int64{3, 3, 1}, 6, []int64{3, 2, 1}},
+        {"no discount", []int64{100}, 0, []int64{0}},
+        {"empty", nil, 100, []int64{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := BagiDiskon(tt.amounts, tt.diskon)
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("BagiDiskon(%v, %d) = %v, want %v", tt.amounts, tt.diskon, got, tt.want)
+            }
+        })
+    }
+}
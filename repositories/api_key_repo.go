@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"warehouse/apperr"
+	"warehouse/models"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyRepo stores API keys for machine-to-machine clients. Each key
+// belongs to a tenant; api_key is not under row-level security because
+// keys are looked up before the tenant is known, so the queries made for
+// a tenant filter on it themselves.
+type APIKeyRepo struct {
+    DB *sql.DB
+}
+
+func NewAPIKeyRepo(db *sql.DB) *APIKeyRepo { return &APIKeyRepo{DB: db} }
+
+const apiKeySelect = `SELECT k.id, k.nama, k.prefix, k.user_id, u.username, k.scopes, k.allowed_ips,
+        k.expires_at, k.last_used_at, k.last_used_ip, k.revoked_at, k.created_by, k.created_at
+    FROM api_key k JOIN users u ON u.id = k.user_id`
+
+func scanAPIKey(sc interface{ Scan(...any) error }) (models.APIKey, error) {
+    var k models.APIKey
+    var exp, used, revoked sql.NullTime
+    var ip sql.NullString
+    var by sql.NullInt64
+    err := sc.Scan(&k.ID, &k.Nama, &k.Prefix, &k.UserID, &k.Username, pq.Array(&k.Scopes), pq.Array(&k.AllowedIPs),
+        &exp, &used, &ip, &revoked, &by, &k.CreatedAt)
+    if exp.Valid { t := exp.Time; k.ExpiresAt = &t }
+    if used.Valid { t := used.Time; k.LastUsedAt = &t }
+    if revoked.Valid { t := revoked.Time; k.RevokedAt = &t }
+    if ip.Valid { v := ip.String; k.LastUsedIP = &v }
+    k.CreatedBy = nullInt64Ptr(by)
+    if k.AllowedIPs == nil { k.AllowedIPs = []string{} }
+    return k, err
+}
+
+// List returns the keys of the tenant of ctx, newest first, optionally only
+// those of userID and only usable (aktif=true: not revoked or expired) or
+// unusable ones.
+func (r *APIKeyRepo) List(ctx context.Context, userID int64, aktif *bool) ([]models.APIKey, error) {
+    where := []string{"k.tenant_id = app_tenant()"}
+    args := []interface{}{}
+    if userID > 0 {
+        args = append(args, userID)
+        where = append(where, fmt.Sprintf("k.user_id = $%d", len(args)))
+    }
+    if aktif != nil {
+        cond := "(k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW()))"
+        if !*aktif { cond = "NOT " + cond }
+        where = append(where, cond)
+    }
+    q := apiKeySelect + " WHERE " + strings.Join(where, " AND ")
+    rows, err := r.DB.QueryContext(ctx, q+" ORDER BY k.id DESC", args...)
+    if err != nil { return nil, fmt.Errorf("query api_key: %w", err) }
+    defer rows.Close()
+    list := make([]models.APIKey, 0)
+    for rows.Next() {
+        k, err := scanAPIKey(rows)
+        if err != nil { return nil, fmt.Errorf("scan api_key: %w", err) }
+        list = append(list, k)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+// GetByID returns key id of the tenant of ctx, or nil when it does not exist.
+func (r *APIKeyRepo) GetByID(ctx context.Context, id int64) (*models.APIKey, error) {
+    k, err := scanAPIKey(r.DB.QueryRowContext(ctx, apiKeySelect+" WHERE k.id = $1 AND k.tenant_id = app_tenant()", id))
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+    return &k, nil
+}
+
+// Create stores a key of the tenant of ctx with the given hash. The user
+// must be an active member of the tenant and either a service user or the
+// creator, and every scope must be a permission of the user's role.
+func (r *APIKeyRepo) Create(ctx context.Context, k *models.APIKey, hash string) error {
+    var aktif, service bool
+    var perms []string
+    err := r.DB.QueryRowContext(ctx, `SELECT u.aktif, u.service, ARRAY(SELECT permission FROM role_permission WHERE role = ut.role)
+        FROM users u JOIN user_tenant ut ON ut.user_id = u.id AND ut.tenant_id = app_tenant()
+        WHERE u.id = $1`, k.UserID).Scan(&aktif, &service, pq.Array(&perms))
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("%w: user id %d not found in this tenant", apperr.ErrValidation, k.UserID)
+    }
+    if err != nil { return err }
+    if !aktif {
+        return fmt.Errorf("%w: user id %d is deactivated", apperr.ErrValidation, k.UserID)
+    }
+    if !service && (k.CreatedBy == nil || *k.CreatedBy != k.UserID) {
+        return fmt.Errorf("%w: user id %d is not a service user; keys are for service users or for yourself", apperr.ErrValidation, k.UserID)
+    }
+    punya := make(map[string]bool, len(perms))
+    for _, p := range perms { punya[p] = true }
+    for _, s := range k.Scopes {
+        if !punya[s] {
+            return fmt.Errorf("%w: the user's role does not have permission %s", apperr.ErrValidation, s)
+        }
+    }
+    const q = `INSERT INTO api_key (nama, prefix, key_hash, user_id, scopes, allowed_ips, expires_at, created_by)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id, created_at`
+    return r.DB.QueryRowContext(ctx, q, k.Nama, k.Prefix, hash, k.UserID, pq.Array(k.Scopes), pq.Array(k.AllowedIPs),
+        k.ExpiresAt, k.CreatedBy).Scan(&k.ID, &k.CreatedAt)
+}
+
+// Revoke makes key id of the tenant of ctx unusable. Revoking a revoked key
+// keeps the first revocation time.
+func (r *APIKeyRepo) Revoke(ctx context.Context, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `UPDATE api_key SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id=$1 AND tenant_id = app_tenant()`, id)
+    if err != nil { return err }
+    if n, _ := res.RowsAffected(); n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+// AuthAPIKey returns the key with the given prefix, its tenant and the
+// state of its user for the auth middleware, or nil when there is none.
+func (r *APIKeyRepo) AuthAPIKey(ctx context.Context, prefix string) (*models.AuthAPIKey, error) {
+    const q = `SELECT k.id, k.tenant_id, k.key_hash, k.user_id, k.scopes, k.allowed_ips, k.expires_at, k.revoked_at,
+            COALESCE(ut.role, ''), ARRAY(SELECT permission FROM role_permission WHERE role = ut.role ORDER BY permission), u.aktif,
+            ut.user_id IS NOT NULL
+        FROM api_key k JOIN users u ON u.id = k.user_id
+        LEFT JOIN user_tenant ut ON ut.user_id = u.id AND ut.tenant_id = k.tenant_id
+        WHERE k.prefix = $1`
+    var k models.AuthAPIKey
+    var exp, revoked sql.NullTime
+    err := r.DB.QueryRowContext(ctx, q, prefix).Scan(&k.ID, &k.TenantID, &k.Hash, &k.UserID, pq.Array(&k.Scopes), pq.Array(&k.AllowedIPs),
+        &exp, &revoked, &k.User.Role, pq.Array(&k.User.Permissions), &k.User.Aktif, &k.User.Anggota)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+    if exp.Valid { t := exp.Time; k.ExpiresAt = &t }
+    if revoked.Valid { t := revoked.Time; k.RevokedAt = &t }
+    return &k, nil
+}
+
+// TouchAPIKey records that key id was used from ip. To spare a write per
+// request the timestamp is only moved once a minute.
+func (r *APIKeyRepo) TouchAPIKey(ctx context.Context, id int64, ip string) error {
+    _, err := r.DB.ExecContext(ctx, `UPDATE api_key SET last_used_at = NOW(), last_used_ip = $2
+        WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute' OR last_used_ip IS DISTINCT FROM $2)`, id, ip)
+    return err
+}
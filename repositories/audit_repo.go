@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"warehouse/models"
+)
+
+// AuditRepo writes and searches the append-only audit_log.
+type AuditRepo struct {
+    DB *sql.DB
+}
+
+func NewAuditRepo(db *sql.DB) *AuditRepo { return &AuditRepo{DB: db} }
+
+// Insert appends e to the audit log and sets its ID and CreatedAt.
+func (r *AuditRepo) Insert(ctx context.Context, e *models.AuditLog) error {
+    var sebelum, sesudah interface{}
+    if len(e.Sebelum) > 0 { sebelum = string(e.Sebelum) }
+    if len(e.Sesudah) > 0 { sesudah = string(e.Sesudah) }
+    err := r.DB.QueryRowContext(ctx, `INSERT INTO audit_log
+            (user_id, aksi, entitas, entitas_id, sebelum, sesudah, method, path, status, ip)
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+            RETURNING id, created_at`,
+        e.UserID, e.Aksi, e.Entitas, e.EntitasID, sebelum, sesudah, e.Method, e.Path, e.Status, e.IP,
+    ).Scan(&e.ID, &e.CreatedAt)
+    if err != nil { return fmt.Errorf("insert audit_log: %w", err) }
+    return nil
+}
+
+// List returns audit entries matching f, newest first, with the username of
+// the actor when the user still exists.
+func (r *AuditRepo) List(ctx context.Context, f models.AuditFilter, page, limit int) ([]models.AuditLog, int, error) {
+    if page < 1 { page = 1 }
+    if limit < 1 { limit = 10 }
+    offset := (page - 1) * limit
+
+    where := make([]string, 0)
+    args := make([]interface{}, 0)
+    add := func(cond string, v interface{}) {
+        args = append(args, v)
+        where = append(where, fmt.Sprintf(cond, len(args)))
+    }
+    if f.Entitas != "" { add("a.entitas = $%d", f.Entitas) }
+    if f.EntitasID > 0 { add("a.entitas_id = $%d", f.EntitasID) }
+    if f.UserID > 0 { add("a.user_id = $%d", f.UserID) }
+    if f.Aksi != "" { add("a.aksi = $%d", f.Aksi) }
+    if f.From != nil { add("a.created_at >= $%d", *f.From) }
+    if f.To != nil { add("a.created_at <= $%d", *f.To) }
+    whereSQL := ""
+    if len(where) > 0 { whereSQL = " WHERE " + strings.Join(where, " AND ") }
+
+    var total int
+    if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log a`+whereSQL, args...).Scan(&total); err != nil {
+        return nil, 0, err
+    }
+    q := fmt.Sprintf(`SELECT a.id, a.user_id, COALESCE(u.username, ''), a.aksi, a.entitas, a.entitas_id, a.sebelum, a.sesudah,
+            a.method, a.path, a.status, a.ip, a.created_at
+        FROM audit_log a
+        LEFT JOIN users u ON u.id = a.user_id%s
+        ORDER BY a.created_at DESC, a.id DESC
+        LIMIT $%d OFFSET $%d`, whereSQL, len(args)+1, len(args)+2)
+    rows, err := r.DB.QueryContext(ctx, q, append(args, limit, offset)...)
+    if err != nil { return nil, 0, err }
+    defer rows.Close()
+
+    list := make([]models.AuditLog, 0)
+    for rows.Next() {
+        var e models.AuditLog
+        var uid, entitasID sql.NullInt64
+        var sebelum, sesudah []byte
+        if err := rows.Scan(&e.ID, &uid, &e.Username, &e.Aksi, &e.Entitas, &entitasID, &sebelum, &sesudah,
+            &e.Method, &e.Path, &e.Status, &e.IP, &e.CreatedAt); err != nil {
+            return nil, 0, err
+        }
+        e.UserID, e.EntitasID = nullInt64Ptr(uid), nullInt64Ptr(entitasID)
+        e.Sebelum, e.Sesudah = sebelum, sesudah
+        list = append(list, e)
+    }
+    if err := rows.Err(); err != nil { return nil, 0, err }
+    return list, total, nil
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"warehouse/apperr"
+	"warehouse/models"
+
+	"github.com/lib/pq"
+)
+
+// Import upserts rows by kode_barang in one transaction: existing barang are
+// updated (with harga_history attributed to userID), unknown or empty codes
+// are inserted, empty codes getting the next BRG-xxxx. Each row runs in its
+// own savepoint so every failing row is reported. With bestEffort the good
+// rows are committed; otherwise any failure rolls the whole import back.
+// progress, when not nil, is called with the number of rows processed.
+//
+// Only the rows touched are locked, so sales and edits of other barang go on
+// during a long import. Imports that generate codes take an advisory lock
+// when the first code is needed, so two of them never hand out the same one.
+func (r *BarangRepo) Import(ctx context.Context, rows []models.BarangImport, bestEffort bool, userID int64, progress func(done int)) (*models.ImportReport, error) {
+    rep := &models.ImportReport{Total: len(rows), Errors: make([]models.ImportError, 0)}
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return nil, fmt.Errorf("begin tx: %w", err) }
+    defer func() { _ = tx.Rollback() }()
+
+    kode := &kodeImport{tx: tx}
+    for i := range rows {
+        if err := ctx.Err(); err != nil { return nil, err }
+        row := &rows[i]
+        if _, err := tx.ExecContext(ctx, `SAVEPOINT import_row`); err != nil {
+            return nil, fmt.Errorf("savepoint: %w", err)
+        }
+        var created bool
+        if row.Barang.KodeBarang == "" {
+            err = kode.insert(ctx, &row.Barang)
+            created = true
+        } else {
+            created, err = upsertBarang(ctx, tx, &row.Barang, userID)
+        }
+        if err != nil {
+            if !errors.Is(err, apperr.ErrValidation) {
+                return nil, fmt.Errorf("row %d: %w", row.Baris, err)
+            }
+            if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`); err != nil {
+                return nil, fmt.Errorf("rollback savepoint: %w", err)
+            }
+            rep.Gagal++
+            rep.Errors = append(rep.Errors, models.ImportError{Baris: row.Baris, KodeBarang: row.Barang.KodeBarang, Pesan: err.Error()})
+        } else {
+            if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT import_row`); err != nil {
+                return nil, fmt.Errorf("release savepoint: %w", err)
+            }
+            if created { rep.Dibuat++ } else { rep.Diperbarui++ }
+        }
+        if progress != nil { progress(i + 1) }
+    }
+
+    if rep.Gagal > 0 && !bestEffort {
+        rep.Dibuat, rep.Diperbarui = 0, 0
+        return rep, nil
+    }
+    if err := tx.Commit(); err != nil { return nil, fmt.Errorf("commit tx: %w", err) }
+    rep.Disimpan = true
+    return rep, nil
+}
+
+// kodeImport hands out BRG-xxxx codes inside an import transaction.
+type kodeImport struct {
+    tx   *sql.Tx
+    next int // 0 until the advisory lock is held
+}
+
+// insert creates b under the next free generated code and sets b.KodeBarang.
+// The advisory lock is per tenant and held until the import ends; a code
+// taken meanwhile by POST /api/barang is skipped.
+func (k *kodeImport) insert(ctx context.Context, b *models.Barang) error {
+    if k.next == 0 {
+        if _, err := k.tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('kode_barang'), COALESCE(app_tenant(), 0)::int)`); err != nil {
+            return fmt.Errorf("lock kode_barang: %w", err)
+        }
+        if err := k.tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(CAST(SUBSTRING(kode_barang FROM '[0-9]+') AS INTEGER)), 0) + 1
+            FROM master_barang WHERE kode_barang LIKE 'BRG-%'`).Scan(&k.next); err != nil {
+            return fmt.Errorf("max kode_barang: %w", err)
+        }
+    }
+    for {
+        b.KodeBarang = fmt.Sprintf("BRG-%04d", k.next)
+        ok, err := insertBarang(ctx, k.tx, b)
+        if err != nil { return err }
+        k.next++
+        if ok { return nil }
+    }
+}
+
+// upsertBarang updates the barang with b.KodeBarang or inserts it, reporting
+// whether it was inserted. Empty deskripsi and kategori_id keep the stored
+// values. Data errors are returned as apperr values.
+func upsertBarang(ctx context.Context, tx *sql.Tx, b *models.Barang, userID int64) (bool, error) {
+    for {
+        var beliLama, jualLama int64
+        err := tx.QueryRowContext(ctx, `SELECT id, harga_beli, harga_jual FROM master_barang WHERE kode_barang=$1 FOR UPDATE`, b.KodeBarang).
+            Scan(&b.ID, &beliLama, &jualLama)
+        if err == sql.ErrNoRows {
+            // Another transaction may insert the same kode first; then update its row.
+            ok, err := insertBarang(ctx, tx, b)
+            if err != nil || ok { return ok, err }
+            continue
+        }
+        if err != nil { return false, err }
+        if _, err := tx.ExecContext(ctx, `UPDATE master_barang
+            SET nama_barang=$1, deskripsi=COALESCE($2, deskripsi), satuan=$3, harga_beli=$4, harga_jual=$5,
+                kena_pajak=COALESCE($6, kena_pajak), harga_termasuk_pajak=COALESCE($7, harga_termasuk_pajak),
+                kategori_id=COALESCE($8, kategori_id), version=version+1
+            WHERE id=$9`,
+            b.NamaBarang, b.Deskripsi, b.Satuan, b.HargaBeli, b.HargaJual, b.KenaPajak, b.HargaTermasukPajak, b.KategoriID, b.ID); err != nil {
+            return false, importError(err)
+        }
+        return false, catatPerubahanHarga(ctx, tx, b.ID, beliLama, b.HargaBeli, jualLama, b.HargaJual, userID, "import barang")
+    }
+}
+
+// insertBarang inserts b unless its kode_barang is already taken, reporting
+// whether it did.
+func insertBarang(ctx context.Context, tx *sql.Tx, b *models.Barang) (bool, error) {
+    err := tx.QueryRowContext(ctx, `INSERT INTO master_barang (kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual, kena_pajak, harga_termasuk_pajak, kategori_id)
+        VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, TRUE), COALESCE($8, FALSE), $9)
+        ON CONFLICT (tenant_id, kode_barang) DO NOTHING RETURNING id`,
+        b.KodeBarang, b.NamaBarang, b.Deskripsi, b.Satuan, b.HargaBeli, b.HargaJual, b.KenaPajak, b.HargaTermasukPajak, b.KategoriID).Scan(&b.ID)
+    if err == sql.ErrNoRows { return false, nil }
+    if err != nil { return false, importError(err) }
+    return true, nil
+}
+
+// importError maps constraint violations of a row to validation errors.
+func importError(err error) error {
+    if pqErr, ok := err.(*pq.Error); ok {
+        switch string(pqErr.Code) {
+        case "23503":
+            return fmt.Errorf("%w: kategori not found", apperr.ErrValidation)
+        case "23505":
+            return fmt.Errorf("%w: duplicate kode_barang", apperr.ErrValidation)
+        case "22001", "23514":
+            return fmt.Errorf("%w: %s", apperr.ErrValidation, pqErr.Message)
+        }
+    }
+    return err
+}
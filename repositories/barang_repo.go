@@ -3,10 +3,14 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
+	"warehouse/apperr"
 	"warehouse/models"
+	"warehouse/storage"
 
 	"github.com/lib/pq"
 )
@@ -20,126 +24,239 @@ func NewBarangRepo(db *sql.DB) *BarangRepo { return &BarangRepo{DB: db} }
 // ErrBarangInUse is returned when a barang cannot be deleted due to FK references
 var ErrBarangInUse = errors.New("barang in use")
 
-func (r *BarangRepo) GetAll(ctx context.Context, search string, page, limit int) ([]models.Barang, int, error) {
+// ArsipFilter selects barang by archive state in lists.
+type ArsipFilter int
+
+const (
+    TanpaArsip  ArsipFilter = iota // active barang only (the default)
+    HanyaArsip                     // archived barang only
+    DenganArsip                    // active and archived
+)
+
+// GetAll lists barang, optionally filtered by a search term on nama/kode and
+// by kategoriID (which includes all of its sub-categories).
+func (r *BarangRepo) GetAll(ctx context.Context, search string, kategoriID int64, arsip ArsipFilter, page, limit int) ([]models.Barang, int, error) {
     if page < 1 { page = 1 }
     if limit < 1 { limit = 10 }
     offset := (page - 1) * limit
 
-    var (
-        countQ string
-        listQ  string
-        rows   *sql.Rows
-        err    error
-        total  int
-    )
-
-    if search != "" {
-        countQ = `SELECT COUNT(*) FROM master_barang
-                  WHERE nama_barang ILIKE $1 OR kode_barang ILIKE $1`
-        listQ = `SELECT id, kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual
-                 FROM master_barang
-                 WHERE nama_barang ILIKE $1 OR kode_barang ILIKE $1
-                 ORDER BY id DESC
-                 LIMIT $2 OFFSET $3`
-        pattern := "%" + search + "%"
-        if err = r.DB.QueryRowContext(ctx, countQ, pattern).Scan(&total); err != nil {
-            return nil, 0, err
-        }
-        rows, err = r.DB.QueryContext(ctx, listQ, pattern, limit, offset)
-        if err != nil { return nil, 0, err }
-    } else {
-        countQ = `SELECT COUNT(*) FROM master_barang`
-        listQ = `SELECT id, kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual
-                 FROM master_barang
-                 ORDER BY id DESC
-                 LIMIT $1 OFFSET $2`
-
-        if err = r.DB.QueryRowContext(ctx, countQ).Scan(&total); err != nil {
-            return nil, 0, err
-        }
-        rows, err = r.DB.QueryContext(ctx, listQ, limit, offset)
-        if err != nil { return nil, 0, err }
+    whereSQL, args := barangFilter(search, kategoriID, arsip)
+    var total int
+    if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM master_barang`+whereSQL, args...).Scan(&total); err != nil {
+        return nil, 0, err
     }
+    listQ := fmt.Sprintf(`SELECT id, kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual, kategori_id, kena_pajak, harga_termasuk_pajak, archived_at, version, induk_id, varian,
+                 gambar_key, thumbnail_key
+                 FROM master_barang%s
+                 ORDER BY id DESC
+                 LIMIT $%d OFFSET $%d`, whereSQL, len(args)+1, len(args)+2)
+    rows, err := r.DB.QueryContext(ctx, listQ, append(args, limit, offset)...)
+    if err != nil { return nil, 0, err }
     defer rows.Close()
 
     items := make([]models.Barang, 0)
     for rows.Next() {
         var b models.Barang
         var ds sql.NullString
-        if err := rows.Scan(&b.ID, &b.KodeBarang, &b.NamaBarang, &ds, &b.Satuan, &b.HargaBeli, &b.HargaJual); err != nil {
+        var kat sql.NullInt64
+        var kena, termasuk bool
+        var arsip sql.NullTime
+        var induk sql.NullInt64
+        var varian []byte
+        var gambar, thumb sql.NullString
+        if err := rows.Scan(&b.ID, &b.KodeBarang, &b.NamaBarang, &ds, &b.Satuan, &b.HargaBeli, &b.HargaJual, &kat, &kena, &termasuk, &arsip, &b.Version, &induk, &varian, &gambar, &thumb); err != nil {
             return nil, 0, err
         }
+        b.KategoriID = nullInt64Ptr(kat)
+        b.IndukID, b.Varian = nullInt64Ptr(induk), decodeVarian(varian)
+        b.GambarURL, b.ThumbnailURL = fileURL(gambar), fileURL(thumb)
+        b.KenaPajak, b.HargaTermasukPajak = &kena, &termasuk
         if ds.Valid { v := ds.String; b.Deskripsi = &v }
+        if arsip.Valid { t := arsip.Time; b.ArchivedAt = &t }
         items = append(items, b)
     }
     if err := rows.Err(); err != nil { return nil, 0, err }
     return items, total, nil
 }
 
+// barangFilter builds the WHERE clause shared by the barang lists.
+func barangFilter(search string, kategoriID int64, arsip ArsipFilter) (string, []interface{}) {
+    where := make([]string, 0)
+    args := make([]interface{}, 0)
+    switch arsip {
+    case TanpaArsip:
+        where = append(where, "archived_at IS NULL")
+    case HanyaArsip:
+        where = append(where, "archived_at IS NOT NULL")
+    }
+    if search != "" {
+        args = append(args, "%"+search+"%")
+        where = append(where, fmt.Sprintf("(nama_barang ILIKE $%d OR kode_barang ILIKE $%d)", len(args), len(args)))
+    }
+    if kategoriID > 0 {
+        args = append(args, kategoriID)
+        where = append(where, "kategori_id IN ("+kategoriTurunan(fmt.Sprintf("$%d", len(args)))+")")
+    }
+    if len(where) == 0 { return "", args }
+    return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// EachBarang calls fn for every barang matching the GetAll filters, without
+// paging, reading rows from the database one at a time. Kategori is the
+// kategori name, empty when the barang has none.
+func (r *BarangRepo) EachBarang(ctx context.Context, search string, kategoriID int64, arsip ArsipFilter, fn func(b models.Barang, kategori string) error) error {
+    whereSQL, args := barangFilter(search, kategoriID, arsip)
+    q := `SELECT m.id, m.kode_barang, m.nama_barang, m.deskripsi, m.satuan, m.harga_beli, m.harga_jual, m.kategori_id, m.kena_pajak, m.harga_termasuk_pajak,
+            m.archived_at, m.version, m.induk_id, m.varian, m.gambar_key, m.thumbnail_key, COALESCE(k.nama, '')
+        FROM (SELECT * FROM master_barang` + whereSQL + `) m
+        LEFT JOIN kategori k ON k.id = m.kategori_id
+        ORDER BY m.kode_barang ASC`
+    rows, err := r.DB.QueryContext(ctx, q, args...)
+    if err != nil { return err }
+    defer rows.Close()
+    for rows.Next() {
+        var b models.Barang
+        var ds sql.NullString
+        var kat sql.NullInt64
+        var kena, termasuk bool
+        var kategori string
+        var arsip sql.NullTime
+        var induk sql.NullInt64
+        var varian []byte
+        var gambar, thumb sql.NullString
+        if err := rows.Scan(&b.ID, &b.KodeBarang, &b.NamaBarang, &ds, &b.Satuan, &b.HargaBeli, &b.HargaJual, &kat, &kena, &termasuk, &arsip, &b.Version, &induk, &varian, &gambar, &thumb, &kategori); err != nil {
+            return err
+        }
+        b.KategoriID = nullInt64Ptr(kat)
+        b.IndukID, b.Varian = nullInt64Ptr(induk), decodeVarian(varian)
+        b.GambarURL, b.ThumbnailURL = fileURL(gambar), fileURL(thumb)
+        b.KenaPajak, b.HargaTermasukPajak = &kena, &termasuk
+        if ds.Valid { v := ds.String; b.Deskripsi = &v }
+        if arsip.Valid { t := arsip.Time; b.ArchivedAt = &t }
+        if err := fn(b, kategori); err != nil { return err }
+    }
+    return rows.Err()
+}
+
 func (r *BarangRepo) GetByID(ctx context.Context, id int64) (*models.Barang, error) {
     const q = `
-        SELECT id, kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual
+        SELECT id, kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual, kategori_id, kena_pajak, harga_termasuk_pajak, archived_at, version,
+            induk_id, varian, gambar_key, thumbnail_key
         FROM master_barang WHERE id = $1`
 
     var (
-        b  models.Barang
-        ds sql.NullString
+        b              models.Barang
+        ds             sql.NullString
+        kat            sql.NullInt64
+        kena, termasuk bool
+        arsip          sql.NullTime
+        induk          sql.NullInt64
+        varian         []byte
+        gambar, thumb  sql.NullString
     )
     err := r.DB.QueryRowContext(ctx, q, id).
-        Scan(&b.ID, &b.KodeBarang, &b.NamaBarang, &ds, &b.Satuan, &b.HargaBeli, &b.HargaJual)
+        Scan(&b.ID, &b.KodeBarang, &b.NamaBarang, &ds, &b.Satuan, &b.HargaBeli, &b.HargaJual, &kat, &kena, &termasuk, &arsip, &b.Version, &induk, &varian, &gambar, &thumb)
     if err == sql.ErrNoRows {
         return nil, nil
     }
     if err != nil { return nil, err }
+    b.KategoriID = nullInt64Ptr(kat)
+    b.IndukID, b.Varian = nullInt64Ptr(induk), decodeVarian(varian)
+    b.GambarURL, b.ThumbnailURL = fileURL(gambar), fileURL(thumb)
+    b.KenaPajak, b.HargaTermasukPajak = &kena, &termasuk
     if ds.Valid { v := ds.String; b.Deskripsi = &v }
+    if arsip.Valid { t := arsip.Time; b.ArchivedAt = &t }
     return &b, nil
 }
 
 func (r *BarangRepo) Create(ctx context.Context, b *models.Barang) error {
     const q = `
-        INSERT INTO master_barang (kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual)
-        VALUES ($1, $2, $3, $4, $5, $6)
-        RETURNING id`
+        INSERT INTO master_barang (kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual, kena_pajak, harga_termasuk_pajak, kategori_id)
+        VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, TRUE), COALESCE($8, FALSE), $9)
+        RETURNING id, kena_pajak, harga_termasuk_pajak, version`
 
     var ds interface{}
     if b.Deskripsi == nil { ds = nil } else { ds = *b.Deskripsi }
 
-    return r.DB.QueryRowContext(ctx, q,
+    var kena, termasuk bool
+    if err := r.DB.QueryRowContext(ctx, q,
         b.KodeBarang,
         b.NamaBarang,
         ds,
         b.Satuan,
         b.HargaBeli,
         b.HargaJual,
-    ).Scan(&b.ID)
+        b.KenaPajak,
+        b.HargaTermasukPajak,
+        b.KategoriID,
+    ).Scan(&b.ID, &kena, &termasuk, &b.Version); err != nil {
+        return barangKategoriError(err)
+    }
+    b.KenaPajak, b.HargaTermasukPajak = &kena, &termasuk
+    return nil
 }
 
-func (r *BarangRepo) Update(ctx context.Context, b *models.Barang) error {
+// Update saves b and, when harga_beli or harga_jual changes, appends a
+// harga_history row attributed to userID in the same transaction. A variant
+// whose price is changed here stops following its barang induk's prices. When
+// b.Version is not 0 it must match the stored version, otherwise
+// apperr.ErrPrecondition is returned; on success b.Version is the new version.
+// A nil b.KategoriID keeps the stored kategori unless hapusKategori is set.
+func (r *BarangRepo) Update(ctx context.Context, b *models.Barang, userID int64, hapusKategori bool) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+
+    var beliLama, jualLama, version int64
+    if err := tx.QueryRowContext(ctx, `SELECT harga_beli, harga_jual, version FROM master_barang WHERE id=$1 FOR UPDATE`, b.ID).
+        Scan(&beliLama, &jualLama, &version); err != nil {
+        return rollback(err)
+    }
+    if b.Version != 0 && b.Version != version {
+        return rollback(versionError(b.ID, version))
+    }
+
     const q = `
         UPDATE master_barang
-        SET nama_barang=$1, deskripsi=$2, satuan=$3, harga_beli=$4, harga_jual=$5
-        WHERE id=$6`
+        SET nama_barang=$1, deskripsi=$2, satuan=$3, harga_beli=$4, harga_jual=$5,
+            kena_pajak=COALESCE($6, kena_pajak), harga_termasuk_pajak=COALESCE($7, harga_termasuk_pajak),
+            kategori_id=CASE WHEN $10 THEN NULL ELSE COALESCE($8, kategori_id) END, version=version+1,
+            harga_sendiri = harga_sendiri OR (induk_id IS NOT NULL AND (harga_beli <> $4 OR harga_jual <> $5))
+        WHERE id=$9
+        RETURNING version`
 
     var ds interface{}
     if b.Deskripsi == nil { ds = nil } else { ds = *b.Deskripsi }
 
-    res, err := r.DB.ExecContext(ctx, q,
+    if err := tx.QueryRowContext(ctx, q,
         b.NamaBarang,
         ds,
         b.Satuan,
         b.HargaBeli,
         b.HargaJual,
+        b.KenaPajak,
+        b.HargaTermasukPajak,
+        b.KategoriID,
         b.ID,
-    )
-    if err != nil { return err }
-    n, _ := res.RowsAffected()
-    if n == 0 { return sql.ErrNoRows }
+        hapusKategori,
+    ).Scan(&b.Version); err != nil {
+        return rollback(barangKategoriError(err))
+    }
+    if err := catatPerubahanHarga(ctx, tx, b.ID, beliLama, b.HargaBeli, jualLama, b.HargaJual, userID, "update barang"); err != nil {
+        return rollback(err)
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
     return nil
 }
 
-func (r *BarangRepo) Delete(ctx context.Context, id int64) error {
-    const q = `DELETE FROM master_barang WHERE id=$1`
-    res, err := r.DB.ExecContext(ctx, q, id)
+// Delete removes a barang. When version is not 0 it must match the stored
+// version, otherwise apperr.ErrPrecondition is returned.
+func (r *BarangRepo) Delete(ctx context.Context, id, version int64) error {
+    const q = `DELETE FROM master_barang WHERE id=$1 AND ($2 = 0 OR version = $2)`
+    res, err := r.DB.ExecContext(ctx, q, id, version)
     if err != nil {
         if pqErr, ok := err.(*pq.Error); ok {
             // 23503 = foreign_key_violation
@@ -150,10 +267,99 @@ func (r *BarangRepo) Delete(ctx context.Context, id int64) error {
         return err
     }
     n, _ := res.RowsAffected()
+    if n == 0 {
+        var current int64
+        if err := r.DB.QueryRowContext(ctx, `SELECT version FROM master_barang WHERE id=$1`, id).Scan(&current); err != nil {
+            return err
+        }
+        return versionError(id, current)
+    }
+    return nil
+}
+
+// versionError reports an If-Match version that is no longer current.
+func versionError(id, current int64) error {
+    return fmt.Errorf("%w: barang %d was modified, current version is %d", apperr.ErrPrecondition, id, current)
+}
+
+// Archive marks a barang as discontinued: it disappears from lists and can
+// no longer be bought or sold, while its stock and history stay intact.
+// Archiving an archived barang keeps the original archived_at.
+func (r *BarangRepo) Archive(ctx context.Context, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `UPDATE master_barang
+        SET archived_at = COALESCE(archived_at, NOW()), version = version + (archived_at IS NULL)::int WHERE id=$1`, id)
+    if err != nil { return err }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+// Restore makes an archived barang active again.
+func (r *BarangRepo) Restore(ctx context.Context, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `UPDATE master_barang
+        SET archived_at = NULL, version = version + (archived_at IS NOT NULL)::int WHERE id=$1`, id)
+    if err != nil { return err }
+    n, _ := res.RowsAffected()
     if n == 0 { return sql.ErrNoRows }
     return nil
 }
 
+// SetGambar stores the storage keys of a barang's image and thumbnail (nil
+// removes the image) and returns the keys it replaced, so the caller can
+// delete those files.
+func (r *BarangRepo) SetGambar(ctx context.Context, id int64, gambarKey, thumbnailKey *string) ([]string, error) {
+    const q = `UPDATE master_barang b
+        SET gambar_key=$2, thumbnail_key=$3, version=b.version+1
+        FROM (SELECT id, gambar_key, thumbnail_key FROM master_barang WHERE id=$1 FOR UPDATE) o
+        WHERE b.id = o.id
+        RETURNING o.gambar_key, o.thumbnail_key`
+    var gambar, thumb sql.NullString
+    if err := r.DB.QueryRowContext(ctx, q, id, gambarKey, thumbnailKey).Scan(&gambar, &thumb); err != nil {
+        return nil, err
+    }
+    return fileKeys(gambar, thumb), nil
+}
+
+// GambarKeys returns the storage keys of a barang's image files.
+func (r *BarangRepo) GambarKeys(ctx context.Context, id int64) ([]string, error) {
+    var gambar, thumb sql.NullString
+    err := r.DB.QueryRowContext(ctx, `SELECT gambar_key, thumbnail_key FROM master_barang WHERE id=$1`, id).Scan(&gambar, &thumb)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+    return fileKeys(gambar, thumb), nil
+}
+
+func fileKeys(keys ...sql.NullString) []string {
+    out := []string{}
+    for _, k := range keys {
+        if k.Valid { out = append(out, k.String) }
+    }
+    return out
+}
+
+// barangKategoriError maps an unknown kategori_id to a validation error.
+func barangKategoriError(err error) error {
+    if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23503" {
+        return fmt.Errorf("%w: kategori not found", apperr.ErrValidation)
+    }
+    return err
+}
+
+// decodeVarian decodes the varian column of a barang; NULL gives nil.
+func decodeVarian(raw []byte) map[string]string {
+    if len(raw) == 0 { return nil }
+    var v map[string]string
+    if err := json.Unmarshal(raw, &v); err != nil { return nil }
+    return v
+}
+
+// fileURL returns the public URL of a stored file; NULL gives nil.
+func fileURL(key sql.NullString) *string {
+    if !key.Valid { return nil }
+    u := storage.URL(key.String)
+    return &u
+}
+
 // GenerateKodeBarang generates a new kode_barang with format BRG-0001, BRG-0002, ...
 // It finds the highest numeric suffix among existing codes with prefix BRG- and increments it.
 func (r *BarangRepo) GenerateKodeBarang(ctx context.Context) (string, error) {
@@ -170,42 +376,76 @@ func (r *BarangRepo) GenerateKodeBarang(ctx context.Context) (string, error) {
     return fmt.Sprintf("BRG-%04d", next), nil
 }
 
-func (r *BarangRepo) GetAllWithStok(ctx context.Context) ([]models.BarangWithStok, error) {
-    const q = `SELECT b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual,
-        COALESCE(s.stok_akhir,0) AS stok_akhir
-        FROM master_barang b
+func (r *BarangRepo) GetAllWithStok(ctx context.Context, arsip ArsipFilter) ([]models.BarangWithStok, error) {
+    list := make([]models.BarangWithStok, 0)
+    err := r.EachWithStok(ctx, arsip, func(item models.BarangWithStok) error {
+        list = append(list, item)
+        return nil
+    })
+    if err != nil { return nil, err }
+    return list, nil
+}
+
+// EachWithStok calls fn for every barang with its current stock, newest
+// first, reading rows one at a time. Virtual bundles show the quantity
+// their components' stock allows.
+func (r *BarangRepo) EachWithStok(ctx context.Context, arsip ArsipFilter, fn func(models.BarangWithStok) error) error {
+    whereSQL, args := barangFilter("", 0, arsip)
+    q := `SELECT b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual,
+        b.kategori_id, b.kena_pajak, b.harga_termasuk_pajak, b.archived_at, b.version, b.induk_id, b.varian, b.gambar_key, b.thumbnail_key, ` + stokTersediaSQL + ` AS stok_akhir
+        FROM (SELECT * FROM master_barang` + whereSQL + `) b
         LEFT JOIN mstok s ON s.barang_id = b.id
         ORDER BY b.id DESC`
-    rows, err := r.DB.QueryContext(ctx, q)
-    if err != nil { return nil, err }
+    rows, err := r.DB.QueryContext(ctx, q, args...)
+    if err != nil { return err }
     defer rows.Close()
-    list := make([]models.BarangWithStok, 0)
     for rows.Next() {
         var ds sql.NullString
         var item models.BarangWithStok
-        if err := rows.Scan(&item.ID, &item.KodeBarang, &item.NamaBarang, &ds, &item.Satuan, &item.HargaBeli, &item.HargaJual, &item.StokAkhir); err != nil {
-            return nil, err
+        var kat sql.NullInt64
+        var kena, termasuk bool
+        var arsip sql.NullTime
+        var induk sql.NullInt64
+        var varian []byte
+        var gambar, thumb sql.NullString
+        if err := rows.Scan(&item.ID, &item.KodeBarang, &item.NamaBarang, &ds, &item.Satuan, &item.HargaBeli, &item.HargaJual, &kat, &kena, &termasuk, &arsip, &item.Version, &induk, &varian, &gambar, &thumb, &item.StokAkhir); err != nil {
+            return err
         }
+        item.KategoriID = nullInt64Ptr(kat)
+        item.IndukID, item.Varian = nullInt64Ptr(induk), decodeVarian(varian)
+        item.GambarURL, item.ThumbnailURL = fileURL(gambar), fileURL(thumb)
+        item.KenaPajak, item.HargaTermasukPajak = &kena, &termasuk
         if ds.Valid { v := ds.String; item.Deskripsi = &v }
-        list = append(list, item)
+        if arsip.Valid { t := arsip.Time; item.ArchivedAt = &t }
+        if err := fn(item); err != nil { return err }
     }
-    if err := rows.Err(); err != nil { return nil, err }
-    return list, nil
+    return rows.Err()
 }
 
 func (r *BarangRepo) GetWithStokByID(ctx context.Context, id int64) (*models.BarangWithStok, error) {
     const q = `SELECT b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual,
-        COALESCE(s.stok_akhir,0) AS stok_akhir
+        b.kategori_id, b.kena_pajak, b.harga_termasuk_pajak, b.archived_at, b.version, b.induk_id, b.varian, b.gambar_key, b.thumbnail_key, ` + stokTersediaSQL + ` AS stok_akhir
         FROM master_barang b
         LEFT JOIN mstok s ON s.barang_id = b.id
         WHERE b.id = $1`
     var ds sql.NullString
     var item models.BarangWithStok
-    err := r.DB.QueryRowContext(ctx, q, id).Scan(&item.ID, &item.KodeBarang, &item.NamaBarang, &ds, &item.Satuan, &item.HargaBeli, &item.HargaJual, &item.StokAkhir)
+    var kat sql.NullInt64
+    var kena, termasuk bool
+    var arsip sql.NullTime
+    var induk sql.NullInt64
+    var varian []byte
+    var gambar, thumb sql.NullString
+    err := r.DB.QueryRowContext(ctx, q, id).Scan(&item.ID, &item.KodeBarang, &item.NamaBarang, &ds, &item.Satuan, &item.HargaBeli, &item.HargaJual, &kat, &kena, &termasuk, &arsip, &item.Version, &induk, &varian, &gambar, &thumb, &item.StokAkhir)
     if err != nil {
         if err == sql.ErrNoRows { return nil, nil }
         return nil, err
     }
+    item.KategoriID = nullInt64Ptr(kat)
+    item.IndukID, item.Varian = nullInt64Ptr(induk), decodeVarian(varian)
+    item.GambarURL, item.ThumbnailURL = fileURL(gambar), fileURL(thumb)
+    item.KenaPajak, item.HargaTermasukPajak = &kena, &termasuk
     if ds.Valid { v := ds.String; item.Deskripsi = &v }
+    if arsip.Valid { t := arsip.Time; item.ArchivedAt = &t }
     return &item, nil
 }
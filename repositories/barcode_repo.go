@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"warehouse/apperr"
+	"warehouse/barcode"
+	"warehouse/models"
+
+	"github.com/lib/pq"
+)
+
+// BarcodeRepo manages the barcodes registered for each barang.
+type BarcodeRepo struct {
+    DB *sql.DB
+}
+
+func NewBarcodeRepo(db *sql.DB) *BarcodeRepo { return &BarcodeRepo{DB: db} }
+
+// ListByBarang returns the barcodes of a barang.
+func (r *BarcodeRepo) ListByBarang(ctx context.Context, barangID int64) ([]models.BarangBarcode, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT id, barang_id, barcode, satuan, isi FROM barang_barcode WHERE barang_id=$1 ORDER BY id ASC`, barangID)
+    if err != nil { return nil, fmt.Errorf("query barcode: %w", err) }
+    defer rows.Close()
+    list := make([]models.BarangBarcode, 0)
+    for rows.Next() {
+        var b models.BarangBarcode
+        var satuan sql.NullString
+        if err := rows.Scan(&b.ID, &b.BarangID, &b.Barcode, &satuan, &b.Isi); err != nil {
+            return nil, fmt.Errorf("scan barcode: %w", err)
+        }
+        if satuan.Valid { v := satuan.String; b.Satuan = &v }
+        list = append(list, b)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+// Create validates the check digit and registers b.Barcode for b.BarangID.
+// A barcode can belong to one barang only.
+func (r *BarcodeRepo) Create(ctx context.Context, b *models.BarangBarcode) error {
+    code, err := barcode.Normalize(b.Barcode)
+    if err != nil {
+        return fmt.Errorf("%w: %v", apperr.ErrValidation, err)
+    }
+    b.Barcode = code
+    if b.Isi == 0 { b.Isi = 1 }
+    if b.Isi < 0 {
+        return fmt.Errorf("%w: isi must be > 0", apperr.ErrValidation)
+    }
+    err = r.DB.QueryRowContext(ctx, `INSERT INTO barang_barcode (barang_id, barcode, satuan, isi) VALUES ($1,$2,$3,$4) RETURNING id`,
+        b.BarangID, b.Barcode, b.Satuan, b.Isi).Scan(&b.ID)
+    if pqErr, ok := err.(*pq.Error); ok {
+        switch string(pqErr.Code) {
+        case "23503":
+            return fmt.Errorf("%w: barang id %d not found", apperr.ErrNotFound, b.BarangID)
+        case "23505":
+            return fmt.Errorf("%w: barcode %s is already registered", apperr.ErrValidation, b.Barcode)
+        }
+    }
+    return err
+}
+
+// Delete removes barcode id of a barang.
+func (r *BarcodeRepo) Delete(ctx context.Context, barangID, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `DELETE FROM barang_barcode WHERE id=$1 AND barang_id=$2`, id, barangID)
+    if err != nil { return err }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+// Scan looks up a scanned barcode and returns the barang with current stock,
+// or nil when the barcode is unknown.
+func (r *BarcodeRepo) Scan(ctx context.Context, code string) (*models.HasilScan, error) {
+    code, err := barcode.Normalize(code)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", apperr.ErrValidation, err)
+    }
+    const q = `SELECT bc.id, bc.barang_id, bc.barcode, bc.satuan, bc.isi,
+        b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual,
+        b.kategori_id, b.kena_pajak, b.harga_termasuk_pajak, b.archived_at, b.version, b.induk_id, b.varian, b.gambar_key, b.thumbnail_key, ` + stokTersediaSQL + ` AS stok_akhir
+        FROM barang_barcode bc
+        JOIN master_barang b ON b.id = bc.barang_id
+        LEFT JOIN mstok s ON s.barang_id = b.id
+        WHERE bc.barcode = $1`
+    var h models.HasilScan
+    var bcSatuan, ds sql.NullString
+    var kat sql.NullInt64
+    var kena, termasuk bool
+    var arsip sql.NullTime
+    var induk sql.NullInt64
+    var varian []byte
+    var gambar, thumb sql.NullString
+    err = r.DB.QueryRowContext(ctx, q, code).Scan(&h.Barcode.ID, &h.Barcode.BarangID, &h.Barcode.Barcode, &bcSatuan, &h.Barcode.Isi,
+        &h.ID, &h.KodeBarang, &h.NamaBarang, &ds, &h.Satuan, &h.HargaBeli, &h.HargaJual,
+        &kat, &kena, &termasuk, &arsip, &h.Version, &induk, &varian, &gambar, &thumb, &h.StokAkhir)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+    if bcSatuan.Valid { v := bcSatuan.String; h.Barcode.Satuan = &v }
+    if ds.Valid { v := ds.String; h.Deskripsi = &v }
+    h.KategoriID = nullInt64Ptr(kat)
+    h.IndukID, h.Varian = nullInt64Ptr(induk), decodeVarian(varian)
+    h.GambarURL, h.ThumbnailURL = fileURL(gambar), fileURL(thumb)
+    h.KenaPajak, h.HargaTermasukPajak = &kena, &termasuk
+    if arsip.Valid { t := arsip.Time; h.ArchivedAt = &t }
+    return &h, nil
+}
+
+// resolveBarcode maps a detail line given by barcode to its barang inside a
+// transaction. Qty is converted to base units using the barcode's isi. When
+// the line also carries barang_id, both must agree.
+func resolveBarcode(ctx context.Context, tx *sql.Tx, code string, barangID, qty *int64) error {
+    if code == "" {
+        return nil
+    }
+    norm, err := barcode.Normalize(code)
+    if err != nil {
+        return fmt.Errorf("%w: %s: %v", apperr.ErrValidation, code, err)
+    }
+    var id, isi int64
+    if err := tx.QueryRowContext(ctx, `SELECT barang_id, isi FROM barang_barcode WHERE barcode=$1`, norm).Scan(&id, &isi); err != nil {
+        if err == sql.ErrNoRows {
+            return fmt.Errorf("%w: barcode %s not registered", apperr.ErrNotFound, code)
+        }
+        return fmt.Errorf("resolve barcode: %w", err)
+    }
+    if *barangID > 0 && *barangID != id {
+        return fmt.Errorf("%w: barcode %s belongs to barang %d, not %d", apperr.ErrValidation, code, id, *barangID)
+    }
+    *barangID = id
+    *qty *= isi
+    return nil
+}
+
+// LabelData returns label data for ids in the given order. Missing ids are
+// reported as ErrNotFound.
+func (r *BarcodeRepo) LabelData(ctx context.Context, ids []int64) ([]models.LabelBarang, error) {
+    const q = `SELECT b.id, b.kode_barang, b.nama_barang, b.harga_jual, COALESCE(bc.barcode, '')
+        FROM master_barang b
+        LEFT JOIN LATERAL (
+            SELECT barcode FROM barang_barcode
+            WHERE barang_id = b.id
+            ORDER BY (isi = 1) DESC, id ASC
+            LIMIT 1
+        ) bc ON TRUE
+        WHERE b.id = ANY($1::bigint[])`
+    rows, err := r.DB.QueryContext(ctx, q, pq.Array(ids))
+    if err != nil { return nil, fmt.Errorf("query label: %w", err) }
+    defer rows.Close()
+    byID := make(map[int64]models.LabelBarang)
+    for rows.Next() {
+        var l models.LabelBarang
+        if err := rows.Scan(&l.BarangID, &l.KodeBarang, &l.NamaBarang, &l.HargaJual, &l.Barcode); err != nil {
+            return nil, fmt.Errorf("scan label: %w", err)
+        }
+        byID[l.BarangID] = l
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+
+    list := make([]models.LabelBarang, 0, len(ids))
+    for _, id := range ids {
+        l, ok := byID[id]
+        if !ok {
+            return nil, fmt.Errorf("%w: barang id %d not found", apperr.ErrNotFound, id)
+        }
+        list = append(list, l)
+    }
+    return list, nil
+}
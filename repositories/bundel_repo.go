@@ -0,0 +1,387 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"warehouse/apperr"
+	"warehouse/models"
+)
+
+// BundelRepo manages bundle definitions (bill of materials) and assembly
+// orders.
+type BundelRepo struct {
+    DB *sql.DB
+}
+
+func NewBundelRepo(db *sql.DB) *BundelRepo { return &BundelRepo{DB: db} }
+
+// stokBundelSQL is how many bundles b.id the component stock allows.
+const stokBundelSQL = `COALESCE((SELECT MIN(COALESCE(sk.stok_akhir, 0) / k.qty)
+            FROM bundel_komponen k
+            LEFT JOIN mstok sk ON sk.barang_id = k.komponen_id
+            WHERE k.bundel_id = b.id), 0)`
+
+// stokTersediaSQL is the stock of b shown in lists: a virtual bundle has
+// none of its own, so its available quantity comes from the components.
+const stokTersediaSQL = `CASE WHEN b.bundel_virtual THEN ` + stokBundelSQL + ` ELSE COALESCE(s.stok_akhir, 0) END`
+
+// GetBundel returns the bundle definition of barang id with component stock,
+// or nil when the barang has no components.
+func (r *BundelRepo) GetBundel(ctx context.Context, id int64) (*models.Bundel, error) {
+    var b models.Bundel
+    err := r.DB.QueryRowContext(ctx, `SELECT b.id, b.kode_barang, b.nama_barang, b.bundel_virtual,
+            CASE WHEN b.bundel_virtual THEN 0 ELSE COALESCE(s.stok_akhir, 0) END, `+stokBundelSQL+`
+        FROM master_barang b
+        LEFT JOIN mstok s ON s.barang_id = b.id
+        WHERE b.id = $1`, id).
+        Scan(&b.BarangID, &b.KodeBarang, &b.NamaBarang, &b.Virtual, &b.StokAkhir, &b.Tersedia)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+
+    rows, err := r.DB.QueryContext(ctx, `SELECT k.komponen_id, m.kode_barang, m.nama_barang, k.qty, COALESCE(s.stok_akhir, 0)
+        FROM bundel_komponen k
+        JOIN master_barang m ON m.id = k.komponen_id
+        LEFT JOIN mstok s ON s.barang_id = k.komponen_id
+        WHERE k.bundel_id = $1
+        ORDER BY m.kode_barang ASC`, id)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    b.Komponen = make([]models.KomponenBundel, 0)
+    for rows.Next() {
+        var k models.KomponenBundel
+        if err := rows.Scan(&k.BarangID, &k.KodeBarang, &k.NamaBarang, &k.Qty, &k.StokAkhir); err != nil {
+            return nil, err
+        }
+        b.Komponen = append(b.Komponen, k)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    if len(b.Komponen) == 0 { return nil, nil }
+    return &b, nil
+}
+
+// ListBundel returns every barang that has components, without the
+// component lines.
+func (r *BundelRepo) ListBundel(ctx context.Context) ([]models.Bundel, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT b.id, b.kode_barang, b.nama_barang, b.bundel_virtual,
+            CASE WHEN b.bundel_virtual THEN 0 ELSE COALESCE(s.stok_akhir, 0) END, `+stokBundelSQL+`
+        FROM master_barang b
+        LEFT JOIN mstok s ON s.barang_id = b.id
+        WHERE EXISTS (SELECT 1 FROM bundel_komponen k WHERE k.bundel_id = b.id)
+        ORDER BY b.kode_barang ASC`)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    list := make([]models.Bundel, 0)
+    for rows.Next() {
+        var b models.Bundel
+        if err := rows.Scan(&b.BarangID, &b.KodeBarang, &b.NamaBarang, &b.Virtual, &b.StokAkhir, &b.Tersedia); err != nil {
+            return nil, err
+        }
+        list = append(list, b)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return list, nil
+}
+
+// SetBundel replaces the components of barang b.BarangID and sets whether it
+// is virtual. Components cannot be virtual bundles themselves, and a virtual
+// bundle must have no stock of its own and must not be a component.
+func (r *BundelRepo) SetBundel(ctx context.Context, b *models.Bundel) error {
+    if len(b.Komponen) == 0 {
+        return fmt.Errorf("%w: komponen is required", apperr.ErrValidation)
+    }
+    seen := make(map[int64]bool)
+    for i, k := range b.Komponen {
+        if k.BarangID <= 0 || k.Qty <= 0 {
+            return fmt.Errorf("%w: invalid komponen at index %d", apperr.ErrValidation, i)
+        }
+        if k.BarangID == b.BarangID {
+            return fmt.Errorf("%w: a bundle cannot contain itself", apperr.ErrValidation)
+        }
+        if seen[k.BarangID] {
+            return fmt.Errorf("%w: komponen barang %d listed twice", apperr.ErrValidation, k.BarangID)
+        }
+        seen[k.BarangID] = true
+    }
+
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+
+    var stok sql.NullInt64
+    var dipakai bool
+    err = tx.QueryRowContext(ctx, `SELECT s.stok_akhir, EXISTS (SELECT 1 FROM bundel_komponen k WHERE k.komponen_id = b.id)
+        FROM master_barang b
+        LEFT JOIN mstok s ON s.barang_id = b.id
+        WHERE b.id = $1
+        FOR UPDATE OF b`, b.BarangID).Scan(&stok, &dipakai)
+    if err == sql.ErrNoRows {
+        return rollback(fmt.Errorf("%w: barang id %d not found", apperr.ErrNotFound, b.BarangID))
+    }
+    if err != nil { return rollback(err) }
+    if b.Virtual && stok.Int64 > 0 {
+        return rollback(fmt.Errorf("%w: barang %d has stock %d; a virtual bundle has no stock of its own", apperr.ErrValidation, b.BarangID, stok.Int64))
+    }
+    if b.Virtual && dipakai {
+        return rollback(fmt.Errorf("%w: barang %d is a component of another bundle and cannot be virtual", apperr.ErrValidation, b.BarangID))
+    }
+    for _, k := range b.Komponen {
+        var virtual bool
+        err := tx.QueryRowContext(ctx, `SELECT bundel_virtual FROM master_barang WHERE id=$1`, k.BarangID).Scan(&virtual)
+        if err == sql.ErrNoRows {
+            return rollback(fmt.Errorf("%w: komponen barang id %d not found", apperr.ErrValidation, k.BarangID))
+        }
+        if err != nil { return rollback(err) }
+        if virtual {
+            return rollback(fmt.Errorf("%w: komponen barang %d is a virtual bundle", apperr.ErrValidation, k.BarangID))
+        }
+    }
+
+    if _, err := tx.ExecContext(ctx, `DELETE FROM bundel_komponen WHERE bundel_id=$1`, b.BarangID); err != nil {
+        return rollback(err)
+    }
+    for _, k := range b.Komponen {
+        if _, err := tx.ExecContext(ctx, `INSERT INTO bundel_komponen (bundel_id, komponen_id, qty) VALUES ($1,$2,$3)`,
+            b.BarangID, k.BarangID, k.Qty); err != nil {
+            return rollback(fmt.Errorf("insert komponen: %w", err))
+        }
+    }
+    if _, err := tx.ExecContext(ctx, `UPDATE master_barang SET bundel_virtual=$1, version=version+1 WHERE id=$2`, b.Virtual, b.BarangID); err != nil {
+        return rollback(err)
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// DeleteBundel removes the bundle definition of barang id, which becomes a
+// normal barang again.
+func (r *BundelRepo) DeleteBundel(ctx context.Context, id int64) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+    res, err := tx.ExecContext(ctx, `DELETE FROM bundel_komponen WHERE bundel_id=$1`, id)
+    if err != nil { return rollback(err) }
+    if n, _ := res.RowsAffected(); n == 0 { return rollback(sql.ErrNoRows) }
+    if _, err := tx.ExecContext(ctx, `UPDATE master_barang SET bundel_virtual=FALSE, version=version+1 WHERE id=$1`, id); err != nil {
+        return rollback(err)
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// komponenVirtual returns the components of barangID when it is a virtual
+// bundle, nil otherwise.
+func komponenVirtual(ctx context.Context, tx *sql.Tx, barangID int64) ([]models.KomponenBundel, error) {
+    rows, err := tx.QueryContext(ctx, `SELECT k.komponen_id, k.qty
+        FROM bundel_komponen k
+        JOIN master_barang b ON b.id = k.bundel_id AND b.bundel_virtual
+        WHERE k.bundel_id = $1
+        ORDER BY k.komponen_id ASC`, barangID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var list []models.KomponenBundel
+    for rows.Next() {
+        var k models.KomponenBundel
+        if err := rows.Scan(&k.BarangID, &k.Qty); err != nil { return nil, err }
+        list = append(list, k)
+    }
+    return list, rows.Err()
+}
+
+// ubahStok adds delta (negative to take out) to the stock of barangID inside
+// tx and records the movement in history_stok as jenis.
+func ubahStok(ctx context.Context, tx *sql.Tx, barangID, delta, userID int64, jenis string) error {
+    var stok sql.NullInt64
+    err := tx.QueryRowContext(ctx, "SELECT stok_akhir FROM mstok WHERE barang_id=$1 FOR UPDATE", barangID).Scan(&stok)
+    if err != nil && err != sql.ErrNoRows { return fmt.Errorf("lock stock: %w", err) }
+    before := stok.Int64
+    after := before + delta
+    if after < 0 {
+        return fmt.Errorf("%w: insufficient stock for barang %d: have %d, need %d", apperr.ErrInsufficientStock, barangID, before, -delta)
+    }
+    if stok.Valid {
+        _, err = tx.ExecContext(ctx, "UPDATE mstok SET stok_akhir=$1 WHERE barang_id=$2", after, barangID)
+    } else {
+        _, err = tx.ExecContext(ctx, "INSERT INTO mstok (barang_id, stok_akhir) VALUES ($1,$2)", barangID, after)
+    }
+    if err != nil { return fmt.Errorf("set mstok: %w", err) }
+    jumlah := delta
+    if jumlah < 0 { jumlah = -jumlah }
+    if err := catatHistoriStok(ctx, tx, barangID, userID, jenis, jumlah, before, after); err != nil {
+        return fmt.Errorf("insert history: %w", err)
+    }
+    return nil
+}
+
+// generateNoRakit generates RKT-001, RKT-002, ...
+func generateNoRakit(ctx context.Context, tx *sql.Tx) (string, error) {
+    const q = `
+        SELECT COALESCE(MAX(CAST(SUBSTRING(no_rakit FROM '[0-9]+') AS INTEGER)), 0)
+        FROM rakit_header
+        WHERE no_rakit LIKE 'RKT-%'`
+    var maxNum int
+    if err := tx.QueryRowContext(ctx, q).Scan(&maxNum); err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("RKT-%03d", maxNum+1), nil
+}
+
+// Rakit builds h.Qty units of kit h.BarangID: every component is taken out
+// of stock (rakit_keluar) and the kit is added (rakit_masuk), all in one
+// transaction.
+func (r *BundelRepo) Rakit(ctx context.Context, h *models.RakitHeader) error {
+    if h == nil { return errors.New("header is nil") }
+    if h.BarangID <= 0 || h.Qty <= 0 {
+        return fmt.Errorf("%w: barang_id and qty > 0 are required", apperr.ErrValidation)
+    }
+
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+
+    var virtual, arsip bool
+    err = tx.QueryRowContext(ctx, `SELECT bundel_virtual, archived_at IS NOT NULL FROM master_barang WHERE id=$1`, h.BarangID).Scan(&virtual, &arsip)
+    if err == sql.ErrNoRows {
+        return rollback(fmt.Errorf("%w: barang id %d not found", apperr.ErrNotFound, h.BarangID))
+    }
+    if err != nil { return rollback(err) }
+    if virtual {
+        return rollback(fmt.Errorf("%w: barang %d is a virtual bundle and is not assembled", apperr.ErrValidation, h.BarangID))
+    }
+    if arsip {
+        return rollback(fmt.Errorf("%w: barang id %d is archived", apperr.ErrValidation, h.BarangID))
+    }
+
+    rows, err := tx.QueryContext(ctx, `SELECT komponen_id, qty FROM bundel_komponen WHERE bundel_id=$1 ORDER BY komponen_id ASC`, h.BarangID)
+    if err != nil { return rollback(err) }
+    h.Details = make([]models.RakitDetail, 0)
+    for rows.Next() {
+        var d models.RakitDetail
+        if err := rows.Scan(&d.BarangID, &d.Qty); err != nil {
+            rows.Close()
+            return rollback(err)
+        }
+        d.Qty *= h.Qty
+        h.Details = append(h.Details, d)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return rollback(err) }
+    if len(h.Details) == 0 {
+        return rollback(fmt.Errorf("%w: barang %d has no bundle components", apperr.ErrValidation, h.BarangID))
+    }
+
+    if h.NoRakit, err = generateNoRakit(ctx, tx); err != nil {
+        return rollback(fmt.Errorf("generate no_rakit: %w", err))
+    }
+    if err := tx.QueryRowContext(ctx, `INSERT INTO rakit_header (no_rakit, barang_id, qty, catatan, user_id)
+            VALUES ($1,$2,$3,$4,$5) RETURNING id, created_at`,
+        h.NoRakit, h.BarangID, h.Qty, h.Catatan, h.UserID).Scan(&h.ID, &h.CreatedAt); err != nil {
+        return rollback(fmt.Errorf("insert header: %w", err))
+    }
+    for i := range h.Details {
+        d := &h.Details[i]
+        d.RakitHeaderID = h.ID
+        if err := ubahStok(ctx, tx, d.BarangID, -d.Qty, h.UserID, "rakit_keluar"); err != nil {
+            return rollback(err)
+        }
+        if err := tx.QueryRowContext(ctx, `INSERT INTO rakit_detail (rakit_header_id, barang_id, qty) VALUES ($1,$2,$3) RETURNING id`,
+            h.ID, d.BarangID, d.Qty).Scan(&d.ID); err != nil {
+            return rollback(fmt.Errorf("insert detail: %w", err))
+        }
+    }
+    if err := ubahStok(ctx, tx, h.BarangID, h.Qty, h.UserID, "rakit_masuk"); err != nil {
+        return rollback(err)
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// ListRakit returns assembly orders, newest first, filtered by optional date range.
+func (r *BundelRepo) ListRakit(ctx context.Context, from, to *time.Time, page, limit int) ([]models.RakitHeader, int, error) {
+    if page < 1 { page = 1 }
+    if limit < 1 { limit = 10 }
+    where := make([]string, 0)
+    args := make([]interface{}, 0)
+    if from != nil {
+        args = append(args, *from)
+        where = append(where, fmt.Sprintf("created_at >= $%d", len(args)))
+    }
+    if to != nil {
+        args = append(args, *to)
+        where = append(where, fmt.Sprintf("created_at <= $%d", len(args)))
+    }
+    whereSQL := ""
+    if len(where) > 0 { whereSQL = " WHERE " + strings.Join(where, " AND ") }
+
+    var total int
+    if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM rakit_header`+whereSQL, args...).Scan(&total); err != nil {
+        return nil, 0, fmt.Errorf("count rakit: %w", err)
+    }
+    q := fmt.Sprintf(`SELECT id, no_rakit, barang_id, qty, catatan, user_id, created_at FROM rakit_header%s
+        ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, whereSQL, len(args)+1, len(args)+2)
+    rows, err := r.DB.QueryContext(ctx, q, append(args, limit, (page-1)*limit)...)
+    if err != nil { return nil, 0, fmt.Errorf("query rakit: %w", err) }
+    defer rows.Close()
+    list := make([]models.RakitHeader, 0)
+    for rows.Next() {
+        var h models.RakitHeader
+        var catatan sql.NullString
+        if err := rows.Scan(&h.ID, &h.NoRakit, &h.BarangID, &h.Qty, &catatan, &h.UserID, &h.CreatedAt); err != nil {
+            return nil, 0, fmt.Errorf("scan rakit: %w", err)
+        }
+        if catatan.Valid { v := catatan.String; h.Catatan = &v }
+        list = append(list, h)
+    }
+    if err := rows.Err(); err != nil { return nil, 0, fmt.Errorf("rows err: %w", err) }
+    return list, total, nil
+}
+
+// GetRakit returns an assembly order with its kit and consumed components,
+// or nil when it does not exist.
+func (r *BundelRepo) GetRakit(ctx context.Context, id int64) (*models.RakitHeader, error) {
+    var h models.RakitHeader
+    var b models.Barang
+    var catatan sql.NullString
+    err := r.DB.QueryRowContext(ctx, `SELECT h.id, h.no_rakit, h.barang_id, h.qty, h.catatan, h.user_id, h.created_at,
+            b.id, b.kode_barang, b.nama_barang, b.satuan
+        FROM rakit_header h
+        JOIN master_barang b ON b.id = h.barang_id
+        WHERE h.id = $1`, id).
+        Scan(&h.ID, &h.NoRakit, &h.BarangID, &h.Qty, &catatan, &h.UserID, &h.CreatedAt, &b.ID, &b.KodeBarang, &b.NamaBarang, &b.Satuan)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, fmt.Errorf("get rakit: %w", err) }
+    if catatan.Valid { v := catatan.String; h.Catatan = &v }
+    h.BarangDetail = &b
+
+    rows, err := r.DB.QueryContext(ctx, `SELECT d.id, d.rakit_header_id, d.barang_id, d.qty, b.id, b.kode_barang, b.nama_barang, b.satuan
+        FROM rakit_detail d
+        JOIN master_barang b ON b.id = d.barang_id
+        WHERE d.rakit_header_id = $1 ORDER BY d.id ASC`, id)
+    if err != nil { return nil, fmt.Errorf("query details: %w", err) }
+    defer rows.Close()
+    h.Details = make([]models.RakitDetail, 0)
+    for rows.Next() {
+        var d models.RakitDetail
+        var kb models.Barang
+        if err := rows.Scan(&d.ID, &d.RakitHeaderID, &d.BarangID, &d.Qty, &kb.ID, &kb.KodeBarang, &kb.NamaBarang, &kb.Satuan); err != nil {
+            return nil, fmt.Errorf("scan detail: %w", err)
+        }
+        d.BarangDetail = &kb
+        h.Details = append(h.Details, d)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return &h, nil
+}
@@ -0,0 +1,275 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"warehouse/apperr"
+	"warehouse/models"
+
+	"github.com/lib/pq"
+)
+
+// HargaRepo manages price history, price lists and customer groups.
+type HargaRepo struct {
+    DB *sql.DB
+}
+
+func NewHargaRepo(db *sql.DB) *HargaRepo { return &HargaRepo{DB: db} }
+
+// ErrDaftarHargaInUse is returned when a price list is still assigned to a customer group.
+var ErrDaftarHargaInUse = errors.New("daftar harga in use")
+
+// ErrDaftarHargaTerpakai is returned when penjualan lines were priced from a
+// price list, which therefore has to be kept.
+var ErrDaftarHargaTerpakai = errors.New("daftar harga used by penjualan")
+
+// catatPerubahanHarga appends a harga_history row inside tx when either price changed.
+// userID 0 is stored as NULL (system change).
+func catatPerubahanHarga(ctx context.Context, tx *sql.Tx, barangID, beliLama, beliBaru, jualLama, jualBaru, userID int64, keterangan string) error {
+    if beliLama == beliBaru && jualLama == jualBaru {
+        return nil
+    }
+    var uid interface{}
+    if userID > 0 { uid = userID }
+    _, err := tx.ExecContext(ctx, `INSERT INTO harga_history
+            (barang_id, harga_beli_lama, harga_beli_baru, harga_jual_lama, harga_jual_baru, user_id, keterangan)
+            VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+        barangID, beliLama, beliBaru, jualLama, jualBaru, uid, keterangan,
+    )
+    if err != nil { return fmt.Errorf("insert harga_history: %w", err) }
+    return nil
+}
+
+// GetHistory returns the price history of a barang, newest first.
+func (r *HargaRepo) GetHistory(ctx context.Context, barangID int64, page, limit int) ([]models.HargaHistory, int, error) {
+    if page < 1 { page = 1 }
+    if limit < 1 { limit = 10 }
+    offset := (page - 1) * limit
+
+    var total int
+    if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM harga_history WHERE barang_id = $1`, barangID).Scan(&total); err != nil {
+        return nil, 0, err
+    }
+    const q = `SELECT id, barang_id, harga_beli_lama, harga_beli_baru, harga_jual_lama, harga_jual_baru, user_id, keterangan, created_at
+        FROM harga_history
+        WHERE barang_id = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT $2 OFFSET $3`
+    rows, err := r.DB.QueryContext(ctx, q, barangID, limit, offset)
+    if err != nil { return nil, 0, err }
+    defer rows.Close()
+    list := make([]models.HargaHistory, 0)
+    for rows.Next() {
+        var h models.HargaHistory
+        var uid sql.NullInt64
+        if err := rows.Scan(&h.ID, &h.BarangID, &h.HargaBeliLama, &h.HargaBeliBaru, &h.HargaJualLama, &h.HargaJualBaru, &uid, &h.Keterangan, &h.CreatedAt); err != nil {
+            return nil, 0, err
+        }
+        if uid.Valid { v := uid.Int64; h.UserID = &v }
+        list = append(list, h)
+    }
+    if err := rows.Err(); err != nil { return nil, 0, err }
+    return list, total, nil
+}
+
+// hargaJualUntuk resolves the unit price of a sales line. During an active
+// promo window (jadwal_harga) the promo harga_jual always applies. Otherwise,
+// when the customer group has a price list with a tier for the barang, the
+// tier with the highest min_qty not exceeding qty wins; else the default harga
+// is used. The returned daftar harga ID is nil when the default price applies.
+func hargaJualUntuk(ctx context.Context, tx *sql.Tx, grupID *int64, barangID, qty, hargaDefault int64) (int64, *int64, error) {
+    if grupID == nil {
+        return hargaDefault, nil, nil
+    }
+    var promo bool
+    if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM jadwal_harga
+            WHERE barang_id = $1 AND status = 'aktif' AND harga_jual IS NOT NULL)`, barangID).Scan(&promo); err != nil {
+        return 0, nil, err
+    }
+    if promo {
+        return hargaDefault, nil, nil
+    }
+    const q = `SELECT i.harga, i.daftar_harga_id
+        FROM grup_pelanggan g
+        JOIN daftar_harga_item i ON i.daftar_harga_id = g.daftar_harga_id
+        WHERE g.id = $1 AND i.barang_id = $2 AND i.min_qty <= $3
+        ORDER BY i.min_qty DESC
+        LIMIT 1`
+    var harga, daftarID int64
+    if err := tx.QueryRowContext(ctx, q, *grupID, barangID, qty).Scan(&harga, &daftarID); err != nil {
+        if err == sql.ErrNoRows { return hargaDefault, nil, nil }
+        return 0, nil, err
+    }
+    return harga, &daftarID, nil
+}
+
+// ListDaftarHarga returns all price lists without their items.
+func (r *HargaRepo) ListDaftarHarga(ctx context.Context) ([]models.DaftarHarga, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT id, nama, keterangan FROM daftar_harga ORDER BY nama ASC`)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    list := make([]models.DaftarHarga, 0)
+    for rows.Next() {
+        var d models.DaftarHarga
+        var ket sql.NullString
+        if err := rows.Scan(&d.ID, &d.Nama, &ket); err != nil { return nil, err }
+        if ket.Valid { v := ket.String; d.Keterangan = &v }
+        list = append(list, d)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return list, nil
+}
+
+// GetDaftarHarga returns a price list with all tiers, or nil when not found.
+func (r *HargaRepo) GetDaftarHarga(ctx context.Context, id int64) (*models.DaftarHarga, error) {
+    var d models.DaftarHarga
+    var ket sql.NullString
+    err := r.DB.QueryRowContext(ctx, `SELECT id, nama, keterangan FROM daftar_harga WHERE id = $1`, id).Scan(&d.ID, &d.Nama, &ket)
+    if err != nil {
+        if err == sql.ErrNoRows { return nil, nil }
+        return nil, err
+    }
+    if ket.Valid { v := ket.String; d.Keterangan = &v }
+
+    const q = `SELECT i.id, i.daftar_harga_id, i.barang_id, i.min_qty, i.harga,
+            b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual
+        FROM daftar_harga_item i
+        JOIN master_barang b ON b.id = i.barang_id
+        WHERE i.daftar_harga_id = $1
+        ORDER BY b.nama_barang ASC, i.min_qty ASC`
+    rows, err := r.DB.QueryContext(ctx, q, id)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    d.Items = make([]models.DaftarHargaItem, 0)
+    for rows.Next() {
+        var it models.DaftarHargaItem
+        var b models.Barang
+        var desc sql.NullString
+        if err := rows.Scan(&it.ID, &it.DaftarHargaID, &it.BarangID, &it.MinQty, &it.Harga,
+            &b.ID, &b.KodeBarang, &b.NamaBarang, &desc, &b.Satuan, &b.HargaBeli, &b.HargaJual); err != nil {
+            return nil, err
+        }
+        if desc.Valid { v := desc.String; b.Deskripsi = &v }
+        it.BarangDetail = &b
+        d.Items = append(d.Items, it)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return &d, nil
+}
+
+func (r *HargaRepo) CreateDaftarHarga(ctx context.Context, d *models.DaftarHarga) error {
+    var ket interface{}
+    if d.Keterangan != nil { ket = *d.Keterangan }
+    err := r.DB.QueryRowContext(ctx, `INSERT INTO daftar_harga (nama, keterangan) VALUES ($1,$2) RETURNING id`, d.Nama, ket).Scan(&d.ID)
+    if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23505" {
+        return fmt.Errorf("%w: daftar harga %q already exists", apperr.ErrValidation, d.Nama)
+    }
+    return err
+}
+
+func (r *HargaRepo) DeleteDaftarHarga(ctx context.Context, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `DELETE FROM daftar_harga WHERE id = $1`, id)
+    if err != nil {
+        if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23503" {
+            switch pqErr.Table {
+            case "grup_pelanggan":
+                return ErrDaftarHargaInUse
+            case "jual_detail":
+                return ErrDaftarHargaTerpakai
+            }
+        }
+        return err
+    }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+// UpsertItems inserts or updates tiers of a price list in one transaction.
+// Tiers are keyed by (barang_id, min_qty).
+func (r *HargaRepo) UpsertItems(ctx context.Context, daftarID int64, items []models.DaftarHargaItem) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+
+    var exists bool
+    if err := tx.QueryRowContext(ctx, `SELECT TRUE FROM daftar_harga WHERE id = $1`, daftarID).Scan(&exists); err != nil {
+        return rollback(err)
+    }
+    for i := range items {
+        it := &items[i]
+        if it.BarangID <= 0 || it.MinQty < 1 || it.Harga < 0 {
+            return rollback(fmt.Errorf("%w: invalid item at index %d", apperr.ErrValidation, i))
+        }
+        it.DaftarHargaID = daftarID
+        err := tx.QueryRowContext(ctx, `INSERT INTO daftar_harga_item (daftar_harga_id, barang_id, min_qty, harga)
+                VALUES ($1,$2,$3,$4)
+                ON CONFLICT (daftar_harga_id, barang_id, min_qty) DO UPDATE SET harga = EXCLUDED.harga
+                RETURNING id`,
+            daftarID, it.BarangID, it.MinQty, it.Harga,
+        ).Scan(&it.ID)
+        if err != nil {
+            if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23503" {
+                return rollback(fmt.Errorf("%w: barang id %d not found (item index %d)", apperr.ErrNotFound, it.BarangID, i))
+            }
+            return rollback(fmt.Errorf("upsert item: %w", err))
+        }
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+func (r *HargaRepo) DeleteItem(ctx context.Context, daftarID, itemID int64) error {
+    res, err := r.DB.ExecContext(ctx, `DELETE FROM daftar_harga_item WHERE id = $1 AND daftar_harga_id = $2`, itemID, daftarID)
+    if err != nil { return err }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+func (r *HargaRepo) ListGrup(ctx context.Context) ([]models.GrupPelanggan, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT id, nama, daftar_harga_id FROM grup_pelanggan ORDER BY nama ASC`)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    list := make([]models.GrupPelanggan, 0)
+    for rows.Next() {
+        var g models.GrupPelanggan
+        var did sql.NullInt64
+        if err := rows.Scan(&g.ID, &g.Nama, &did); err != nil { return nil, err }
+        if did.Valid { v := did.Int64; g.DaftarHargaID = &v }
+        list = append(list, g)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return list, nil
+}
+
+func (r *HargaRepo) CreateGrup(ctx context.Context, g *models.GrupPelanggan) error {
+    err := r.DB.QueryRowContext(ctx, `INSERT INTO grup_pelanggan (nama, daftar_harga_id) VALUES ($1,$2) RETURNING id`, g.Nama, g.DaftarHargaID).Scan(&g.ID)
+    return grupError(err, g)
+}
+
+func (r *HargaRepo) UpdateGrup(ctx context.Context, g *models.GrupPelanggan) error {
+    res, err := r.DB.ExecContext(ctx, `UPDATE grup_pelanggan SET nama=$1, daftar_harga_id=$2 WHERE id=$3`, g.Nama, g.DaftarHargaID, g.ID)
+    if err != nil { return grupError(err, g) }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+func grupError(err error, g *models.GrupPelanggan) error {
+    if pqErr, ok := err.(*pq.Error); ok {
+        switch string(pqErr.Code) {
+        case "23505":
+            return fmt.Errorf("%w: grup pelanggan %q already exists", apperr.ErrValidation, g.Nama)
+        case "23503":
+            return fmt.Errorf("%w: daftar harga not found", apperr.ErrNotFound)
+        }
+    }
+    return err
+}
@@ -0,0 +1,391 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"warehouse/apperr"
+	"warehouse/models"
+
+	"github.com/lib/pq"
+)
+
+// Status values of jadwal_harga.
+const (
+    JadwalTerjadwal  = "terjadwal"
+    JadwalAktif      = "aktif"
+    JadwalSelesai    = "selesai"
+    JadwalDibatalkan = "dibatalkan"
+)
+
+// JadwalHargaRepo manages scheduled price changes.
+type JadwalHargaRepo struct {
+    DB *sql.DB
+}
+
+func NewJadwalHargaRepo(db *sql.DB) *JadwalHargaRepo { return &JadwalHargaRepo{DB: db} }
+
+func validateJadwal(j *models.JadwalHarga) error {
+    if j.HargaBeli == nil && j.HargaJual == nil {
+        return fmt.Errorf("%w: harga_beli or harga_jual required", apperr.ErrValidation)
+    }
+    if (j.HargaBeli != nil && *j.HargaBeli < 0) || (j.HargaJual != nil && *j.HargaJual < 0) {
+        return fmt.Errorf("%w: harga must be >= 0", apperr.ErrValidation)
+    }
+    if j.Mulai.Before(time.Now().Add(-time.Minute)) {
+        return fmt.Errorf("%w: mulai cannot be in the past", apperr.ErrValidation)
+    }
+    if j.Selesai != nil && !j.Selesai.After(j.Mulai) {
+        return fmt.Errorf("%w: selesai must be after mulai", apperr.ErrValidation)
+    }
+    return nil
+}
+
+// Create validates and stores a scheduled price change. Windows for the same
+// barang may not overlap, otherwise restoring one would undo the other.
+func (r *JadwalHargaRepo) Create(ctx context.Context, j *models.JadwalHarga) error {
+    if err := validateJadwal(j); err != nil {
+        return err
+    }
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    if err := insertJadwal(ctx, tx, j); err != nil {
+        _ = tx.Rollback()
+        return err
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// CreateForKategori schedules the change in tmpl for every barang in the
+// kategori and its sub-categories, as one row per barang. Either all rows are
+// stored or, when any barang has an overlapping schedule, none are.
+func (r *JadwalHargaRepo) CreateForKategori(ctx context.Context, tmpl models.JadwalHarga, kategoriID int64) ([]models.JadwalHarga, error) {
+    if err := validateJadwal(&tmpl); err != nil {
+        return nil, err
+    }
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return nil, fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) ([]models.JadwalHarga, error) {
+        _ = tx.Rollback()
+        return nil, e
+    }
+
+    rows, err := tx.QueryContext(ctx, `SELECT id FROM master_barang WHERE kategori_id IN (`+kategoriTurunan("$1")+`) ORDER BY id ASC`, kategoriID)
+    if err != nil { return rollback(fmt.Errorf("query barang: %w", err)) }
+    ids := make([]int64, 0)
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil {
+            rows.Close()
+            return rollback(err)
+        }
+        ids = append(ids, id)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return rollback(err) }
+    if len(ids) == 0 {
+        return rollback(fmt.Errorf("%w: kategori %d has no barang", apperr.ErrValidation, kategoriID))
+    }
+
+    list := make([]models.JadwalHarga, 0, len(ids))
+    for _, id := range ids {
+        j := tmpl
+        j.BarangID = id
+        j.KategoriID = &kategoriID
+        if err := insertJadwal(ctx, tx, &j); err != nil {
+            return rollback(err)
+        }
+        list = append(list, j)
+    }
+    if err := tx.Commit(); err != nil { return nil, fmt.Errorf("commit tx: %w", err) }
+    return list, nil
+}
+
+// insertJadwal locks the barang, rejects overlapping windows and inserts j.
+func insertJadwal(ctx context.Context, tx *sql.Tx, j *models.JadwalHarga) error {
+    // Lock the barang so concurrent schedules for it are checked one at a time.
+    var exists bool
+    if err := tx.QueryRowContext(ctx, `SELECT TRUE FROM master_barang WHERE id=$1 FOR UPDATE`, j.BarangID).Scan(&exists); err != nil {
+        if err == sql.ErrNoRows {
+            return fmt.Errorf("%w: barang id %d not found", apperr.ErrNotFound, j.BarangID)
+        }
+        return err
+    }
+    var overlap int64
+    err := tx.QueryRowContext(ctx, `SELECT id FROM jadwal_harga
+            WHERE barang_id = $1 AND status IN ('terjadwal','aktif')
+              AND tstzrange(mulai, COALESCE(selesai, mulai), '[]') && tstzrange($2, COALESCE($3, $2), '[]')
+            LIMIT 1`,
+        j.BarangID, j.Mulai, j.Selesai,
+    ).Scan(&overlap)
+    if err == nil {
+        return fmt.Errorf("%w: barang %d overlaps jadwal harga #%d", apperr.ErrValidation, j.BarangID, overlap)
+    }
+    if err != sql.ErrNoRows {
+        return fmt.Errorf("check overlap: %w", err)
+    }
+
+    j.Status = JadwalTerjadwal
+    if err := tx.QueryRowContext(ctx, `INSERT INTO jadwal_harga (barang_id, kategori_id, harga_beli, harga_jual, mulai, selesai, status, keterangan, user_id)
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id, created_at`,
+        j.BarangID, j.KategoriID, j.HargaBeli, j.HargaJual, j.Mulai, j.Selesai, j.Status, j.Keterangan, j.UserID,
+    ).Scan(&j.ID, &j.CreatedAt); err != nil {
+        return fmt.Errorf("insert jadwal: %w", err)
+    }
+    return nil
+}
+
+// List returns scheduled changes with the given statuses (default: upcoming
+// and active), optionally for one barang, ordered by start time.
+func (r *JadwalHargaRepo) List(ctx context.Context, statuses []string, barangID int64) ([]models.JadwalHarga, error) {
+    if len(statuses) == 0 {
+        statuses = []string{JadwalTerjadwal, JadwalAktif}
+    }
+    where := []string{"j.status = ANY($1)"}
+    args := []interface{}{pq.Array(statuses)}
+    if barangID > 0 {
+        where = append(where, "j.barang_id = $2")
+        args = append(args, barangID)
+    }
+    q := `SELECT j.id, j.barang_id, j.kategori_id, j.harga_beli, j.harga_jual, j.mulai, j.selesai, j.status,
+            j.harga_beli_asal, j.harga_jual_asal, j.keterangan, j.user_id, j.created_at,
+            b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual
+        FROM jadwal_harga j
+        JOIN master_barang b ON b.id = j.barang_id
+        WHERE ` + strings.Join(where, " AND ") + `
+        ORDER BY j.mulai ASC, j.id ASC`
+    rows, err := r.DB.QueryContext(ctx, q, args...)
+    if err != nil { return nil, fmt.Errorf("query jadwal: %w", err) }
+    defer rows.Close()
+
+    list := make([]models.JadwalHarga, 0)
+    for rows.Next() {
+        var j models.JadwalHarga
+        var b models.Barang
+        var desc sql.NullString
+        var kat, beli, jual, beliAsal, jualAsal sql.NullInt64
+        var selesai sql.NullTime
+        if err := rows.Scan(&j.ID, &j.BarangID, &kat, &beli, &jual, &j.Mulai, &selesai, &j.Status,
+            &beliAsal, &jualAsal, &j.Keterangan, &j.UserID, &j.CreatedAt,
+            &b.ID, &b.KodeBarang, &b.NamaBarang, &desc, &b.Satuan, &b.HargaBeli, &b.HargaJual); err != nil {
+            return nil, fmt.Errorf("scan jadwal: %w", err)
+        }
+        j.KategoriID = nullInt64Ptr(kat)
+        j.HargaBeli, j.HargaJual = nullInt64Ptr(beli), nullInt64Ptr(jual)
+        j.HargaBeliAsal, j.HargaJualAsal = nullInt64Ptr(beliAsal), nullInt64Ptr(jualAsal)
+        if selesai.Valid { t := selesai.Time; j.Selesai = &t }
+        if desc.Valid { v := desc.String; b.Deskripsi = &v }
+        j.BarangDetail = &b
+        list = append(list, j)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+// Cancel cancels an upcoming change, or ends an active one immediately and
+// restores the original prices.
+func (r *JadwalHargaRepo) Cancel(ctx context.Context, id int64) error {
+    var barangID int64
+    if err := r.DB.QueryRowContext(ctx, `SELECT barang_id FROM jadwal_harga WHERE id=$1`, id).Scan(&barangID); err != nil {
+        return err
+    }
+
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+    // Same lock order as terapkanJadwalHarga: barang first, then jadwal rows.
+    if _, err := tx.ExecContext(ctx, `SELECT 1 FROM master_barang WHERE id=$1 FOR UPDATE`, barangID); err != nil {
+        return rollback(err)
+    }
+    var status string
+    if err := tx.QueryRowContext(ctx, `SELECT status FROM jadwal_harga WHERE id=$1 FOR UPDATE`, id).Scan(&status); err != nil {
+        return rollback(err)
+    }
+    now := time.Now()
+    switch status {
+    case JadwalTerjadwal:
+        if _, err := tx.ExecContext(ctx, `UPDATE jadwal_harga SET status=$1 WHERE id=$2`, JadwalDibatalkan, id); err != nil {
+            return rollback(err)
+        }
+    case JadwalAktif:
+        if _, err := tx.ExecContext(ctx, `UPDATE jadwal_harga SET selesai=$1 WHERE id=$2`, now, id); err != nil {
+            return rollback(err)
+        }
+        if err := terapkanJadwalHarga(ctx, tx, barangID, now); err != nil {
+            return rollback(err)
+        }
+    default:
+        return rollback(fmt.Errorf("%w: jadwal harga #%d is already %s", apperr.ErrValidation, id, status))
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// ApplyDue starts and ends every scheduled change that is due at now.
+// It returns the number of barang whose prices were processed.
+func (r *JadwalHargaRepo) ApplyDue(ctx context.Context, now time.Time) (int, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT DISTINCT barang_id FROM jadwal_harga
+        WHERE (status = 'terjadwal' AND mulai <= $1) OR (status = 'aktif' AND selesai <= $1)`, now)
+    if err != nil { return 0, fmt.Errorf("query due: %w", err) }
+    ids := make([]int64, 0)
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil {
+            rows.Close()
+            return 0, err
+        }
+        ids = append(ids, id)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return 0, err }
+
+    for i, id := range ids {
+        tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+        if err != nil { return i, fmt.Errorf("begin tx: %w", err) }
+        if err := terapkanJadwalHarga(ctx, tx, id, now); err != nil {
+            _ = tx.Rollback()
+            return i, fmt.Errorf("barang %d: %w", id, err)
+        }
+        if err := tx.Commit(); err != nil { return i, fmt.Errorf("commit tx: %w", err) }
+    }
+    return len(ids), nil
+}
+
+type jadwalDue struct {
+    id                 int64
+    beli, jual         sql.NullInt64
+    beliAsal, jualAsal sql.NullInt64
+    mulai              time.Time
+    selesai            sql.NullTime
+    status             string
+}
+
+type jadwalEvent struct {
+    at    time.Time
+    start bool
+    j     *jadwalDue
+}
+
+// terapkanJadwalHarga applies, inside tx, every start/end of a scheduled change
+// for the barang that is due at now, in chronological order, and records each
+// price change in harga_history. Transactions call it before reading prices so
+// a sale inside a promo window always gets the promo price even if the
+// background scheduler has not run yet.
+func terapkanJadwalHarga(ctx context.Context, tx *sql.Tx, barangID int64, now time.Time) error {
+    const dueCond = `barang_id = $1 AND ((status = 'terjadwal' AND mulai <= $2) OR (status = 'aktif' AND selesai <= $2))`
+    var due bool
+    if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM jadwal_harga WHERE `+dueCond+`)`, barangID, now).Scan(&due); err != nil {
+        return fmt.Errorf("check jadwal: %w", err)
+    }
+    if !due {
+        return nil
+    }
+
+    var beli, jual int64
+    if err := tx.QueryRowContext(ctx, `SELECT harga_beli, harga_jual FROM master_barang WHERE id=$1 FOR UPDATE`, barangID).Scan(&beli, &jual); err != nil {
+        return fmt.Errorf("lock barang: %w", err)
+    }
+    list, err := muatJadwalDue(ctx, tx, barangID, now, true)
+    if err != nil { return err }
+
+    for _, ev := range eventJadwal(list, now) {
+        beliBaru, jualBaru, keterangan := ev.terapkan(beli, jual)
+        if err := catatPerubahanHarga(ctx, tx, barangID, beli, beliBaru, jual, jualBaru, 0, keterangan); err != nil {
+            return err
+        }
+        beli, jual = beliBaru, jualBaru
+        j := ev.j
+        if _, err := tx.ExecContext(ctx, `UPDATE jadwal_harga SET status=$1, harga_beli_asal=$2, harga_jual_asal=$3 WHERE id=$4`,
+            j.status, j.beliAsal, j.jualAsal, j.id); err != nil {
+            return fmt.Errorf("update jadwal: %w", err)
+        }
+    }
+    if _, err := tx.ExecContext(ctx, `UPDATE master_barang SET harga_beli=$1, harga_jual=$2, version=version+1 WHERE id=$3`, beli, jual, barangID); err != nil {
+        return fmt.Errorf("update harga: %w", err)
+    }
+    return nil
+}
+
+// hargaJualBerlaku returns the harga_jual barangID would have at now once its
+// due scheduled changes were applied, without locking or writing anything.
+// jual is the price currently stored in master_barang.
+func hargaJualBerlaku(ctx context.Context, tx *sql.Tx, barangID int64, now time.Time, jual int64) (int64, error) {
+    list, err := muatJadwalDue(ctx, tx, barangID, now, false)
+    if err != nil { return 0, err }
+    var beli int64
+    for _, ev := range eventJadwal(list, now) {
+        beli, jual, _ = ev.terapkan(beli, jual)
+    }
+    return jual, nil
+}
+
+// muatJadwalDue loads the schedules of barangID with a start or end due at
+// now, locking them when lock is set.
+func muatJadwalDue(ctx context.Context, tx *sql.Tx, barangID int64, now time.Time, lock bool) ([]*jadwalDue, error) {
+    q := `SELECT id, harga_beli, harga_jual, harga_beli_asal, harga_jual_asal, mulai, selesai, status
+        FROM jadwal_harga WHERE barang_id = $1 AND ((status = 'terjadwal' AND mulai <= $2) OR (status = 'aktif' AND selesai <= $2))
+        ORDER BY mulai ASC, id ASC`
+    if lock { q += " FOR UPDATE" }
+    rows, err := tx.QueryContext(ctx, q, barangID, now)
+    if err != nil { return nil, fmt.Errorf("query jadwal: %w", err) }
+    defer rows.Close()
+    list := make([]*jadwalDue, 0)
+    for rows.Next() {
+        j := &jadwalDue{}
+        if err := rows.Scan(&j.id, &j.beli, &j.jual, &j.beliAsal, &j.jualAsal, &j.mulai, &j.selesai, &j.status); err != nil {
+            return nil, fmt.Errorf("scan jadwal: %w", err)
+        }
+        list = append(list, j)
+    }
+    return list, rows.Err()
+}
+
+// eventJadwal returns the due starts and ends of list in chronological order.
+func eventJadwal(list []*jadwalDue, now time.Time) []jadwalEvent {
+    events := make([]jadwalEvent, 0, len(list)*2)
+    for _, j := range list {
+        if j.status == JadwalTerjadwal {
+            events = append(events, jadwalEvent{at: j.mulai, start: true, j: j})
+        }
+        if j.selesai.Valid && !j.selesai.Time.After(now) {
+            events = append(events, jadwalEvent{at: j.selesai.Time, start: false, j: j})
+        }
+    }
+    sort.SliceStable(events, func(a, b int) bool { return events[a].at.Before(events[b].at) })
+    return events
+}
+
+// terapkan moves ev's schedule to its next status and returns the prices
+// after the event together with the harga_history note.
+func (ev jadwalEvent) terapkan(beli, jual int64) (int64, int64, string) {
+    j := ev.j
+    beliBaru, jualBaru := beli, jual
+    if ev.start {
+        j.beliAsal = sql.NullInt64{Int64: beli, Valid: true}
+        j.jualAsal = sql.NullInt64{Int64: jual, Valid: true}
+        if j.beli.Valid { beliBaru = j.beli.Int64 }
+        if j.jual.Valid { jualBaru = j.jual.Int64 }
+        j.status = JadwalAktif
+        if !j.selesai.Valid { j.status = JadwalSelesai }
+        return beliBaru, jualBaru, fmt.Sprintf("jadwal harga #%d mulai", j.id)
+    }
+    // Only restore the prices this schedule changed.
+    if j.beli.Valid && j.beliAsal.Valid { beliBaru = j.beliAsal.Int64 }
+    if j.jual.Valid && j.jualAsal.Valid { jualBaru = j.jualAsal.Int64 }
+    j.status = JadwalSelesai
+    return beliBaru, jualBaru, fmt.Sprintf("jadwal harga #%d selesai", j.id)
+}
+
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+    if !n.Valid { return nil }
+    v := n.Int64
+    return &v
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"warehouse/apperr"
+	"warehouse/models"
+
+	"github.com/lib/pq"
+)
+
+// KategoriRepo manages the barang category tree.
+type KategoriRepo struct {
+    DB *sql.DB
+}
+
+func NewKategoriRepo(db *sql.DB) *KategoriRepo { return &KategoriRepo{DB: db} }
+
+// ErrKategoriInUse is returned when a kategori still has barang or sub-categories.
+var ErrKategoriInUse = errors.New("kategori in use")
+
+// kategoriTurunan returns a subquery selecting the id of the kategori given by
+// placeholder param and the ids of all its descendants.
+func kategoriTurunan(param string) string {
+    return `WITH RECURSIVE turunan AS (
+            SELECT id FROM kategori WHERE id = ` + param + `
+            UNION ALL
+            SELECT k.id FROM kategori k JOIN turunan t ON k.parent_id = t.id
+        ) SELECT id FROM turunan`
+}
+
+// kategoriPohonCTE pairs every kategori (akar) with itself and all of its
+// descendants (kategori_id), so joining on kategori_id rolls totals up the tree.
+const kategoriPohonCTE = `WITH RECURSIVE pohon AS (
+        SELECT id AS akar, id AS kategori_id FROM kategori
+        UNION ALL
+        SELECT p.akar, k.id FROM kategori k JOIN pohon p ON k.parent_id = p.kategori_id
+    )`
+
+// GetAll returns all categories ordered by name.
+func (r *KategoriRepo) GetAll(ctx context.Context) ([]models.Kategori, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT id, nama, parent_id FROM kategori ORDER BY nama ASC, id ASC`)
+    if err != nil { return nil, fmt.Errorf("query kategori: %w", err) }
+    defer rows.Close()
+    list := make([]models.Kategori, 0)
+    for rows.Next() {
+        var k models.Kategori
+        var parent sql.NullInt64
+        if err := rows.Scan(&k.ID, &k.Nama, &parent); err != nil {
+            return nil, fmt.Errorf("scan kategori: %w", err)
+        }
+        k.ParentID = nullInt64Ptr(parent)
+        list = append(list, k)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+// Tree nests the flat list returned by GetAll under their parents.
+func (r *KategoriRepo) Tree(ctx context.Context) ([]models.Kategori, error) {
+    list, err := r.GetAll(ctx)
+    if err != nil { return nil, err }
+    children := make(map[int64][]models.Kategori)
+    roots := make([]models.Kategori, 0)
+    for _, k := range list {
+        if k.ParentID == nil {
+            roots = append(roots, k)
+        } else {
+            children[*k.ParentID] = append(children[*k.ParentID], k)
+        }
+    }
+    var build func(k models.Kategori) models.Kategori
+    build = func(k models.Kategori) models.Kategori {
+        for _, c := range children[k.ID] {
+            k.Children = append(k.Children, build(c))
+        }
+        return k
+    }
+    for i := range roots {
+        roots[i] = build(roots[i])
+    }
+    return roots, nil
+}
+
+// GetByID returns a kategori, or nil when not found.
+func (r *KategoriRepo) GetByID(ctx context.Context, id int64) (*models.Kategori, error) {
+    var k models.Kategori
+    var parent sql.NullInt64
+    err := r.DB.QueryRowContext(ctx, `SELECT id, nama, parent_id FROM kategori WHERE id=$1`, id).Scan(&k.ID, &k.Nama, &parent)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+    k.ParentID = nullInt64Ptr(parent)
+    return &k, nil
+}
+
+func (r *KategoriRepo) Create(ctx context.Context, k *models.Kategori) error {
+    err := r.DB.QueryRowContext(ctx, `INSERT INTO kategori (nama, parent_id) VALUES ($1,$2) RETURNING id`, k.Nama, k.ParentID).Scan(&k.ID)
+    return kategoriError(err)
+}
+
+// Update renames or moves k. A kategori cannot be moved under itself or one
+// of its descendants.
+func (r *KategoriRepo) Update(ctx context.Context, k *models.Kategori) error {
+    if k.ParentID != nil {
+        var cycle bool
+        if err := r.DB.QueryRowContext(ctx, `SELECT $2 IN (`+kategoriTurunan("$1")+`)`, k.ID, *k.ParentID).Scan(&cycle); err != nil {
+            return err
+        }
+        if cycle {
+            return fmt.Errorf("%w: parent_id cannot be the kategori itself or one of its descendants", apperr.ErrValidation)
+        }
+    }
+    res, err := r.DB.ExecContext(ctx, `UPDATE kategori SET nama=$1, parent_id=$2 WHERE id=$3`, k.Nama, k.ParentID, k.ID)
+    if err != nil { return kategoriError(err) }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+// Delete removes an empty kategori; one still holding barang or
+// sub-categories is rejected with ErrKategoriInUse.
+func (r *KategoriRepo) Delete(ctx context.Context, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `DELETE FROM kategori WHERE id=$1`, id)
+    if err != nil {
+        if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23503" {
+            return ErrKategoriInUse
+        }
+        return err
+    }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+func kategoriError(err error) error {
+    if pqErr, ok := err.(*pq.Error); ok {
+        switch string(pqErr.Code) {
+        case "23503":
+            return fmt.Errorf("%w: parent kategori not found", apperr.ErrValidation)
+        case "23505":
+            return fmt.Errorf("%w: kategori with this nama already exists under the same parent", apperr.ErrValidation)
+        }
+    }
+    return err
+}
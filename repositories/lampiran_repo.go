@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"warehouse/models"
+)
+
+// lampiranTabel maps the entitas of an attachment to its header table.
+var lampiranTabel = map[string]string{
+    "pembelian": "beli_header",
+    "penjualan": "jual_header",
+}
+
+type LampiranRepo struct {
+    DB *sql.DB
+}
+
+func NewLampiranRepo(db *sql.DB) *LampiranRepo { return &LampiranRepo{DB: db} }
+
+// Create records an attachment whose file is already stored under
+// l.StorageKey. It returns sql.ErrNoRows when the transaction does not exist.
+func (r *LampiranRepo) Create(ctx context.Context, l *models.Lampiran) error {
+    tabel, ok := lampiranTabel[l.Entitas]
+    if !ok { return fmt.Errorf("lampiran: unknown entitas %q", l.Entitas) }
+    q := `INSERT INTO lampiran (entitas, entitas_id, nama_file, content_type, ukuran, storage_key, user_id)
+        SELECT $1, h.id, $3, $4, $5, $6, $7 FROM ` + tabel + ` h WHERE h.id = $2
+        RETURNING id, created_at`
+    return r.DB.QueryRowContext(ctx, q, l.Entitas, l.EntitasID, l.NamaFile, l.ContentType, l.Ukuran, l.StorageKey, l.UserID).
+        Scan(&l.ID, &l.CreatedAt)
+}
+
+// List returns the attachments of one transaction, oldest first.
+func (r *LampiranRepo) List(ctx context.Context, entitas string, entitasID int64) ([]models.Lampiran, error) {
+    const q = `SELECT id, entitas, entitas_id, nama_file, content_type, ukuran, storage_key, user_id, created_at
+        FROM lampiran WHERE entitas = $1 AND entitas_id = $2 ORDER BY id ASC`
+    rows, err := r.DB.QueryContext(ctx, q, entitas, entitasID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    list := []models.Lampiran{}
+    for rows.Next() {
+        var l models.Lampiran
+        if err := rows.Scan(&l.ID, &l.Entitas, &l.EntitasID, &l.NamaFile, &l.ContentType, &l.Ukuran, &l.StorageKey, &l.UserID, &l.CreatedAt); err != nil {
+            return nil, err
+        }
+        list = append(list, l)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return list, nil
+}
+
+// GetByID returns nil when the attachment does not exist.
+func (r *LampiranRepo) GetByID(ctx context.Context, id int64) (*models.Lampiran, error) {
+    const q = `SELECT id, entitas, entitas_id, nama_file, content_type, ukuran, storage_key, user_id, created_at
+        FROM lampiran WHERE id = $1`
+    var l models.Lampiran
+    err := r.DB.QueryRowContext(ctx, q, id).
+        Scan(&l.ID, &l.Entitas, &l.EntitasID, &l.NamaFile, &l.ContentType, &l.Ukuran, &l.StorageKey, &l.UserID, &l.CreatedAt)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+    return &l, nil
+}
+
+// Delete removes the attachment row; the caller deletes its file.
+func (r *LampiranRepo) Delete(ctx context.Context, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `DELETE FROM lampiran WHERE id=$1`, id)
+    if err != nil { return err }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"warehouse/apperr"
+	"warehouse/models"
+)
+
+// kodePPN is the tarif_pajak code used for Pajak Pertambahan Nilai.
+const kodePPN = "PPN"
+
+type PajakRepo struct {
+    DB *sql.DB
+    // Lokasi is the time zone "today" is read in, the same as the reports.
+    Lokasi *time.Location
+}
+
+func NewPajakRepo(db *sql.DB, loc *time.Location) *PajakRepo { return &PajakRepo{DB: db, Lokasi: loc} }
+
+// ListTarif returns all PPN rates, newest effective date first.
+func (r *PajakRepo) ListTarif(ctx context.Context) ([]models.TarifPajak, error) {
+    const q = `SELECT id, kode, tarif_bp, berlaku_mulai, created_at
+        FROM tarif_pajak WHERE kode = $1 ORDER BY berlaku_mulai DESC`
+    rows, err := r.DB.QueryContext(ctx, q, kodePPN)
+    if err != nil { return nil, fmt.Errorf("query tarif: %w", err) }
+    defer rows.Close()
+    list := make([]models.TarifPajak, 0)
+    for rows.Next() {
+        var t models.TarifPajak
+        if err := rows.Scan(&t.ID, &t.Kode, &t.TarifBP, &t.BerlakuMulai, &t.CreatedAt); err != nil {
+            return nil, fmt.Errorf("scan tarif: %w", err)
+        }
+        list = append(list, t)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+// CreateTarif schedules a new PPN rate. Rates cannot be back-dated, so
+// invoices that already exist keep the rate they were issued with. A rate
+// scheduled for the same date is replaced, but only while it has not taken
+// effect yet.
+func (r *PajakRepo) CreateTarif(ctx context.Context, t *models.TarifPajak) error {
+    if t.TarifBP < 0 || t.TarifBP > 10000 {
+        return fmt.Errorf("%w: tarif_bp must be between 0 and 10000", apperr.ErrValidation)
+    }
+    today := hariIni(time.Now(), r.Lokasi)
+    if t.BerlakuMulai.Before(today) {
+        return fmt.Errorf("%w: berlaku_mulai cannot be in the past", apperr.ErrValidation)
+    }
+    t.Kode = kodePPN
+    const q = `INSERT INTO tarif_pajak (kode, tarif_bp, berlaku_mulai) VALUES ($1,$2,$3)
+        ON CONFLICT (tenant_id, kode, berlaku_mulai) DO UPDATE SET tarif_bp = EXCLUDED.tarif_bp
+            WHERE tarif_pajak.berlaku_mulai > $4::date
+        RETURNING id, created_at`
+    err := r.DB.QueryRowContext(ctx, q, t.Kode, t.TarifBP, t.BerlakuMulai, today.Format("2006-01-02")).Scan(&t.ID, &t.CreatedAt)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("%w: the rate from %s is already in effect and cannot be changed", apperr.ErrValidation, t.BerlakuMulai.Format("2006-01-02"))
+    }
+    return err
+}
+
+// hariIni returns the date of now in loc as midnight UTC, the form dates
+// parsed from YYYY-MM-DD take.
+func hariIni(now time.Time, loc *time.Location) time.Time {
+    if loc == nil { loc = time.UTC }
+    y, m, d := now.In(loc).Date()
+    return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// tarifPPNBerlaku returns the PPN rate (basis points) effective on the given date,
+// or 0 when no rate has been configured yet.
+func tarifPPNBerlaku(ctx context.Context, tx *sql.Tx, at time.Time) (int64, error) {
+    const q = `SELECT tarif_bp FROM tarif_pajak
+        WHERE kode = $1 AND berlaku_mulai <= $2
+        ORDER BY berlaku_mulai DESC LIMIT 1`
+    var bp int64
+    if err := tx.QueryRowContext(ctx, q, kodePPN, at).Scan(&bp); err != nil {
+        if err == sql.ErrNoRows { return 0, nil }
+        return 0, err
+    }
+    return bp, nil
+}
+
+// hitungPajak splits a line subtotal into DPP and PPN.
+// Exempt lines carry no PPN. For tax-inclusive prices the subtotal already
+// contains PPN, so DPP is backed out of it; otherwise PPN is added on top.
+// Amounts are rounded half-up to whole Rupiah.
+func hitungPajak(subtotal, tarifBP int64, kenaPajak, termasukPajak bool) (dpp, ppn int64) {
+    if !kenaPajak || tarifBP == 0 {
+        return subtotal, 0
+    }
+    if termasukPajak {
+        dpp = (subtotal*10000 + (10000+tarifBP)/2) / (10000 + tarifBP)
+        return dpp, subtotal - dpp
+    }
+    return subtotal, (subtotal*tarifBP + 5000) / 10000
+}
+
+// LaporanPPN builds the PPN keluaran/masukan report for [from, to).
+func (r *PajakRepo) LaporanPPN(ctx context.Context, from, to time.Time) (*models.LaporanPPN, error) {
+    keluaran, err := r.ringkasanPPN(ctx, "jual_header", "jual_detail", "jual_header_id", "customer", from, to)
+    if err != nil { return nil, fmt.Errorf("ppn keluaran: %w", err) }
+    masukan, err := r.ringkasanPPN(ctx, "beli_header", "beli_detail", "beli_header_id", "supplier", from, to)
+    if err != nil { return nil, fmt.Errorf("ppn masukan: %w", err) }
+    return &models.LaporanPPN{
+        Periode:     from.Format("2006-01"),
+        Keluaran:    *keluaran,
+        Masukan:     *masukan,
+        PPNTerutang: keluaran.PPN - masukan.PPN,
+    }, nil
+}
+
+func (r *PajakRepo) ringkasanPPN(ctx context.Context, headerTbl, detailTbl, fk, pihakCol string, from, to time.Time) (*models.RingkasanPPN, error) {
+    q := fmt.Sprintf(`SELECT h.id, h.no_faktur, h.%s, h.created_at, h.tarif_ppn_bp, h.dpp, h.ppn, h.grand_total,
+            COALESCE((SELECT SUM(d.dpp) FROM %s d WHERE d.%s = h.id AND NOT d.kena_pajak), 0)
+        FROM %s h
+        WHERE h.created_at >= $1 AND h.created_at < $2
+        ORDER BY h.created_at ASC`, pihakCol, detailTbl, fk, headerTbl)
+    rows, err := r.DB.QueryContext(ctx, q, from, to)
+    if err != nil { return nil, err }
+    defer rows.Close()
+
+    res := &models.RingkasanPPN{Faktur: make([]models.FakturPPN, 0)}
+    for rows.Next() {
+        var f models.FakturPPN
+        var bebas int64
+        if err := rows.Scan(&f.ID, &f.NoFaktur, &f.Pihak, &f.Tanggal, &f.TarifBP, &f.DPP, &f.PPN, &f.GrandTotal, &bebas); err != nil {
+            return nil, err
+        }
+        res.JumlahFaktur++
+        res.DPP += f.DPP - bebas
+        res.DPPBebasPajak += bebas
+        res.PPN += f.PPN
+        res.Faktur = append(res.Faktur, f)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return res, nil
+}
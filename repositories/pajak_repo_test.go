@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHitungPajak(t *testing.T) {
+    tests := []struct {
+        name                     string
+        subtotal, tarif          int64
+        kenaPajak, termasukPajak bool
+        dpp, ppn                 int64
+    }{
+        {"not taxable", 10000, 1100, false, false, 10000, 0},
+        {"no rate", 10000, 0, true, true, 10000, 0},
+        {"exclusive", 10000, 1100, true, false, 10000, 1100},
+        {"exclusive rounds half up", 50, 1100, true, false, 50, 6},
+        {"exclusive rounds down", 1, 1100, true, false, 1, 0},
+        {"inclusive", 11100, 1100, true, true, 10000, 1100},
+        {"inclusive rounds dpp", 100, 1100, true, true, 90, 10},
+        {"inclusive 12%", 11200, 1200, true, true, 10000, 1200},
+        {"zero subtotal", 0, 1100, true, false, 0, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dpp, ppn := hitungPajak(tt.subtotal, tt.tarif, tt.kenaPajak, tt.termasukPajak)
+            if dpp != tt.dpp || ppn != tt.ppn {
+                t.Errorf("hitungPajak(%d, %d, %v, %v) = %d, %d; want %d, %d",
+                    tt.subtotal, tt.tarif, tt.kenaPajak, tt.termasukPajak, dpp, ppn, tt.dpp, tt.ppn)
+            }
+            if tt.termasukPajak && dpp+ppn != tt.subtotal {
+                t.Errorf("dpp + ppn = %d, want subtotal %d", dpp+ppn, tt.subtotal)
+            }
+        })
+    }
+}
+
+func TestHariIni(t *testing.T) {
+    wib := time.FixedZone("WIB", 7*60*60)
+    tests := []struct {
+        name string
+        now  time.Time
+        loc  *time.Location
+        want string
+    }{
+        {"early morning WIB is still the previous day in UTC", time.Date(2026, 1, 1, 1, 30, 0, 0, wib), wib, "2026-01-01"},
+        {"late evening UTC is the next day in WIB", time.Date(2026, 3, 31, 20, 0, 0, 0, time.UTC), wib, "2026-04-01"},
+        {"midday", time.Date(2026, 3, 31, 12, 0, 0, 0, wib), wib, "2026-03-31"},
+        {"no location is UTC", time.Date(2026, 1, 1, 1, 30, 0, 0, wib), nil, "2025-12-31"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := hariIni(tt.now, tt.loc)
+            want, _ := time.Parse("2006-01-02", tt.want)
+            if !got.Equal(want) {
+                t.Errorf("hariIni = %v, want %v", got, want)
+            }
+        })
+    }
+}
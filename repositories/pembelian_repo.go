@@ -56,7 +56,7 @@ func (r *PembelianRepo) GetAll(ctx context.Context, from, to *time.Time, page, l
         return nil, 0, fmt.Errorf("count headers: %w", err)
     }
 
-    dataQ := "SELECT id, no_faktur, supplier, total, user_id, status, created_at FROM beli_header"
+    dataQ := "SELECT id, no_faktur, supplier, total, tarif_ppn_bp, dpp, ppn, grand_total, user_id, status, created_at FROM beli_header"
     if len(where) > 0 {
         dataQ += " WHERE " + strings.Join(where, " AND ")
     }
@@ -73,7 +73,7 @@ func (r *PembelianRepo) GetAll(ctx context.Context, from, to *time.Time, page, l
     list := make([]models.BeliHeader, 0)
     for rows.Next() {
         var h models.BeliHeader
-        if err := rows.Scan(&h.ID, &h.NoFaktur, &h.Supplier, &h.Total, &h.UserID, &h.Status, &h.CreatedAt); err != nil {
+        if err := rows.Scan(&h.ID, &h.NoFaktur, &h.Supplier, &h.Total, &h.TarifPPN, &h.DPP, &h.PPN, &h.GrandTotal, &h.UserID, &h.Status, &h.CreatedAt); err != nil {
             return nil, 0, fmt.Errorf("scan header: %w", err)
         }
         list = append(list, h)
@@ -85,15 +85,15 @@ func (r *PembelianRepo) GetAll(ctx context.Context, from, to *time.Time, page, l
 }
 
 func (r *PembelianRepo) GetByID(ctx context.Context, id int64) (*models.BeliHeader, error) {
-    const qHeader = `SELECT h.id, h.no_faktur, h.supplier, h.total, h.user_id, h.status, h.created_at,
-                            u.id, u.username, u.password, u.email, u.full_name, u.role
+    const qHeader = `SELECT h.id, h.no_faktur, h.supplier, h.total, h.tarif_ppn_bp, h.dpp, h.ppn, h.grand_total, h.user_id, h.status, h.created_at,
+                            u.id, u.username, u.password, u.email, u.full_name, ` + roleDiTenant + `
                      FROM beli_header h
                      JOIN users u ON u.id = h.user_id
                      WHERE h.id = $1`
     var h models.BeliHeader
     var u models.User
     if err := r.DB.QueryRowContext(ctx, qHeader, id).Scan(
-        &h.ID, &h.NoFaktur, &h.Supplier, &h.Total, &h.UserID, &h.Status, &h.CreatedAt,
+        &h.ID, &h.NoFaktur, &h.Supplier, &h.Total, &h.TarifPPN, &h.DPP, &h.PPN, &h.GrandTotal, &h.UserID, &h.Status, &h.CreatedAt,
         &u.ID, &u.Username, &u.Password, &u.Email, &u.FullName, &u.Role,
     ); err != nil {
         if err == sql.ErrNoRows {
@@ -103,7 +103,7 @@ func (r *PembelianRepo) GetByID(ctx context.Context, id int64) (*models.BeliHead
     }
     h.UserDetail = &u
 
-    const qDetail = `SELECT d.id, d.beli_header_id, d.barang_id, d.qty, d.harga, d.subtotal,
+    const qDetail = `SELECT d.id, d.beli_header_id, d.barang_id, d.qty, d.harga, d.subtotal, d.kena_pajak, d.dpp, d.ppn,
                             b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual
                      FROM beli_detail d
                      JOIN master_barang b ON b.id = d.barang_id
@@ -121,7 +121,7 @@ func (r *PembelianRepo) GetByID(ctx context.Context, id int64) (*models.BeliHead
         var b models.Barang
         var desc sql.NullString
         if err := rows.Scan(
-            &d.ID, &d.BeliHeaderID, &d.BarangID, &d.Qty, &d.Harga, &d.Subtotal,
+            &d.ID, &d.BeliHeaderID, &d.BarangID, &d.Qty, &d.Harga, &d.Subtotal, &d.KenaPajak, &d.DPP, &d.PPN,
             &b.ID, &b.KodeBarang, &b.NamaBarang, &desc, &b.Satuan, &b.HargaBeli, &b.HargaJual,
         ); err != nil {
             return nil, fmt.Errorf("scan detail: %w", err)
@@ -156,33 +156,60 @@ func (r *PembelianRepo) CreatePembelianTx(ctx context.Context, hdr *models.BeliH
     }
     hdr.NoFaktur = faktur
 
+    now := time.Now()
+    termasukPajak := make([]bool, len(hdr.Details))
     for i := range hdr.Details {
         d := &hdr.Details[i]
-        var exists bool
+        if err := resolveBarcode(ctx, tx, d.Barcode, &d.BarangID, &d.Qty); err != nil {
+            return rollback(fmt.Errorf("%w (detail index %d)", err, i))
+        }
+        // Start/end any scheduled price change that is due so the line uses the current price.
+        if err := terapkanJadwalHarga(ctx, tx, d.BarangID, now); err != nil {
+            return rollback(fmt.Errorf("jadwal harga: %w", err))
+        }
+        var arsip, virtual bool
         var hargaBeli int64
-        if err := tx.QueryRowContext(ctx, "SELECT 1, harga_beli FROM master_barang WHERE id=$1", d.BarangID).Scan(&exists, &hargaBeli); err != nil {
+        if err := tx.QueryRowContext(ctx, "SELECT archived_at IS NOT NULL, bundel_virtual, harga_beli, kena_pajak, harga_termasuk_pajak FROM master_barang WHERE id=$1", d.BarangID).Scan(&arsip, &virtual, &hargaBeli, &d.KenaPajak, &termasukPajak[i]); err != nil {
             if err == sql.ErrNoRows {
                 return rollback(fmt.Errorf("%w: barang id %d not found (detail index %d)", apperr.ErrNotFound, d.BarangID, i))
             }
             return rollback(fmt.Errorf("validate barang: %w", err))
         }
+        if arsip {
+            return rollback(fmt.Errorf("%w: barang id %d is archived (detail index %d)", apperr.ErrValidation, d.BarangID, i))
+        }
+        if virtual {
+            return rollback(fmt.Errorf("%w: barang id %d is a virtual bundle; buy its components (detail index %d)", apperr.ErrValidation, d.BarangID, i))
+        }
         d.Harga = hargaBeli
     }
 
-    var total int64
+    tarif, err := tarifPPNBerlaku(ctx, tx, now)
+    if err != nil {
+        return rollback(fmt.Errorf("tarif ppn: %w", err))
+    }
+    hdr.TarifPPN = tarif
+
+    var total, dpp, ppn int64
     for i := range hdr.Details {
         d := &hdr.Details[i]
         if d.Qty <= 0 { return rollback(fmt.Errorf("%w: qty must be > 0 for barang %d", apperr.ErrValidation, d.BarangID)) }
         if d.Harga < 0 { return rollback(fmt.Errorf("%w: harga must be >= 0 for barang %d", apperr.ErrValidation, d.BarangID)) }
         if d.Subtotal == 0 { d.Subtotal = d.Qty * d.Harga }
+        d.DPP, d.PPN = hitungPajak(d.Subtotal, tarif, d.KenaPajak, termasukPajak[i])
         total += d.Subtotal
+        dpp += d.DPP
+        ppn += d.PPN
     }
     hdr.Total = total
+    hdr.DPP = dpp
+    hdr.PPN = ppn
+    hdr.GrandTotal = dpp + ppn
     if hdr.Status == "" { hdr.Status = "completed" }
 
-    err = tx.QueryRowContext(ctx, `INSERT INTO beli_header (no_faktur, supplier, total, user_id, status)
-            VALUES ($1,$2,$3,$4,$5) RETURNING id, created_at`,
-        hdr.NoFaktur, hdr.Supplier, hdr.Total, hdr.UserID, hdr.Status,
+    err = tx.QueryRowContext(ctx, `INSERT INTO beli_header (no_faktur, supplier, total, tarif_ppn_bp, dpp, ppn, grand_total, user_id, status)
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id, created_at`,
+        hdr.NoFaktur, hdr.Supplier, hdr.Total, hdr.TarifPPN, hdr.DPP, hdr.PPN, hdr.GrandTotal, hdr.UserID, hdr.Status,
     ).Scan(&hdr.ID, &hdr.CreatedAt)
     if err != nil { return rollback(fmt.Errorf("insert header: %w", err)) }
 
@@ -196,9 +223,9 @@ func (r *PembelianRepo) CreatePembelianTx(ctx context.Context, hdr *models.BeliH
         var before int64
         if stokBefore.Valid { before = stokBefore.Int64 }
         after := before + d.Qty
-        err = tx.QueryRowContext(ctx, `INSERT INTO beli_detail (beli_header_id, barang_id, qty, harga, subtotal)
-                VALUES ($1,$2,$3,$4,$5) RETURNING id`,
-            hdr.ID, d.BarangID, d.Qty, d.Harga, d.Subtotal,
+        err = tx.QueryRowContext(ctx, `INSERT INTO beli_detail (beli_header_id, barang_id, qty, harga, subtotal, kena_pajak, dpp, ppn)
+                VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id`,
+            hdr.ID, d.BarangID, d.Qty, d.Harga, d.Subtotal, d.KenaPajak, d.DPP, d.PPN,
         ).Scan(&d.ID)
         if err != nil { return rollback(fmt.Errorf("insert detail: %w", err)) }
         if stokBefore.Valid {
@@ -211,10 +238,7 @@ func (r *PembelianRepo) CreatePembelianTx(ctx context.Context, hdr *models.BeliH
             _, iErr := tx.ExecContext(ctx, "INSERT INTO mstok (barang_id, stok_akhir) VALUES ($1,$2)", d.BarangID, after)
             if iErr != nil { return rollback(fmt.Errorf("insert mstok: %w", iErr)) }
         }
-        _, hErr := tx.ExecContext(ctx, `INSERT INTO history_stok (barang_id, user_id, jenis_transaksi, jumlah, stok_sebelum, stok_sesudah)
-                VALUES ($1,$2,$3,$4,$5,$6)`,
-            d.BarangID, hdr.UserID, "pembelian", d.Qty, before, after,
-        )
+        hErr := catatHistoriStok(ctx, tx, d.BarangID, hdr.UserID, "pembelian", d.Qty, before, after)
         if hErr != nil { return rollback(fmt.Errorf("insert history: %w", hErr)) }
     }
     if err = tx.Commit(); err != nil {
@@ -226,6 +250,18 @@ func (r *PembelianRepo) CreatePembelianTx(ctx context.Context, hdr *models.BeliH
 
 // GetReport returns pembelian headers filtered by optional date range.
 func (r *PembelianRepo) GetReport(ctx context.Context, from, to *time.Time) ([]models.BeliHeader, error) {
+    list := make([]models.BeliHeader, 0)
+    err := r.EachReport(ctx, from, to, func(h models.BeliHeader) error {
+        list = append(list, h)
+        return nil
+    })
+    if err != nil { return nil, err }
+    return list, nil
+}
+
+// EachReport calls fn for every header in the optional date range, newest
+// first, reading rows one at a time.
+func (r *PembelianRepo) EachReport(ctx context.Context, from, to *time.Time, fn func(models.BeliHeader) error) error {
     where := make([]string, 0)
     args := make([]interface{}, 0)
     idx := 1
@@ -239,24 +275,23 @@ func (r *PembelianRepo) GetReport(ctx context.Context, from, to *time.Time) ([]m
         args = append(args, *to)
         idx++
     }
-    q := "SELECT id, no_faktur, supplier, total, user_id, status, created_at FROM beli_header"
+    q := "SELECT id, no_faktur, supplier, total, tarif_ppn_bp, dpp, ppn, grand_total, user_id, status, created_at FROM beli_header"
     if len(where) > 0 {
         q += " WHERE " + strings.Join(where, " AND ")
     }
     q += " ORDER BY created_at DESC"
 
     rows, err := r.DB.QueryContext(ctx, q, args...)
-    if err != nil { return nil, fmt.Errorf("query report: %w", err) }
+    if err != nil { return fmt.Errorf("query report: %w", err) }
     defer rows.Close()
 
-    list := make([]models.BeliHeader, 0)
     for rows.Next() {
         var h models.BeliHeader
-        if err := rows.Scan(&h.ID, &h.NoFaktur, &h.Supplier, &h.Total, &h.UserID, &h.Status, &h.CreatedAt); err != nil {
-            return nil, fmt.Errorf("scan header: %w", err)
+        if err := rows.Scan(&h.ID, &h.NoFaktur, &h.Supplier, &h.Total, &h.TarifPPN, &h.DPP, &h.PPN, &h.GrandTotal, &h.UserID, &h.Status, &h.CreatedAt); err != nil {
+            return fmt.Errorf("scan header: %w", err)
         }
-        list = append(list, h)
+        if err := fn(h); err != nil { return err }
     }
-    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
-    return list, nil
+    if err := rows.Err(); err != nil { return fmt.Errorf("rows err: %w", err) }
+    return nil
 }
@@ -10,6 +10,7 @@ import (
 
 	"warehouse/apperr"
 	"warehouse/models"
+	"warehouse/promo"
 )
 
 type PenjualanRepo struct {
@@ -52,39 +53,40 @@ func (r *PenjualanRepo) CreatePenjualanTx(ctx context.Context, hdr *models.JualH
     }
     hdr.NoFaktur = faktur
 
-    for i := range hdr.Details {
-        d := &hdr.Details[i]
-        var exists bool
-        var hargaJual int64
-        if err := tx.QueryRowContext(ctx, "SELECT 1, harga_jual FROM master_barang WHERE id=$1", d.BarangID).Scan(&exists, &hargaJual); err != nil {
-            if err == sql.ErrNoRows {
-                return rollback(fmt.Errorf("barang id %d not found (detail index %d)", d.BarangID, i))
-            }
-            return rollback(fmt.Errorf("validate barang: %w", err))
-        }
-        d.Harga = hargaJual
-    }
-
-    var total int64
-    for i := range hdr.Details {
-        d := &hdr.Details[i]
-        if d.Qty <= 0 { return rollback(fmt.Errorf("%w: qty must be > 0 for barang %d", apperr.ErrValidation, d.BarangID)) }
-        if d.Harga < 0 { return rollback(fmt.Errorf("%w: harga must be >= 0 for barang %d", apperr.ErrValidation, d.BarangID)) }
-        if d.Subtotal == 0 { d.Subtotal = d.Qty * d.Harga }
-        total += d.Subtotal
+    if err := hitungPenjualan(ctx, tx, hdr, time.Now(), true); err != nil {
+        return rollback(err)
     }
-    hdr.Total = total
     if hdr.Status == "" { hdr.Status = "completed" }
 
-    if err := tx.QueryRowContext(ctx, `INSERT INTO jual_header (no_faktur, customer, total, user_id, status)
-            VALUES ($1,$2,$3,$4,$5) RETURNING id, created_at`,
-        hdr.NoFaktur, hdr.Customer, hdr.Total, hdr.UserID, hdr.Status,
+    if err := tx.QueryRowContext(ctx, `INSERT INTO jual_header (no_faktur, customer, grup_pelanggan_id, total, diskon, promo_id, tarif_ppn_bp, dpp, ppn, grand_total, user_id, status)
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING id, created_at`,
+        hdr.NoFaktur, hdr.Customer, hdr.GrupPelangganID, hdr.Total, hdr.Diskon, hdr.PromoID, hdr.TarifPPN, hdr.DPP, hdr.PPN, hdr.GrandTotal, hdr.UserID, hdr.Status,
     ).Scan(&hdr.ID, &hdr.CreatedAt); err != nil {
         return rollback(fmt.Errorf("insert header: %w", err))
     }
 
     for i := range hdr.Details {
         d := &hdr.Details[i]
+        d.JualHeaderID = hdr.ID
+        if err := tx.QueryRowContext(ctx, `INSERT INTO jual_detail (jual_header_id, barang_id, qty, harga, diskon, subtotal, promo_id, daftar_harga_id, kena_pajak, dpp, ppn)
+                VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING id`,
+            hdr.ID, d.BarangID, d.Qty, d.Harga, d.Diskon, d.Subtotal, d.PromoID, d.DaftarHargaID, d.KenaPajak, d.DPP, d.PPN,
+        ).Scan(&d.ID); err != nil {
+            return rollback(fmt.Errorf("insert detail: %w", err))
+        }
+
+        // A virtual bundle has no stock of its own; its components are taken out instead.
+        komponen, err := komponenVirtual(ctx, tx, d.BarangID)
+        if err != nil { return rollback(fmt.Errorf("load bundel: %w", err)) }
+        if komponen != nil {
+            for _, k := range komponen {
+                if err := ubahStok(ctx, tx, k.BarangID, -d.Qty*k.Qty, hdr.UserID, "penjualan_bundel"); err != nil {
+                    return rollback(fmt.Errorf("%w (bundel %d, detail index %d)", err, d.BarangID, i))
+                }
+            }
+            continue
+        }
+
         var stokBefore sql.NullInt64
         if err := tx.QueryRowContext(ctx, "SELECT stok_akhir FROM mstok WHERE barang_id=$1 FOR UPDATE", d.BarangID).Scan(&stokBefore); err != nil && err != sql.ErrNoRows {
             return rollback(fmt.Errorf("lock stock: %w", err))
@@ -96,23 +98,13 @@ func (r *PenjualanRepo) CreatePenjualanTx(ctx context.Context, hdr *models.JualH
         }
         after := before - d.Qty
 
-        if err := tx.QueryRowContext(ctx, `INSERT INTO jual_detail (jual_header_id, barang_id, qty, harga, subtotal)
-                VALUES ($1,$2,$3,$4,$5) RETURNING id`,
-            hdr.ID, d.BarangID, d.Qty, d.Harga, d.Subtotal,
-        ).Scan(&d.ID); err != nil {
-            return rollback(fmt.Errorf("insert detail: %w", err))
-        }
-
         res, uErr := tx.ExecContext(ctx, "UPDATE mstok SET stok_akhir=$1 WHERE barang_id=$2", after, d.BarangID)
         if uErr != nil { return rollback(fmt.Errorf("update mstok: %w", uErr)) }
         if rows, _ := res.RowsAffected(); rows == 0 {
             return rollback(errors.New("expected mstok update to affect 1 row"))
         }
 
-        if _, hErr := tx.ExecContext(ctx, `INSERT INTO history_stok (barang_id, user_id, jenis_transaksi, jumlah, stok_sebelum, stok_sesudah)
-                VALUES ($1,$2,$3,$4,$5,$6)`,
-            d.BarangID, hdr.UserID, "penjualan", d.Qty, before, after,
-        ); hErr != nil {
+        if hErr := catatHistoriStok(ctx, tx, d.BarangID, hdr.UserID, "penjualan", d.Qty, before, after); hErr != nil {
             return rollback(fmt.Errorf("insert history: %w", hErr))
         }
     }
@@ -124,6 +116,122 @@ func (r *PenjualanRepo) CreatePenjualanTx(ctx context.Context, hdr *models.JualH
     return nil
 }
 
+// PreviewPenjualan prices hdr exactly like CreatePenjualanTx (scheduled prices,
+// price lists, promos, PPN) in a read-only transaction: due scheduled prices
+// are computed but not applied. Stock is not checked.
+func (r *PenjualanRepo) PreviewPenjualan(ctx context.Context, hdr *models.JualHeader) error {
+    if hdr == nil { return errors.New("header is nil") }
+    if len(hdr.Details) == 0 { return errors.New("details empty") }
+
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    defer func() { _ = tx.Rollback() }()
+    return hitungPenjualan(ctx, tx, hdr, time.Now(), false)
+}
+
+type pajakBarang struct {
+    kenaPajak, termasukPajak bool
+}
+
+// hitungPenjualan prices every line of hdr inside tx: it applies due scheduled
+// price changes (or, when simpan is false, only computes them), resolves
+// customer-group price lists, evaluates active promos (which may move units
+// into free lines) and computes PPN on the discounted amounts.
+// Client-supplied prices, discounts and subtotals are ignored.
+func hitungPenjualan(ctx context.Context, tx *sql.Tx, hdr *models.JualHeader, now time.Time, simpan bool) error {
+    if hdr.GrupPelangganID != nil {
+        var exists bool
+        if err := tx.QueryRowContext(ctx, "SELECT TRUE FROM grup_pelanggan WHERE id=$1", *hdr.GrupPelangganID).Scan(&exists); err != nil {
+            if err == sql.ErrNoRows {
+                return fmt.Errorf("%w: grup pelanggan id %d not found", apperr.ErrValidation, *hdr.GrupPelangganID)
+            }
+            return fmt.Errorf("validate grup pelanggan: %w", err)
+        }
+    }
+
+    pajak := make(map[int64]pajakBarang)
+    for i := range hdr.Details {
+        d := &hdr.Details[i]
+        if err := resolveBarcode(ctx, tx, d.Barcode, &d.BarangID, &d.Qty); err != nil {
+            return fmt.Errorf("%w (detail index %d)", err, i)
+        }
+        if d.Qty <= 0 { return fmt.Errorf("%w: qty must be > 0 for barang %d", apperr.ErrValidation, d.BarangID) }
+        d.Diskon, d.Subtotal, d.PromoID = 0, 0, nil
+        // Start/end any scheduled price change that is due so the line uses the current price.
+        if simpan {
+            if err := terapkanJadwalHarga(ctx, tx, d.BarangID, now); err != nil {
+                return fmt.Errorf("jadwal harga: %w", err)
+            }
+        }
+        var hargaJual int64
+        var pb pajakBarang
+        var arsip bool
+        if err := tx.QueryRowContext(ctx, "SELECT harga_jual, kena_pajak, harga_termasuk_pajak, archived_at IS NOT NULL FROM master_barang WHERE id=$1", d.BarangID).Scan(&hargaJual, &pb.kenaPajak, &pb.termasukPajak, &arsip); err != nil {
+            if err == sql.ErrNoRows {
+                return fmt.Errorf("%w: barang id %d not found (detail index %d)", apperr.ErrNotFound, d.BarangID, i)
+            }
+            return fmt.Errorf("validate barang: %w", err)
+        }
+        if arsip {
+            return fmt.Errorf("%w: barang id %d is archived (detail index %d)", apperr.ErrValidation, d.BarangID, i)
+        }
+        if !simpan {
+            berlaku, err := hargaJualBerlaku(ctx, tx, d.BarangID, now, hargaJual)
+            if err != nil {
+                return fmt.Errorf("jadwal harga: %w", err)
+            }
+            hargaJual = berlaku
+        }
+        pajak[d.BarangID] = pb
+        // Price list tiers of the customer group override the default harga_jual.
+        harga, daftarID, err := hargaJualUntuk(ctx, tx, hdr.GrupPelangganID, d.BarangID, d.Qty, hargaJual)
+        if err != nil {
+            return fmt.Errorf("resolve harga: %w", err)
+        }
+        if harga < 0 { return fmt.Errorf("%w: harga must be >= 0 for barang %d", apperr.ErrValidation, d.BarangID) }
+        d.Harga = harga
+        d.DaftarHargaID = daftarID
+    }
+
+    rules, err := promoAktif(ctx, tx, now)
+    if err != nil {
+        return fmt.Errorf("load promo: %w", err)
+    }
+    hasil := promo.Terapkan(hdr.Details, rules)
+    hdr.Details = hasil.Details
+    hdr.Diskon = hasil.Diskon
+    hdr.PromoID = hasil.PromoID
+
+    tarif, err := tarifPPNBerlaku(ctx, tx, now)
+    if err != nil {
+        return fmt.Errorf("tarif ppn: %w", err)
+    }
+    hdr.TarifPPN = tarif
+
+    subtotals := make([]int64, len(hdr.Details))
+    for i, d := range hdr.Details {
+        subtotals[i] = d.Subtotal
+    }
+
+    // The header discount is spread over the lines so PPN is charged on what the customer pays.
+    alokasi := promo.BagiDiskon(subtotals, hdr.Diskon)
+    var total, dpp, ppn int64
+    for i := range hdr.Details {
+        d := &hdr.Details[i]
+        pb := pajak[d.BarangID]
+        d.KenaPajak = pb.kenaPajak
+        d.DPP, d.PPN = hitungPajak(d.Subtotal-alokasi[i], tarif, pb.kenaPajak, pb.termasukPajak)
+        total += d.Subtotal
+        dpp += d.DPP
+        ppn += d.PPN
+    }
+    hdr.Total = total
+    hdr.DPP = dpp
+    hdr.PPN = ppn
+    hdr.GrandTotal = dpp + ppn
+    return nil
+}
+
 func (r *PenjualanRepo) GetAll(ctx context.Context, from, to *time.Time, page, limit int) ([]models.JualHeader, int, error) {
     where := make([]string, 0)
     args := make([]interface{}, 0)
@@ -147,7 +255,7 @@ func (r *PenjualanRepo) GetAll(ctx context.Context, from, to *time.Time, page, l
         return nil, 0, fmt.Errorf("count headers: %w", err)
     }
 
-    dataQ := "SELECT id, no_faktur, customer, total, user_id, status, created_at FROM jual_header"
+    dataQ := "SELECT id, no_faktur, customer, total, diskon, tarif_ppn_bp, dpp, ppn, grand_total, user_id, status, created_at FROM jual_header"
     if len(where) > 0 {
         dataQ += " WHERE " + strings.Join(where, " AND ")
     }
@@ -162,7 +270,7 @@ func (r *PenjualanRepo) GetAll(ctx context.Context, from, to *time.Time, page, l
     list := make([]models.JualHeader, 0)
     for rows.Next() {
         var h models.JualHeader
-        if err := rows.Scan(&h.ID, &h.NoFaktur, &h.Customer, &h.Total, &h.UserID, &h.Status, &h.CreatedAt); err != nil {
+        if err := rows.Scan(&h.ID, &h.NoFaktur, &h.Customer, &h.Total, &h.Diskon, &h.TarifPPN, &h.DPP, &h.PPN, &h.GrandTotal, &h.UserID, &h.Status, &h.CreatedAt); err != nil {
             return nil, 0, fmt.Errorf("scan header: %w", err)
         }
         list = append(list, h)
@@ -172,23 +280,25 @@ func (r *PenjualanRepo) GetAll(ctx context.Context, from, to *time.Time, page, l
 }
 
 func (r *PenjualanRepo) GetByID(ctx context.Context, id int64) (*models.JualHeader, error) {
-    const qHeader = `SELECT h.id, h.no_faktur, h.customer, h.total, h.user_id, h.status, h.created_at,
-                            u.id, u.username, u.password, u.email, u.full_name, u.role
+    const qHeader = `SELECT h.id, h.no_faktur, h.customer, h.grup_pelanggan_id, h.total, h.diskon, h.promo_id, h.tarif_ppn_bp, h.dpp, h.ppn, h.grand_total, h.user_id, h.status, h.created_at,
+                            u.id, u.username, u.password, u.email, u.full_name, ` + roleDiTenant + `
                      FROM jual_header h
                      JOIN users u ON u.id = h.user_id
                      WHERE h.id = $1`
     var h models.JualHeader
     var u models.User
+    var grupID, promoID sql.NullInt64
     if err := r.DB.QueryRowContext(ctx, qHeader, id).Scan(
-        &h.ID, &h.NoFaktur, &h.Customer, &h.Total, &h.UserID, &h.Status, &h.CreatedAt,
+        &h.ID, &h.NoFaktur, &h.Customer, &grupID, &h.Total, &h.Diskon, &promoID, &h.TarifPPN, &h.DPP, &h.PPN, &h.GrandTotal, &h.UserID, &h.Status, &h.CreatedAt,
         &u.ID, &u.Username, &u.Password, &u.Email, &u.FullName, &u.Role,
     ); err != nil {
         if err == sql.ErrNoRows { return nil, nil }
         return nil, fmt.Errorf("get header: %w", err)
     }
+    h.GrupPelangganID, h.PromoID = nullInt64Ptr(grupID), nullInt64Ptr(promoID)
     h.UserDetail = &u
 
-    const qDetail = `SELECT d.id, d.jual_header_id, d.barang_id, d.qty, d.harga, d.subtotal,
+    const qDetail = `SELECT d.id, d.jual_header_id, d.barang_id, d.qty, d.harga, d.diskon, d.subtotal, d.promo_id, d.daftar_harga_id, d.kena_pajak, d.dpp, d.ppn,
                             b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual
                      FROM jual_detail d
                      JOIN master_barang b ON b.id = d.barang_id
@@ -201,13 +311,15 @@ func (r *PenjualanRepo) GetByID(ctx context.Context, id int64) (*models.JualHead
         var d models.JualDetail
         var b models.Barang
         var desc sql.NullString
+        var daftarID, promoID sql.NullInt64
         if err := rows.Scan(
-            &d.ID, &d.JualHeaderID, &d.BarangID, &d.Qty, &d.Harga, &d.Subtotal,
+            &d.ID, &d.JualHeaderID, &d.BarangID, &d.Qty, &d.Harga, &d.Diskon, &d.Subtotal, &promoID, &daftarID, &d.KenaPajak, &d.DPP, &d.PPN,
             &b.ID, &b.KodeBarang, &b.NamaBarang, &desc, &b.Satuan, &b.HargaBeli, &b.HargaJual,
         ); err != nil {
             return nil, fmt.Errorf("scan detail: %w", err)
         }
         if desc.Valid { v := desc.String; b.Deskripsi = &v }
+        d.DaftarHargaID, d.PromoID = nullInt64Ptr(daftarID), nullInt64Ptr(promoID)
         d.BarangDetail = &b
         details = append(details, d)
     }
@@ -218,6 +330,18 @@ func (r *PenjualanRepo) GetByID(ctx context.Context, id int64) (*models.JualHead
 
 // GetReport returns penjualan headers filtered by optional date range.
 func (r *PenjualanRepo) GetReport(ctx context.Context, from, to *time.Time) ([]models.JualHeader, error) {
+    list := make([]models.JualHeader, 0)
+    err := r.EachReport(ctx, from, to, func(h models.JualHeader) error {
+        list = append(list, h)
+        return nil
+    })
+    if err != nil { return nil, err }
+    return list, nil
+}
+
+// EachReport calls fn for every header in the optional date range, newest
+// first, reading rows one at a time.
+func (r *PenjualanRepo) EachReport(ctx context.Context, from, to *time.Time, fn func(models.JualHeader) error) error {
     where := make([]string, 0)
     args := make([]interface{}, 0)
     idx := 1
@@ -231,23 +355,130 @@ func (r *PenjualanRepo) GetReport(ctx context.Context, from, to *time.Time) ([]m
         args = append(args, *to)
         idx++
     }
-    q := "SELECT id, no_faktur, customer, total, user_id, status, created_at FROM jual_header"
+    q := "SELECT id, no_faktur, customer, total, diskon, tarif_ppn_bp, dpp, ppn, grand_total, user_id, status, created_at FROM jual_header"
     if len(where) > 0 {
         q += " WHERE " + strings.Join(where, " AND ")
     }
     q += " ORDER BY created_at DESC"
 
     rows, err := r.DB.QueryContext(ctx, q, args...)
-    if err != nil { return nil, fmt.Errorf("query report: %w", err) }
+    if err != nil { return fmt.Errorf("query report: %w", err) }
     defer rows.Close()
 
-    list := make([]models.JualHeader, 0)
     for rows.Next() {
         var h models.JualHeader
-        if err := rows.Scan(&h.ID, &h.NoFaktur, &h.Customer, &h.Total, &h.UserID, &h.Status, &h.CreatedAt); err != nil {
-            return nil, fmt.Errorf("scan header: %w", err)
+        if err := rows.Scan(&h.ID, &h.NoFaktur, &h.Customer, &h.Total, &h.Diskon, &h.TarifPPN, &h.DPP, &h.PPN, &h.GrandTotal, &h.UserID, &h.Status, &h.CreatedAt); err != nil {
+            return fmt.Errorf("scan header: %w", err)
         }
-        list = append(list, h)
+        if err := fn(h); err != nil { return err }
+    }
+    if err := rows.Err(); err != nil { return fmt.Errorf("rows err: %w", err) }
+    return nil
+}
+
+// GetReportPerKategori sums sold lines per kategori (including sub-categories)
+// within the optional date range; KategoriID nil holds uncategorized barang.
+func (r *PenjualanRepo) GetReportPerKategori(ctx context.Context, from, to *time.Time) ([]models.PenjualanKategori, error) {
+    where := make([]string, 0)
+    args := make([]interface{}, 0)
+    idx := 1
+    if from != nil {
+        where = append(where, fmt.Sprintf("h.created_at >= $%d", idx))
+        args = append(args, *from)
+        idx++
+    }
+    if to != nil {
+        where = append(where, fmt.Sprintf("h.created_at <= $%d", idx))
+        args = append(args, *to)
+        idx++
+    }
+    filter := ""
+    if len(where) > 0 {
+        filter = " AND " + strings.Join(where, " AND ")
+    }
+    q := kategoriPohonCTE + `,
+        baris AS (
+            SELECT d.jual_header_id, d.qty, d.subtotal, d.dpp, d.ppn, b.kategori_id
+            FROM jual_detail d
+            JOIN jual_header h ON h.id = d.jual_header_id
+            JOIN master_barang b ON b.id = d.barang_id
+            WHERE TRUE` + filter + `
+        )
+        SELECT k.id, k.nama, k.parent_id, COUNT(DISTINCT x.jual_header_id),
+            COALESCE(SUM(x.qty), 0), COALESCE(SUM(x.subtotal), 0), COALESCE(SUM(x.dpp), 0), COALESCE(SUM(x.ppn), 0)
+        FROM kategori k
+        JOIN pohon p ON p.akar = k.id
+        LEFT JOIN baris x ON x.kategori_id = p.kategori_id
+        GROUP BY k.id, k.nama, k.parent_id
+        UNION ALL
+        SELECT NULL, 'Tanpa Kategori', NULL, COUNT(DISTINCT x.jual_header_id),
+            COALESCE(SUM(x.qty), 0), COALESCE(SUM(x.subtotal), 0), COALESCE(SUM(x.dpp), 0), COALESCE(SUM(x.ppn), 0)
+        FROM baris x
+        WHERE x.kategori_id IS NULL
+        ORDER BY 2 ASC`
+
+    rows, err := r.DB.QueryContext(ctx, q, args...)
+    if err != nil { return nil, fmt.Errorf("query report: %w", err) }
+    defer rows.Close()
+
+    list := make([]models.PenjualanKategori, 0)
+    for rows.Next() {
+        var k models.PenjualanKategori
+        var id, parent sql.NullInt64
+        if err := rows.Scan(&id, &k.Nama, &parent, &k.JumlahFaktur, &k.Qty, &k.Subtotal, &k.DPP, &k.PPN); err != nil {
+            return nil, fmt.Errorf("scan report: %w", err)
+        }
+        k.KategoriID, k.ParentID = nullInt64Ptr(id), nullInt64Ptr(parent)
+        list = append(list, k)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+// GetReportPerInduk sums sold lines within the optional date range with
+// variants rolled up to their barang induk; barang without a parent are rows
+// of their own.
+func (r *PenjualanRepo) GetReportPerInduk(ctx context.Context, from, to *time.Time) ([]models.PenjualanInduk, error) {
+    where := make([]string, 0)
+    args := make([]interface{}, 0)
+    idx := 1
+    if from != nil {
+        where = append(where, fmt.Sprintf("h.created_at >= $%d", idx))
+        args = append(args, *from)
+        idx++
+    }
+    if to != nil {
+        where = append(where, fmt.Sprintf("h.created_at <= $%d", idx))
+        args = append(args, *to)
+        idx++
+    }
+    whereSQL := ""
+    if len(where) > 0 {
+        whereSQL = " WHERE " + strings.Join(where, " AND ")
+    }
+    q := `SELECT i.id, CASE WHEN i.id IS NULL THEN b.id END,
+            COALESCE(i.kode_induk, b.kode_barang), COALESCE(i.nama, b.nama_barang), COUNT(DISTINCT d.jual_header_id),
+            COALESCE(SUM(d.qty), 0), COALESCE(SUM(d.subtotal), 0), COALESCE(SUM(d.dpp), 0), COALESCE(SUM(d.ppn), 0)
+        FROM jual_detail d
+        JOIN jual_header h ON h.id = d.jual_header_id
+        JOIN master_barang b ON b.id = d.barang_id
+        LEFT JOIN barang_induk i ON i.id = b.induk_id` + whereSQL + `
+        GROUP BY 1, 2, 3, 4
+        ORDER BY 4 ASC, 3 ASC`
+
+    rows, err := r.DB.QueryContext(ctx, q, args...)
+    if err != nil { return nil, fmt.Errorf("query report: %w", err) }
+    defer rows.Close()
+
+    list := make([]models.PenjualanInduk, 0)
+    for rows.Next() {
+        var k models.PenjualanInduk
+        var induk, barang sql.NullInt64
+        if err := rows.Scan(&induk, &barang, &k.Kode, &k.Nama, &k.JumlahFaktur, &k.Qty, &k.Subtotal, &k.DPP, &k.PPN); err != nil {
+            return nil, fmt.Errorf("scan report: %w", err)
+        }
+        k.IndukID, k.BarangID = nullInt64Ptr(induk), nullInt64Ptr(barang)
+        list = append(list, k)
     }
     if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
     return list, nil
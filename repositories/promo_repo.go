@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"warehouse/apperr"
+	"warehouse/models"
+
+	"github.com/lib/pq"
+)
+
+// PromoRepo manages promotion rules.
+type PromoRepo struct {
+    DB *sql.DB
+}
+
+func NewPromoRepo(db *sql.DB) *PromoRepo { return &PromoRepo{DB: db} }
+
+// ErrPromoInUse is returned when a promo that was applied to a sale is deleted.
+var ErrPromoInUse = errors.New("promo in use")
+
+const promoColumns = `id, nama, jenis, aktif, mulai, selesai, prioritas, barang_id, beli_qty, gratis_qty,
+    gratis_barang_id, harga_bundel, min_total, diskon_bp, diskon_nominal, maks_diskon, created_at`
+
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanPromo(sc rowScanner) (models.Promo, error) {
+    var p models.Promo
+    var selesai sql.NullTime
+    var barangID, gratisID sql.NullInt64
+    err := sc.Scan(&p.ID, &p.Nama, &p.Jenis, &p.Aktif, &p.Mulai, &selesai, &p.Prioritas, &barangID, &p.BeliQty, &p.GratisQty,
+        &gratisID, &p.HargaBundel, &p.MinTotal, &p.DiskonBP, &p.DiskonNominal, &p.MaksDiskon, &p.CreatedAt)
+    if err != nil { return p, err }
+    if selesai.Valid { t := selesai.Time; p.Selesai = &t }
+    p.BarangID, p.GratisBarangID = nullInt64Ptr(barangID), nullInt64Ptr(gratisID)
+    return p, nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+    QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadPromoItems fills Items of every harga_bundel promo in list.
+func loadPromoItems(ctx context.Context, q querier, list []models.Promo) error {
+    idx := make(map[int64]int)
+    ids := make([]int64, 0)
+    for i, p := range list {
+        if p.Jenis == models.PromoHargaBundel {
+            idx[p.ID] = i
+            ids = append(ids, p.ID)
+        }
+    }
+    if len(ids) == 0 {
+        return nil
+    }
+    rows, err := q.QueryContext(ctx, `SELECT promo_id, barang_id, qty FROM promo_item WHERE promo_id = ANY($1) ORDER BY id ASC`, pq.Array(ids))
+    if err != nil { return err }
+    defer rows.Close()
+    for rows.Next() {
+        var promoID int64
+        var it models.PromoItem
+        if err := rows.Scan(&promoID, &it.BarangID, &it.Qty); err != nil { return err }
+        i := idx[promoID]
+        list[i].Items = append(list[i].Items, it)
+    }
+    return rows.Err()
+}
+
+// promoAktif returns the promos active at now, read inside the sale's transaction.
+func promoAktif(ctx context.Context, tx *sql.Tx, now time.Time) ([]models.Promo, error) {
+    rows, err := tx.QueryContext(ctx, `SELECT `+promoColumns+` FROM promo
+        WHERE aktif AND mulai <= $1 AND (selesai IS NULL OR selesai > $1)
+        ORDER BY prioritas ASC, id ASC`, now)
+    if err != nil { return nil, err }
+    list := make([]models.Promo, 0)
+    for rows.Next() {
+        p, err := scanPromo(rows)
+        if err != nil {
+            rows.Close()
+            return nil, err
+        }
+        list = append(list, p)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return nil, err }
+    if err := loadPromoItems(ctx, tx, list); err != nil { return nil, err }
+    return list, nil
+}
+
+// GetAll returns promos; when aktifSaja is set only promos active now.
+func (r *PromoRepo) GetAll(ctx context.Context, aktifSaja bool) ([]models.Promo, error) {
+    q := `SELECT ` + promoColumns + ` FROM promo`
+    args := []interface{}{}
+    if aktifSaja {
+        q += ` WHERE aktif AND mulai <= $1 AND (selesai IS NULL OR selesai > $1)`
+        args = append(args, time.Now())
+    }
+    q += ` ORDER BY prioritas ASC, id ASC`
+    rows, err := r.DB.QueryContext(ctx, q, args...)
+    if err != nil { return nil, fmt.Errorf("query promo: %w", err) }
+    list := make([]models.Promo, 0)
+    for rows.Next() {
+        p, err := scanPromo(rows)
+        if err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("scan promo: %w", err)
+        }
+        list = append(list, p)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    if err := loadPromoItems(ctx, r.DB, list); err != nil { return nil, fmt.Errorf("promo items: %w", err) }
+    return list, nil
+}
+
+// GetByID returns a promo with its bundle items, or nil when not found.
+func (r *PromoRepo) GetByID(ctx context.Context, id int64) (*models.Promo, error) {
+    p, err := scanPromo(r.DB.QueryRowContext(ctx, `SELECT `+promoColumns+` FROM promo WHERE id = $1`, id))
+    if err != nil {
+        if err == sql.ErrNoRows { return nil, nil }
+        return nil, err
+    }
+    list := []models.Promo{p}
+    if err := loadPromoItems(ctx, r.DB, list); err != nil { return nil, err }
+    return &list[0], nil
+}
+
+// validatePromo checks that the fields required by the promo jenis are set.
+func validatePromo(p *models.Promo) error {
+    if p.Nama == "" {
+        return fmt.Errorf("%w: nama is required", apperr.ErrValidation)
+    }
+    if p.Mulai.IsZero() {
+        p.Mulai = time.Now()
+    }
+    if p.Selesai != nil && !p.Selesai.After(p.Mulai) {
+        return fmt.Errorf("%w: selesai must be after mulai", apperr.ErrValidation)
+    }
+    switch p.Jenis {
+    case models.PromoBeliGratis:
+        if p.BarangID == nil || p.BeliQty <= 0 || p.GratisQty <= 0 {
+            return fmt.Errorf("%w: barang_id, beli_qty and gratis_qty are required", apperr.ErrValidation)
+        }
+    case models.PromoHargaBundel:
+        if len(p.Items) == 0 || p.HargaBundel < 0 {
+            return fmt.Errorf("%w: items and harga_bundel are required", apperr.ErrValidation)
+        }
+        for i, it := range p.Items {
+            if it.BarangID <= 0 || it.Qty <= 0 {
+                return fmt.Errorf("%w: invalid item at index %d", apperr.ErrValidation, i)
+            }
+        }
+    case models.PromoDiskonMinimal:
+        if p.MinTotal < 0 || p.DiskonBP < 0 || p.DiskonBP > 10000 || p.DiskonNominal < 0 || (p.DiskonBP == 0 && p.DiskonNominal == 0) {
+            return fmt.Errorf("%w: min_total and diskon_bp or diskon_nominal are required", apperr.ErrValidation)
+        }
+    default:
+        return fmt.Errorf("%w: jenis must be one of %s, %s, %s", apperr.ErrValidation,
+            models.PromoBeliGratis, models.PromoHargaBundel, models.PromoDiskonMinimal)
+    }
+    return nil
+}
+
+// Save inserts (ID 0) or replaces a promo together with its bundle items.
+func (r *PromoRepo) Save(ctx context.Context, p *models.Promo) error {
+    if err := validatePromo(p); err != nil {
+        return err
+    }
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+
+    args := []interface{}{p.Nama, p.Jenis, p.Aktif, p.Mulai, p.Selesai, p.Prioritas, p.BarangID, p.BeliQty, p.GratisQty,
+        p.GratisBarangID, p.HargaBundel, p.MinTotal, p.DiskonBP, p.DiskonNominal, p.MaksDiskon}
+    if p.ID == 0 {
+        err = tx.QueryRowContext(ctx, `INSERT INTO promo (nama, jenis, aktif, mulai, selesai, prioritas, barang_id, beli_qty, gratis_qty,
+                gratis_barang_id, harga_bundel, min_total, diskon_bp, diskon_nominal, maks_diskon)
+                VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING id, created_at`, args...,
+        ).Scan(&p.ID, &p.CreatedAt)
+    } else {
+        err = tx.QueryRowContext(ctx, `UPDATE promo SET nama=$1, jenis=$2, aktif=$3, mulai=$4, selesai=$5, prioritas=$6, barang_id=$7,
+                beli_qty=$8, gratis_qty=$9, gratis_barang_id=$10, harga_bundel=$11, min_total=$12, diskon_bp=$13,
+                diskon_nominal=$14, maks_diskon=$15
+                WHERE id=$16 RETURNING created_at`, append(args, p.ID)...,
+        ).Scan(&p.CreatedAt)
+    }
+    if err != nil {
+        return rollback(promoError(err))
+    }
+
+    if _, err := tx.ExecContext(ctx, `DELETE FROM promo_item WHERE promo_id = $1`, p.ID); err != nil {
+        return rollback(fmt.Errorf("delete items: %w", err))
+    }
+    for _, it := range p.Items {
+        if _, err := tx.ExecContext(ctx, `INSERT INTO promo_item (promo_id, barang_id, qty) VALUES ($1,$2,$3)`, p.ID, it.BarangID, it.Qty); err != nil {
+            return rollback(promoError(err))
+        }
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// Delete removes a promo that was never applied; applied promos should be
+// deactivated instead so sales keep their reference.
+func (r *PromoRepo) Delete(ctx context.Context, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `DELETE FROM promo WHERE id = $1`, id)
+    if err != nil {
+        if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23503" {
+            return ErrPromoInUse
+        }
+        return err
+    }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+func promoError(err error) error {
+    if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23503" {
+        return fmt.Errorf("%w: barang not found", apperr.ErrNotFound)
+    }
+    return err
+}
@@ -0,0 +1,212 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"warehouse/apperr"
+	"warehouse/models"
+	"warehouse/permission"
+
+	"github.com/lib/pq"
+)
+
+// ErrRoleInUse is returned when a role that is still given to users is deleted.
+var ErrRoleInUse = errors.New("role in use")
+
+// RoleRepo manages roles and the permissions they grant.
+type RoleRepo struct {
+    DB *sql.DB
+}
+
+func NewRoleRepo(db *sql.DB) *RoleRepo { return &RoleRepo{DB: db} }
+
+// Sync brings the permission table in line with the catalogue: new
+// permissions are added (and granted to the "user" role when marked Staff),
+// descriptions are updated, permissions no longer in the catalogue are
+// removed and the admin role is given every permission.
+func (r *RoleRepo) Sync(ctx context.Context, defs []permission.Def) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    defer func() { _ = tx.Rollback() }()
+    ada := map[string]bool{}
+    rows, err := tx.QueryContext(ctx, `SELECT kode FROM permission`)
+    if err != nil { return err }
+    for rows.Next() {
+        var k string
+        if err := rows.Scan(&k); err != nil { rows.Close(); return err }
+        ada[k] = true
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return err }
+
+    for _, d := range defs {
+        if _, err := tx.ExecContext(ctx, `INSERT INTO permission (kode, deskripsi) VALUES ($1, $2)
+            ON CONFLICT (kode) DO UPDATE SET deskripsi = EXCLUDED.deskripsi`, d.Kode, d.Deskripsi); err != nil {
+            return fmt.Errorf("sync permission %s: %w", d.Kode, err)
+        }
+    }
+    kode, staffBaru := rencanaSync(defs, ada)
+    for _, k := range staffBaru {
+        if _, err := tx.ExecContext(ctx, `INSERT INTO role_permission (role, permission)
+            SELECT nama, $1 FROM role WHERE nama = 'user' ON CONFLICT DO NOTHING`, k); err != nil {
+            return err
+        }
+    }
+    if _, err := tx.ExecContext(ctx, `DELETE FROM permission WHERE NOT (kode = ANY($1))`, pq.Array(kode)); err != nil { return err }
+    if _, err := tx.ExecContext(ctx, `INSERT INTO role_permission (role, permission)
+        SELECT $1, kode FROM permission ON CONFLICT DO NOTHING`, permission.Admin); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// rencanaSync returns the codes of defs and, of those, the Staff permissions
+// missing from ada (the codes already in the database), which Sync grants to
+// the "user" role. Existing permissions are never granted again, so a
+// permission an admin took away from "user" stays removed.
+func rencanaSync(defs []permission.Def, ada map[string]bool) (kode, staffBaru []string) {
+    kode = make([]string, 0, len(defs))
+    for _, d := range defs {
+        kode = append(kode, d.Kode)
+        if !ada[d.Kode] && d.Staff { staffBaru = append(staffBaru, d.Kode) }
+    }
+    return kode, staffBaru
+}
+
+// Permissions returns the permission catalogue.
+func (r *RoleRepo) Permissions(ctx context.Context) ([]models.Permission, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT kode, deskripsi FROM permission ORDER BY kode`)
+    if err != nil { return nil, fmt.Errorf("query permission: %w", err) }
+    defer rows.Close()
+    list := make([]models.Permission, 0)
+    for rows.Next() {
+        var p models.Permission
+        if err := rows.Scan(&p.Kode, &p.Deskripsi); err != nil { return nil, fmt.Errorf("scan permission: %w", err) }
+        list = append(list, p)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+const roleSelect = `SELECT r.nama, r.deskripsi, r.wajib_2fa, r.created_at,
+        ARRAY(SELECT permission FROM role_permission WHERE role = r.nama ORDER BY permission),
+        (SELECT COUNT(*) FROM user_tenant WHERE role = r.nama AND tenant_id = app_tenant())
+    FROM role r`
+
+func scanRole(sc interface{ Scan(...any) error }) (models.Role, error) {
+    var ro models.Role
+    var ds sql.NullString
+    var created time.Time
+    err := sc.Scan(&ro.Nama, &ds, &ro.Wajib2FA, &created, pq.Array(&ro.Permissions), &ro.JumlahUser)
+    if ds.Valid { v := ds.String; ro.Deskripsi = &v }
+    ro.CreatedAt = &created
+    if ro.Permissions == nil { ro.Permissions = []string{} }
+    return ro, err
+}
+
+// List returns all roles with their permissions and number of users in
+// the tenant of ctx.
+func (r *RoleRepo) List(ctx context.Context) ([]models.Role, error) {
+    rows, err := r.DB.QueryContext(ctx, roleSelect+` ORDER BY r.nama`)
+    if err != nil { return nil, fmt.Errorf("query role: %w", err) }
+    defer rows.Close()
+    list := make([]models.Role, 0)
+    for rows.Next() {
+        ro, err := scanRole(rows)
+        if err != nil { return nil, fmt.Errorf("scan role: %w", err) }
+        list = append(list, ro)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+// Get returns role nama, or nil when it does not exist.
+func (r *RoleRepo) Get(ctx context.Context, nama string) (*models.Role, error) {
+    ro, err := scanRole(r.DB.QueryRowContext(ctx, roleSelect+` WHERE r.nama = $1`, nama))
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+    return &ro, nil
+}
+
+// DipakaiDiLuar reports whether role nama is given to users of another
+// tenant than the one of ctx in which actorID does not hold role.manage;
+// with lokal (requests made with an API key), of any other tenant. Roles
+// are shared by all tenants, so changing one changes what its users may do
+// in each of them.
+func (r *RoleRepo) DipakaiDiLuar(ctx context.Context, nama string, actorID int64, lokal bool) (bool, error) {
+    var ada bool
+    err := r.DB.QueryRowContext(ctx, `SELECT EXISTS (
+            SELECT 1 FROM user_tenant t
+            WHERE t.role = $1 AND t.tenant_id IS DISTINCT FROM app_tenant()
+              AND ($3 OR NOT EXISTS (
+                  SELECT 1 FROM user_tenant a JOIN role_permission rp ON rp.role = a.role
+                  WHERE a.user_id = $2 AND a.tenant_id = t.tenant_id AND rp.permission = $4)))`,
+        nama, actorID, lokal, permission.RoleManage).Scan(&ada)
+    return ada, err
+}
+
+// Create adds a role with its permissions.
+func (r *RoleRepo) Create(ctx context.Context, ro *models.Role) error {
+    return r.simpan(ctx, ro, func(tx *sql.Tx) error {
+        _, err := tx.ExecContext(ctx, `INSERT INTO role (nama, deskripsi, wajib_2fa) VALUES ($1, $2, $3)`, ro.Nama, ro.Deskripsi, ro.Wajib2FA)
+        if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23505" {
+            return fmt.Errorf("%w: role %s already exists", apperr.ErrValidation, ro.Nama)
+        }
+        return err
+    })
+}
+
+// Update changes the description and two-factor requirement of a role
+// and replaces its permissions. The permissions of the admin role cannot
+// be changed; it always has all of them.
+func (r *RoleRepo) Update(ctx context.Context, ro *models.Role) error {
+    admin := ro.Nama == permission.Admin
+    if admin && len(ro.Permissions) > 0 {
+        return fmt.Errorf("%w: role %s always has every permission; leave permissions empty", apperr.ErrValidation, permission.Admin)
+    }
+    return r.simpan(ctx, ro, func(tx *sql.Tx) error {
+        res, err := tx.ExecContext(ctx, `UPDATE role SET deskripsi=$1, wajib_2fa=$2 WHERE nama=$3`, ro.Deskripsi, ro.Wajib2FA, ro.Nama)
+        if err != nil { return err }
+        if n, _ := res.RowsAffected(); n == 0 { return sql.ErrNoRows }
+        if admin { return nil }
+        _, err = tx.ExecContext(ctx, `DELETE FROM role_permission WHERE role=$1`, ro.Nama)
+        return err
+    })
+}
+
+// simpan runs write (which creates or updates the role row) and stores the
+// permissions of ro in one transaction.
+func (r *RoleRepo) simpan(ctx context.Context, ro *models.Role, write func(*sql.Tx) error) error {
+    for _, p := range ro.Permissions {
+        if !permission.Valid(p) {
+            return fmt.Errorf("%w: unknown permission %s", apperr.ErrValidation, p)
+        }
+    }
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    defer func() { _ = tx.Rollback() }()
+    if err := write(tx); err != nil { return err }
+    if _, err := tx.ExecContext(ctx, `INSERT INTO role_permission (role, permission)
+        SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`, ro.Nama, pq.Array(ro.Permissions)); err != nil {
+        return fmt.Errorf("insert role_permission: %w", err)
+    }
+    return tx.Commit()
+}
+
+// Delete removes a role that no user has. The admin role cannot be deleted.
+func (r *RoleRepo) Delete(ctx context.Context, nama string) error {
+    if nama == permission.Admin {
+        return fmt.Errorf("%w: role %s cannot be deleted", apperr.ErrValidation, permission.Admin)
+    }
+    res, err := r.DB.ExecContext(ctx, `DELETE FROM role WHERE nama=$1`, nama)
+    if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23503" {
+        return ErrRoleInUse
+    }
+    if err != nil { return err }
+    if n, _ := res.RowsAffected(); n == 0 { return sql.ErrNoRows }
+    return nil
+}
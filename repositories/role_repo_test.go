@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+
+	"warehouse/permission"
+)
+
+func TestRencanaSync(t *testing.T) {
+    defs := []permission.Def{
+        {Kode: "a.view", Staff: true},
+        {Kode: "a.write", Staff: false},
+        {Kode: "b.view", Staff: true},
+    }
+    tests := []struct {
+        name      string
+        ada       map[string]bool
+        staffBaru []string
+    }{
+        {"empty database", map[string]bool{}, []string{"a.view", "b.view"}},
+        {"existing staff permission not granted again", map[string]bool{"a.view": true, "a.write": true}, []string{"b.view"}},
+        {"all present", map[string]bool{"a.view": true, "a.write": true, "b.view": true}, nil},
+        {"stale codes ignored", map[string]bool{"old.perm": true}, []string{"a.view", "b.view"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            kode, staffBaru := rencanaSync(defs, tt.ada)
+            if want := []string{"a.view", "a.write", "b.view"}; !reflect.DeepEqual(kode, want) {
+                t.Errorf("kode = %v, want %v", kode, want)
+            }
+            if !reflect.DeepEqual(staffBaru, tt.staffBaru) {
+                t.Errorf("staffBaru = %v, want %v", staffBaru, tt.staffBaru)
+            }
+        })
+    }
+}
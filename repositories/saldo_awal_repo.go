@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"warehouse/apperr"
+	"warehouse/models"
+)
+
+// ImportSaldoAwal sets the opening stock of each row's barang: it records a
+// saldo_awal row, creates or updates mstok and appends a history_stok entry
+// of type saldo_awal. A barang can get an opening balance only once, and
+// only while its stock is zero. Failing rows are rolled back to a savepoint
+// and reported; without bestEffort any failure discards the whole import.
+// progress, when not nil, is called with the number of rows processed.
+func (r *StokRepo) ImportSaldoAwal(ctx context.Context, rows []models.SaldoAwalImport, bestEffort bool, userID int64, progress func(done int)) (*models.SaldoAwalReport, error) {
+    rep := &models.SaldoAwalReport{Total: len(rows), Items: make([]models.SaldoAwal, 0, len(rows)), Errors: make([]models.ImportError, 0)}
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return nil, fmt.Errorf("begin tx: %w", err) }
+    defer func() { _ = tx.Rollback() }()
+
+    for i, row := range rows {
+        if err := ctx.Err(); err != nil { return nil, err }
+        if _, err := tx.ExecContext(ctx, `SAVEPOINT saldo_awal_row`); err != nil {
+            return nil, fmt.Errorf("savepoint: %w", err)
+        }
+        sa, err := terapkanSaldoAwal(ctx, tx, row, userID)
+        if err != nil {
+            if !errors.Is(err, apperr.ErrValidation) && !errors.Is(err, apperr.ErrNotFound) {
+                return nil, fmt.Errorf("row %d: %w", row.Baris, err)
+            }
+            if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT saldo_awal_row`); err != nil {
+                return nil, fmt.Errorf("rollback savepoint: %w", err)
+            }
+            rep.Gagal++
+            rep.Errors = append(rep.Errors, models.ImportError{Baris: row.Baris, KodeBarang: row.KodeBarang, Pesan: err.Error()})
+        } else {
+            if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT saldo_awal_row`); err != nil {
+                return nil, fmt.Errorf("release savepoint: %w", err)
+            }
+            rep.Diterapkan++
+            rep.TotalQty += sa.Qty
+            rep.TotalNilai += sa.Nilai
+            rep.Items = append(rep.Items, *sa)
+        }
+        if progress != nil { progress(i + 1) }
+    }
+
+    if rep.Gagal > 0 && !bestEffort {
+        rep.Diterapkan, rep.TotalQty, rep.TotalNilai = 0, 0, 0
+        rep.Items = rep.Items[:0]
+        return rep, nil
+    }
+    if err := tx.Commit(); err != nil { return nil, fmt.Errorf("commit tx: %w", err) }
+    rep.Disimpan = true
+    return rep, nil
+}
+
+func terapkanSaldoAwal(ctx context.Context, tx *sql.Tx, row models.SaldoAwalImport, userID int64) (*models.SaldoAwal, error) {
+    sa := &models.SaldoAwal{KodeBarang: row.KodeBarang, Qty: row.Qty, UserID: userID}
+    var hargaBeli int64
+    var virtual bool
+    err := tx.QueryRowContext(ctx, `SELECT id, nama_barang, harga_beli, bundel_virtual FROM master_barang WHERE kode_barang=$1`, row.KodeBarang).
+        Scan(&sa.BarangID, &sa.NamaBarang, &hargaBeli, &virtual)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("%w: kode_barang %s not found", apperr.ErrNotFound, row.KodeBarang)
+    }
+    if err != nil { return nil, err }
+    if virtual {
+        return nil, fmt.Errorf("%w: %s is a virtual bundle without stock of its own", apperr.ErrValidation, row.KodeBarang)
+    }
+    sa.HargaSatuan = hargaBeli
+    if row.HargaSatuan != nil { sa.HargaSatuan = *row.HargaSatuan }
+    sa.Nilai = sa.Qty * sa.HargaSatuan
+
+    var ada bool
+    if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM saldo_awal WHERE barang_id=$1)`, sa.BarangID).Scan(&ada); err != nil {
+        return nil, err
+    }
+    if ada {
+        return nil, fmt.Errorf("%w: opening balance already set", apperr.ErrValidation)
+    }
+    var stok sql.NullInt64
+    err = tx.QueryRowContext(ctx, `SELECT stok_akhir FROM mstok WHERE barang_id=$1 FOR UPDATE`, sa.BarangID).Scan(&stok)
+    if err != nil && err != sql.ErrNoRows { return nil, fmt.Errorf("lock stock: %w", err) }
+    if stok.Int64 != 0 {
+        return nil, fmt.Errorf("%w: stok is %d, opening balance needs zero stock", apperr.ErrValidation, stok.Int64)
+    }
+
+    if err := tx.QueryRowContext(ctx, `INSERT INTO saldo_awal (barang_id, qty, harga_satuan, nilai, user_id)
+            VALUES ($1,$2,$3,$4,$5) RETURNING id, created_at`,
+        sa.BarangID, sa.Qty, sa.HargaSatuan, sa.Nilai, userID).Scan(&sa.ID, &sa.CreatedAt); err != nil {
+        return nil, fmt.Errorf("insert saldo_awal: %w", err)
+    }
+    if stok.Valid {
+        _, err = tx.ExecContext(ctx, `UPDATE mstok SET stok_akhir=$1 WHERE barang_id=$2`, sa.Qty, sa.BarangID)
+    } else {
+        _, err = tx.ExecContext(ctx, `INSERT INTO mstok (barang_id, stok_akhir) VALUES ($1,$2)`, sa.BarangID, sa.Qty)
+    }
+    if err != nil { return nil, fmt.Errorf("set mstok: %w", err) }
+    if err := catatHistoriStok(ctx, tx, sa.BarangID, userID, "saldo_awal", sa.Qty, 0, sa.Qty); err != nil {
+        return nil, fmt.Errorf("insert history: %w", err)
+    }
+    return sa, nil
+}
+
+// GetSaldoAwal lists all opening balances with their barang.
+func (r *StokRepo) GetSaldoAwal(ctx context.Context) ([]models.SaldoAwal, error) {
+    const q = `SELECT s.id, s.barang_id, b.kode_barang, b.nama_barang, s.qty, s.harga_satuan, s.nilai, s.user_id, s.created_at
+        FROM saldo_awal s
+        JOIN master_barang b ON b.id = s.barang_id
+        ORDER BY b.kode_barang ASC`
+    rows, err := r.DB.QueryContext(ctx, q)
+    if err != nil { return nil, fmt.Errorf("query saldo_awal: %w", err) }
+    defer rows.Close()
+    list := make([]models.SaldoAwal, 0)
+    for rows.Next() {
+        var s models.SaldoAwal
+        if err := rows.Scan(&s.ID, &s.BarangID, &s.KodeBarang, &s.NamaBarang, &s.Qty, &s.HargaSatuan, &s.Nilai, &s.UserID, &s.CreatedAt); err != nil {
+            return nil, fmt.Errorf("scan saldo_awal: %w", err)
+        }
+        list = append(list, s)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+    // ErrRefreshInvalid is returned for an unknown, expired or revoked refresh token.
+    ErrRefreshInvalid = errors.New("refresh token is invalid or revoked")
+    // ErrRefreshReuse is returned when an already rotated refresh token is
+    // presented again; its whole family has been revoked.
+    ErrRefreshReuse = errors.New("refresh token was already used; the session has been revoked")
+)
+
+// SesiRepo persists refresh tokens. A session is a family of refresh
+// tokens started by one login; each refresh rotates to the next token.
+type SesiRepo struct {
+    DB *sql.DB
+}
+
+func NewSesiRepo(db *sql.DB) *SesiRepo { return &SesiRepo{DB: db} }
+
+// Create records the first refresh token of a new session and drops the
+// user's refresh tokens that have expired.
+func (r *SesiRepo) Create(ctx context.Context, jti, familyID string, userID int64, expiresAt time.Time) error {
+    if _, err := r.DB.ExecContext(ctx, `DELETE FROM refresh_token WHERE user_id=$1 AND expires_at < NOW()`, userID); err != nil {
+        return err
+    }
+    _, err := r.DB.ExecContext(ctx, `INSERT INTO refresh_token (jti, family_id, user_id, expires_at) VALUES ($1, $2, $3, $4)`,
+        jti, familyID, userID, expiresAt)
+    return err
+}
+
+// Rotate marks refresh token jti used and records newJTI as its successor
+// in the same family. Reusing a token that was already rotated revokes the
+// family and returns ErrRefreshReuse.
+func (r *SesiRepo) Rotate(ctx context.Context, jti, newJTI string, expiresAt time.Time) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+
+    var familyID string
+    var userID int64
+    var exp time.Time
+    var used, revoked sql.NullTime
+    err = tx.QueryRowContext(ctx, `SELECT family_id, user_id, expires_at, used_at, revoked_at FROM refresh_token WHERE jti=$1 FOR UPDATE`, jti).
+        Scan(&familyID, &userID, &exp, &used, &revoked)
+    if err == sql.ErrNoRows { return rollback(ErrRefreshInvalid) }
+    if err != nil { return rollback(err) }
+    if revoked.Valid || exp.Before(time.Now()) { return rollback(ErrRefreshInvalid) }
+    if used.Valid {
+        if _, err := tx.ExecContext(ctx, `UPDATE refresh_token SET revoked_at=NOW() WHERE family_id=$1 AND revoked_at IS NULL`, familyID); err != nil {
+            return rollback(err)
+        }
+        if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+        return ErrRefreshReuse
+    }
+    if _, err := tx.ExecContext(ctx, `UPDATE refresh_token SET used_at=NOW() WHERE jti=$1`, jti); err != nil {
+        return rollback(err)
+    }
+    if _, err := tx.ExecContext(ctx, `INSERT INTO refresh_token (jti, family_id, user_id, expires_at) VALUES ($1, $2, $3, $4)`,
+        newJTI, familyID, userID, expiresAt); err != nil {
+        return rollback(err)
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// RevokeFamily ends one session (logout).
+func (r *SesiRepo) RevokeFamily(ctx context.Context, familyID string) error {
+    _, err := r.DB.ExecContext(ctx, `UPDATE refresh_token SET revoked_at=NOW() WHERE family_id=$1 AND revoked_at IS NULL`, familyID)
+    return err
+}
+
+// RevokeUser ends every session of a user except the family keep (empty
+// keeps none) and returns how many sessions were ended.
+func (r *SesiRepo) RevokeUser(ctx context.Context, userID int64, keep string) (int64, error) {
+    var n int64
+    err := r.DB.QueryRowContext(ctx, `WITH dicabut AS (
+            UPDATE refresh_token SET revoked_at=NOW()
+            WHERE user_id=$1 AND family_id <> $2 AND revoked_at IS NULL AND expires_at > NOW()
+            RETURNING family_id)
+        SELECT COUNT(DISTINCT family_id) FROM dicabut`, userID, keep).Scan(&n)
+    return n, err
+}
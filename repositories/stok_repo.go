@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"warehouse/apikey"
 	"warehouse/models"
 )
 
@@ -12,7 +13,31 @@ type StokRepo struct {
 
 func NewStokRepo(db *sql.DB) *StokRepo { return &StokRepo{DB: db} }
 
+// catatHistoriStok appends a stock movement of barangID made by userID to
+// history_stok inside tx. Movements of requests made with an API key also
+// name the key.
+func catatHistoriStok(ctx context.Context, tx *sql.Tx, barangID, userID int64, jenis string, jumlah, before, after int64) error {
+    var keyID *int64
+    if id, ok := apikey.FromContext(ctx); ok { keyID = &id }
+    _, err := tx.ExecContext(ctx, `INSERT INTO history_stok (barang_id, user_id, api_key_id, jenis_transaksi, jumlah, stok_sebelum, stok_sesudah)
+            VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+        barangID, userID, keyID, jenis, jumlah, before, after)
+    return err
+}
+
 func (r *StokRepo) GetStokAkhirAll(ctx context.Context) ([]models.Mstok, error) {
+    list := []models.Mstok{}
+    err := r.EachStokAkhir(ctx, func(m models.Mstok) error {
+        list = append(list, m)
+        return nil
+    })
+    if err != nil { return nil, err }
+    return list, nil
+}
+
+// EachStokAkhir calls fn for the current stock of every barang, ordered by
+// nama_barang, reading rows one at a time.
+func (r *StokRepo) EachStokAkhir(ctx context.Context, fn func(models.Mstok) error) error {
     const q = `SELECT s.id, s.barang_id, s.stok_akhir,
         b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual
         FROM mstok s
@@ -20,24 +45,22 @@ func (r *StokRepo) GetStokAkhirAll(ctx context.Context) ([]models.Mstok, error)
         ORDER BY b.nama_barang ASC`
 
     rows, err := r.DB.QueryContext(ctx, q)
-    if err != nil { return nil, err }
+    if err != nil { return err }
     defer rows.Close()
 
-    list := []models.Mstok{}
     for rows.Next() {
         var m models.Mstok
         var b models.Barang
         var desc sql.NullString
         if err := rows.Scan(&m.ID, &m.BarangID, &m.StokAkhir,
             &b.ID, &b.KodeBarang, &b.NamaBarang, &desc, &b.Satuan, &b.HargaBeli, &b.HargaJual); err != nil {
-            return nil, err
+            return err
         }
         if desc.Valid { v := desc.String; b.Deskripsi = &v }
         m.Barang = &b
-        list = append(list, m)
+        if err := fn(m); err != nil { return err }
     }
-    if err := rows.Err(); err != nil { return nil, err }
-    return list, nil
+    return rows.Err()
 }
 
 func (r *StokRepo) GetHistoryAll(ctx context.Context, page, limit int) ([]models.HistoryStok, int, error) {
@@ -49,12 +72,13 @@ func (r *StokRepo) GetHistoryAll(ctx context.Context, page, limit int) ([]models
     var total int
     if err := r.DB.QueryRowContext(ctx, countQ).Scan(&total); err != nil { return nil, 0, err }
 
-    const q = `SELECT h.id, h.barang_id, h.user_id, h.jenis_transaksi, h.jumlah, h.stok_sebelum, h.stok_sesudah, h.created_at,
+    const q = `SELECT h.id, h.barang_id, h.user_id, h.jenis_transaksi, h.jumlah, h.stok_sebelum, h.stok_sesudah, h.created_at, h.api_key_id, k.nama,
         b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual,
-        u.id, u.username, u.password, u.email, u.full_name, u.role
+        u.id, u.username, u.password, u.email, u.full_name, ` + roleDiTenant + `
         FROM history_stok h
         JOIN master_barang b ON b.id = h.barang_id
         JOIN users u ON u.id = h.user_id
+        LEFT JOIN api_key k ON k.id = h.api_key_id
         ORDER BY h.created_at DESC
         LIMIT $1 OFFSET $2`
 
@@ -67,13 +91,16 @@ func (r *StokRepo) GetHistoryAll(ctx context.Context, page, limit int) ([]models
         var hs models.HistoryStok
         var b models.Barang
         var u models.User
-        var desc sql.NullString
-        if err := rows.Scan(&hs.ID, &hs.BarangID, &hs.UserID, &hs.JenisTransaksi, &hs.Jumlah, &hs.StokSebelum, &hs.StokSesudah, &hs.CreatedAt,
+        var desc, keyNama sql.NullString
+        var keyID sql.NullInt64
+        if err := rows.Scan(&hs.ID, &hs.BarangID, &hs.UserID, &hs.JenisTransaksi, &hs.Jumlah, &hs.StokSebelum, &hs.StokSesudah, &hs.CreatedAt, &keyID, &keyNama,
             &b.ID, &b.KodeBarang, &b.NamaBarang, &desc, &b.Satuan, &b.HargaBeli, &b.HargaJual,
             &u.ID, &u.Username, &u.Password, &u.Email, &u.FullName, &u.Role); err != nil {
             return nil, 0, err
         }
         if desc.Valid { v := desc.String; b.Deskripsi = &v }
+        hs.APIKeyID = nullInt64Ptr(keyID)
+        if keyNama.Valid { v := keyNama.String; hs.APIKeyNama = &v }
         hs.BarangDetail = &b
         hs.UserDetail = &u
         list = append(list, hs)
@@ -111,12 +138,13 @@ func (r *StokRepo) GetHistoryByBarangID(ctx context.Context, barangID int64, pag
     var total int
     if err := r.DB.QueryRowContext(ctx, countQ, barangID).Scan(&total); err != nil { return nil, 0, err }
 
-    const q = `SELECT h.id, h.barang_id, h.user_id, h.jenis_transaksi, h.jumlah, h.stok_sebelum, h.stok_sesudah, h.created_at,
+    const q = `SELECT h.id, h.barang_id, h.user_id, h.jenis_transaksi, h.jumlah, h.stok_sebelum, h.stok_sesudah, h.created_at, h.api_key_id, k.nama,
         b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual,
-        u.id, u.username, u.password, u.email, u.full_name, u.role
+        u.id, u.username, u.password, u.email, u.full_name, ` + roleDiTenant + `
         FROM history_stok h
         JOIN master_barang b ON b.id = h.barang_id
         JOIN users u ON u.id = h.user_id
+        LEFT JOIN api_key k ON k.id = h.api_key_id
         WHERE h.barang_id = $1
         ORDER BY h.created_at DESC
         LIMIT $2 OFFSET $3`
@@ -128,13 +156,16 @@ func (r *StokRepo) GetHistoryByBarangID(ctx context.Context, barangID int64, pag
         var hs models.HistoryStok
         var b models.Barang
         var u models.User
-        var desc sql.NullString
-        if err := rows.Scan(&hs.ID, &hs.BarangID, &hs.UserID, &hs.JenisTransaksi, &hs.Jumlah, &hs.StokSebelum, &hs.StokSesudah, &hs.CreatedAt,
+        var desc, keyNama sql.NullString
+        var keyID sql.NullInt64
+        if err := rows.Scan(&hs.ID, &hs.BarangID, &hs.UserID, &hs.JenisTransaksi, &hs.Jumlah, &hs.StokSebelum, &hs.StokSesudah, &hs.CreatedAt, &keyID, &keyNama,
             &b.ID, &b.KodeBarang, &b.NamaBarang, &desc, &b.Satuan, &b.HargaBeli, &b.HargaJual,
             &u.ID, &u.Username, &u.Password, &u.Email, &u.FullName, &u.Role); err != nil {
             return nil, 0, err
         }
         if desc.Valid { v := desc.String; b.Deskripsi = &v }
+        hs.APIKeyID = nullInt64Ptr(keyID)
+        if keyNama.Valid { v := keyNama.String; hs.APIKeyNama = &v }
         hs.BarangDetail = &b
         hs.UserDetail = &u
         list = append(list, hs)
@@ -142,3 +173,111 @@ func (r *StokRepo) GetHistoryByBarangID(ctx context.Context, barangID int64, pag
     if err := rows.Err(); err != nil { return nil, 0, err }
     return list, total, nil
 }
+
+// GetStokPerKategori returns stock totals per kategori, each including its
+// sub-categories, plus a row with KategoriID nil for uncategorized barang.
+func (r *StokRepo) GetStokPerKategori(ctx context.Context) ([]models.StokKategori, error) {
+    const q = kategoriPohonCTE + `
+        SELECT k.id, k.nama, k.parent_id, COUNT(b.id),
+            COALESCE(SUM(s.stok_akhir), 0), COALESCE(SUM(s.stok_akhir::BIGINT * b.harga_beli), 0)
+        FROM kategori k
+        JOIN pohon p ON p.akar = k.id
+        LEFT JOIN master_barang b ON b.kategori_id = p.kategori_id
+        LEFT JOIN mstok s ON s.barang_id = b.id
+        GROUP BY k.id, k.nama, k.parent_id
+        UNION ALL
+        SELECT NULL, 'Tanpa Kategori', NULL, COUNT(b.id),
+            COALESCE(SUM(s.stok_akhir), 0), COALESCE(SUM(s.stok_akhir::BIGINT * b.harga_beli), 0)
+        FROM master_barang b
+        LEFT JOIN mstok s ON s.barang_id = b.id
+        WHERE b.kategori_id IS NULL
+        ORDER BY 2 ASC`
+
+    rows, err := r.DB.QueryContext(ctx, q)
+    if err != nil { return nil, err }
+    defer rows.Close()
+
+    list := []models.StokKategori{}
+    for rows.Next() {
+        var k models.StokKategori
+        var id, parent sql.NullInt64
+        if err := rows.Scan(&id, &k.Nama, &parent, &k.JumlahBarang, &k.TotalStok, &k.NilaiStok); err != nil {
+            return nil, err
+        }
+        k.KategoriID, k.ParentID = nullInt64Ptr(id), nullInt64Ptr(parent)
+        list = append(list, k)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return list, nil
+}
+
+// GetStokPerInduk returns stock totals with variants rolled up to their
+// barang induk; barang without a parent are rows of their own.
+func (r *StokRepo) GetStokPerInduk(ctx context.Context) ([]models.StokInduk, error) {
+    const q = `
+        SELECT i.id, CASE WHEN i.id IS NULL THEN b.id END,
+            COALESCE(i.kode_induk, b.kode_barang), COALESCE(i.nama, b.nama_barang), COUNT(b.id),
+            COALESCE(SUM(s.stok_akhir), 0), COALESCE(SUM(s.stok_akhir::BIGINT * b.harga_beli), 0)
+        FROM master_barang b
+        LEFT JOIN barang_induk i ON i.id = b.induk_id
+        LEFT JOIN mstok s ON s.barang_id = b.id
+        GROUP BY 1, 2, 3, 4
+        ORDER BY 4 ASC, 3 ASC`
+
+    rows, err := r.DB.QueryContext(ctx, q)
+    if err != nil { return nil, err }
+    defer rows.Close()
+
+    list := []models.StokInduk{}
+    for rows.Next() {
+        var k models.StokInduk
+        var induk, barang sql.NullInt64
+        if err := rows.Scan(&induk, &barang, &k.Kode, &k.Nama, &k.JumlahBarang, &k.TotalStok, &k.NilaiStok); err != nil {
+            return nil, err
+        }
+        k.IndukID, k.BarangID = nullInt64Ptr(induk), nullInt64Ptr(barang)
+        list = append(list, k)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return list, nil
+}
+
+// EachHistory calls fn for every stock movement, newest first, optionally
+// limited to one barang (barangID > 0), reading rows one at a time.
+func (r *StokRepo) EachHistory(ctx context.Context, barangID int64, fn func(models.HistoryStok) error) error {
+    q := `SELECT h.id, h.barang_id, h.user_id, h.jenis_transaksi, h.jumlah, h.stok_sebelum, h.stok_sesudah, h.created_at, h.api_key_id, k.nama,
+        b.id, b.kode_barang, b.nama_barang, b.deskripsi, b.satuan, b.harga_beli, b.harga_jual,
+        u.id, u.username, u.password, u.email, u.full_name, ` + roleDiTenant + `
+        FROM history_stok h
+        JOIN master_barang b ON b.id = h.barang_id
+        JOIN users u ON u.id = h.user_id
+        LEFT JOIN api_key k ON k.id = h.api_key_id`
+    args := []interface{}{}
+    if barangID > 0 {
+        q += ` WHERE h.barang_id = $1`
+        args = append(args, barangID)
+    }
+    q += ` ORDER BY h.created_at DESC`
+    rows, err := r.DB.QueryContext(ctx, q, args...)
+    if err != nil { return err }
+    defer rows.Close()
+    for rows.Next() {
+        var hs models.HistoryStok
+        var b models.Barang
+        var u models.User
+        var desc, keyNama sql.NullString
+        var keyID sql.NullInt64
+        if err := rows.Scan(&hs.ID, &hs.BarangID, &hs.UserID, &hs.JenisTransaksi, &hs.Jumlah, &hs.StokSebelum, &hs.StokSesudah, &hs.CreatedAt, &keyID, &keyNama,
+            &b.ID, &b.KodeBarang, &b.NamaBarang, &desc, &b.Satuan, &b.HargaBeli, &b.HargaJual,
+            &u.ID, &u.Username, &u.Password, &u.Email, &u.FullName, &u.Role); err != nil {
+            return err
+        }
+        if desc.Valid { v := desc.String; b.Deskripsi = &v }
+        hs.APIKeyID = nullInt64Ptr(keyID)
+        if keyNama.Valid { v := keyNama.String; hs.APIKeyNama = &v }
+        hs.BarangDetail = &b
+        hs.UserDetail = &u
+        if err := fn(hs); err != nil { return err }
+    }
+    return rows.Err()
+}
@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"warehouse/apperr"
+	"warehouse/models"
+	"warehouse/permission"
+	"warehouse/tenant"
+
+	"github.com/lib/pq"
+)
+
+// TenantRepo manages tenants and which users belong to them. The tenant and
+// user_tenant tables are shared by all tenants.
+type TenantRepo struct {
+    DB *sql.DB
+}
+
+func NewTenantRepo(db *sql.DB) *TenantRepo { return &TenantRepo{DB: db} }
+
+const tenantSelect = `SELECT t.id, t.kode, t.nama, t.created_at,
+        (SELECT COUNT(*) FROM user_tenant WHERE tenant_id = t.id), ut.role
+    FROM tenant t JOIN user_tenant ut ON ut.tenant_id = t.id`
+
+func scanTenants(rows *sql.Rows) ([]models.Tenant, error) {
+    defer rows.Close()
+    list := make([]models.Tenant, 0)
+    for rows.Next() {
+        var t models.Tenant
+        if err := rows.Scan(&t.ID, &t.Kode, &t.Nama, &t.CreatedAt, &t.JumlahUser, &t.Role); err != nil {
+            return nil, fmt.Errorf("scan tenant: %w", err)
+        }
+        list = append(list, t)
+    }
+    if err := rows.Err(); err != nil { return nil, fmt.Errorf("rows err: %w", err) }
+    return list, nil
+}
+
+// ListForUser returns the tenants user userID is a member of, with the
+// user's role in each. When actorID is set, only those actorID is also a
+// member of are returned.
+func (r *TenantRepo) ListForUser(ctx context.Context, userID, actorID int64) ([]models.Tenant, error) {
+    rows, err := r.DB.QueryContext(ctx, tenantSelect+`
+        WHERE ut.user_id = $1
+          AND ($2::bigint = 0 OR t.id IN (SELECT tenant_id FROM user_tenant WHERE user_id = $2))
+        ORDER BY t.id`, userID, actorID)
+    if err != nil { return nil, fmt.Errorf("query tenant: %w", err) }
+    return scanTenants(rows)
+}
+
+// IDs returns the ids of all tenants, for background work done per tenant.
+func (r *TenantRepo) IDs(ctx context.Context) ([]int64, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT id FROM tenant ORDER BY id`)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    ids := make([]int64, 0)
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil { return nil, err }
+        ids = append(ids, id)
+    }
+    return ids, rows.Err()
+}
+
+// Resolve returns the tenant user userID works in, with the user's role in
+// it: the one with kode, or the user's first tenant when kode is empty. It
+// returns nil when the user is not a member of it (or of any tenant).
+func (r *TenantRepo) Resolve(ctx context.Context, userID int64, kode string) (*models.Tenant, error) {
+    var t models.Tenant
+    err := r.DB.QueryRowContext(ctx, `SELECT t.id, t.kode, t.nama, t.created_at, ut.role
+        FROM tenant t JOIN user_tenant ut ON ut.tenant_id = t.id AND ut.user_id = $1
+        WHERE $2 = '' OR t.kode = $2
+        ORDER BY t.id LIMIT 1`, userID, kode).Scan(&t.ID, &t.Kode, &t.Nama, &t.CreatedAt, &t.Role)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, fmt.Errorf("resolve tenant: %w", err) }
+    return &t, nil
+}
+
+// IDByKode returns the id of the tenant with kode, 0 when there is none.
+func (r *TenantRepo) IDByKode(ctx context.Context, kode string) (int64, error) {
+    var id int64
+    err := r.DB.QueryRowContext(ctx, `SELECT id FROM tenant WHERE kode = $1`, kode).Scan(&id)
+    if err == sql.ErrNoRows { return 0, nil }
+    return id, err
+}
+
+// Permissions returns the permissions user userID holds in each of their
+// tenants, by tenant id.
+func (r *TenantRepo) Permissions(ctx context.Context, userID int64) (map[int64][]string, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT ut.tenant_id, ARRAY(SELECT permission FROM role_permission WHERE role = ut.role ORDER BY permission)
+        FROM user_tenant ut WHERE ut.user_id = $1`, userID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    perms := make(map[int64][]string)
+    for rows.Next() {
+        var tid int64
+        var p []string
+        if err := rows.Scan(&tid, pq.Array(&p)); err != nil { return nil, err }
+        perms[tid] = p
+    }
+    return perms, rows.Err()
+}
+
+// Create adds a tenant with userID, its creator, as the first member and
+// its admin. The default price lists (retail, grosir, reseller) every
+// tenant starts with are created in it, and it gets the tax rates of the
+// tenant of ctx.
+func (r *TenantRepo) Create(ctx context.Context, t *models.Tenant, userID int64) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    defer func() { _ = tx.Rollback() }()
+    var tarif []models.TarifPajak
+    rows, err := tx.QueryContext(ctx, `SELECT kode, tarif_bp, berlaku_mulai FROM tarif_pajak`)
+    if err != nil { return fmt.Errorf("query tarif: %w", err) }
+    for rows.Next() {
+        var tp models.TarifPajak
+        if err := rows.Scan(&tp.Kode, &tp.TarifBP, &tp.BerlakuMulai); err != nil { rows.Close(); return err }
+        tarif = append(tarif, tp)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return err }
+
+    err = tx.QueryRowContext(ctx, `INSERT INTO tenant (kode, nama) VALUES ($1, $2) RETURNING id, created_at`, t.Kode, t.Nama).Scan(&t.ID, &t.CreatedAt)
+    if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23505" {
+        return fmt.Errorf("%w: tenant %s already exists", apperr.ErrValidation, t.Kode)
+    }
+    if err != nil { return fmt.Errorf("insert tenant: %w", err) }
+    if _, err := tx.ExecContext(ctx, `INSERT INTO user_tenant (user_id, tenant_id, role) VALUES ($1, $2, $3)`, userID, t.ID, permission.Admin); err != nil {
+        return fmt.Errorf("insert user_tenant: %w", err)
+    }
+    // Rows of the new tenant are written with it as the statement's tenant.
+    baru := tenant.WithID(ctx, t.ID)
+    if _, err := tx.ExecContext(baru, `INSERT INTO daftar_harga (nama) VALUES ('retail'), ('grosir'), ('reseller')`); err != nil {
+        return fmt.Errorf("insert daftar_harga: %w", err)
+    }
+    for _, tp := range tarif {
+        if _, err := tx.ExecContext(baru, `INSERT INTO tarif_pajak (kode, tarif_bp, berlaku_mulai) VALUES ($1, $2, $3)`,
+            tp.Kode, tp.TarifBP, tp.BerlakuMulai); err != nil {
+            return fmt.Errorf("insert tarif_pajak: %w", err)
+        }
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    t.JumlahUser, t.Role = 1, permission.Admin
+    return nil
+}
+
+// SetUserTenants makes tenantIDs the tenants user userID is a member of,
+// among those actorID (who makes the change) is a member of; memberships
+// in other tenants are kept. Every id must be a tenant of actorID. New
+// memberships get role; removing the last active admin of a tenant is
+// rejected.
+func (r *TenantRepo) SetUserTenants(ctx context.Context, actorID, userID int64, tenantIDs []int64, role string) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    defer func() { _ = tx.Rollback() }()
+    var ada bool
+    if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&ada); err != nil { return err }
+    if !ada { return sql.ErrNoRows }
+    var asing []int64
+    if err := tx.QueryRowContext(ctx, `SELECT ARRAY(SELECT unnest($2::bigint[]) EXCEPT SELECT tenant_id FROM user_tenant WHERE user_id = $1)`,
+        actorID, pq.Array(tenantIDs)).Scan(pq.Array(&asing)); err != nil {
+        return err
+    }
+    if len(asing) > 0 {
+        return fmt.Errorf("%w: no access to tenant %d", apperr.ErrValidation, asing[0])
+    }
+    var keluar []int64
+    if err := tx.QueryRowContext(ctx, `SELECT ARRAY(SELECT tenant_id FROM user_tenant WHERE user_id = $1 AND NOT (tenant_id = ANY($2))
+        AND tenant_id IN (SELECT tenant_id FROM user_tenant WHERE user_id = $3))`, userID, pq.Array(tenantIDs), actorID).Scan(pq.Array(&keluar)); err != nil {
+        return err
+    }
+    if err := sisaAdmin(ctx, tx, userID, keluar); err != nil { return err }
+    if _, err := tx.ExecContext(ctx, `DELETE FROM user_tenant WHERE user_id = $1 AND tenant_id = ANY($2)`, userID, pq.Array(keluar)); err != nil {
+        return err
+    }
+    if _, err := tx.ExecContext(ctx, `INSERT INTO user_tenant (user_id, tenant_id, role)
+        SELECT $1, unnest($2::bigint[]), $3 ON CONFLICT DO NOTHING`, userID, pq.Array(tenantIDs), role); err != nil {
+        return userError(err)
+    }
+    return tx.Commit()
+}
+
+// BypassesRLS reports whether the database role the application connects
+// as ignores row-level security (superuser or BYPASSRLS), which would
+// make every tenant's rows visible to every other.
+func (r *TenantRepo) BypassesRLS(ctx context.Context) (bool, error) {
+    var bypass bool
+    err := r.DB.QueryRowContext(ctx, `SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user`).Scan(&bypass)
+    return bypass, err
+}
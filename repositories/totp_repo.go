@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"warehouse/apperr"
+	"warehouse/models"
+	"warehouse/totp"
+)
+
+// ErrTOTPInvalid is returned for a wrong, reused or expired two-factor code.
+var ErrTOTPInvalid = errors.New("invalid two-factor code")
+
+// recoveryCodes is the number of recovery codes issued at a time.
+const recoveryCodes = 10
+
+// TOTPRepo stores the two-factor state of users. Secrets are sealed with
+// Box; Issuer names the account in authenticator apps.
+type TOTPRepo struct {
+    DB     *sql.DB
+    Box    *totp.Box
+    Issuer string
+}
+
+func NewTOTPRepo(db *sql.DB, box *totp.Box, issuer string) *TOTPRepo {
+    return &TOTPRepo{DB: db, Box: box, Issuer: issuer}
+}
+
+// Status returns the two-factor state of user id. Two-factor is required
+// when the user's role in any of their tenants requires it, as the account
+// and its second factor are shared by all of them.
+func (r *TOTPRepo) Status(ctx context.Context, id int64) (models.Status2FA, error) {
+    const q = `SELECT u.totp_aktif,
+            EXISTS (SELECT 1 FROM user_tenant ut JOIN role ro ON ro.nama = ut.role WHERE ut.user_id = u.id AND ro.wajib_2fa),
+            (SELECT COUNT(*) FROM recovery_code WHERE user_id = u.id AND used_at IS NULL)
+        FROM users u WHERE u.id = $1`
+    var s models.Status2FA
+    err := r.DB.QueryRowContext(ctx, q, id).Scan(&s.Aktif, &s.Wajib, &s.SisaRecoveryCode)
+    return s, err
+}
+
+// Start generates a new secret for user id, replacing an unfinished
+// enrollment. It fails when two-factor is already active.
+func (r *TOTPRepo) Start(ctx context.Context, id int64) (*models.Enrollment2FA, error) {
+    var e *models.Enrollment2FA
+    err := r.inTx(ctx, func(tx *sql.Tx) error {
+        var username string
+        var aktif bool
+        err := tx.QueryRowContext(ctx, `SELECT username, totp_aktif FROM users WHERE id=$1 FOR UPDATE`, id).Scan(&username, &aktif)
+        if err != nil { return err }
+        if aktif {
+            return fmt.Errorf("%w: two-factor authentication is already enabled", apperr.ErrValidation)
+        }
+        secret, err := totp.NewSecret()
+        if err != nil { return err }
+        stored, err := r.Box.Seal(secret)
+        if err != nil { return err }
+        if _, err := tx.ExecContext(ctx, `UPDATE users SET totp_secret=$1, totp_step=0 WHERE id=$2`, stored, id); err != nil {
+            return err
+        }
+        e = &models.Enrollment2FA{Secret: secret, OtpauthURI: totp.URI(r.Issuer, username, secret)}
+        return nil
+    })
+    return e, err
+}
+
+// Activate finishes enrollment with a code from the new secret and returns
+// fresh recovery codes.
+func (r *TOTPRepo) Activate(ctx context.Context, id int64, code string) ([]string, error) {
+    var codes []string
+    err := r.inTx(ctx, func(tx *sql.Tx) error {
+        st, err := r.lock(ctx, tx, id)
+        if err != nil { return err }
+        if st.aktif {
+            return fmt.Errorf("%w: two-factor authentication is already enabled", apperr.ErrValidation)
+        }
+        if st.secret == "" {
+            return fmt.Errorf("%w: start enrollment first", apperr.ErrValidation)
+        }
+        if err := r.verify(ctx, tx, id, st, code); err != nil { return err }
+        if _, err := tx.ExecContext(ctx, `UPDATE users SET totp_aktif=TRUE WHERE id=$1`, id); err != nil { return err }
+        codes, err = replaceRecoveryCodes(ctx, tx, id)
+        return err
+    })
+    return codes, err
+}
+
+// Check verifies a second factor of user id: a TOTP code or, when code is
+// empty, a recovery code, which is used up.
+func (r *TOTPRepo) Check(ctx context.Context, id int64, code, recovery string) error {
+    return r.inTx(ctx, func(tx *sql.Tx) error {
+        st, err := r.lock(ctx, tx, id)
+        if err != nil { return err }
+        if !st.aktif {
+            return fmt.Errorf("%w: two-factor authentication is not enabled", apperr.ErrValidation)
+        }
+        if code != "" { return r.verify(ctx, tx, id, st, code) }
+        res, err := tx.ExecContext(ctx, `UPDATE recovery_code SET used_at=NOW()
+            WHERE id = (SELECT id FROM recovery_code WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL LIMIT 1)`,
+            id, totp.HashRecoveryCode(recovery))
+        if err != nil { return err }
+        if n, _ := res.RowsAffected(); n == 0 { return ErrTOTPInvalid }
+        return nil
+    })
+}
+
+// NewRecoveryCodes replaces the recovery codes of user id.
+func (r *TOTPRepo) NewRecoveryCodes(ctx context.Context, id int64) ([]string, error) {
+    var codes []string
+    err := r.inTx(ctx, func(tx *sql.Tx) error {
+        var err error
+        codes, err = replaceRecoveryCodes(ctx, tx, id)
+        return err
+    })
+    return codes, err
+}
+
+// Disable turns two-factor off for user id and drops its secret and
+// recovery codes.
+func (r *TOTPRepo) Disable(ctx context.Context, id int64) error {
+    return r.inTx(ctx, func(tx *sql.Tx) error {
+        res, err := tx.ExecContext(ctx, `UPDATE users SET totp_secret=NULL, totp_aktif=FALSE, totp_step=0 WHERE id=$1`, id)
+        if err != nil { return err }
+        if n, _ := res.RowsAffected(); n == 0 { return sql.ErrNoRows }
+        _, err = tx.ExecContext(ctx, `DELETE FROM recovery_code WHERE user_id=$1`, id)
+        return err
+    })
+}
+
+type totpState struct {
+    secret string
+    aktif  bool
+    step   int64
+}
+
+// lock reads and locks the two-factor columns of user id, so concurrent
+// requests cannot both accept the same code.
+func (r *TOTPRepo) lock(ctx context.Context, tx *sql.Tx, id int64) (totpState, error) {
+    var st totpState
+    var stored sql.NullString
+    err := tx.QueryRowContext(ctx, `SELECT totp_secret, totp_aktif, totp_step FROM users WHERE id=$1 FOR UPDATE`, id).Scan(&stored, &st.aktif, &st.step)
+    if err != nil { return st, err }
+    if stored.Valid {
+        if st.secret, err = r.Box.Open(stored.String); err != nil { return st, err }
+    }
+    return st, nil
+}
+
+// verify checks code and records its time step.
+func (r *TOTPRepo) verify(ctx context.Context, tx *sql.Tx, id int64, st totpState, code string) error {
+    step, ok := totp.Verify(st.secret, code, time.Now(), st.step)
+    if !ok { return ErrTOTPInvalid }
+    _, err := tx.ExecContext(ctx, `UPDATE users SET totp_step=$1 WHERE id=$2`, step, id)
+    return err
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, id int64) ([]string, error) {
+    codes, err := totp.NewRecoveryCodes(recoveryCodes)
+    if err != nil { return nil, err }
+    if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_code WHERE user_id=$1`, id); err != nil { return nil, err }
+    for _, c := range codes {
+        if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_code (user_id, code_hash) VALUES ($1, $2)`, id, totp.HashRecoveryCode(c)); err != nil {
+            return nil, err
+        }
+    }
+    return codes, nil
+}
+
+func (r *TOTPRepo) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    if err := fn(tx); err != nil {
+        _ = tx.Rollback()
+        return err
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
@@ -4,19 +4,247 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+
+	"warehouse/apperr"
 	"warehouse/models"
+	"warehouse/tenant"
+
+	"github.com/lib/pq"
 )
 
 type UserRepo struct { DB *sql.DB }
 
 func NewUserRepo(db *sql.DB) *UserRepo { return &UserRepo{DB: db} }
 
-func (r *UserRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
-    const q = `SELECT id, username, password, email, full_name, role FROM users WHERE username = $1`
+// roleDiTenant is the role of user u in the tenant of the statement, empty
+// when u is not a member of it. Roles are given per tenant.
+const roleDiTenant = `COALESCE((SELECT ut.role FROM user_tenant ut WHERE ut.user_id = u.id AND ut.tenant_id = app_tenant()), '')`
+
+const userColumns = `u.id, u.username, u.password, u.email, u.full_name, ` + roleDiTenant + `, u.service, u.aktif, u.created_at`
+
+func scanUser(row interface{ Scan(...any) error }) (*models.User, error) {
     var u models.User
-    if err := r.DB.QueryRowContext(ctx, q, username).Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.FullName, &u.Role); err != nil {
+    var aktif bool
+    var created sql.NullTime
+    if err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.FullName, &u.Role, &u.Service, &aktif, &created); err != nil {
+        return nil, err
+    }
+    u.Aktif = &aktif
+    if created.Valid { t := created.Time; u.CreatedAt = &t }
+    return &u, nil
+}
+
+func (r *UserRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+    u, err := scanUser(r.DB.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users u WHERE u.username = $1`, username))
+    if err != nil {
         if err == sql.ErrNoRows { return nil, nil }
         return nil, fmt.Errorf("get user by username: %w", err)
     }
-    return &u, nil
+    return u, nil
+}
+
+// GetByID returns nil when the user does not exist.
+func (r *UserRepo) GetByID(ctx context.Context, id int64) (*models.User, error) {
+    u, err := scanUser(r.DB.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users u WHERE u.id = $1`, id))
+    if err != nil {
+        if err == sql.ErrNoRows { return nil, nil }
+        return nil, fmt.Errorf("get user by id: %w", err)
+    }
+    return u, nil
+}
+
+// AuthStatus returns the current role of a user in tenant tenantID and its
+// permissions, whether the user is active, still a member of the tenant and
+// whether session sid has been revoked. It is checked on every authenticated
+// request so deactivation, role, permission and membership changes and
+// logout apply immediately.
+func (r *UserRepo) AuthStatus(ctx context.Context, id, tenantID int64, sid string) (models.StatusAuth, error) {
+    const q = `SELECT COALESCE(ut.role, ''), ARRAY(SELECT permission FROM role_permission WHERE role = ut.role ORDER BY permission), u.aktif,
+            EXISTS (SELECT 1 FROM refresh_token WHERE family_id = $2 AND revoked_at IS NOT NULL),
+            ut.user_id IS NOT NULL
+        FROM users u LEFT JOIN user_tenant ut ON ut.user_id = u.id AND ut.tenant_id = $3
+        WHERE u.id = $1`
+    var s models.StatusAuth
+    err := r.DB.QueryRowContext(ctx, q, id, sid, tenantID).Scan(&s.Role, pq.Array(&s.Permissions), &s.Aktif, &s.SesiDicabut, &s.Anggota)
+    if err == sql.ErrNoRows { return models.StatusAuth{}, nil }
+    return s, err
+}
+
+// Dikelola reports whether actorID may change user id, whose account,
+// sessions and two-factor settings are shared by all of their tenants:
+// actorID must hold perm in every other tenant user id is a member of. With
+// lokal (requests made with an API key, which belongs to one tenant) user id
+// must not be a member of any other tenant.
+func (r *UserRepo) Dikelola(ctx context.Context, id, actorID int64, perm string, lokal bool) (bool, error) {
+    var ok bool
+    err := r.DB.QueryRowContext(ctx, `SELECT NOT EXISTS (
+            SELECT 1 FROM user_tenant t
+            WHERE t.user_id = $1 AND t.tenant_id IS DISTINCT FROM app_tenant()
+              AND ($4 OR NOT EXISTS (
+                  SELECT 1 FROM user_tenant a JOIN role_permission rp ON rp.role = a.role
+                  WHERE a.user_id = $2 AND a.tenant_id = t.tenant_id AND rp.permission = $3)))`,
+        id, actorID, perm, lokal).Scan(&ok)
+    return ok, err
+}
+
+// Anggota reports whether user id is a member of the tenant of ctx.
+func (r *UserRepo) Anggota(ctx context.Context, id int64) (bool, error) {
+    var ok bool
+    err := r.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM user_tenant WHERE user_id = $1 AND tenant_id = app_tenant())`, id).Scan(&ok)
+    return ok, err
+}
+
+// List returns the members of the tenant of ctx matching search (username,
+// email or full_name), optionally filtered by aktif.
+func (r *UserRepo) List(ctx context.Context, search string, aktif *bool, page, limit int) ([]models.User, int, error) {
+    if page < 1 { page = 1 }
+    if limit < 1 { limit = 10 }
+    where := []string{"id IN (SELECT user_id FROM user_tenant WHERE tenant_id = app_tenant())"}
+    args := []interface{}{}
+    if search != "" {
+        args = append(args, "%"+search+"%")
+        where = append(where, fmt.Sprintf("(username ILIKE $%d OR email ILIKE $%d OR full_name ILIKE $%d)", len(args), len(args), len(args)))
+    }
+    if aktif != nil {
+        args = append(args, *aktif)
+        where = append(where, fmt.Sprintf("aktif = $%d", len(args)))
+    }
+    whereSQL := " WHERE " + strings.Join(where, " AND ")
+
+    var total int
+    if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+whereSQL, args...).Scan(&total); err != nil {
+        return nil, 0, err
+    }
+    q := fmt.Sprintf(`SELECT `+userColumns+` FROM users u%s ORDER BY u.username ASC LIMIT $%d OFFSET $%d`, whereSQL, len(args)+1, len(args)+2)
+    rows, err := r.DB.QueryContext(ctx, q, append(args, limit, (page-1)*limit)...)
+    if err != nil { return nil, 0, err }
+    defer rows.Close()
+    list := []models.User{}
+    for rows.Next() {
+        u, err := scanUser(rows)
+        if err != nil { return nil, 0, err }
+        list = append(list, *u)
+    }
+    if err := rows.Err(); err != nil { return nil, 0, err }
+    return list, total, nil
+}
+
+// Create inserts an active user, a member of the tenant of ctx with role
+// u.Role; u.Password must already be hashed.
+func (r *UserRepo) Create(ctx context.Context, u *models.User) error {
+    const q = `WITH u AS (
+            INSERT INTO users (username, password, email, full_name, service)
+            VALUES ($1, $2, $3, $4, $6) RETURNING id, aktif, created_at
+        ), m AS (
+            INSERT INTO user_tenant (user_id, tenant_id, role) SELECT id, app_tenant(), $5 FROM u
+        )
+        SELECT id, aktif, created_at FROM u`
+    var aktif bool
+    var created sql.NullTime
+    if err := r.DB.QueryRowContext(ctx, q, u.Username, u.Password, u.Email, u.FullName, u.Role, u.Service).Scan(&u.ID, &aktif, &created); err != nil {
+        return userError(err)
+    }
+    u.Aktif = &aktif
+    if created.Valid { t := created.Time; u.CreatedAt = &t }
+    return nil
+}
+
+// Update changes email, full_name and the role in the tenant of ctx.
+// Demoting the last active admin of the tenant is rejected.
+func (r *UserRepo) Update(ctx context.Context, u *models.User) error {
+    tid, _ := tenant.FromContext(ctx)
+    return r.inTx(ctx, func(tx *sql.Tx) error {
+        if u.Role != "admin" {
+            if err := sisaAdmin(ctx, tx, u.ID, []int64{tid}); err != nil { return err }
+        }
+        res, err := tx.ExecContext(ctx, `UPDATE users SET email=$1, full_name=$2 WHERE id=$3`, u.Email, u.FullName, u.ID)
+        if err != nil { return userError(err) }
+        if n, _ := res.RowsAffected(); n == 0 { return sql.ErrNoRows }
+        res, err = tx.ExecContext(ctx, `UPDATE user_tenant SET role=$1 WHERE user_id=$2 AND tenant_id = app_tenant()`, u.Role, u.ID)
+        if err != nil { return userError(err) }
+        if n, _ := res.RowsAffected(); n == 0 { return sql.ErrNoRows }
+        return nil
+    })
+}
+
+// SetAktif activates or deactivates a user in all of their tenants.
+// Deactivating the last active admin of any of them is rejected.
+func (r *UserRepo) SetAktif(ctx context.Context, id int64, aktif bool) error {
+    return r.inTx(ctx, func(tx *sql.Tx) error {
+        if !aktif {
+            var tenants []int64
+            if err := tx.QueryRowContext(ctx, `SELECT ARRAY(SELECT tenant_id FROM user_tenant WHERE user_id = $1)`, id).Scan(pq.Array(&tenants)); err != nil {
+                return err
+            }
+            if err := sisaAdmin(ctx, tx, id, tenants); err != nil { return err }
+        }
+        res, err := tx.ExecContext(ctx, `UPDATE users SET aktif=$1 WHERE id=$2`, aktif, id)
+        if err != nil { return err }
+        if n, _ := res.RowsAffected(); n == 0 { return sql.ErrNoRows }
+        return nil
+    })
+}
+
+// UpdatePassword stores a new bcrypt hash.
+func (r *UserRepo) UpdatePassword(ctx context.Context, id int64, hash string) error {
+    res, err := r.DB.ExecContext(ctx, `UPDATE users SET password=$1 WHERE id=$2`, hash, id)
+    if err != nil { return err }
+    if n, _ := res.RowsAffected(); n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+func (r *UserRepo) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    if err := fn(tx); err != nil {
+        _ = tx.Rollback()
+        return err
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// sisaAdmin fails when user id is the only active admin of one of tenants,
+// which would lock everyone out of the admin endpoints there. The active
+// admins of those tenants are locked so two concurrent requests cannot each
+// remove the other.
+func sisaAdmin(ctx context.Context, tx *sql.Tx, id int64, tenants []int64) error {
+    if len(tenants) == 0 { return nil }
+    rows, err := tx.QueryContext(ctx, `SELECT ut.tenant_id, ut.user_id FROM user_tenant ut JOIN users u ON u.id = ut.user_id
+        WHERE ut.role = 'admin' AND u.aktif AND ut.tenant_id = ANY($1::bigint[])
+        FOR UPDATE`, pq.Array(tenants))
+    if err != nil { return err }
+    defer rows.Close()
+    lain := make(map[int64]int)
+    self := make(map[int64]bool)
+    for rows.Next() {
+        var tid, aid int64
+        if err := rows.Scan(&tid, &aid); err != nil { return err }
+        if aid == id { self[tid] = true } else { lain[tid]++ }
+    }
+    if err := rows.Err(); err != nil { return err }
+    for _, tid := range tenants {
+        if self[tid] && lain[tid] == 0 {
+            return fmt.Errorf("%w: at least one active admin is required in tenant %d", apperr.ErrValidation, tid)
+        }
+    }
+    return nil
+}
+
+// userError maps duplicate usernames and emails and unknown roles to
+// validation errors.
+func userError(err error) error {
+    if pqErr, ok := err.(*pq.Error); ok {
+        switch string(pqErr.Code) {
+        case "23505":
+            if strings.Contains(pqErr.Constraint, "email") {
+                return fmt.Errorf("%w: email is already used", apperr.ErrValidation)
+            }
+            return fmt.Errorf("%w: username is already used", apperr.ErrValidation)
+        case "23503":
+            return fmt.Errorf("%w: role does not exist", apperr.ErrValidation)
+        }
+    }
+    return err
 }
@@ -0,0 +1,429 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"warehouse/apperr"
+	"warehouse/models"
+
+	"github.com/lib/pq"
+)
+
+// VarianRepo manages parent products (barang_induk) and their variants,
+// which are master_barang rows linked by induk_id.
+type VarianRepo struct {
+    DB *sql.DB
+}
+
+func NewVarianRepo(db *sql.DB) *VarianRepo { return &VarianRepo{DB: db} }
+
+// ErrIndukInUse is returned when a barang induk still has variants.
+var ErrIndukInUse = errors.New("barang induk in use")
+
+// maxVarian caps the number of variants of one barang induk.
+const maxVarian = 500
+
+// GenerateKodeInduk generates a new kode_induk with format IND-0001, IND-0002, ...
+func (r *VarianRepo) GenerateKodeInduk(ctx context.Context) (string, error) {
+    const q = `
+        SELECT COALESCE(MAX(CAST(SUBSTRING(kode_induk FROM '[0-9]+') AS INTEGER)), 0)
+        FROM barang_induk
+        WHERE kode_induk LIKE 'IND-%'`
+    var maxNum int
+    if err := r.DB.QueryRowContext(ctx, q).Scan(&maxNum); err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("IND-%04d", maxNum+1), nil
+}
+
+// CreateInduk saves in and generates one variant per combination of its
+// attribute values, priced from in unless an entry of harga matches.
+func (r *VarianRepo) CreateInduk(ctx context.Context, in *models.BarangInduk, harga []models.HargaVarian) error {
+    atribut, err := rapikanAtribut(in.Atribut)
+    if err != nil { return err }
+    in.Atribut = atribut
+    raw, _ := json.Marshal(in.Atribut)
+
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+    err = tx.QueryRowContext(ctx, `INSERT INTO barang_induk (kode_induk, nama, deskripsi, satuan, harga_beli, harga_jual, kategori_id, atribut)
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id, created_at`,
+        in.KodeInduk, in.Nama, in.Deskripsi, in.Satuan, in.HargaBeli, in.HargaJual, in.KategoriID, string(raw),
+    ).Scan(&in.ID, &in.CreatedAt)
+    if err != nil { return rollback(indukError(err)) }
+    if _, err := buatVarian(ctx, tx, in, harga); err != nil { return rollback(err) }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// TambahVarian adds attribute values to barang induk id and generates the
+// variants that do not exist yet, returning how many were created. The
+// attribute names are fixed when the barang induk is created.
+func (r *VarianRepo) TambahVarian(ctx context.Context, id int64, tambah []models.AtributVarian, harga []models.HargaVarian) (int, error) {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return 0, fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) (int, error) {
+        _ = tx.Rollback()
+        return 0, e
+    }
+
+    var in models.BarangInduk
+    var ds sql.NullString
+    var kat sql.NullInt64
+    var raw []byte
+    err = tx.QueryRowContext(ctx, `SELECT id, kode_induk, nama, deskripsi, satuan, harga_beli, harga_jual, kategori_id, atribut
+            FROM barang_induk WHERE id=$1 FOR UPDATE`, id).
+        Scan(&in.ID, &in.KodeInduk, &in.Nama, &ds, &in.Satuan, &in.HargaBeli, &in.HargaJual, &kat, &raw)
+    if err != nil { return rollback(err) }
+    if ds.Valid { v := ds.String; in.Deskripsi = &v }
+    in.KategoriID = nullInt64Ptr(kat)
+    if err := json.Unmarshal(raw, &in.Atribut); err != nil { return rollback(fmt.Errorf("decode atribut: %w", err)) }
+
+    for _, t := range tambah {
+        i := indexAtribut(in.Atribut, t.Nama)
+        if i < 0 {
+            return rollback(fmt.Errorf("%w: unknown atribut %q", apperr.ErrValidation, t.Nama))
+        }
+        in.Atribut[i].Nilai = append(in.Atribut[i].Nilai, t.Nilai...)
+    }
+    if in.Atribut, err = rapikanAtribut(in.Atribut); err != nil { return rollback(err) }
+    raw, _ = json.Marshal(in.Atribut)
+    if _, err := tx.ExecContext(ctx, `UPDATE barang_induk SET atribut=$1 WHERE id=$2`, string(raw), id); err != nil {
+        return rollback(err)
+    }
+    n, err := buatVarian(ctx, tx, &in, harga)
+    if err != nil { return rollback(err) }
+    if err := tx.Commit(); err != nil { return 0, fmt.Errorf("commit tx: %w", err) }
+    return n, nil
+}
+
+// UpdateInduk saves the parent fields of in. Satuan and kategori are copied
+// to every variant and the prices to the variants without prices of their
+// own, recording harga_history attributed to userID.
+func (r *VarianRepo) UpdateInduk(ctx context.Context, in *models.BarangInduk, userID int64) error {
+    tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return fmt.Errorf("begin tx: %w", err) }
+    rollback := func(e error) error {
+        _ = tx.Rollback()
+        return e
+    }
+
+    var raw []byte
+    err = tx.QueryRowContext(ctx, `UPDATE barang_induk
+            SET nama=$1, deskripsi=$2, satuan=$3, harga_beli=$4, harga_jual=$5, kategori_id=$6
+            WHERE id=$7
+            RETURNING kode_induk, atribut, created_at`,
+        in.Nama, in.Deskripsi, in.Satuan, in.HargaBeli, in.HargaJual, in.KategoriID, in.ID,
+    ).Scan(&in.KodeInduk, &raw, &in.CreatedAt)
+    if err != nil { return rollback(indukError(err)) }
+    if err := json.Unmarshal(raw, &in.Atribut); err != nil { return rollback(fmt.Errorf("decode atribut: %w", err)) }
+
+    if _, err := tx.ExecContext(ctx, `UPDATE master_barang SET satuan=$1, kategori_id=$2, version=version+1
+            WHERE induk_id=$3 AND (satuan <> $1 OR kategori_id IS DISTINCT FROM $2)`,
+        in.Satuan, in.KategoriID, in.ID); err != nil {
+        return rollback(err)
+    }
+
+    type harga struct{ id, beli, jual int64 }
+    rows, err := tx.QueryContext(ctx, `SELECT id, harga_beli, harga_jual FROM master_barang
+            WHERE induk_id=$1 AND NOT harga_sendiri AND (harga_beli <> $2 OR harga_jual <> $3)
+            ORDER BY id FOR UPDATE`, in.ID, in.HargaBeli, in.HargaJual)
+    if err != nil { return rollback(err) }
+    lama := make([]harga, 0)
+    for rows.Next() {
+        var h harga
+        if err := rows.Scan(&h.id, &h.beli, &h.jual); err != nil {
+            rows.Close()
+            return rollback(err)
+        }
+        lama = append(lama, h)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return rollback(err) }
+    for _, h := range lama {
+        if _, err := tx.ExecContext(ctx, `UPDATE master_barang SET harga_beli=$1, harga_jual=$2, version=version+1 WHERE id=$3`,
+            in.HargaBeli, in.HargaJual, h.id); err != nil {
+            return rollback(err)
+        }
+        if err := catatPerubahanHarga(ctx, tx, h.id, h.beli, in.HargaBeli, h.jual, in.HargaJual, userID, "harga barang induk"); err != nil {
+            return rollback(err)
+        }
+    }
+    if err := tx.Commit(); err != nil { return fmt.Errorf("commit tx: %w", err) }
+    return nil
+}
+
+// DeleteInduk removes a barang induk without variants.
+func (r *VarianRepo) DeleteInduk(ctx context.Context, id int64) error {
+    res, err := r.DB.ExecContext(ctx, `DELETE FROM barang_induk WHERE id=$1`, id)
+    if err != nil {
+        if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23503" {
+            return ErrIndukInUse
+        }
+        return err
+    }
+    n, _ := res.RowsAffected()
+    if n == 0 { return sql.ErrNoRows }
+    return nil
+}
+
+const indukKolom = `i.id, i.kode_induk, i.nama, i.deskripsi, i.satuan, i.harga_beli, i.harga_jual, i.kategori_id, i.atribut, i.created_at`
+
+// scanInduk scans indukKolom followed by dest.
+func scanInduk(sc interface{ Scan(...interface{}) error }, in *models.BarangInduk, dest ...interface{}) error {
+    var ds sql.NullString
+    var kat sql.NullInt64
+    var raw []byte
+    if err := sc.Scan(append([]interface{}{&in.ID, &in.KodeInduk, &in.Nama, &ds, &in.Satuan, &in.HargaBeli, &in.HargaJual, &kat, &raw, &in.CreatedAt}, dest...)...); err != nil {
+        return err
+    }
+    if ds.Valid { v := ds.String; in.Deskripsi = &v }
+    in.KategoriID = nullInt64Ptr(kat)
+    in.Atribut = make([]models.AtributVarian, 0)
+    if err := json.Unmarshal(raw, &in.Atribut); err != nil { return fmt.Errorf("decode atribut: %w", err) }
+    return nil
+}
+
+// ListInduk lists barang induk, newest first, optionally filtered by a search
+// term on nama/kode, with variant count and stock totals.
+func (r *VarianRepo) ListInduk(ctx context.Context, search string, page, limit int) ([]models.BarangInduk, int, error) {
+    if page < 1 { page = 1 }
+    if limit < 1 { limit = 10 }
+    offset := (page - 1) * limit
+
+    whereSQL := ""
+    args := make([]interface{}, 0)
+    if search != "" {
+        args = append(args, "%"+search+"%")
+        whereSQL = " WHERE (i.nama ILIKE $1 OR i.kode_induk ILIKE $1)"
+    }
+    var total int
+    if err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM barang_induk i`+whereSQL, args...).Scan(&total); err != nil {
+        return nil, 0, err
+    }
+    q := fmt.Sprintf(`SELECT `+indukKolom+`,
+            COUNT(b.id), COALESCE(SUM(s.stok_akhir), 0), COALESCE(SUM(s.stok_akhir::BIGINT * b.harga_beli), 0)
+        FROM barang_induk i
+        LEFT JOIN master_barang b ON b.induk_id = i.id
+        LEFT JOIN mstok s ON s.barang_id = b.id%s
+        GROUP BY i.id
+        ORDER BY i.id DESC
+        LIMIT $%d OFFSET $%d`, whereSQL, len(args)+1, len(args)+2)
+    rows, err := r.DB.QueryContext(ctx, q, append(args, limit, offset)...)
+    if err != nil { return nil, 0, err }
+    defer rows.Close()
+
+    list := make([]models.BarangInduk, 0)
+    for rows.Next() {
+        var in models.BarangInduk
+        if err := scanInduk(rows, &in, &in.JumlahVarian, &in.TotalStok, &in.NilaiStok); err != nil {
+            return nil, 0, err
+        }
+        list = append(list, in)
+    }
+    if err := rows.Err(); err != nil { return nil, 0, err }
+    return list, total, nil
+}
+
+// GetInduk returns a barang induk with all of its variants and their stock,
+// archived variants included, or nil when it does not exist.
+func (r *VarianRepo) GetInduk(ctx context.Context, id int64) (*models.BarangInduk, error) {
+    var in models.BarangInduk
+    err := scanInduk(r.DB.QueryRowContext(ctx, `SELECT `+indukKolom+` FROM barang_induk i WHERE i.id=$1`, id), &in)
+    if err == sql.ErrNoRows { return nil, nil }
+    if err != nil { return nil, err }
+
+    rows, err := r.DB.QueryContext(ctx, `SELECT b.id, b.kode_barang, b.nama_barang, b.varian, b.harga_beli, b.harga_jual, b.harga_sendiri,
+            COALESCE(s.stok_akhir, 0), b.archived_at
+        FROM master_barang b
+        LEFT JOIN mstok s ON s.barang_id = b.id
+        WHERE b.induk_id = $1
+        ORDER BY b.id ASC`, id)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    in.Varian = make([]models.VarianStok, 0)
+    for rows.Next() {
+        var v models.VarianStok
+        var raw []byte
+        var arsip sql.NullTime
+        if err := rows.Scan(&v.BarangID, &v.KodeBarang, &v.NamaBarang, &raw, &v.HargaBeli, &v.HargaJual, &v.HargaSendiri, &v.StokAkhir, &arsip); err != nil {
+            return nil, err
+        }
+        v.Varian = decodeVarian(raw)
+        if arsip.Valid { t := arsip.Time; v.ArchivedAt = &t }
+        in.JumlahVarian++
+        in.TotalStok += v.StokAkhir
+        in.NilaiStok += v.StokAkhir * v.HargaBeli
+        in.Varian = append(in.Varian, v)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    return &in, nil
+}
+
+// buatVarian inserts the variants of in that do not exist yet and returns
+// how many were created.
+func buatVarian(ctx context.Context, tx *sql.Tx, in *models.BarangInduk, harga []models.HargaVarian) (int, error) {
+    kombinasi := kombinasiVarian(in.Atribut)
+    if len(kombinasi) > maxVarian {
+        return 0, fmt.Errorf("%w: %d variants, at most %d allowed", apperr.ErrValidation, len(kombinasi), maxVarian)
+    }
+    for _, h := range harga {
+        if (h.HargaBeli != nil && *h.HargaBeli < 0) || (h.HargaJual != nil && *h.HargaJual < 0) {
+            return 0, fmt.Errorf("%w: harga_varian prices must be >= 0", apperr.ErrValidation)
+        }
+    }
+
+    ada := make(map[string]bool)
+    rows, err := tx.QueryContext(ctx, `SELECT varian FROM master_barang WHERE induk_id=$1`, in.ID)
+    if err != nil { return 0, err }
+    for rows.Next() {
+        var raw []byte
+        if err := rows.Scan(&raw); err != nil {
+            rows.Close()
+            return 0, err
+        }
+        k, _ := json.Marshal(decodeVarian(raw))
+        ada[string(k)] = true
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return 0, err }
+
+    n := 0
+    for _, nilai := range kombinasi {
+        varian := make(map[string]string, len(nilai))
+        for i, a := range in.Atribut {
+            varian[a.Nama] = nilai[i]
+        }
+        raw, _ := json.Marshal(varian) // map keys are sorted, so this is canonical
+        if ada[string(raw)] { continue }
+
+        beli, jual, sendiri := in.HargaBeli, in.HargaJual, false
+        for _, h := range harga {
+            if !cocokVarian(varian, h.Atribut) { continue }
+            if h.HargaBeli != nil { beli, sendiri = *h.HargaBeli, true }
+            if h.HargaJual != nil { jual, sendiri = *h.HargaJual, true }
+        }
+        kode := kodeVarian(in.KodeInduk, nilai)
+        nama := in.Nama + " " + strings.Join(nilai, " ")
+        if len(kode) > 50 || len(nama) > 120 {
+            return 0, fmt.Errorf("%w: variant %s is too long for kode_barang (50) or nama_barang (120)", apperr.ErrValidation, kode)
+        }
+        _, err := tx.ExecContext(ctx, `INSERT INTO master_barang
+                (kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual, kategori_id, induk_id, varian, harga_sendiri)
+                VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`,
+            kode, nama, in.Deskripsi, in.Satuan, beli, jual, in.KategoriID, in.ID, string(raw), sendiri)
+        if err != nil {
+            if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23505" {
+                return 0, fmt.Errorf("%w: kode_barang %s already exists", apperr.ErrValidation, kode)
+            }
+            return 0, fmt.Errorf("insert varian: %w", err)
+        }
+        n++
+    }
+    return n, nil
+}
+
+// rapikanAtribut trims attribute names and values and drops duplicate values.
+// Names must be unique and every attribute needs at least one value.
+func rapikanAtribut(atribut []models.AtributVarian) ([]models.AtributVarian, error) {
+    if len(atribut) == 0 {
+        return nil, fmt.Errorf("%w: atribut is required", apperr.ErrValidation)
+    }
+    out := make([]models.AtributVarian, 0, len(atribut))
+    for _, a := range atribut {
+        a.Nama = strings.TrimSpace(a.Nama)
+        if a.Nama == "" {
+            return nil, fmt.Errorf("%w: atribut nama is required", apperr.ErrValidation)
+        }
+        if indexAtribut(out, a.Nama) >= 0 {
+            return nil, fmt.Errorf("%w: duplicate atribut %q", apperr.ErrValidation, a.Nama)
+        }
+        nilai := make([]string, 0, len(a.Nilai))
+        seen := make(map[string]bool)
+        for _, v := range a.Nilai {
+            v = strings.TrimSpace(v)
+            if v == "" || seen[strings.ToLower(v)] { continue }
+            seen[strings.ToLower(v)] = true
+            nilai = append(nilai, v)
+        }
+        if len(nilai) == 0 {
+            return nil, fmt.Errorf("%w: atribut %q needs at least one nilai", apperr.ErrValidation, a.Nama)
+        }
+        out = append(out, models.AtributVarian{Nama: a.Nama, Nilai: nilai})
+    }
+    return out, nil
+}
+
+// indexAtribut finds an attribute by name, ignoring case.
+func indexAtribut(atribut []models.AtributVarian, nama string) int {
+    for i, a := range atribut {
+        if strings.EqualFold(a.Nama, strings.TrimSpace(nama)) { return i }
+    }
+    return -1
+}
+
+// kombinasiVarian returns every combination of attribute values, the first
+// attribute varying slowest: S/Merah, S/Biru, M/Merah, ...
+func kombinasiVarian(atribut []models.AtributVarian) [][]string {
+    out := [][]string{{}}
+    for _, a := range atribut {
+        next := make([][]string, 0, len(out)*len(a.Nilai))
+        for _, prefix := range out {
+            for _, v := range a.Nilai {
+                k := append(append(make([]string, 0, len(prefix)+1), prefix...), v)
+                next = append(next, k)
+            }
+        }
+        out = next
+        if len(out) > maxVarian { break }
+    }
+    return out
+}
+
+// cocokVarian reports whether varian has every pair of filter (names
+// compared ignoring case).
+func cocokVarian(varian, filter map[string]string) bool {
+    for k, v := range filter {
+        found := false
+        for vk, vv := range varian {
+            if strings.EqualFold(vk, k) && strings.EqualFold(vv, strings.TrimSpace(v)) {
+                found = true
+                break
+            }
+        }
+        if !found { return false }
+    }
+    return true
+}
+
+// kodeVarian builds a variant kode from the parent kode and the values:
+// IND-0001 + [M, Merah Muda] -> IND-0001-M-MERAHMUDA.
+func kodeVarian(kodeInduk string, nilai []string) string {
+    parts := []string{kodeInduk}
+    for _, v := range nilai {
+        parts = append(parts, strings.ToUpper(strings.Join(strings.Fields(v), "")))
+    }
+    return strings.Join(parts, "-")
+}
+
+// indukError maps constraint violations on barang_induk to validation errors.
+func indukError(err error) error {
+    if pqErr, ok := err.(*pq.Error); ok {
+        switch string(pqErr.Code) {
+        case "23503":
+            return fmt.Errorf("%w: kategori not found", apperr.ErrValidation)
+        case "23505":
+            return fmt.Errorf("%w: kode_induk already exists", apperr.ErrValidation)
+        }
+    }
+    return err
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"warehouse/repositories"
+	"warehouse/tenant"
+)
+
+// HargaScheduler periodically applies scheduled price changes (jadwal_harga)
+// of every tenant.
+type HargaScheduler struct {
+    Repo     *repositories.JadwalHargaRepo
+    Tenants  *repositories.TenantRepo
+    Interval time.Duration
+}
+
+func NewHargaScheduler(repo *repositories.JadwalHargaRepo, tenants *repositories.TenantRepo, interval time.Duration) *HargaScheduler {
+    if interval <= 0 { interval = time.Minute }
+    return &HargaScheduler{Repo: repo, Tenants: tenants, Interval: interval}
+}
+
+// Run applies due changes immediately and then on every tick until ctx is done.
+func (s *HargaScheduler) Run(ctx context.Context) {
+    t := time.NewTicker(s.Interval)
+    defer t.Stop()
+    for {
+        s.tick(ctx)
+        select {
+        case <-ctx.Done():
+            return
+        case <-t.C:
+        }
+    }
+}
+
+func (s *HargaScheduler) tick(ctx context.Context) {
+    ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+    defer cancel()
+    ids, err := s.Tenants.IDs(ctx)
+    if err != nil {
+        log.Printf("jadwal harga: %v", err)
+        return
+    }
+    now := time.Now()
+    for _, id := range ids {
+        n, err := s.Repo.ApplyDue(tenant.WithID(ctx, id), now)
+        if err != nil {
+            log.Printf("jadwal harga: tenant %d: %v", id, err)
+            continue
+        }
+        if n > 0 {
+            log.Printf("jadwal harga: tenant %d: applied price changes for %d barang", id, n)
+        }
+    }
+}
@@ -3,6 +3,8 @@
 
 -- 0) Optional: set sane defaults for this session
 SET client_min_messages = WARNING;
+-- Rows seeded below belong to the default tenant (see 30)
+SET app.tenant_id = '1';
 
 -- 1) users
 CREATE TABLE IF NOT EXISTS users (
@@ -97,4 +99,514 @@ CREATE TABLE IF NOT EXISTS history_stok (
 CREATE INDEX IF NOT EXISTS idx_history_stok_barang ON history_stok (barang_id);
 CREATE INDEX IF NOT EXISTS idx_history_stok_user   ON history_stok (user_id);
 
+-- 9) tarif_pajak (effective-dated PPN rate, in basis points: 1100 = 11%)
+CREATE TABLE IF NOT EXISTS tarif_pajak (
+    id             BIGSERIAL PRIMARY KEY,
+    kode           VARCHAR(20)  NOT NULL DEFAULT 'PPN',
+    tarif_bp       INTEGER      NOT NULL CHECK (tarif_bp BETWEEN 0 AND 10000),
+    berlaku_mulai  DATE         NOT NULL,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (kode, berlaku_mulai)
+);
+INSERT INTO tarif_pajak (kode, tarif_bp, berlaku_mulai)
+SELECT 'PPN', 1100, '2022-04-01' WHERE NOT EXISTS (SELECT 1 FROM tarif_pajak WHERE kode = 'PPN' AND berlaku_mulai = '2022-04-01');
+
+-- Tax settings per barang: exempt items and tax-inclusive pricing
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS kena_pajak           BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS harga_termasuk_pajak BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Tax totals per invoice; tarif_ppn_bp keeps the rate the invoice was issued with
+ALTER TABLE jual_header ADD COLUMN IF NOT EXISTS tarif_ppn_bp INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jual_header ADD COLUMN IF NOT EXISTS dpp          INTEGER NOT NULL DEFAULT 0 CHECK (dpp >= 0);
+ALTER TABLE jual_header ADD COLUMN IF NOT EXISTS ppn          INTEGER NOT NULL DEFAULT 0 CHECK (ppn >= 0);
+ALTER TABLE jual_header ADD COLUMN IF NOT EXISTS grand_total  INTEGER NOT NULL DEFAULT 0 CHECK (grand_total >= 0);
+ALTER TABLE jual_detail ADD COLUMN IF NOT EXISTS kena_pajak   BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE jual_detail ADD COLUMN IF NOT EXISTS dpp          INTEGER NOT NULL DEFAULT 0 CHECK (dpp >= 0);
+ALTER TABLE jual_detail ADD COLUMN IF NOT EXISTS ppn          INTEGER NOT NULL DEFAULT 0 CHECK (ppn >= 0);
+
+ALTER TABLE beli_header ADD COLUMN IF NOT EXISTS tarif_ppn_bp INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE beli_header ADD COLUMN IF NOT EXISTS dpp          INTEGER NOT NULL DEFAULT 0 CHECK (dpp >= 0);
+ALTER TABLE beli_header ADD COLUMN IF NOT EXISTS ppn          INTEGER NOT NULL DEFAULT 0 CHECK (ppn >= 0);
+ALTER TABLE beli_header ADD COLUMN IF NOT EXISTS grand_total  INTEGER NOT NULL DEFAULT 0 CHECK (grand_total >= 0);
+ALTER TABLE beli_detail ADD COLUMN IF NOT EXISTS kena_pajak   BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE beli_detail ADD COLUMN IF NOT EXISTS dpp          INTEGER NOT NULL DEFAULT 0 CHECK (dpp >= 0);
+ALTER TABLE beli_detail ADD COLUMN IF NOT EXISTS ppn          INTEGER NOT NULL DEFAULT 0 CHECK (ppn >= 0);
+CREATE INDEX IF NOT EXISTS idx_jual_header_created ON jual_header (created_at);
+CREATE INDEX IF NOT EXISTS idx_beli_header_created ON beli_header (created_at);
+
+-- Invoices created before PPN was introduced (older than the first tarif_pajak row) carried
+-- no tax: their DPP is the subtotal and grand_total the total. Details go first, while the
+-- header still shows as not backfilled.
+UPDATE jual_detail d SET dpp = d.subtotal
+FROM jual_header h
+WHERE h.id = d.jual_header_id AND h.dpp = 0 AND h.grand_total = 0 AND h.total > 0 AND d.dpp = 0 AND d.ppn = 0
+  AND h.created_at < (SELECT MIN(created_at) FROM tarif_pajak);
+UPDATE jual_header SET dpp = total, grand_total = total
+WHERE dpp = 0 AND grand_total = 0 AND total > 0 AND created_at < (SELECT MIN(created_at) FROM tarif_pajak);
+UPDATE beli_detail d SET dpp = d.subtotal
+FROM beli_header h
+WHERE h.id = d.beli_header_id AND h.dpp = 0 AND h.grand_total = 0 AND h.total > 0 AND d.dpp = 0 AND d.ppn = 0
+  AND h.created_at < (SELECT MIN(created_at) FROM tarif_pajak);
+UPDATE beli_header SET dpp = total, grand_total = total
+WHERE dpp = 0 AND grand_total = 0 AND total > 0 AND created_at < (SELECT MIN(created_at) FROM tarif_pajak);
+
+-- 10) harga_history (written on every price change of a barang)
+CREATE TABLE IF NOT EXISTS harga_history (
+    id               BIGSERIAL PRIMARY KEY,
+    barang_id        BIGINT       NOT NULL REFERENCES master_barang(id) ON DELETE CASCADE,
+    harga_beli_lama  INTEGER      NOT NULL,
+    harga_beli_baru  INTEGER      NOT NULL,
+    harga_jual_lama  INTEGER      NOT NULL,
+    harga_jual_baru  INTEGER      NOT NULL,
+    user_id          BIGINT       REFERENCES users(id), -- NULL = system change
+    keterangan       VARCHAR(120) NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_harga_history_barang ON harga_history (barang_id, created_at DESC);
+
+-- 11) daftar_harga (named price lists) and daftar_harga_item (quantity-break tiers)
+CREATE TABLE IF NOT EXISTS daftar_harga (
+    id          BIGSERIAL PRIMARY KEY,
+    nama        VARCHAR(50) NOT NULL UNIQUE,
+    keterangan  TEXT
+);
+INSERT INTO daftar_harga (nama)
+SELECT v.nama FROM (VALUES ('retail'), ('grosir'), ('reseller')) AS v(nama)
+WHERE NOT EXISTS (SELECT 1 FROM daftar_harga d WHERE d.nama = v.nama);
+
+CREATE TABLE IF NOT EXISTS daftar_harga_item (
+    id               BIGSERIAL PRIMARY KEY,
+    daftar_harga_id  BIGINT  NOT NULL REFERENCES daftar_harga(id) ON DELETE CASCADE,
+    barang_id        BIGINT  NOT NULL REFERENCES master_barang(id) ON DELETE CASCADE,
+    min_qty          INTEGER NOT NULL DEFAULT 1 CHECK (min_qty >= 1),
+    harga            INTEGER NOT NULL CHECK (harga >= 0),
+    UNIQUE (daftar_harga_id, barang_id, min_qty)
+);
+CREATE INDEX IF NOT EXISTS idx_daftar_harga_item_barang ON daftar_harga_item (barang_id);
+
+-- 12) grup_pelanggan (customer groups priced by a daftar_harga)
+CREATE TABLE IF NOT EXISTS grup_pelanggan (
+    id               BIGSERIAL PRIMARY KEY,
+    nama             VARCHAR(50) NOT NULL UNIQUE,
+    daftar_harga_id  BIGINT      REFERENCES daftar_harga(id)
+);
+
+ALTER TABLE jual_header ADD COLUMN IF NOT EXISTS grup_pelanggan_id BIGINT REFERENCES grup_pelanggan(id);
+ALTER TABLE jual_detail ADD COLUMN IF NOT EXISTS daftar_harga_id   BIGINT REFERENCES daftar_harga(id);
+
+-- 13) jadwal_harga (scheduled price changes; NULL selesai = permanent change)
+CREATE TABLE IF NOT EXISTS jadwal_harga (
+    id               BIGSERIAL PRIMARY KEY,
+    barang_id        BIGINT       NOT NULL REFERENCES master_barang(id) ON DELETE CASCADE,
+    harga_beli       INTEGER      CHECK (harga_beli >= 0),
+    harga_jual       INTEGER      CHECK (harga_jual >= 0),
+    mulai            TIMESTAMPTZ  NOT NULL,
+    selesai          TIMESTAMPTZ  CHECK (selesai > mulai),
+    status           VARCHAR(20)  NOT NULL DEFAULT 'terjadwal', -- terjadwal, aktif, selesai, dibatalkan
+    harga_beli_asal  INTEGER,     -- prices before the change started, restored at selesai
+    harga_jual_asal  INTEGER,
+    keterangan       VARCHAR(120) NOT NULL DEFAULT '',
+    user_id          BIGINT       NOT NULL REFERENCES users(id),
+    created_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    CHECK (harga_beli IS NOT NULL OR harga_jual IS NOT NULL)
+);
+CREATE INDEX IF NOT EXISTS idx_jadwal_harga_barang ON jadwal_harga (barang_id);
+CREATE INDEX IF NOT EXISTS idx_jadwal_harga_due    ON jadwal_harga (status, mulai);
+
+-- kategori (barang categories) and master_barang.kategori_id; a change scheduled for a
+-- whole kategori is stored as one jadwal_harga row per barang carrying the kategori_id
+CREATE TABLE IF NOT EXISTS kategori (
+    id         BIGSERIAL PRIMARY KEY,
+    nama       VARCHAR(80) NOT NULL UNIQUE
+);
+
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS kategori_id BIGINT REFERENCES kategori(id);
+CREATE INDEX IF NOT EXISTS idx_master_barang_kategori ON master_barang (kategori_id);
+
+ALTER TABLE jadwal_harga ADD COLUMN IF NOT EXISTS kategori_id BIGINT REFERENCES kategori(id) ON DELETE SET NULL;
+
+-- 14) promo (promotion rules) and promo_item (harga_bundel components)
+CREATE TABLE IF NOT EXISTS promo (
+    id                BIGSERIAL PRIMARY KEY,
+    nama              VARCHAR(100) NOT NULL,
+    jenis             VARCHAR(30)  NOT NULL CHECK (jenis IN ('beli_x_gratis_y', 'harga_bundel', 'diskon_min_belanja')),
+    aktif             BOOLEAN      NOT NULL DEFAULT TRUE,
+    mulai             TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    selesai           TIMESTAMPTZ  CHECK (selesai > mulai),
+    prioritas         INTEGER      NOT NULL DEFAULT 0, -- lower is evaluated first
+    barang_id         BIGINT       REFERENCES master_barang(id),
+    beli_qty          INTEGER      NOT NULL DEFAULT 0,
+    gratis_qty        INTEGER      NOT NULL DEFAULT 0,
+    gratis_barang_id  BIGINT       REFERENCES master_barang(id), -- NULL = same as barang_id
+    harga_bundel      INTEGER      NOT NULL DEFAULT 0,
+    min_total         INTEGER      NOT NULL DEFAULT 0,
+    diskon_bp         INTEGER      NOT NULL DEFAULT 0 CHECK (diskon_bp BETWEEN 0 AND 10000),
+    diskon_nominal    INTEGER      NOT NULL DEFAULT 0,
+    maks_diskon       INTEGER      NOT NULL DEFAULT 0, -- 0 = no cap
+    created_at        TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_promo_aktif ON promo (aktif, mulai);
+
+CREATE TABLE IF NOT EXISTS promo_item (
+    id         BIGSERIAL PRIMARY KEY,
+    promo_id   BIGINT  NOT NULL REFERENCES promo(id) ON DELETE CASCADE,
+    barang_id  BIGINT  NOT NULL REFERENCES master_barang(id),
+    qty        INTEGER NOT NULL CHECK (qty > 0)
+);
+
+ALTER TABLE jual_header ADD COLUMN IF NOT EXISTS diskon   INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jual_header ADD COLUMN IF NOT EXISTS promo_id BIGINT  REFERENCES promo(id);
+ALTER TABLE jual_detail ADD COLUMN IF NOT EXISTS diskon   INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jual_detail ADD COLUMN IF NOT EXISTS promo_id BIGINT  REFERENCES promo(id);
+
+-- 15) kategori tree: parent_id (NULL = root; no cascade, non-empty parents cannot be deleted)
+ALTER TABLE kategori ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES kategori(id);
+-- Names are unique among siblings (roots included)
+ALTER TABLE kategori DROP CONSTRAINT IF EXISTS kategori_nama_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_kategori_parent_nama ON kategori (COALESCE(parent_id, 0), LOWER(nama));
+CREATE INDEX IF NOT EXISTS idx_kategori_parent ON kategori (parent_id);
+
+-- 16) barang_barcode (EAN/UPC barcodes, many per barang; UPC-A stored as EAN-13)
+CREATE TABLE IF NOT EXISTS barang_barcode (
+    id         BIGSERIAL PRIMARY KEY,
+    barang_id  BIGINT      NOT NULL REFERENCES master_barang(id) ON DELETE CASCADE,
+    barcode    VARCHAR(14) NOT NULL UNIQUE,
+    satuan     VARCHAR(30),                           -- packaging unit, NULL = base satuan
+    isi        INTEGER     NOT NULL DEFAULT 1 CHECK (isi > 0) -- base units per scanned unit
+);
+CREATE INDEX IF NOT EXISTS idx_barang_barcode_barang ON barang_barcode (barang_id);
+
+-- 17) saldo_awal (opening-balance stock, at most one per barang)
+CREATE TABLE IF NOT EXISTS saldo_awal (
+    id            BIGSERIAL PRIMARY KEY,
+    barang_id     BIGINT      NOT NULL UNIQUE REFERENCES master_barang(id) ON DELETE CASCADE,
+    qty           INTEGER     NOT NULL CHECK (qty > 0),
+    harga_satuan  INTEGER     NOT NULL CHECK (harga_satuan >= 0), -- unit cost
+    nilai         BIGINT      NOT NULL CHECK (nilai >= 0),        -- qty * harga_satuan
+    user_id       BIGINT      NOT NULL REFERENCES users(id),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 18) master_barang.archived_at (NULL = active; archived barang are hidden from lists
+--     and cannot be bought or sold, but keep their history)
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_master_barang_aktif ON master_barang (id) WHERE archived_at IS NULL;
+
+-- 19) master_barang.version (optimistic concurrency: bumped on every update, sent as ETag)
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+-- 20) audit_log (append-only record of every mutating request)
+CREATE TABLE IF NOT EXISTS audit_log (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     BIGINT,                          -- no FK: entries outlive users; NULL = anonymous
+    aksi        VARCHAR(30)  NOT NULL,           -- create, update, delete, archive, import, login, ...
+    entitas     VARCHAR(50)  NOT NULL,           -- barang, penjualan, kategori, ...
+    entitas_id  BIGINT,
+    sebelum     JSONB,                           -- changed fields before (full row on delete)
+    sesudah     JSONB,                           -- changed fields after (full row on create)
+    method      VARCHAR(10)  NOT NULL,
+    path        VARCHAR(255) NOT NULL,
+    status      INTEGER      NOT NULL,
+    ip          VARCHAR(45)  NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entitas ON audit_log (entitas, entitas_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log (user_id, created_at DESC);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS trg_audit_log_append_only ON audit_log;
+CREATE TRIGGER trg_audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+DROP TRIGGER IF EXISTS trg_audit_log_no_truncate ON audit_log;
+CREATE TRIGGER trg_audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- 21) barang_induk (parent product, e.g. a shirt model) and its variants: every
+--     combination of attribute values (ukuran x warna) is a master_barang row with
+--     its own kode_barang and stock, linked by induk_id
+CREATE TABLE IF NOT EXISTS barang_induk (
+    id           BIGSERIAL PRIMARY KEY,
+    kode_induk   VARCHAR(50)  NOT NULL UNIQUE,
+    nama         VARCHAR(120) NOT NULL,
+    deskripsi    TEXT,
+    satuan       VARCHAR(30)  NOT NULL,
+    harga_beli   INTEGER      NOT NULL DEFAULT 0 CHECK (harga_beli >= 0), -- default for variants
+    harga_jual   INTEGER      NOT NULL DEFAULT 0 CHECK (harga_jual >= 0),
+    kategori_id  BIGINT       REFERENCES kategori(id),
+    atribut      JSONB        NOT NULL DEFAULT '[]', -- [{"nama":"ukuran","nilai":["S","M"]}, ...]
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS induk_id BIGINT REFERENCES barang_induk(id);
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS varian JSONB;  -- {"ukuran":"M","warna":"Merah"}
+-- TRUE when the variant has its own prices instead of following the parent
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS harga_sendiri BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_master_barang_varian ON master_barang (induk_id, varian) WHERE induk_id IS NOT NULL;
+
+-- 22) bundel_komponen (bill of materials: component barang and qty per bundle barang).
+--     A virtual bundle has no stock of its own and selling it takes its components out of
+--     stock; other bundles are kits built into stock by assembly orders (rakit_header)
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS bundel_virtual BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS bundel_komponen (
+    id           BIGSERIAL PRIMARY KEY,
+    bundel_id    BIGINT  NOT NULL REFERENCES master_barang(id) ON DELETE CASCADE,
+    komponen_id  BIGINT  NOT NULL REFERENCES master_barang(id),
+    qty          INTEGER NOT NULL CHECK (qty > 0),            -- per bundle
+    UNIQUE (bundel_id, komponen_id),
+    CHECK (bundel_id <> komponen_id)
+);
+CREATE INDEX IF NOT EXISTS idx_bundel_komponen_komponen ON bundel_komponen (komponen_id);
+
+-- 23) rakit_header / rakit_detail (assembly orders: components out as rakit_keluar,
+--     finished kit in as rakit_masuk)
+CREATE TABLE IF NOT EXISTS rakit_header (
+    id          BIGSERIAL PRIMARY KEY,
+    no_rakit    VARCHAR(50) NOT NULL UNIQUE,
+    barang_id   BIGINT      NOT NULL REFERENCES master_barang(id),
+    qty         INTEGER     NOT NULL CHECK (qty > 0),
+    catatan     TEXT,
+    user_id     BIGINT      NOT NULL REFERENCES users(id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_rakit_header_barang ON rakit_header (barang_id);
+
+CREATE TABLE IF NOT EXISTS rakit_detail (
+    id               BIGSERIAL PRIMARY KEY,
+    rakit_header_id  BIGINT  NOT NULL REFERENCES rakit_header(id) ON DELETE CASCADE,
+    barang_id        BIGINT  NOT NULL REFERENCES master_barang(id),
+    qty              INTEGER NOT NULL CHECK (qty > 0)  -- total consumed
+);
+CREATE INDEX IF NOT EXISTS idx_rakit_detail_header ON rakit_detail (rakit_header_id);
+
+-- 24) product images (storage keys of the image and its JPEG thumbnail; files live in
+--     the configured storage backend) and lampiran (documents attached to transactions,
+--     e.g. scanned supplier invoices)
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS gambar_key VARCHAR(255);
+ALTER TABLE master_barang ADD COLUMN IF NOT EXISTS thumbnail_key VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS lampiran (
+    id            BIGSERIAL PRIMARY KEY,
+    entitas       VARCHAR(20)  NOT NULL CHECK (entitas IN ('pembelian', 'penjualan')),
+    entitas_id    BIGINT       NOT NULL,                     -- beli_header.id / jual_header.id
+    nama_file     VARCHAR(255) NOT NULL,
+    content_type  VARCHAR(100) NOT NULL,
+    ukuran        BIGINT       NOT NULL,                     -- bytes
+    storage_key   VARCHAR(255) NOT NULL UNIQUE,
+    user_id       BIGINT       NOT NULL REFERENCES users(id),
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_lampiran_entitas ON lampiran (entitas, entitas_id);
+
+-- 25) users.aktif (deactivated users cannot log in and their tokens are rejected;
+--     users are never deleted because transactions reference them)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS aktif BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- 26) refresh_token (one row per issued refresh token). A login starts a family; every
+--     refresh marks the presented token used and issues the next one in the same family.
+--     Presenting a used token again means it was stolen, so the whole family is revoked.
+--     Access tokens carry the family id and are rejected once it is revoked.
+CREATE TABLE IF NOT EXISTS refresh_token (
+    jti         VARCHAR(64) PRIMARY KEY,
+    family_id   VARCHAR(64) NOT NULL,
+    user_id     BIGINT      NOT NULL REFERENCES users(id),
+    expires_at  TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at     TIMESTAMPTZ,            -- exchanged for the next token
+    revoked_at  TIMESTAMPTZ             -- logout, reuse or admin revocation
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_token_family ON refresh_token (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_token_user ON refresh_token (user_id);
+
+-- 27) roles and permissions. user_tenant.role (see 30) refers to role; role_permission
+--     maps roles to permissions. The permission rows are synced from the catalogue in the permission
+--     package at startup; the admin role always holds every permission.
+CREATE TABLE IF NOT EXISTS role (
+    nama        VARCHAR(20) PRIMARY KEY,
+    deskripsi   TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+INSERT INTO role (nama, deskripsi) VALUES
+    ('admin', 'Administrator, all permissions'),
+    ('user', 'Warehouse staff')
+ON CONFLICT (nama) DO NOTHING;
+INSERT INTO role (nama) SELECT DISTINCT role FROM users WHERE role IS NOT NULL ON CONFLICT (nama) DO NOTHING;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_fkey;
+ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES role(nama) ON UPDATE CASCADE ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS permission (
+    kode       VARCHAR(50) PRIMARY KEY,
+    deskripsi  TEXT        NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS role_permission (
+    role        VARCHAR(20) NOT NULL REFERENCES role(nama) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission  VARCHAR(50) NOT NULL REFERENCES permission(kode) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+-- 28) api_key (keys for machine-to-machine clients, sent in the X-API-Key header).
+--     Requests act as user_id, limited to the scopes that the user's role also has.
+--     Keys belong to service users (accounts for integrations, which cannot log in)
+--     or to the user who created them. Only the SHA-256 of the key is stored; the
+--     prefix identifies it in listings.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS service BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS api_key (
+    id            BIGSERIAL PRIMARY KEY,
+    nama          VARCHAR(100) NOT NULL,
+    prefix        VARCHAR(20)  NOT NULL UNIQUE,
+    key_hash      CHAR(64)     NOT NULL,
+    user_id       BIGINT       NOT NULL REFERENCES users(id),
+    scopes        TEXT[]       NOT NULL,
+    allowed_ips   TEXT[]       NOT NULL DEFAULT '{}',  -- IPs or CIDR ranges; empty = any
+    expires_at    TIMESTAMPTZ,
+    last_used_at  TIMESTAMPTZ,
+    last_used_ip  VARCHAR(64),
+    revoked_at    TIMESTAMPTZ,
+    created_by    BIGINT       REFERENCES users(id),
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_api_key_user ON api_key (user_id);
+-- Stock movements made with a key name it.
+ALTER TABLE history_stok ADD COLUMN IF NOT EXISTS api_key_id BIGINT REFERENCES api_key(id);
+
+-- 29) two-factor authentication (TOTP, RFC 6238). totp_secret is set when enrollment
+--     starts and becomes effective once a code is verified (totp_aktif). totp_step is the
+--     last accepted time step so a code cannot be used twice. Roles with wajib_2fa make
+--     their users enroll at their next login.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;  -- AES-GCM sealed when TOTP_ENCRYPTION_KEY is set
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_aktif BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_step BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE role ADD COLUMN IF NOT EXISTS wajib_2fa BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS recovery_code (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users(id),
+    code_hash  CHAR(64)    NOT NULL,  -- SHA-256
+    used_at    TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_code_user ON recovery_code (user_id);
+
+-- 30) tenants (companies sharing one deployment). Every business table carries tenant_id
+--     and is guarded by row-level security: a connection only sees and writes the rows of
+--     the tenant in its app.tenant_id setting, which the application sets per statement
+--     (package tenant). tenant_id defaults to that setting, so inserts need not name it.
+--     Foreign keys between tenant tables include tenant_id, so no row can refer to a row
+--     of another tenant; new tables must follow the same pattern. Users, roles and
+--     sessions are shared; user_tenant lists the tenants a user may work in and the user's
+--     role in each, and api_key is filtered by the application. Data from before tenants
+--     belongs to tenant 1. Requires PostgreSQL 15+ (ON DELETE SET NULL (column)), and the
+--     application must not connect as a superuser or a role with BYPASSRLS.
+CREATE TABLE IF NOT EXISTS tenant (
+    id          BIGSERIAL PRIMARY KEY,
+    kode        VARCHAR(30)  NOT NULL UNIQUE,
+    nama        VARCHAR(120) NOT NULL,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+INSERT INTO tenant (id, kode, nama) VALUES (1, 'default', 'Default') ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('tenant', 'id'), (SELECT MAX(id) FROM tenant));
+
+CREATE TABLE IF NOT EXISTS user_tenant (
+    user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id  BIGINT NOT NULL REFERENCES tenant(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, tenant_id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_tenant_tenant ON user_tenant (tenant_id);
+-- Roles are given per tenant. users.role is the role from before and only seeds the
+-- memberships of existing users; it is no longer read or written.
+ALTER TABLE user_tenant ADD COLUMN IF NOT EXISTS role VARCHAR(20) REFERENCES role(nama) ON UPDATE CASCADE;
+UPDATE user_tenant ut SET role = u.role FROM users u WHERE u.id = ut.user_id AND ut.role IS NULL;
+-- First migration: every existing user works in the default tenant.
+INSERT INTO user_tenant (user_id, tenant_id, role)
+SELECT id, 1, role FROM users WHERE NOT EXISTS (SELECT 1 FROM user_tenant);
+ALTER TABLE user_tenant ALTER COLUMN role SET NOT NULL;
+ALTER TABLE users ALTER COLUMN role DROP NOT NULL;
+
+CREATE OR REPLACE FUNCTION app_tenant() RETURNS BIGINT AS $$
+    SELECT NULLIF(current_setting('app.tenant_id', true), '')::BIGINT
+$$ LANGUAGE sql STABLE;
+
+DO $$
+DECLARE
+    tabel TEXT[] := ARRAY['master_barang', 'mstok', 'beli_header', 'beli_detail', 'jual_header', 'jual_detail',
+        'history_stok', 'tarif_pajak', 'harga_history', 'daftar_harga', 'daftar_harga_item', 'grup_pelanggan', 'jadwal_harga',
+        'promo', 'promo_item', 'kategori', 'barang_barcode', 'saldo_awal', 'barang_induk', 'bundel_komponen',
+        'rakit_header', 'rakit_detail', 'lampiran'];
+    t TEXT;
+    fk RECORD;
+BEGIN
+    -- ADD COLUMN with a constant default fills existing rows without firing the
+    -- append-only trigger of audit_log.
+    FOREACH t IN ARRAY tabel || ARRAY['audit_log', 'api_key'] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS tenant_id BIGINT NOT NULL DEFAULT 1 REFERENCES tenant(id)', t);
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN tenant_id SET DEFAULT app_tenant()', t);
+    END LOOP;
+
+    FOREACH t IN ARRAY tabel LOOP
+        IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = t::regclass AND conname = t || '_tenant_id_key') THEN
+            EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I UNIQUE (tenant_id, id)', t, t || '_tenant_id_key');
+        END IF;
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+        EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %I', t);
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_id = app_tenant()) WITH CHECK (tenant_id = app_tenant())', t);
+    END LOOP;
+
+    -- Single-column foreign keys between tenant tables become (tenant_id, column).
+    FOR fk IN
+        SELECT c.conname, c.conrelid::regclass AS tabel, c.confrelid::regclass AS ref, a.attname AS kolom, c.confdeltype
+        FROM pg_constraint c
+        JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+        WHERE c.contype = 'f' AND array_length(c.conkey, 1) = 1
+          AND c.conrelid::regclass::text = ANY (tabel) AND c.confrelid::regclass::text = ANY (tabel)
+    LOOP
+        EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I', fk.tabel, fk.conname);
+        EXECUTE format('ALTER TABLE %s ADD CONSTRAINT %I FOREIGN KEY (tenant_id, %I) REFERENCES %s (tenant_id, id) ON DELETE %s',
+            fk.tabel, fk.conname, fk.kolom, fk.ref,
+            CASE fk.confdeltype WHEN 'c' THEN 'CASCADE' WHEN 'n' THEN format('SET NULL (%I)', fk.kolom) WHEN 'r' THEN 'RESTRICT' ELSE 'NO ACTION' END);
+    END LOOP;
+
+    IF pg_get_indexdef('uq_kategori_parent_nama'::regclass) NOT LIKE '%tenant_id%' THEN
+        DROP INDEX uq_kategori_parent_nama;
+        CREATE UNIQUE INDEX uq_kategori_parent_nama ON kategori (tenant_id, COALESCE(parent_id, 0), LOWER(nama));
+    END IF;
+END $$;
+
+-- audit_log: entries of requests made before authentication are recorded in the tenant
+-- they concern; failed logins of unknown users in the tenant named in the request, or the
+-- default tenant. Entries without a tenant are only visible to statements without one.
+ALTER TABLE audit_log ALTER COLUMN tenant_id DROP NOT NULL;
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_log FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON audit_log;
+CREATE POLICY tenant_isolation ON audit_log
+    USING (tenant_id IS NOT DISTINCT FROM app_tenant()) WITH CHECK (tenant_id IS NOT DISTINCT FROM app_tenant());
+CREATE INDEX IF NOT EXISTS idx_audit_log_tenant ON audit_log (tenant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_api_key_tenant ON api_key (tenant_id);
+
+-- Codes and numbers are unique per tenant; faktur and rakit numbers are counted per tenant.
+ALTER TABLE tarif_pajak DROP CONSTRAINT IF EXISTS tarif_pajak_kode_berlaku_mulai_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_tarif_pajak ON tarif_pajak (tenant_id, kode, berlaku_mulai);
+ALTER TABLE master_barang DROP CONSTRAINT IF EXISTS master_barang_kode_barang_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_master_barang_kode ON master_barang (tenant_id, kode_barang);
+ALTER TABLE beli_header DROP CONSTRAINT IF EXISTS beli_header_no_faktur_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_beli_header_no_faktur ON beli_header (tenant_id, no_faktur);
+ALTER TABLE jual_header DROP CONSTRAINT IF EXISTS jual_header_no_faktur_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_jual_header_no_faktur ON jual_header (tenant_id, no_faktur);
+ALTER TABLE daftar_harga DROP CONSTRAINT IF EXISTS daftar_harga_nama_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_daftar_harga_nama ON daftar_harga (tenant_id, nama);
+ALTER TABLE grup_pelanggan DROP CONSTRAINT IF EXISTS grup_pelanggan_nama_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_grup_pelanggan_nama ON grup_pelanggan (tenant_id, nama);
+ALTER TABLE barang_barcode DROP CONSTRAINT IF EXISTS barang_barcode_barcode_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_barang_barcode ON barang_barcode (tenant_id, barcode);
+ALTER TABLE barang_induk DROP CONSTRAINT IF EXISTS barang_induk_kode_induk_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_barang_induk_kode ON barang_induk (tenant_id, kode_induk);
+ALTER TABLE rakit_header DROP CONSTRAINT IF EXISTS rakit_header_no_rakit_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_rakit_header_no_rakit ON rakit_header (tenant_id, no_rakit);
+
 -- End of schema
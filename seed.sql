@@ -1,3 +1,6 @@
+-- Semua data contoh milik tenant default (lihat schema.sql bagian 30).
+SET app.tenant_id = '1';
+
 -- 1. WIPING DATA (Bersihkan semua data & reset ID)
 -- CATATAN: TRUNCATE CASCADE memastikan semua FK terhapus juga.
 
@@ -17,6 +20,7 @@ TRUNCATE TABLE users RESTART IDENTITY CASCADE;
 INSERT INTO users (username, password, email, full_name, role) VALUES
 ('admin', '$2a$10$wE9s/m9mFjO/gT0.fX4gNe.f3gHjK.cO.S6mGjJqT9g', 'admin@example.com', 'Administrator', 'admin'),
 ('user1', '$2a$10$wE9s/m9mFjO/gT0.fX4gNe.f3gHjK.cO.S6mGjJqT9g', 'user1@example.com', 'Pegawai Gudang', 'user');
+INSERT INTO user_tenant (user_id, tenant_id, role) SELECT id, 1, role FROM users;
 
 -- Master Barang (Item A, B, C, D)
 INSERT INTO master_barang (kode_barang, nama_barang, deskripsi, satuan, harga_beli, harga_jual) VALUES
@@ -0,0 +1,232 @@
+// Package spreadsheet reads tabular uploads (CSV or XLSX) into rows of
+// strings and streams exports in the same formats. XLSX is handled with the
+// standard library only: on input the first worksheet is read and every cell
+// is returned as its text value; on output a single sheet is written.
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrFormat is returned when data is neither CSV nor XLSX.
+var ErrFormat = errors.New("file must be CSV or XLSX")
+
+// Read parses data as XLSX when it is a zip archive, otherwise as CSV.
+// Trailing empty rows are dropped.
+func Read(data []byte) ([][]string, error) {
+    var (
+        rows [][]string
+        err  error
+    )
+    if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+        rows, err = ReadXLSX(data)
+    } else {
+        rows, err = ReadCSV(data)
+    }
+    if err != nil { return nil, err }
+    for len(rows) > 0 && isEmpty(rows[len(rows)-1]) {
+        rows = rows[:len(rows)-1]
+    }
+    return rows, nil
+}
+
+func isEmpty(row []string) bool {
+    for _, c := range row {
+        if strings.TrimSpace(c) != "" { return false }
+    }
+    return true
+}
+
+// ReadCSV parses comma- or semicolon-separated data (the separator is taken
+// from the first line, as spreadsheet programs with an Indonesian locale
+// export ";"). A UTF-8 byte order mark is ignored.
+func ReadCSV(data []byte) ([][]string, error) {
+    data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+    if !isText(data) { return nil, ErrFormat }
+    first := data
+    if i := bytes.IndexByte(data, '\n'); i >= 0 { first = data[:i] }
+    r := csv.NewReader(bytes.NewReader(data))
+    if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+        r.Comma = ';'
+    }
+    r.FieldsPerRecord = -1
+    r.TrimLeadingSpace = true
+    rows, err := r.ReadAll()
+    if err != nil { return nil, fmt.Errorf("csv: %w", err) }
+    return rows, nil
+}
+
+// isText rejects obviously binary data such as XLS files.
+func isText(data []byte) bool {
+    n := min(len(data), 512)
+    return bytes.IndexByte(data[:n], 0) < 0
+}
+
+// ReadXLSX returns the cells of the first worksheet of an XLSX workbook.
+func ReadXLSX(data []byte) ([][]string, error) {
+    zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil { return nil, ErrFormat }
+    files := make(map[string]*zip.File, len(zr.File))
+    for _, f := range zr.File {
+        files[f.Name] = f
+    }
+    sheet, err := firstSheet(files)
+    if err != nil { return nil, err }
+    var shared []string
+    if f, ok := files["xl/sharedStrings.xml"]; ok {
+        if shared, err = readSharedStrings(f); err != nil { return nil, err }
+    }
+    f, ok := files[sheet]
+    if !ok { return nil, fmt.Errorf("xlsx: %s not found", sheet) }
+    return readSheet(f, shared)
+}
+
+func decodeXML(f *zip.File, v any) error {
+    rc, err := f.Open()
+    if err != nil { return fmt.Errorf("xlsx: %w", err) }
+    defer rc.Close()
+    if err := xml.NewDecoder(rc).Decode(v); err != nil {
+        return fmt.Errorf("xlsx: %s: %w", f.Name, err)
+    }
+    return nil
+}
+
+// firstSheet resolves the part name of the first sheet in workbook order.
+func firstSheet(files map[string]*zip.File) (string, error) {
+    const fallback = "xl/worksheets/sheet1.xml"
+    wb, ok := files["xl/workbook.xml"]
+    rels, ok2 := files["xl/_rels/workbook.xml.rels"]
+    if !ok || !ok2 {
+        if _, ok := files[fallback]; ok { return fallback, nil }
+        return "", ErrFormat
+    }
+    var w struct {
+        Sheets []struct {
+            RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+        } `xml:"sheets>sheet"`
+    }
+    if err := decodeXML(wb, &w); err != nil { return "", err }
+    var rs struct {
+        Rel []struct {
+            ID     string `xml:"Id,attr"`
+            Target string `xml:"Target,attr"`
+        } `xml:"Relationship"`
+    }
+    if err := decodeXML(rels, &rs); err != nil { return "", err }
+    if len(w.Sheets) == 0 { return "", errors.New("xlsx: workbook has no sheets") }
+    for _, r := range rs.Rel {
+        if r.ID != w.Sheets[0].RID { continue }
+        if strings.HasPrefix(r.Target, "/") {
+            return strings.TrimPrefix(r.Target, "/"), nil
+        }
+        return path.Join("xl", r.Target), nil
+    }
+    return fallback, nil
+}
+
+// xlsxText is a string item: plain <t> or rich text runs <r><t>.
+type xlsxText struct {
+    T    string `xml:"t"`
+    Runs []struct {
+        T string `xml:"t"`
+    } `xml:"r"`
+}
+
+func (s xlsxText) String() string {
+    if len(s.Runs) == 0 { return s.T }
+    var b strings.Builder
+    for _, r := range s.Runs {
+        b.WriteString(r.T)
+    }
+    return b.String()
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+    var sst struct {
+        SI []xlsxText `xml:"si"`
+    }
+    if err := decodeXML(f, &sst); err != nil { return nil, err }
+    out := make([]string, len(sst.SI))
+    for i, si := range sst.SI {
+        out[i] = si.String()
+    }
+    return out, nil
+}
+
+func readSheet(f *zip.File, shared []string) ([][]string, error) {
+    var ws struct {
+        Rows []struct {
+            R     int `xml:"r,attr"`
+            Cells []struct {
+                Ref  string   `xml:"r,attr"`
+                Type string   `xml:"t,attr"`
+                V    string   `xml:"v"`
+                Is   xlsxText `xml:"is"`
+            } `xml:"c"`
+        } `xml:"sheetData>row"`
+    }
+    if err := decodeXML(f, &ws); err != nil { return nil, err }
+    rows := make([][]string, 0, len(ws.Rows))
+    for _, row := range ws.Rows {
+        // Rows may be sparse; keep row numbers aligned with the sheet.
+        for row.R > len(rows)+1 {
+            rows = append(rows, nil)
+        }
+        var cells []string
+        for i, c := range row.Cells {
+            col := i
+            if c.Ref != "" {
+                if n, ok := column(c.Ref); ok { col = n }
+            }
+            for len(cells) <= col {
+                cells = append(cells, "")
+            }
+            switch c.Type {
+            case "s":
+                idx, err := strconv.Atoi(c.V)
+                if err != nil || idx < 0 || idx >= len(shared) {
+                    return nil, fmt.Errorf("xlsx: cell %s: bad shared string %q", c.Ref, c.V)
+                }
+                cells[col] = shared[idx]
+            case "inlineStr":
+                cells[col] = c.Is.String()
+            case "b":
+                cells[col] = map[string]string{"1": "true", "0": "false"}[c.V]
+            default:
+                cells[col] = c.V
+            }
+        }
+        rows = append(rows, cells)
+    }
+    return rows, nil
+}
+
+// column returns the zero-based column index of a cell reference like "AB12".
+func column(ref string) (int, bool) {
+    n := 0
+    i := 0
+    for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+        n = n*26 + int(ref[i]-'A'+1)
+    }
+    if i == 0 { return 0, false }
+    return n - 1, true
+}
+
+// ReadAll reads r fully, failing when it is larger than limit bytes.
+func ReadAll(r io.Reader, limit int64) ([]byte, error) {
+    data, err := io.ReadAll(io.LimitReader(r, limit+1))
+    if err != nil { return nil, err }
+    if int64(len(data)) > limit {
+        return nil, fmt.Errorf("file is larger than %d MB", limit>>20)
+    }
+    return data, nil
+}
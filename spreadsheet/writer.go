@@ -0,0 +1,264 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse/format"
+)
+
+// Output formats.
+const (
+    CSV  = "csv"
+    XLSX = "xlsx"
+)
+
+// ContentType returns the MIME type of an output format.
+func ContentType(f string) string {
+    if f == XLSX {
+        return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+    }
+    return "text/csv; charset=utf-8"
+}
+
+// Kind tells the writer how to present a column.
+type Kind int
+
+const (
+    Text     Kind = iota
+    Number        // integer
+    Money         // Rupiah: "Rp 15.000" in CSV, a number formatted as Rupiah in XLSX
+    Date          // time.Time, date only
+    DateTime      // time.Time with hours and minutes
+)
+
+// Column is an output column with its (localised) header.
+type Column struct {
+    Header string
+    Kind   Kind
+}
+
+// Writer writes rows one at a time. Values are strings, integers,
+// time.Time or nil (empty cell), matching the column kinds.
+type Writer interface {
+    WriteRow(values ...any) error
+    // Close flushes buffered output and completes the file.
+    Close() error
+}
+
+// NewWriter starts a file of the given format with a header row.
+func NewWriter(w io.Writer, f string, cols []Column) (Writer, error) {
+    switch f {
+    case CSV:
+        return newCSVWriter(w, cols)
+    case XLSX:
+        return newXLSXWriter(w, cols)
+    }
+    return nil, fmt.Errorf("format must be %s or %s", CSV, XLSX)
+}
+
+func toInt(v any) (int64, bool) {
+    switch n := v.(type) {
+    case int64:
+        return n, true
+    case int:
+        return int64(n), true
+    case *int64:
+        if n != nil { return *n, true }
+    }
+    return 0, false
+}
+
+type csvWriter struct {
+    cols []Column
+    bw   *bufio.Writer
+    cw   *csv.Writer
+    rec  []string
+}
+
+func newCSVWriter(w io.Writer, cols []Column) (*csvWriter, error) {
+    bw := bufio.NewWriter(w)
+    // A byte order mark makes spreadsheet programs read the file as UTF-8.
+    if _, err := bw.WriteString("\ufeff"); err != nil { return nil, err }
+    c := &csvWriter{cols: cols, bw: bw, cw: csv.NewWriter(bw), rec: make([]string, len(cols))}
+    for i, col := range cols {
+        c.rec[i] = col.Header
+    }
+    return c, c.cw.Write(c.rec)
+}
+
+func (c *csvWriter) WriteRow(values ...any) error {
+    for i := range c.rec {
+        c.rec[i] = ""
+        if i >= len(values) || values[i] == nil { continue }
+        v := values[i]
+        switch c.cols[i].Kind {
+        case Money:
+            if n, ok := toInt(v); ok { c.rec[i] = format.Rupiah(n); continue }
+        case Number:
+            if n, ok := toInt(v); ok { c.rec[i] = strconv.FormatInt(n, 10); continue }
+        case Date, DateTime:
+            if t, ok := v.(time.Time); ok {
+                if c.cols[i].Kind == Date {
+                    c.rec[i] = t.Format("02/01/2006")
+                } else {
+                    c.rec[i] = t.Format("02/01/2006 15:04")
+                }
+                continue
+            }
+        }
+        if n, ok := toInt(v); ok {
+            c.rec[i] = strconv.FormatInt(n, 10)
+        } else {
+            c.rec[i] = fmt.Sprint(v)
+        }
+    }
+    return c.cw.Write(c.rec)
+}
+
+func (c *csvWriter) Close() error {
+    c.cw.Flush()
+    if err := c.cw.Error(); err != nil { return err }
+    return c.bw.Flush()
+}
+
+// Cell styles defined in xlsxStyles.
+const (
+    styleHeader   = 1
+    styleMoney    = 2
+    styleDate     = 3
+    styleDateTime = 4
+)
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="3"><numFmt numFmtId="164" formatCode="&quot;Rp&quot;\ #,##0"/><numFmt numFmtId="165" formatCode="dd/mm/yyyy"/><numFmt numFmtId="166" formatCode="dd/mm/yyyy\ hh:mm"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="5"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="166" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>
+</styleSheet>`
+
+var xlsxParts = []struct{ name, body string }{
+    {"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+    {"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+    {"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Data" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+    {"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+    {"xl/styles.xml", xlsxStyles},
+}
+
+// xlsxWriter streams a single-sheet workbook: the fixed parts are written
+// first and the sheet last, so rows go straight to the zip stream.
+type xlsxWriter struct {
+    cols  []Column
+    zw    *zip.Writer
+    sheet *bufio.Writer
+    row   int
+}
+
+func newXLSXWriter(w io.Writer, cols []Column) (*xlsxWriter, error) {
+    zw := zip.NewWriter(w)
+    for _, p := range xlsxParts {
+        f, err := zw.Create(p.name)
+        if err != nil { return nil, err }
+        if _, err := io.WriteString(f, p.body); err != nil { return nil, err }
+    }
+    f, err := zw.Create("xl/worksheets/sheet1.xml")
+    if err != nil { return nil, err }
+    x := &xlsxWriter{cols: cols, zw: zw, sheet: bufio.NewWriter(f)}
+    x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+        `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+        `<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><cols>`)
+    for i, c := range cols {
+        width := max(12, len(c.Header)+4)
+        if c.Kind == Text { width = max(width, 20) }
+        fmt.Fprintf(x.sheet, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+    }
+    x.sheet.WriteString(`</cols><sheetData>`)
+    x.row = 1
+    x.sheet.WriteString(`<row r="1">`)
+    for i, c := range cols {
+        x.text(i, c.Header, styleHeader)
+    }
+    x.sheet.WriteString(`</row>`)
+    return x, nil
+}
+
+// cellRef returns the A1-style reference of column i in the current row.
+func (x *xlsxWriter) cellRef(i int) string {
+    var col []byte
+    for n := i + 1; n > 0; n = (n - 1) / 26 {
+        col = append([]byte{byte('A' + (n-1)%26)}, col...)
+    }
+    return string(col) + strconv.Itoa(x.row)
+}
+
+func (x *xlsxWriter) text(i int, s string, style int) {
+    fmt.Fprintf(x.sheet, `<c r="%s" t="inlineStr"`, x.cellRef(i))
+    if style != 0 { fmt.Fprintf(x.sheet, ` s="%d"`, style) }
+    x.sheet.WriteString(`><is><t xml:space="preserve">`)
+    _ = xml.EscapeText(x.sheet, []byte(s))
+    x.sheet.WriteString(`</t></is></c>`)
+}
+
+func (x *xlsxWriter) number(i int, v string, style int) {
+    if style != 0 {
+        fmt.Fprintf(x.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, x.cellRef(i), style, v)
+        return
+    }
+    fmt.Fprintf(x.sheet, `<c r="%s"><v>%s</v></c>`, x.cellRef(i), v)
+}
+
+// excelEpoch is day 0 of the 1900 date system (accounting for its leap-year bug).
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// serial converts t to an Excel date serial in t's own time zone.
+func serial(t time.Time) string {
+    wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+    days := wall.Sub(excelEpoch).Hours() / 24
+    return strconv.FormatFloat(days, 'f', -1, 64)
+}
+
+func (x *xlsxWriter) WriteRow(values ...any) error {
+    x.row++
+    fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+    for i, c := range x.cols {
+        if i >= len(values) || values[i] == nil { continue }
+        v := values[i]
+        if t, ok := v.(time.Time); ok && (c.Kind == Date || c.Kind == DateTime) {
+            if t.IsZero() { continue }
+            style := styleDateTime
+            if c.Kind == Date { style = styleDate }
+            x.number(i, serial(t), style)
+            continue
+        }
+        if n, ok := toInt(v); ok {
+            style := 0
+            if c.Kind == Money { style = styleMoney }
+            x.number(i, strconv.FormatInt(n, 10), style)
+            continue
+        }
+        if p, ok := v.(*int64); ok && p == nil { continue }
+        x.text(i, strings.ToValidUTF8(fmt.Sprint(v), "?"), 0)
+    }
+    _, err := x.sheet.WriteString(`</row>`)
+    return err
+}
+
+func (x *xlsxWriter) Close() error {
+    x.sheet.WriteString(`</sheetData></worksheet>`)
+    if err := x.sheet.Flush(); err != nil { return err }
+    return x.zw.Close()
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local stores objects as files below Dir.
+type Local struct {
+    Dir string
+}
+
+func NewLocal(dir string) *Local { return &Local{Dir: dir} }
+
+func (l *Local) path(key string) (string, error) {
+    if !validKey(key) { return "", ErrInvalidKey }
+    return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes data to a temporary file and renames it into place, so readers
+// never see a partial object.
+func (l *Local) Put(_ context.Context, key string, data []byte, _ string) error {
+    p, err := l.path(key)
+    if err != nil { return err }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil { return err }
+    f, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+    if err != nil { return err }
+    if _, err := f.Write(data); err != nil {
+        f.Close()
+        os.Remove(f.Name())
+        return err
+    }
+    if err := f.Close(); err != nil {
+        os.Remove(f.Name())
+        return err
+    }
+    if err := os.Rename(f.Name(), p); err != nil {
+        os.Remove(f.Name())
+        return err
+    }
+    return nil
+}
+
+func (l *Local) Open(_ context.Context, key string) (io.ReadCloser, error) {
+    p, err := l.path(key)
+    if err != nil { return nil, err }
+    f, err := os.Open(p)
+    if os.IsNotExist(err) { return nil, ErrNotFound }
+    return f, err
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+    p, err := l.path(key)
+    if err != nil { return err }
+    if err := os.Remove(p); err != nil && !os.IsNotExist(err) { return err }
+    return nil
+}
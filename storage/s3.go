@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 stores objects in a bucket of an S3-compatible service (AWS S3, MinIO,
+// ...) using path-style URLs (Endpoint/Bucket/key) and Signature Version 4.
+// Any local stand-in speaking the S3 API, such as a MinIO container, can be
+// used by pointing Endpoint at it.
+type S3 struct {
+    Endpoint  string // e.g. https://s3.ap-southeast-1.amazonaws.com or http://localhost:9000
+    Region    string
+    Bucket    string
+    AccessKey string
+    SecretKey string
+    Client    *http.Client
+}
+
+func NewS3(endpoint, region, bucket, accessKey, secretKey string) *S3 {
+    if region == "" { region = "us-east-1" }
+    return &S3{
+        Endpoint:  strings.TrimRight(endpoint, "/"),
+        Region:    region,
+        Bucket:    bucket,
+        AccessKey: accessKey,
+        SecretKey: secretKey,
+        Client:    &http.Client{Timeout: time.Minute},
+    }
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+    resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+    if err != nil { return err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { return s3Error(resp) }
+    return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+    resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+    if err != nil { return nil, err }
+    switch resp.StatusCode {
+    case http.StatusOK:
+        return resp.Body, nil
+    case http.StatusNotFound:
+        resp.Body.Close()
+        return nil, ErrNotFound
+    }
+    defer resp.Body.Close()
+    return nil, s3Error(resp)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+    resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+    if err != nil { return err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+        return s3Error(resp)
+    }
+    return nil
+}
+
+// do sends a signed request for key.
+func (s *S3) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+    if !validKey(key) { return nil, ErrInvalidKey }
+    segs := strings.Split(key, "/")
+    for i, seg := range segs { segs[i] = s3Escape(seg) }
+    uri := "/" + s3Escape(s.Bucket) + "/" + strings.Join(segs, "/")
+
+    req, err := http.NewRequestWithContext(ctx, method, s.Endpoint+uri, bytes.NewReader(body))
+    if err != nil { return nil, err }
+    if contentType != "" { req.Header.Set("Content-Type", contentType) }
+    s.sign(req, uri, body, time.Now().UTC())
+    return s.Client.Do(req)
+}
+
+// s3Escape encodes one path segment the way SigV4 canonicalizes it: every
+// byte except A-Z, a-z, 0-9, '-', '.', '_' and '~' is percent-encoded.
+func s3Escape(seg string) string {
+    var b strings.Builder
+    for i := 0; i < len(seg); i++ {
+        c := seg[i]
+        if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+            b.WriteByte(c)
+            continue
+        }
+        fmt.Fprintf(&b, "%%%02X", c)
+    }
+    return b.String()
+}
+
+// sign adds the SigV4 headers for a request without query parameters. The
+// host and every header already set on req are signed; uri must be escaped
+// with s3Escape.
+func (s *S3) sign(req *http.Request, uri string, body []byte, now time.Time) {
+    amzDate := now.Format("20060102T150405Z")
+    day := now.Format("20060102")
+    payload := sha256.Sum256(body)
+    payloadHash := hex.EncodeToString(payload[:])
+    req.Header.Set("X-Amz-Date", amzDate)
+    req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+    headers := map[string]string{"host": req.URL.Host}
+    for k, v := range req.Header { headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ",")) }
+    names := make([]string, 0, len(headers))
+    for k := range headers { names = append(names, k) }
+    sort.Strings(names)
+    var canonHeaders strings.Builder
+    for _, k := range names { canonHeaders.WriteString(k + ":" + headers[k] + "\n") }
+    signed := strings.Join(names, ";")
+    canonical := strings.Join([]string{
+        req.Method,
+        uri,
+        "", // query
+        canonHeaders.String(),
+        signed,
+        payloadHash,
+    }, "\n")
+    scope := day + "/" + s.Region + "/s3/aws4_request"
+    sum := sha256.Sum256([]byte(canonical))
+    toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+    key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+    key = hmacSHA256(key, s.Region)
+    key = hmacSHA256(key, "s3")
+    key = hmacSHA256(key, "aws4_request")
+    sig := hex.EncodeToString(hmacSHA256(key, toSign))
+    req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+        s.AccessKey, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+    m := hmac.New(sha256.New, key)
+    m.Write([]byte(data))
+    return m.Sum(nil)
+}
+
+// s3Error reports an unexpected response with the start of its XML body.
+func s3Error(resp *http.Response) error {
+    msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+    return fmt.Errorf("storage: s3 %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
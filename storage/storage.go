@@ -0,0 +1,45 @@
+// Package storage keeps uploaded files (product images, transaction
+// attachments) on a pluggable backend: the local filesystem or an
+// S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+    // ErrNotFound is returned by Open for a key that does not exist.
+    ErrNotFound = errors.New("storage: object not found")
+    // ErrInvalidKey is returned for empty, absolute or escaping ("../") keys.
+    ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Storage stores objects under slash-separated keys such as
+// "barang/12/3f9c.jpg". Keys are chosen by the application and never reused.
+type Storage interface {
+    Put(ctx context.Context, key string, data []byte, contentType string) error
+    Open(ctx context.Context, key string) (io.ReadCloser, error)
+    // Delete removes key; deleting a missing key is not an error.
+    Delete(ctx context.Context, key string) error
+}
+
+// BaseURL is prepended to a key to build the public URL of an object served
+// by the application (see URL). It can point at a CDN or public bucket instead.
+var BaseURL = "/files/"
+
+// URL returns the public URL of key.
+func URL(key string) string {
+    return strings.TrimRight(BaseURL, "/") + "/" + key
+}
+
+// validKey rejects empty, absolute and escaping keys.
+func validKey(key string) bool {
+    if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+        return false
+    }
+    return path.Clean(key) == key && key != ".." && !strings.HasPrefix(key, "../")
+}
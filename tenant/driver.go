@@ -0,0 +1,141 @@
+package tenant
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+)
+
+// unknown marks a connection whose setting is not known, such as after a
+// rolled back transaction that may have changed it.
+const unknown = -1
+
+// NewConnector wraps base so that every statement runs with the tenant of
+// its context; see the package doc. base must be a PostgreSQL connector
+// whose connections support the context interfaces of database/sql/driver
+// (lib/pq's do).
+func NewConnector(base driver.Connector) driver.Connector {
+    return &connector{base: base}
+}
+
+type connector struct {
+    base driver.Connector
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+    cn, err := c.base.Connect(ctx)
+    if err != nil { return nil, err }
+    ex, okE := cn.(driver.ExecerContext)
+    q, okQ := cn.(driver.QueryerContext)
+    b, okB := cn.(driver.ConnBeginTx)
+    p, okP := cn.(driver.ConnPrepareContext)
+    if !okE || !okQ || !okB || !okP {
+        _ = cn.Close()
+        return nil, errors.New("tenant: driver connection lacks context support")
+    }
+    // A new session has no tenant set.
+    return &conn{Conn: cn, exec: ex, query: q, begin: b, prepare: p}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return c.base.Driver() }
+
+type conn struct {
+    driver.Conn
+    exec    driver.ExecerContext
+    query   driver.QueryerContext
+    begin   driver.ConnBeginTx
+    prepare driver.ConnPrepareContext
+    tenant  int64 // tenant in the session's setting, 0 = none
+}
+
+// sync makes the session's setting match the tenant of ctx.
+func (c *conn) sync(ctx context.Context) error {
+    id, _ := FromContext(ctx)
+    if id == c.tenant { return nil }
+    v := ""
+    if id > 0 { v = strconv.FormatInt(id, 10) }
+    _, err := c.exec.ExecContext(ctx, `SELECT set_config('`+Setting+`', $1, false)`, []driver.NamedValue{{Ordinal: 1, Value: v}})
+    if err != nil {
+        c.tenant = unknown
+        return err
+    }
+    c.tenant = id
+    return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+    if err := c.sync(ctx); err != nil { return nil, err }
+    return c.exec.ExecContext(ctx, query, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+    if err := c.sync(ctx); err != nil { return nil, err }
+    return c.query.QueryContext(ctx, query, args)
+}
+
+// BeginTx sets the tenant before the transaction starts, so it is not lost
+// when the transaction rolls back.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+    if err := c.sync(ctx); err != nil { return nil, err }
+    t, err := c.begin.BeginTx(ctx, opts)
+    if err != nil { return nil, err }
+    return &tx{Tx: t, c: c}, nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+    return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+    s, err := c.prepare.PrepareContext(ctx, query)
+    if err != nil { return nil, err }
+    return &stmt{Stmt: s, c: c}, nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+    return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+    if r, ok := c.Conn.(driver.SessionResetter); ok { return r.ResetSession(ctx) }
+    return nil
+}
+
+func (c *conn) IsValid() bool {
+    if v, ok := c.Conn.(driver.Validator); ok { return v.IsValid() }
+    return true
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+    if p, ok := c.Conn.(driver.Pinger); ok { return p.Ping(ctx) }
+    return nil
+}
+
+type tx struct {
+    driver.Tx
+    c *conn
+}
+
+// Rollback also undoes set_config calls made inside the transaction.
+func (t *tx) Rollback() error {
+    t.c.tenant = unknown
+    return t.Tx.Rollback()
+}
+
+type stmt struct {
+    driver.Stmt
+    c *conn
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+    if err := s.c.sync(ctx); err != nil { return nil, err }
+    if e, ok := s.Stmt.(driver.StmtExecContext); ok { return e.ExecContext(ctx, args) }
+    return nil, errors.New("tenant: statement lacks context support")
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+    if err := s.c.sync(ctx); err != nil { return nil, err }
+    if q, ok := s.Stmt.(driver.StmtQueryContext); ok { return q.QueryContext(ctx, args) }
+    return nil, errors.New("tenant: statement lacks context support")
+}
@@ -0,0 +1,33 @@
+// Package tenant scopes the data of one company (tenant) from another.
+//
+// Tenant tables carry a tenant_id column guarded by PostgreSQL row-level
+// security: their policies only let a connection see and write the rows of
+// the tenant in the app.tenant_id setting. NewConnector wraps the database
+// driver so that, before every statement, the setting of the connection is
+// made to match the tenant in the statement's context (WithID), or cleared
+// when the context has none. Queries therefore stay as they are; a query
+// without a tenant sees no tenant rows at all.
+package tenant
+
+import "context"
+
+// Setting is the PostgreSQL setting holding the current tenant id; the
+// schema's app_tenant() function reads it.
+const Setting = "app.tenant_id"
+
+// DefaultID is the tenant every deployment has (schema.sql section 30),
+// which owns the data from before tenants.
+const DefaultID int64 = 1
+
+type ctxKey struct{}
+
+// WithID returns a context whose database statements act within tenant id.
+func WithID(ctx context.Context, id int64) context.Context {
+    return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant of ctx; false when there is none.
+func FromContext(ctx context.Context) (int64, bool) {
+    id, ok := ctx.Value(ctxKey{}).(int64)
+    return id, ok && id > 0
+}
@@ -0,0 +1,48 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sort"
+)
+
+// JWK is the public part of a key in JSON Web Key format (RFC 7517).
+type JWK struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Alg string `json:"alg"`
+    Use string `json:"use"`
+    N   string `json:"n,omitempty"`
+    E   string `json:"e,omitempty"`
+    Crv string `json:"crv,omitempty"`
+    X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys other services can verify tokens with.
+// HS256 secrets are never published, so only RS256 and EdDSA keys appear.
+func (m *Manager) JWKS() []JWK {
+    b64 := base64.RawURLEncoding.EncodeToString
+    list := []JWK{}
+    for _, k := range m.keys {
+        switch pub := k.publicKey().(type) {
+        case *rsa.PublicKey:
+            list = append(list, JWK{Kty: "RSA", Kid: k.ID, Alg: k.Alg, Use: "sig",
+                N: b64(pub.N.Bytes()), E: b64(big.NewInt(int64(pub.E)).Bytes())})
+        case ed25519.PublicKey:
+            list = append(list, JWK{Kty: "OKP", Kid: k.ID, Alg: k.Alg, Use: "sig", Crv: "Ed25519", X: b64(pub)})
+        }
+    }
+    sort.Slice(list, func(i, j int) bool { return list[i].Kid < list[j].Kid })
+    return list
+}
+
+// ServeJWKS handles GET /.well-known/jwks.json.
+func (m *Manager) ServeJWKS(w http.ResponseWriter, _ *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "public, max-age=300")
+    _ = json.NewEncoder(w).Encode(map[string][]JWK{"keys": m.JWKS()})
+}
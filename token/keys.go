@@ -0,0 +1,173 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+    HS256 = "HS256"
+    RS256 = "RS256"
+    EdDSA = "EdDSA"
+)
+
+// Key is one signing or verification key. Sign is nil for keys that only
+// verify tokens issued before a rotation.
+type Key struct {
+    ID     string // kid header
+    Alg    string
+    Sign   any // []byte, *rsa.PrivateKey or ed25519.PrivateKey
+    Verify any // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+func (k *Key) method() jwt.SigningMethod {
+    switch k.Alg {
+    case RS256:
+        return jwt.SigningMethodRS256
+    case EdDSA:
+        return jwt.SigningMethodEdDSA
+    }
+    return jwt.SigningMethodHS256
+}
+
+// HMACKey returns an HS256 key. An empty id is derived from the secret, so
+// every secret gets its own kid.
+func HMACKey(id, secret string) (*Key, error) {
+    if len(secret) < 32 {
+        return nil, errors.New("token: HS256 secret must be at least 32 bytes")
+    }
+    if id == "" { id = keyID([]byte("hs256:" + secret)) }
+    return &Key{ID: id, Alg: HS256, Sign: []byte(secret), Verify: []byte(secret)}, nil
+}
+
+// ParsePrivateKeyPEM returns an RS256 or EdDSA signing key from a PKCS#8 or
+// PKCS#1 PEM. An empty id is derived from the public key.
+func ParsePrivateKeyPEM(id string, data []byte) (*Key, error) {
+    if rk, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+        if rk.N.BitLen() < 2048 { return nil, errors.New("token: RSA keys must have at least 2048 bits") }
+        return asymmetricKey(id, RS256, rk, &rk.PublicKey)
+    }
+    if ek, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+        priv := ek.(ed25519.PrivateKey)
+        return asymmetricKey(id, EdDSA, priv, priv.Public())
+    }
+    return nil, errors.New("token: private key must be an RSA or Ed25519 PEM")
+}
+
+// ParsePublicKeyPEM returns a verification-only RS256 or EdDSA key.
+func ParsePublicKeyPEM(id string, data []byte) (*Key, error) {
+    if rk, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+        return asymmetricKey(id, RS256, nil, rk)
+    }
+    if ek, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+        return asymmetricKey(id, EdDSA, nil, ek)
+    }
+    return nil, errors.New("token: public key must be an RSA or Ed25519 PEM")
+}
+
+func asymmetricKey(id, alg string, priv any, pub crypto.PublicKey) (*Key, error) {
+    if id == "" {
+        der, err := x509.MarshalPKIXPublicKey(pub)
+        if err != nil { return nil, err }
+        id = keyID(der)
+    }
+    k := &Key{ID: id, Alg: alg, Verify: pub}
+    if priv != nil { k.Sign = priv }
+    return k, nil
+}
+
+func keyID(material []byte) string {
+    sum := sha256.Sum256(material)
+    return hex.EncodeToString(sum[:8])
+}
+
+// keyFile is the JSON format of JWT_KEYS_FILE. Active names the kid that
+// signs new tokens; every listed key verifies tokens. PEM files are relative
+// to the key file.
+//
+//	{"active": "2026-10", "keys": [
+//	    {"kid": "2026-10", "private_key_file": "2026-10.pem"},
+//	    {"kid": "2026-04", "public_key_file": "2026-04.pub.pem"},
+//	    {"kid": "legacy", "alg": "HS256", "secret": "..."}]}
+type keyFile struct {
+    Active string `json:"active"`
+    Keys   []struct {
+        ID             string `json:"kid"`
+        Alg            string `json:"alg"`
+        Secret         string `json:"secret"`
+        PrivateKey     string `json:"private_key"`
+        PrivateKeyFile string `json:"private_key_file"`
+        PublicKey      string `json:"public_key"`
+        PublicKeyFile  string `json:"public_key_file"`
+    } `json:"keys"`
+}
+
+// LoadKeyFile reads a key file and returns its keys, the active one first.
+func LoadKeyFile(path string) ([]*Key, error) {
+    raw, err := os.ReadFile(path)
+    if err != nil { return nil, err }
+    var f keyFile
+    if err := json.Unmarshal(raw, &f); err != nil {
+        return nil, fmt.Errorf("token: %s: %w", path, err)
+    }
+    dir := filepath.Dir(path)
+    pem := func(inline, file string) ([]byte, error) {
+        if inline != "" { return []byte(inline), nil }
+        if !filepath.IsAbs(file) { file = filepath.Join(dir, file) }
+        return os.ReadFile(file)
+    }
+    var active *Key
+    others := []*Key{}
+    for i, e := range f.Keys {
+        if e.ID == "" { return nil, fmt.Errorf("token: %s: key %d has no kid", path, i+1) }
+        var k *Key
+        switch {
+        case e.Secret != "":
+            k, err = HMACKey(e.ID, e.Secret)
+        case e.PrivateKey != "" || e.PrivateKeyFile != "":
+            var data []byte
+            if data, err = pem(e.PrivateKey, e.PrivateKeyFile); err == nil {
+                k, err = ParsePrivateKeyPEM(e.ID, data)
+            }
+        case e.PublicKey != "" || e.PublicKeyFile != "":
+            var data []byte
+            if data, err = pem(e.PublicKey, e.PublicKeyFile); err == nil {
+                k, err = ParsePublicKeyPEM(e.ID, data)
+            }
+        default:
+            err = errors.New("no secret or key")
+        }
+        if err != nil { return nil, fmt.Errorf("token: %s: key %q: %w", path, e.ID, err) }
+        if e.Alg != "" && e.Alg != k.Alg {
+            return nil, fmt.Errorf("token: %s: key %q is %s, not %s", path, e.ID, k.Alg, e.Alg)
+        }
+        if e.ID == f.Active {
+            active = k
+        } else {
+            others = append(others, k)
+        }
+    }
+    if active == nil { return nil, fmt.Errorf("token: %s: active key %q not found", path, f.Active) }
+    return append([]*Key{active}, others...), nil
+}
+
+// publicKey returns the verification key of an asymmetric key, nil for HS256.
+func (k *Key) publicKey() any {
+    switch v := k.Verify.(type) {
+    case *rsa.PublicKey, ed25519.PublicKey:
+        return v
+    }
+    return nil
+}
@@ -0,0 +1,207 @@
+// Package token issues and verifies the API's JWTs. Tokens are signed with
+// one active key and carry its kid; any configured key verifies them, so
+// keys can be rotated without logging everyone out.
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Manager signs and verifies tokens.
+type Manager struct {
+    active     *Key
+    keys       map[string]*Key
+    AccessTTL  time.Duration
+    RefreshTTL time.Duration
+}
+
+// New returns a Manager signing with keys[0] and verifying with all keys.
+func New(keys []*Key, accessTTL, refreshTTL time.Duration) (*Manager, error) {
+    if len(keys) == 0 { return nil, errors.New("token: no keys") }
+    if keys[0].Sign == nil { return nil, fmt.Errorf("token: active key %q cannot sign", keys[0].ID) }
+    m := &Manager{active: keys[0], keys: map[string]*Key{}, AccessTTL: accessTTL, RefreshTTL: refreshTTL}
+    for _, k := range keys {
+        if _, dup := m.keys[k.ID]; dup { return nil, fmt.Errorf("token: duplicate kid %q", k.ID) }
+        m.keys[k.ID] = k
+    }
+    return m, nil
+}
+
+// Config selects where the keys come from; the first one set wins.
+type Config struct {
+    KeysFile        string   // JSON key file (see LoadKeyFile)
+    PrivateKeyFile  string   // RS256/EdDSA PEM
+    Secret          string   // HS256 secret
+    PreviousSecrets []string // HS256 secrets still accepted after rotating Secret
+    AccessTTL       time.Duration
+    RefreshTTL      time.Duration
+}
+
+// Load builds a Manager from cfg. Without any key a random HS256 secret is
+// generated, so tokens do not survive a restart.
+func Load(cfg Config) (*Manager, error) {
+    var keys []*Key
+    switch {
+    case cfg.KeysFile != "":
+        var err error
+        if keys, err = LoadKeyFile(cfg.KeysFile); err != nil { return nil, err }
+    case cfg.PrivateKeyFile != "":
+        data, err := os.ReadFile(cfg.PrivateKeyFile)
+        if err != nil { return nil, err }
+        k, err := ParsePrivateKeyPEM("", data)
+        if err != nil { return nil, err }
+        keys = append(keys, k)
+    case cfg.Secret != "":
+        k, err := HMACKey("", cfg.Secret)
+        if err != nil { return nil, err }
+        keys = append(keys, k)
+    default:
+        b := make([]byte, 32)
+        if _, err := rand.Read(b); err != nil { return nil, err }
+        log.Println("token: no JWT key configured, using a random secret; tokens will not survive a restart")
+        k, _ := HMACKey("", hex.EncodeToString(b))
+        keys = append(keys, k)
+    }
+    if cfg.KeysFile == "" {
+        for _, s := range cfg.PreviousSecrets {
+            if s = strings.TrimSpace(s); s == "" { continue }
+            k, err := HMACKey("", s)
+            if err != nil { return nil, err }
+            k.Sign = nil
+            keys = append(keys, k)
+        }
+    }
+    return New(keys, cfg.AccessTTL, cfg.RefreshTTL)
+}
+
+// Token types, carried in the typ claim so a token only works where it is
+// meant to: access tokens as Bearer tokens, refresh tokens at /api/refresh
+// and challenge tokens at the second step of a two-factor login.
+const (
+    TypAccess    = "access"
+    TypRefresh   = "refresh"
+    TypChallenge = "challenge"
+)
+
+// ChallengeTTL is how long a two-factor login challenge stays valid.
+const ChallengeTTL = 5 * time.Minute
+
+// ErrWrongType is returned when a token of the other type is presented.
+var ErrWrongType = errors.New("token: wrong token type")
+
+// NewID returns a random id for jti and session (family) ids.
+func NewID() string {
+    b := make([]byte, 16)
+    _, _ = rand.Read(b)
+    return hex.EncodeToString(b)
+}
+
+// IssueAccess returns a short-lived token for API requests in tenant
+// tenantID belonging to session sid.
+func (m *Manager) IssueAccess(userID, tenantID int64, role, sid string) (string, error) {
+    claims := m.claims(TypAccess, userID, tenantID, role, sid, m.AccessTTL)
+    return m.Sign(claims)
+}
+
+// IssueRefresh returns a longer-lived token used once to get new tokens for
+// session sid. Its jti identifies it server-side.
+func (m *Manager) IssueRefresh(userID, tenantID int64, role, sid, jti string) (string, time.Time, error) {
+    claims := m.claims(TypRefresh, userID, tenantID, role, sid, m.RefreshTTL)
+    claims["jti"] = jti
+    t, err := m.Sign(claims)
+    return t, time.Unix(claims["exp"].(int64), 0), err
+}
+
+// IssueChallenge returns a token proving that the password of userID was
+// checked. It is exchanged with a second factor for the tokens of session
+// sid in tenant tenantID.
+func (m *Manager) IssueChallenge(userID, tenantID int64, role, sid string) (string, error) {
+    return m.Sign(m.claims(TypChallenge, userID, tenantID, role, sid, ChallengeTTL))
+}
+
+func (m *Manager) claims(typ string, userID, tenantID int64, role, sid string, ttl time.Duration) jwt.MapClaims {
+    now := time.Now()
+    return jwt.MapClaims{
+        "typ":     typ,
+        "user_id": userID,
+        "tid":     tenantID,
+        "role":    role,
+        "sid":     sid,
+        "iat":     now.Unix(),
+        "exp":     now.Add(ttl).Unix(),
+    }
+}
+
+// Sign signs claims with the active key, setting its kid header.
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+    t := jwt.NewWithClaims(m.active.method(), claims)
+    t.Header["kid"] = m.active.ID
+    return t.SignedString(m.active.Sign)
+}
+
+// ParseAccess verifies an access token and returns its claims.
+func (m *Manager) ParseAccess(tokenStr string) (jwt.MapClaims, error) {
+    return m.parseTyp(tokenStr, TypAccess)
+}
+
+// ParseRefresh verifies a refresh token and returns its claims.
+func (m *Manager) ParseRefresh(tokenStr string) (jwt.MapClaims, error) {
+    claims, err := m.parseTyp(tokenStr, TypRefresh)
+    if err != nil { return nil, err }
+    if jti, _ := claims["jti"].(string); jti == "" { return nil, jwt.ErrTokenInvalidClaims }
+    return claims, nil
+}
+
+// ParseChallenge verifies a challenge token and returns its claims.
+func (m *Manager) ParseChallenge(tokenStr string) (jwt.MapClaims, error) {
+    return m.parseTyp(tokenStr, TypChallenge)
+}
+
+func (m *Manager) parseTyp(tokenStr, typ string) (jwt.MapClaims, error) {
+    claims, err := m.Parse(tokenStr)
+    if err != nil { return nil, err }
+    if t, _ := claims["typ"].(string); t != typ { return nil, ErrWrongType }
+    if sid, _ := claims["sid"].(string); sid == "" { return nil, jwt.ErrTokenInvalidClaims }
+    // Tokens issued before tenants were added have no tid; their users log in again.
+    if TenantID(claims) <= 0 { return nil, jwt.ErrTokenInvalidClaims }
+    return claims, nil
+}
+
+// TenantID returns the tenant (tid claim) a token was issued for, or 0.
+func TenantID(claims jwt.MapClaims) int64 {
+    tid, _ := claims["tid"].(float64)
+    return int64(tid)
+}
+
+// Parse verifies the signature and expiry of a token. The kid selects the
+// key and the token's alg must be that key's; tokens without a kid (issued
+// before kids were added) are tried against every key of their alg.
+func (m *Manager) Parse(tokenStr string) (jwt.MapClaims, error) {
+    claims := jwt.MapClaims{}
+    _, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+        alg := t.Method.Alg()
+        if kid, ok := t.Header["kid"].(string); ok {
+            k := m.keys[kid]
+            if k == nil { return nil, fmt.Errorf("unknown kid %q", kid) }
+            if k.Alg != alg { return nil, jwt.ErrTokenSignatureInvalid }
+            return k.Verify, nil
+        }
+        set := jwt.VerificationKeySet{}
+        for _, k := range m.keys {
+            if k.Alg == alg { set.Keys = append(set.Keys, k.Verify) }
+        }
+        if len(set.Keys) == 0 { return nil, jwt.ErrTokenSignatureInvalid }
+        return set, nil
+    }, jwt.WithValidMethods([]string{HS256, RS256, EdDSA}), jwt.WithExpirationRequired())
+    if err != nil { return nil, err }
+    return claims, nil
+}
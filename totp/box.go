@@ -0,0 +1,51 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Box encrypts TOTP secrets at rest with AES-256-GCM. A nil Box stores
+// secrets in clear.
+type Box struct {
+    aead cipher.AEAD
+}
+
+const sealed = "v1:"
+
+// NewBox returns a Box using a 32-byte key.
+func NewBox(key []byte) (*Box, error) {
+    if len(key) != 32 { return nil, errors.New("totp: encryption key must be 32 bytes") }
+    block, err := aes.NewCipher(key)
+    if err != nil { return nil, err }
+    aead, err := cipher.NewGCM(block)
+    if err != nil { return nil, err }
+    return &Box{aead: aead}, nil
+}
+
+// Seal returns the stored form of secret.
+func (b *Box) Seal(secret string) (string, error) {
+    if b == nil { return secret, nil }
+    nonce := make([]byte, b.aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil { return "", err }
+    out := b.aead.Seal(nonce, nonce, []byte(secret), nil)
+    return sealed + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Open returns the secret of a stored value. Values stored in clear (before
+// a key was configured) are returned as they are.
+func (b *Box) Open(stored string) (string, error) {
+    if !strings.HasPrefix(stored, sealed) { return stored, nil }
+    if b == nil { return "", errors.New("totp: secret is encrypted but no key is configured") }
+    data, err := base64.StdEncoding.DecodeString(stored[len(sealed):])
+    if err != nil { return "", err }
+    n := b.aead.NonceSize()
+    if len(data) < n { return "", errors.New("totp: sealed secret too short") }
+    plain, err := b.aead.Open(nil, data[:n], data[n:], nil)
+    if err != nil { return "", errors.New("totp: cannot decrypt secret (wrong key?)") }
+    return string(plain), nil
+}
@@ -0,0 +1,102 @@
+// Package totp implements time-based one-time passwords (RFC 6238) with the
+// parameters every authenticator app supports: HMAC-SHA1, 6 digits and a
+// 30 second period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+    Digits = 6
+    Period = 30 // seconds
+    // Skew is the number of periods before and after the current one whose
+    // codes are still accepted, to allow for clock drift.
+    Skew = 1
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160-bit secret, base32 encoded.
+func NewSecret() (string, error) {
+    b := make([]byte, 20)
+    if _, err := rand.Read(b); err != nil { return "", err }
+    return b32.EncodeToString(b), nil
+}
+
+// URI returns the otpauth:// provisioning URI shown as a QR code to
+// authenticator apps.
+func URI(issuer, account, secret string) string {
+    v := url.Values{}
+    v.Set("secret", secret)
+    v.Set("issuer", issuer)
+    v.Set("algorithm", "SHA1")
+    v.Set("digits", fmt.Sprint(Digits))
+    v.Set("period", fmt.Sprint(Period))
+    label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+    return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// Step returns the time step of t.
+func Step(t time.Time) int64 { return t.Unix() / Period }
+
+// Code returns the code of secret for time step step (RFC 4226 HOTP).
+func Code(secret string, step int64) (string, error) {
+    key, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+    if err != nil { return "", fmt.Errorf("totp: invalid secret: %w", err) }
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(step))
+    mac := hmac.New(sha1.New, key)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+    off := sum[len(sum)-1] & 0x0f
+    n := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", Digits, n%1000000), nil
+}
+
+// Verify checks code against secret at time t and returns the matching
+// time step. Steps up to after are rejected, so a code cannot be used twice:
+// pass the step returned by the last successful Verify (0 for none).
+func Verify(secret, code string, t time.Time, after int64) (int64, bool) {
+    code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+    if len(code) != Digits { return 0, false }
+    now := Step(t)
+    for s := now - Skew; s <= now+Skew; s++ {
+        if s <= after { continue }
+        want, err := Code(secret, s)
+        if err != nil { return 0, false }
+        if hmac.Equal([]byte(want), []byte(code)) { return s, true }
+    }
+    return 0, false
+}
+
+// NewRecoveryCodes returns n single-use recovery codes formatted as
+// xxxx-xxxx-xxxx (60 random bits each).
+func NewRecoveryCodes(n int) ([]string, error) {
+    codes := make([]string, n)
+    for i := range codes {
+        b := make([]byte, 8)
+        if _, err := rand.Read(b); err != nil { return nil, err }
+        s := strings.ToLower(b32.EncodeToString(b))[:12]
+        codes[i] = s[:4] + "-" + s[4:8] + "-" + s[8:]
+    }
+    return codes, nil
+}
+
+// HashRecoveryCode returns the stored form of a recovery code. Dashes,
+// spaces and case are ignored.
+func HashRecoveryCode(code string) string {
+    code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+    sum := sha256.Sum256([]byte(code))
+    return hex.EncodeToString(sum[:])
+}
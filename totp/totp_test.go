@@ -0,0 +1,139 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890".
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238(t *testing.T) {
+    // RFC 6238 appendix B, SHA-1; the 8-digit values truncated to the last 6 digits.
+    tests := []struct {
+        unix int64
+        want string
+    }{
+        {59, "287082"},
+        {1111111109, "081804"},
+        {1111111111, "050471"},
+        {1234567890, "005924"},
+        {2000000000, "279037"},
+        {20000000000, "353130"},
+    }
+    for _, tt := range tests {
+        got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+        if err != nil {
+            t.Fatalf("Code at %d: %v", tt.unix, err)
+        }
+        if got != tt.want {
+            t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+        }
+    }
+}
+
+func TestCodeSecretForms(t *testing.T) {
+    want, _ := Code(rfcSecret, 1)
+    for _, s := range []string{rfcSecret + "====", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq"} {
+        got, err := Code(s, 1)
+        if err != nil || got != want {
+            t.Errorf("Code(%q) = %s, %v; want %s", s, got, err, want)
+        }
+    }
+    if _, err := Code("not base32!", 1); err == nil {
+        t.Error("Code accepted an invalid secret")
+    }
+}
+
+func TestVerifySkew(t *testing.T) {
+    now := time.Unix(1111111111, 0)
+    step := Step(now)
+    tests := []struct {
+        name  string
+        delta int64
+        ok    bool
+    }{
+        {"current period", 0, true},
+        {"previous period", -1, true},
+        {"next period", 1, true},
+        {"two periods back", -2, false},
+        {"two periods ahead", 2, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            code, _ := Code(rfcSecret, step+tt.delta)
+            got, ok := Verify(rfcSecret, code, now, 0)
+            if ok != tt.ok {
+                t.Fatalf("Verify ok = %v, want %v", ok, tt.ok)
+            }
+            if ok && got != step+tt.delta {
+                t.Errorf("Verify step = %d, want %d", got, step+tt.delta)
+            }
+        })
+    }
+}
+
+func TestVerifyReplay(t *testing.T) {
+    now := time.Unix(1234567890, 0)
+    code, _ := Code(rfcSecret, Step(now))
+    step, ok := Verify(rfcSecret, code, now, 0)
+    if !ok {
+        t.Fatal("first use rejected")
+    }
+    if _, ok := Verify(rfcSecret, code, now, step); ok {
+        t.Error("same code accepted twice")
+    }
+    // Still within the skew window, but at or before the last accepted step.
+    if _, ok := Verify(rfcSecret, code, now.Add(Period*time.Second), step); ok {
+        t.Error("code accepted again in the next period")
+    }
+    prev, _ := Code(rfcSecret, step-1)
+    if _, ok := Verify(rfcSecret, prev, now, step); ok {
+        t.Error("older code accepted after a newer one")
+    }
+    next, _ := Code(rfcSecret, step+1)
+    if got, ok := Verify(rfcSecret, next, now, step); !ok || got != step+1 {
+        t.Errorf("newer code = %d, %v; want %d, true", got, ok, step+1)
+    }
+}
+
+func TestVerifyInput(t *testing.T) {
+    now := time.Unix(59, 0)
+    tests := []struct {
+        code string
+        ok   bool
+    }{
+        {"287082", true},
+        {" 287 082 ", true},
+        {"28708", false},
+        {"2870820", false},
+        {"287083", false},
+        {"", false},
+    }
+    for _, tt := range tests {
+        if _, ok := Verify(rfcSecret, tt.code, now, 0); ok != tt.ok {
+            t.Errorf("Verify(%q) = %v, want %v", tt.code, ok, tt.ok)
+        }
+    }
+}
+
+func TestRecoveryCodes(t *testing.T) {
+    codes, err := NewRecoveryCodes(10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    seen := map[string]bool{}
+    for _, c := range codes {
+        if len(c) != 14 || c[4] != '-' || c[9] != '-' {
+            t.Errorf("code %q is not formatted xxxx-xxxx-xxxx", c)
+        }
+        if seen[c] {
+            t.Errorf("duplicate code %q", c)
+        }
+        seen[c] = true
+    }
+    if HashRecoveryCode("ABCD-efgh-IJKL") != HashRecoveryCode("abcd efghijkl") {
+        t.Error("hash depends on dashes, spaces or case")
+    }
+}